@@ -0,0 +1,294 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Chiffrement WinZip AES-256 des entrées, lisible notamment par 7-Zip et WinZip.
+// Chaque entrée est compressée puis chiffrée en AES-CTR avec une clé dérivée du
+// mot de passe et d'un sel aléatoire ; un code HMAC-SHA1 authentifie les données
+// chiffrées.
+const (
+	methodWinZipAES  = 99     // méthode de compression des entrées chiffrées
+	winZipAESExtraID = 0x9901 // champ supplémentaire décrivant le chiffrement
+	winZipAESVersion = 1      // AE-1 : le CRC des données est conservé
+	winZipAES256     = 3      // force du chiffrement : clé de 256 bits
+	aesSaltSize      = 16
+	aesKeySize       = 32
+	aesVerifierSize  = 2
+	aesAuthCodeSize  = 10
+	pbkdf2Iterations = 1000
+	flagEncrypted    = 0x1
+)
+
+// encryptEntry prépare l'en-tête d'une entrée chiffrée ; method est la
+// compression appliquée avant le chiffrement
+func encryptEntry(header *zip.FileHeader, method uint16) {
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], winZipAESExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], winZipAESVersion)
+	copy(extra[6:], "AE")
+	extra[8] = winZipAES256
+	binary.LittleEndian.PutUint16(extra[9:], method)
+
+	header.Method = methodWinZipAES
+	header.Flags |= flagEncrypted
+	header.Extra = append(header.Extra, extra...)
+}
+
+// encryptor renvoie le compresseur des entrées chiffrées avec le mot de passe
+// donné, au niveau de compression donné
+func encryptor(password, level string) zip.Compressor {
+	return func(w io.Writer) (io.WriteCloser, error) {
+		salt := make([]byte, aesSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("impossible de générer le sel : %v", err)
+		}
+		encKey, macKey, verifier := deriveKeys(password, salt)
+		stream, err := newCTR(encKey)
+		if err != nil {
+			return nil, err
+		}
+		enc := &aesWriter{w: w, header: append(salt, verifier...), stream: stream, mac: hmac.New(sha1.New, macKey)}
+		if level == LevelNone {
+			return enc, nil
+		}
+		compressor, err := flate.NewWriter(enc, flateLevels[level])
+		if err != nil {
+			return nil, err
+		}
+		return &chainWriter{WriteCloser: compressor, next: enc}, nil
+	}
+}
+
+// Verify rouvre une archive chiffrée et vérifie que chaque entrée se déchiffre
+// avec le mot de passe : vérificateur, code d'authentification, taille et CRC
+func Verify(path, password string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("archive illisible : %v", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if err := verifyEntry(f, password); err != nil {
+			return fmt.Errorf("%s : %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func verifyEntry(f *zip.File, password string) error {
+	if f.Method != methodWinZipAES || f.Flags&flagEncrypted == 0 {
+		return fmt.Errorf("entrée non chiffrée")
+	}
+	method, err := aesMethod(f.Extra)
+	if err != nil {
+		return err
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(raw)
+	if err != nil {
+		return err
+	}
+	if len(data) < aesSaltSize+aesVerifierSize+aesAuthCodeSize {
+		return fmt.Errorf("données chiffrées tronquées")
+	}
+
+	salt := data[:aesSaltSize]
+	verifier := data[aesSaltSize : aesSaltSize+aesVerifierSize]
+	sealed := data[aesSaltSize+aesVerifierSize : len(data)-aesAuthCodeSize]
+	authCode := data[len(data)-aesAuthCodeSize:]
+
+	encKey, macKey, expected := deriveKeys(password, salt)
+	if !bytes.Equal(verifier, expected) {
+		return fmt.Errorf("mot de passe refusé")
+	}
+	mac := hmac.New(sha1.New, macKey)
+	mac.Write(sealed)
+	if !hmac.Equal(mac.Sum(nil)[:aesAuthCodeSize], authCode) {
+		return fmt.Errorf("code d'authentification invalide")
+	}
+
+	stream, err := newCTR(encKey)
+	if err != nil {
+		return err
+	}
+	plain := make([]byte, len(sealed))
+	stream.XORKeyStream(plain, sealed)
+
+	var content io.Reader = bytes.NewReader(plain)
+	switch method {
+	case zip.Store:
+	case zip.Deflate:
+		inflater := flate.NewReader(content)
+		defer inflater.Close()
+		content = inflater
+	default:
+		return fmt.Errorf("compression %d non prise en charge", method)
+	}
+	checksum := crc32.NewIEEE()
+	size, err := io.Copy(checksum, content)
+	if err != nil {
+		return fmt.Errorf("données déchiffrées invalides : %v", err)
+	}
+	if uint64(size) != f.UncompressedSize64 || checksum.Sum32() != f.CRC32 {
+		return fmt.Errorf("contenu déchiffré différent de l'original")
+	}
+	return nil
+}
+
+// aesMethod lit la compression appliquée avant le chiffrement dans le champ
+// supplémentaire WinZip AES
+func aesMethod(extra []byte) (uint16, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:])
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			break
+		}
+		if id == winZipAESExtraID && size == 7 {
+			if extra[8] != winZipAES256 {
+				return 0, fmt.Errorf("force de chiffrement %d non prise en charge", extra[8])
+			}
+			return binary.LittleEndian.Uint16(extra[9:]), nil
+		}
+		extra = extra[4+size:]
+	}
+	return 0, fmt.Errorf("champ WinZip AES absent")
+}
+
+// deriveKeys dérive du mot de passe la clé de chiffrement, la clé
+// d'authentification et le vérificateur de mot de passe
+func deriveKeys(password string, salt []byte) (encKey, macKey, verifier []byte) {
+	key := pbkdf2SHA1([]byte(password), salt, pbkdf2Iterations, 2*aesKeySize+aesVerifierSize)
+	return key[:aesKeySize], key[aesKeySize : 2*aesKeySize], key[2*aesKeySize:]
+}
+
+// pbkdf2SHA1 dérive une clé selon PBKDF2 (RFC 8018) avec HMAC-SHA1
+func pbkdf2SHA1(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// ctrStream est le mode CTR de WinZip : compteur de 128 bits en petit-boutiste,
+// commençant à 1
+type ctrStream struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	buf     [aes.BlockSize]byte
+	used    int
+}
+
+func newCTR(key []byte) (*ctrStream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ctrStream{block: block, used: aes.BlockSize}, nil
+}
+
+func (s *ctrStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == aes.BlockSize {
+			for j := range s.counter {
+				s.counter[j]++
+				if s.counter[j] != 0 {
+					break
+				}
+			}
+			s.block.Encrypt(s.buf[:], s.counter[:])
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.buf[s.used]
+		s.used++
+	}
+}
+
+// aesWriter chiffre les données écrites puis ajoute le code d'authentification
+// à la fermeture. Le sel et le vérificateur précèdent les données : ils ne sont
+// écrits qu'à la première écriture, l'en-tête local de l'entrée étant écrit
+// après la création du compresseur.
+type aesWriter struct {
+	w      io.Writer
+	header []byte // sel et vérificateur, pas encore écrits
+	stream *ctrStream
+	mac    hash.Hash
+	buf    []byte
+}
+
+func (a *aesWriter) writeHeader() error {
+	if a.header == nil {
+		return nil
+	}
+	_, err := a.w.Write(a.header)
+	a.header = nil
+	return err
+}
+
+func (a *aesWriter) Write(p []byte) (int, error) {
+	if err := a.writeHeader(); err != nil {
+		return 0, err
+	}
+	a.buf = append(a.buf[:0], p...)
+	a.stream.XORKeyStream(a.buf, a.buf)
+	a.mac.Write(a.buf)
+	if _, err := a.w.Write(a.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *aesWriter) Close() error {
+	if err := a.writeHeader(); err != nil {
+		return err
+	}
+	_, err := a.w.Write(a.mac.Sum(nil)[:aesAuthCodeSize])
+	return err
+}
+
+// chainWriter ferme le compresseur puis l'écrivain qu'il alimente
+type chainWriter struct {
+	io.WriteCloser
+	next io.Closer
+}
+
+func (c *chainWriter) Close() error {
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return c.next.Close()
+}
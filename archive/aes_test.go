@@ -0,0 +1,98 @@
+package archive
+
+import (
+	"archive/zip"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedZipRoundTrip(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"a.pdf":         strings.Repeat("%PDF-1.4 contenu compressible ", 200),
+		"vide.pdf":      "",
+		"dossier/b.pdf": "contenu b",
+	})
+
+	for _, level := range Levels {
+		t.Run(level, func(t *testing.T) {
+			paths, err := Create(t.TempDir(), files, Options{Name: "pdfs.zip", Level: level, Password: "s3cret"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Verify(paths[0], "s3cret"); err != nil {
+				t.Fatalf("archive illisible avec le mot de passe : %v", err)
+			}
+			if err := Verify(paths[0], "autre"); err == nil || !strings.Contains(err.Error(), "mot de passe refusé") {
+				t.Fatalf("mauvais mot de passe : %v", err)
+			}
+
+			// Toutes les entrées, liste des empreintes comprise, sont chiffrées
+			r, err := zip.OpenReader(paths[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if len(r.File) != len(files)+1 {
+				t.Fatalf("%d entrées, attendu %d", len(r.File), len(files)+1)
+			}
+			for _, f := range r.File {
+				if f.Method != methodWinZipAES || f.Flags&flagEncrypted == 0 {
+					t.Errorf("%s non chiffré", f.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	files := writeFiles(t, map[string]string{"a.pdf": strings.Repeat("contenu ", 100)})
+	paths, err := Create(t.TempDir(), files, Options{Name: "pdfs.zip", Level: LevelNone, Password: "s3cret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Altération d'un octet des données chiffrées de la première entrée, après
+	// le sel et le vérificateur
+	r, err := zip.OpenReader(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, err := r.File[0].DataOffset()
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[start+aesSaltSize+aesVerifierSize+10] ^= 0xFF
+	if err := os.WriteFile(paths[0], data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(paths[0], "s3cret"); err == nil || !strings.Contains(err.Error(), "authentification") {
+		t.Fatalf("archive altérée : %v", err)
+	}
+}
+
+func TestVerifyRejectsPlainZip(t *testing.T) {
+	files := writeFiles(t, map[string]string{"a.pdf": "a"})
+	paths, err := Create(t.TempDir(), files, Options{Name: "pdfs.zip"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(paths[0], "s3cret"); err == nil || !strings.Contains(err.Error(), "non chiffrée") {
+		t.Fatalf("archive non chiffrée : %v", err)
+	}
+}
+
+func TestPasswordRequiresZip(t *testing.T) {
+	for _, format := range []string{FormatTarGz, FormatTarZst} {
+		if _, err := Create(t.TempDir(), nil, Options{Name: DefaultName, Format: format, Password: "s3cret"}, nil); err == nil {
+			t.Errorf("%s chiffré accepté", format)
+		}
+	}
+}
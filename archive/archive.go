@@ -0,0 +1,404 @@
+// Package archive regroupe les PDF convertis dans des archives ZIP, tar.gz ou
+// tar.zst nommées selon un modèle, tel que "pdfs_{date}_{run}.zip", et découpées
+// au besoin pour tenir en pièce jointe d'un courriel. Chaque archive contient la
+// liste SHA-256 de ses fichiers.
+package archive
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultName date et identifie chaque archive : celle d'une exécution précédente
+// n'est pas écrasée
+const DefaultName = "pdfs_{date}_{run}.zip"
+
+// Niveaux de compression
+const (
+	LevelNone   = "aucune"   // fichiers stockés sans compression
+	LevelFast   = "rapide"   // compression rapide
+	LevelNormal = "normale"  // compromis par défaut
+	LevelBest   = "maximale" // archives les plus petites
+)
+
+// Levels liste les niveaux de compression acceptés
+var Levels = []string{LevelNone, LevelFast, LevelNormal, LevelBest}
+
+var flateLevels = map[string]int{
+	LevelFast:   flate.BestSpeed,
+	LevelNormal: flate.DefaultCompression,
+	LevelBest:   flate.BestCompression,
+}
+
+// Champs disponibles dans le modèle de nom
+var fields = []string{
+	"date",  // date de l'exécution (AAAA-MM-JJ)
+	"time",  // heure de l'exécution (HHMMSS)
+	"run",   // identifiant de l'exécution
+	"count", // nombre de PDF contenus dans l'archive
+	"part",  // numéro de l'archive lorsqu'elles sont découpées
+}
+
+var placeholderRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ManifestName est le nom, à la racine de chaque archive, de la liste des
+// empreintes SHA-256 des fichiers, au format de "sha256sum -c"
+const ManifestName = "SHA256SUMS"
+
+// File désigne un fichier à archiver
+type File struct {
+	Path string // chemin du fichier sur le disque
+	Name string // chemin dans l'archive, avec des "/"
+}
+
+// Options règle la création des archives
+type Options struct {
+	Name    string    // modèle du nom des archives
+	Format  string    // format des archives, zip si vide
+	Level   string    // niveau de compression, normale si vide
+	MaxSize int64     // taille maximale d'une archive en octets, sans limite si 0
+	RunID   string    // identifiant de l'exécution, champ {run}
+	Date    time.Time // date de l'exécution, champs {date} et {time}
+
+	// Mot de passe des archives ZIP chiffrées en AES-256, sans chiffrement si vide
+	Password string
+}
+
+// ValidateName vérifie que le modèle de nom n'utilise que des champs connus
+func ValidateName(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("modèle de nom d'archive vide")
+	}
+	for _, m := range placeholderRe.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(fields, m[1]) {
+			return fmt.Errorf("champ inconnu dans le modèle de nom d'archive : {%s} (disponibles : %s)", m[1], strings.Join(fields, ", "))
+		}
+	}
+	return nil
+}
+
+// ValidateLevel vérifie le niveau de compression
+func ValidateLevel(level string) error {
+	if level != "" && !slices.Contains(Levels, level) {
+		return fmt.Errorf("niveau de compression inconnu : %s (valeurs : %s)", level, strings.Join(Levels, ", "))
+	}
+	return nil
+}
+
+// PDFs renvoie les PDF des conversions réussies, rangés dans l'archive selon leur
+// chemin relatif à baseDir
+func PDFs(baseDir string, results []types.ProcessResult) []File {
+	var files []File
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		for _, pdf := range result.PDFs() {
+			files = append(files, File{Path: pdf, Name: EntryName(baseDir, pdf)})
+		}
+	}
+	return files
+}
+
+// Root renvoie des fichiers placés à la racine de l'archive
+func Root(paths ...string) []File {
+	files := make([]File, len(paths))
+	for i, path := range paths {
+		files[i] = File{Path: path, Name: filepath.Base(path)}
+	}
+	return files
+}
+
+// EntryName renvoie le nom d'un fichier dans l'archive : son chemin relatif à
+// baseDir avec des "/", ou son seul nom s'il est situé ailleurs
+func EntryName(baseDir, path string) string {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// part est une archive en cours d'écriture
+type part struct {
+	tmp      string
+	file     *os.File
+	counter  *countingWriter
+	archiver Archiver
+	entries  int    // fichiers archivés
+	pdfs     int    // PDF archivés, champ {count}
+	manifest []byte // empreintes des fichiers archivés
+	date     time.Time
+}
+
+// Writer archive des fichiers au fur et à mesure qu'ils sont ajoutés. Une nouvelle
+// archive est commencée lorsque le fichier suivant ferait dépasser MaxSize ; un
+// fichier plus grand que MaxSize occupe seul son archive. Les dates de
+// modification des fichiers sont conservées. Chaque archive est écrite sous un nom
+// temporaire puis renommée par Close une fois toutes complètes et, si elles sont
+// chiffrées, relues avec le mot de passe. Un Writer n'est utilisable que depuis
+// une seule goroutine.
+type Writer struct {
+	dir     string
+	opts    Options
+	parts   []*part
+	current *part
+	err     error // échec d'écriture : les archives ne sont pas mises en place
+}
+
+// NewWriter prépare l'archivage dans le dossier dir ; les archives ne sont
+// créées qu'à l'ajout du premier fichier
+func NewWriter(dir string, opts Options) (*Writer, error) {
+	if err := ValidateName(opts.Name); err != nil {
+		return nil, err
+	}
+	if err := ValidateLevel(opts.Level); err != nil {
+		return nil, err
+	}
+	if err := ValidateFormat(opts.Format); err != nil {
+		return nil, err
+	}
+	if opts.Format == "" {
+		opts.Format = FormatZip
+	}
+	if opts.Level == "" {
+		opts.Level = LevelNormal
+	}
+	return &Writer{dir: dir, opts: opts}, nil
+}
+
+// Add ajoute un fichier à l'archive. Un fichier impossible à lire rendrait
+// l'archive incomplète : l'erreur est renvoyée, et l'archivage ne peut plus se
+// poursuivre.
+func (w *Writer) Add(f File) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	src, err := os.Open(f.Path)
+	if err != nil {
+		return w.fail(fmt.Errorf("impossible d'ajouter %s à l'archive : %v", filepath.Base(f.Path), err))
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return w.fail(fmt.Errorf("impossible d'ajouter %s à l'archive : %v", filepath.Base(f.Path), err))
+	}
+
+	// Archive suivante si ce fichier ferait dépasser la taille maximale
+	if w.current != nil && w.opts.MaxSize > 0 && w.current.entries > 0 && w.current.estimate(f, info.Size()) > w.opts.MaxSize {
+		if err := w.current.close(); err != nil {
+			return w.fail(err)
+		}
+		w.current = nil
+	}
+	if w.current == nil {
+		if err := w.next(); err != nil {
+			return w.fail(err)
+		}
+	}
+
+	if err := w.current.add(f, src, info); err != nil {
+		return w.fail(fmt.Errorf("impossible d'ajouter %s à l'archive : %v", filepath.Base(f.Path), err))
+	}
+	return nil
+}
+
+// fail retient la première erreur d'écriture, renvoyée par les appels suivants
+func (w *Writer) fail(err error) error {
+	w.err = err
+	return err
+}
+
+// next commence l'archive suivante
+func (w *Writer) next() error {
+	p, err := newPart(w.dir, len(w.parts)+1, w.opts)
+	if err != nil {
+		return err
+	}
+	w.parts = append(w.parts, p)
+	w.current = p
+	return nil
+}
+
+// Close termine les archives, les vérifie si elles sont chiffrées puis les met en
+// place ; le chemin des archives créées est renvoyé. Sans fichier ajouté, une
+// archive vide est tout de même créée.
+func (w *Writer) Close() ([]string, error) {
+	defer w.Abort()
+
+	if w.err != nil {
+		return nil, w.err
+	}
+	if w.current == nil {
+		if err := w.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.current.close(); err != nil {
+		return nil, err
+	}
+
+	// Une archive chiffrée illisible avec le mot de passe ne doit pas être envoyée
+	if w.opts.Password != "" {
+		for _, p := range w.parts {
+			if err := Verify(p.tmp, w.opts.Password); err != nil {
+				return nil, fmt.Errorf("vérification de l'archive chiffrée : %v", err)
+			}
+		}
+	}
+
+	// Mise en place des archives complètes, nommées maintenant que leur nombre
+	// et leur contenu sont connus
+	paths := make([]string, len(w.parts))
+	for i, p := range w.parts {
+		paths[i] = filepath.Join(w.dir, w.opts.fileName(i+1, len(w.parts), p.pdfs))
+		if err := os.Rename(p.tmp, paths[i]); err != nil {
+			return nil, fmt.Errorf("impossible de renommer l'archive : %v", err)
+		}
+	}
+	w.parts = nil
+	return paths, nil
+}
+
+// Abort abandonne les archives en cours d'écriture, sans effet après Close
+func (w *Writer) Abort() {
+	for _, p := range w.parts {
+		p.file.Close()
+		os.Remove(p.tmp)
+	}
+	w.parts = nil
+	w.current = nil
+}
+
+// Create archive les fichiers dans le dossier dir et renvoie le chemin des
+// archives créées, comme le ferait un Writer ; progress, s'il n'est pas nil, est
+// appelé après chaque fichier.
+func Create(dir string, files []File, opts Options, progress func(File)) ([]string, error) {
+	w, err := NewWriter(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Abort()
+
+	for _, f := range files {
+		if err := w.Add(f); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(f)
+		}
+	}
+	return w.Close()
+}
+
+// fileName renvoie le nom de l'archive numéro n parmi total. Sans champ {part}
+// dans le modèle, les archives découpées sont suffixées de leur numéro. L'extension
+// est celle du format, quelle que soit celle du modèle.
+func (o Options) fileName(n, total, pdfs int) string {
+	values := map[string]string{
+		"date":  o.Date.Format("2006-01-02"),
+		"time":  o.Date.Format("150405"),
+		"run":   o.RunID,
+		"count": strconv.Itoa(pdfs),
+		"part":  strconv.Itoa(n),
+	}
+	name := placeholderRe.ReplaceAllStringFunc(o.Name, func(p string) string {
+		return values[p[1:len(p)-1]]
+	})
+	name = trimExtension(helper.SanitizeFilename(strings.TrimSpace(name)))
+	if total > 1 && !strings.Contains(o.Name, "{part}") {
+		name += "_" + strconv.Itoa(n)
+	}
+	return name + Extension(o.Format)
+}
+
+func newPart(dir string, n int, opts Options) (*part, error) {
+	file, err := os.CreateTemp(dir, fmt.Sprintf(".~archive-%d-*.tmp", n))
+	if err != nil {
+		return nil, fmt.Errorf("impossible de créer l'archive : %v", err)
+	}
+	os.Chmod(file.Name(), 0644)
+	p := &part{tmp: file.Name(), file: file, counter: &countingWriter{w: file}, date: opts.Date}
+	if p.date.IsZero() {
+		p.date = time.Now()
+	}
+	if p.archiver, err = NewArchiver(opts.Format, p.counter, opts); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return p, nil
+}
+
+// estimate renvoie la taille approximative de l'archive une fois le fichier
+// ajouté, liste des empreintes comprise
+func (p *part) estimate(f File, size int64) int64 {
+	p.archiver.Flush()
+	manifest := int64(len(p.manifest) + manifestLine(f.Name))
+	return p.counter.n + p.archiver.Pending() + p.archiver.EntrySize(Entry{Name: f.Name, Size: size}) +
+		p.archiver.EntrySize(Entry{Name: ManifestName, Size: manifest})
+}
+
+// add copie un fichier dans l'archive, avec sa date de modification, puis note
+// son empreinte une fois la copie complète
+func (p *part) add(f File, src io.Reader, info os.FileInfo) error {
+	sum := sha256.New()
+	entry := Entry{Name: f.Name, Size: info.Size(), ModTime: info.ModTime()}
+	if err := p.archiver.Add(entry, io.TeeReader(src, sum)); err != nil {
+		return err
+	}
+
+	p.entries++
+	p.manifest = fmt.Appendf(p.manifest, "%s  %s\n", hex.EncodeToString(sum.Sum(nil)), f.Name)
+	if strings.EqualFold(filepath.Ext(f.Name), ".pdf") {
+		p.pdfs++
+	}
+	return nil
+}
+
+// manifestLine renvoie la longueur de la ligne d'un fichier dans la liste des
+// empreintes
+func manifestLine(name string) int {
+	return 2*sha256.Size + len("  ") + len(name) + len("\n")
+}
+
+// close ajoute la liste des empreintes puis termine l'archive
+func (p *part) close() error {
+	entry := Entry{Name: ManifestName, Size: int64(len(p.manifest)), ModTime: p.date}
+	if err := p.archiver.Add(entry, bytes.NewReader(p.manifest)); err != nil {
+		return fmt.Errorf("impossible d'écrire la liste des empreintes : %v", err)
+	}
+	if err := p.archiver.Close(); err != nil {
+		return fmt.Errorf("impossible d'écrire l'archive : %v", err)
+	}
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("impossible d'écrire l'archive : %v", err)
+	}
+	return nil
+}
+
+// countingWriter compte les octets écrits
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,292 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeFiles crée des fichiers de contenus donnés et renvoie leur description
+func writeFiles(t *testing.T, contents map[string]string) []File {
+	t.Helper()
+	dir := t.TempDir()
+	var files []File
+	for name, content := range contents {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, File{Path: path, Name: name})
+	}
+	slices.SortFunc(files, func(a, b File) int { return strings.Compare(a.Name, b.Name) })
+	return files
+}
+
+// readArchive renvoie le contenu des entrées d'une archive non chiffrée
+func readArchive(t *testing.T, path string) map[string]string {
+	t.Helper()
+	entries := make(map[string]string)
+
+	if strings.HasSuffix(path, Extension(FormatZip)) {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries[f.Name] = string(data)
+		}
+		return entries
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var stream io.Reader
+	switch {
+	case strings.HasSuffix(path, Extension(FormatTarGz)):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = gz
+	case strings.HasSuffix(path, Extension(FormatTarZst)):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		stream = zr
+	default:
+		t.Fatalf("format d'archive inattendu : %s", path)
+	}
+	tr := tar.NewReader(stream)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[header.Name] = string(data)
+	}
+	return entries
+}
+
+// leftovers renvoie les fichiers temporaires laissés dans dir
+func leftovers(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".~") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func TestFileName(t *testing.T) {
+	date := time.Date(2025, 3, 7, 14, 5, 9, 0, time.UTC)
+	tests := []struct {
+		name   string
+		format string
+		n      int
+		total  int
+		want   string
+	}{
+		{DefaultName, FormatZip, 1, 1, "pdfs_2025-03-07_r42.zip"},
+		{DefaultName, FormatZip, 2, 3, "pdfs_2025-03-07_r42_2.zip"},
+		{"lot_{part}_{count}.zip", FormatZip, 2, 3, "lot_2_5.zip"},
+		{"pdfs_{time}.zip", FormatTarGz, 1, 1, "pdfs_140509.tar.gz"},
+		{"pdfs.tar.gz", FormatTarZst, 1, 1, "pdfs.tar.zst"},
+		{"a/b:{run}", FormatZip, 1, 1, "a_b_r42.zip"},
+	}
+	for _, tt := range tests {
+		opts := Options{Name: tt.name, Format: tt.format, RunID: "r42", Date: date}
+		if got := opts.fileName(tt.n, tt.total, 5); got != tt.want {
+			t.Errorf("fileName(%q, %s, %d/%d) = %q, attendu %q", tt.name, tt.format, tt.n, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	if err := ValidateName("pdfs_{date}_{inconnu}.zip"); err == nil {
+		t.Error("champ inconnu accepté")
+	}
+	if err := ValidateName(" "); err == nil {
+		t.Error("modèle vide accepté")
+	}
+	if err := ValidateName(DefaultName); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"a.pdf":         "contenu a",
+		"clients/b.pdf": "contenu b",
+	})
+	dir := t.TempDir()
+
+	var added []string
+	paths, err := Create(dir, files, Options{Name: "pdfs.zip"}, func(f File) { added = append(added, f.Name) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || filepath.Base(paths[0]) != "pdfs.zip" {
+		t.Fatalf("archives = %v", paths)
+	}
+	if len(added) != len(files) {
+		t.Errorf("progression = %v", added)
+	}
+	entries := readArchive(t, paths[0])
+	if entries["a.pdf"] != "contenu a" || entries["clients/b.pdf"] != "contenu b" {
+		t.Errorf("entrées = %v", entries)
+	}
+	if _, ok := entries[ManifestName]; !ok {
+		t.Errorf("%s absent de l'archive", ManifestName)
+	}
+	if left := leftovers(t, dir); len(left) > 0 {
+		t.Errorf("fichiers temporaires laissés : %v", left)
+	}
+}
+
+func TestCreateSplit(t *testing.T) {
+	content := strings.Repeat("x", 4000)
+	files := writeFiles(t, map[string]string{
+		"1.pdf": content,
+		"2.pdf": content,
+		"3.pdf": content,
+	})
+	dir := t.TempDir()
+
+	paths, err := Create(dir, files, Options{Name: "lot_{part}.zip", Level: LevelNone, MaxSize: 6000}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("%d archives, attendu 3 : %v", len(paths), paths)
+	}
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 6000 {
+			t.Errorf("%s : %d octets, au-delà de la limite", filepath.Base(path), info.Size())
+		}
+		entries := readArchive(t, path)
+		name := files[i].Name
+		if len(entries) != 2 || entries[name] != content {
+			t.Errorf("%s : entrées %v, attendu %s", filepath.Base(path), entryNames(entries), name)
+		}
+	}
+}
+
+func TestWriterAbortsOnMissingFile(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			files := writeFiles(t, map[string]string{"a.pdf": "a", "c.pdf": "c"})
+			// Un PDF disparu rendrait l'archive incomplète
+			missing := File{Path: filepath.Join(t.TempDir(), "absent.pdf"), Name: "absent.pdf"}
+			files = []File{files[0], missing, files[1]}
+			dir := t.TempDir()
+
+			var added []string
+			paths, err := Create(dir, files, Options{Name: DefaultName, Format: format}, func(f File) { added = append(added, f.Name) })
+			if err == nil || !strings.Contains(err.Error(), "absent.pdf") {
+				t.Fatalf("erreur = %v, archives = %v", err, paths)
+			}
+			if len(added) != 1 {
+				t.Errorf("progression = %v", added)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) > 0 {
+				t.Errorf("fichiers laissés : %v", entries)
+			}
+		})
+	}
+}
+
+func TestWriterAbortsOnCopyError(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			files := writeFiles(t, map[string]string{"a.pdf": "a"})
+			// Un dossier s'ouvre mais sa lecture échoue, comme un fichier devenu
+			// illisible pendant sa copie ; non vide, sa taille n'est pas nulle
+			unreadable := writeFiles(t, map[string]string{"illisible/contenu": "x"})[0]
+			files = append(files, File{Path: filepath.Dir(unreadable.Path), Name: "illisible.pdf"})
+			dir := t.TempDir()
+
+			w, err := NewWriter(dir, Options{Name: DefaultName, Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Add(files[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Add(files[1]); err == nil {
+				t.Fatal("erreur de copie ignorée")
+			}
+			if err := w.Add(files[0]); err == nil {
+				t.Error("ajout accepté après une erreur de copie")
+			}
+			if paths, err := w.Close(); err == nil {
+				t.Fatalf("archive incohérente mise en place : %v", paths)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) > 0 {
+				t.Errorf("fichiers laissés : %v", entries)
+			}
+		})
+	}
+}
+
+// entryNames renvoie le nom des entrées, pour les messages d'erreur
+func entryNames(entries map[string]string) []string {
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Formats d'archive
+const (
+	FormatZip    = "zip"     // lisible partout, seul format chiffrable
+	FormatTarGz  = "tar.gz"  // archive tar compressée en gzip
+	FormatTarZst = "tar.zst" // archive tar compressée en zstd, plus rapide à compresser
+)
+
+// Formats liste les formats d'archive acceptés
+var Formats = []string{FormatZip, FormatTarGz, FormatTarZst}
+
+// Entry décrit une entrée d'archive
+type Entry struct {
+	Name    string    // chemin dans l'archive, avec des "/"
+	Size    int64     // taille des données en octets
+	ModTime time.Time // date de modification
+}
+
+// Archiver écrit les entrées d'une archive dans un format donné
+type Archiver interface {
+	// Add ajoute une entrée dont les données sont lues depuis r
+	Add(entry Entry, r io.Reader) error
+	// EntrySize estime la place occupée par une entrée, en-têtes compris
+	EntrySize(entry Entry) int64
+	// Pending estime les octets restant à écrire à la fermeture
+	Pending() int64
+	// Flush écrit les données en attente, pour que la taille écrite soit à jour
+	Flush() error
+	// Close termine l'archive, sans fermer l'écrivain sous-jacent
+	Close() error
+}
+
+// NewArchiver renvoie l'archiveur du format donné, zip si vide, écrivant dans w
+func NewArchiver(format string, w io.Writer, opts Options) (Archiver, error) {
+	if opts.Password != "" && format != FormatZip && format != "" {
+		return nil, fmt.Errorf("le chiffrement n'est disponible qu'au format %s", FormatZip)
+	}
+	switch format {
+	case FormatZip, "":
+		return newZipArchiver(w, opts), nil
+	case FormatTarGz:
+		return newTarGzArchiver(w, opts)
+	case FormatTarZst:
+		return newTarZstArchiver(w, opts)
+	}
+	return nil, ValidateFormat(format)
+}
+
+// ValidateFormat vérifie le format d'archive
+func ValidateFormat(format string) error {
+	if format != "" && !slices.Contains(Formats, format) {
+		return fmt.Errorf("format d'archive inconnu : %s (valeurs : %s)", format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+// Extension renvoie l'extension des archives du format donné, point compris
+func Extension(format string) string {
+	if format == "" {
+		format = FormatZip
+	}
+	return "." + format
+}
+
+// trimExtension retire du nom une extension d'archive connue, quel que soit le
+// format choisi : "pdfs.zip" devient "pdfs.tar.gz" en tar.gz
+func trimExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar.zst", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	contents := map[string]string{
+		"a.pdf":                "contenu a",
+		"clients/Dupont/b.pdf": strings.Repeat("b", 10000),
+		"rapport.json":         "{}",
+	}
+
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			files := writeFiles(t, contents)
+
+			paths, err := Create(t.TempDir(), files, Options{Name: "pdfs_{count}", Format: format}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "pdfs_2" + Extension(format); filepath.Base(paths[0]) != want {
+				t.Errorf("archive = %s, attendu %s", filepath.Base(paths[0]), want)
+			}
+
+			entries := readArchive(t, paths[0])
+			if len(entries) != len(contents)+1 {
+				t.Errorf("entrées = %v", entryNames(entries))
+			}
+
+			var want strings.Builder
+			for _, f := range files {
+				sum := sha256.Sum256([]byte(contents[f.Name]))
+				fmt.Fprintf(&want, "%s  %s\n", hex.EncodeToString(sum[:]), f.Name)
+				if entries[f.Name] != contents[f.Name] {
+					t.Errorf("%s : contenu différent", f.Name)
+				}
+			}
+			if entries[ManifestName] != want.String() {
+				t.Errorf("%s =\n%s\nattendu :\n%s", ManifestName, entries[ManifestName], want.String())
+			}
+		})
+	}
+}
+
+func TestManifestPerPart(t *testing.T) {
+	content := strings.Repeat("x", 4000)
+	files := writeFiles(t, map[string]string{"1.pdf": content, "2.pdf": content})
+
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			paths, err := Create(t.TempDir(), files, Options{Name: DefaultName, Format: format, Level: LevelNone, MaxSize: 7000}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 2 {
+				t.Fatalf("%d archives, attendu 2", len(paths))
+			}
+			// Chaque archive ne liste que ses propres fichiers
+			sum := sha256.Sum256([]byte(content))
+			for i, path := range paths {
+				want := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), files[i].Name)
+				if got := readArchive(t, path)[ManifestName]; got != want {
+					t.Errorf("%s : %s = %q, attendu %q", filepath.Base(path), ManifestName, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range append([]string{""}, Formats...) {
+		if err := ValidateFormat(format); err != nil {
+			t.Errorf("%q : %v", format, err)
+		}
+	}
+	if err := ValidateFormat("rar"); err == nil {
+		t.Error("format inconnu accepté")
+	}
+}
@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Taille d'un bloc tar : en-têtes et données sont alignés sur cette taille, et
+// l'archive se termine par deux blocs vides
+const tarBlockSize = 512
+
+// zstd n'a pas de niveau sans compression : "aucune" utilise le plus rapide
+var zstdLevels = map[string]zstd.EncoderLevel{
+	LevelNone:   zstd.SpeedFastest,
+	LevelFast:   zstd.SpeedFastest,
+	LevelNormal: zstd.SpeedDefault,
+	LevelBest:   zstd.SpeedBestCompression,
+}
+
+// compressor est le flux de compression d'une archive tar
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// tarArchiver écrit une archive tar dans un flux de compression
+type tarArchiver struct {
+	writer     *tar.Writer
+	compressor compressor
+}
+
+func newTarGzArchiver(w io.Writer, opts Options) (*tarArchiver, error) {
+	level := gzip.DefaultCompression
+	if opts.Level == LevelNone {
+		level = gzip.NoCompression
+	} else if l, ok := flateLevels[opts.Level]; ok {
+		level = l
+	}
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &tarArchiver{writer: tar.NewWriter(gz), compressor: gz}, nil
+}
+
+func newTarZstArchiver(w io.Writer, opts Options) (*tarArchiver, error) {
+	level, ok := zstdLevels[opts.Level]
+	if !ok {
+		level = zstd.SpeedDefault
+	}
+	zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	return &tarArchiver{writer: tar.NewWriter(zw), compressor: zw}, nil
+}
+
+func (a *tarArchiver) Add(entry Entry, r io.Reader) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entry.Name,
+		Size:     entry.Size,
+		Mode:     0644,
+		ModTime:  entry.ModTime,
+	}
+	if err := a.writer.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.CopyN(a.writer, r, entry.Size)
+	return err
+}
+
+// EntrySize compte l'en-tête, un en-tête PAX pour les noms longs et l'alignement
+// des données, sans gain de compression
+func (a *tarArchiver) EntrySize(entry Entry) int64 {
+	return 3*tarBlockSize + int64(len(entry.Name)) + entry.Size + entry.Size/1000
+}
+
+// Pending compte les deux blocs de fin et la fin du flux compressé
+func (a *tarArchiver) Pending() int64 { return 2*tarBlockSize + 64 }
+
+func (a *tarArchiver) Flush() error {
+	if err := a.writer.Flush(); err != nil {
+		return err
+	}
+	return a.compressor.Flush()
+}
+
+func (a *tarArchiver) Close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+	return a.compressor.Close()
+}
@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"io"
+)
+
+// Taille réservée pour les en-têtes d'une entrée ZIP, dans le fichier et dans le
+// répertoire central, en plus de son nom ; elle couvre aussi le sel et le code
+// d'authentification d'une entrée chiffrée
+const entryOverhead = 128
+
+// zipArchiver écrit une archive ZIP, chiffrée en AES-256 si un mot de passe est
+// donné
+type zipArchiver struct {
+	writer   *zip.Writer
+	method   uint16 // compression des entrées
+	password string
+	central  int64 // taille estimée du répertoire central
+}
+
+func newZipArchiver(w io.Writer, opts Options) *zipArchiver {
+	a := &zipArchiver{writer: zip.NewWriter(w), method: zip.Deflate, password: opts.Password}
+	if opts.Level == LevelNone {
+		a.method = zip.Store
+	}
+	if l, ok := flateLevels[opts.Level]; ok && l != flate.DefaultCompression {
+		a.writer.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, l)
+		})
+	}
+	if opts.Password != "" {
+		a.writer.RegisterCompressor(methodWinZipAES, encryptor(opts.Password, opts.Level))
+	}
+	return a
+}
+
+func (a *zipArchiver) Add(entry Entry, r io.Reader) error {
+	header := &zip.FileHeader{Name: entry.Name, Method: a.method}
+	header.Modified = entry.ModTime
+	header.SetMode(0644)
+	if a.password != "" {
+		encryptEntry(header, header.Method)
+	}
+	writer, err := a.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, r); err != nil {
+		return err
+	}
+	a.central += int64(entryOverhead/2 + len(entry.Name))
+	return nil
+}
+
+// EntrySize compte les données sans gain de compression : les PDF, déjà
+// compressés, n'en tirent presque rien
+func (a *zipArchiver) EntrySize(entry Entry) int64 {
+	return entry.Size + entry.Size/1000 + int64(entryOverhead+2*len(entry.Name))
+}
+
+func (a *zipArchiver) Pending() int64 { return a.central }
+
+func (a *zipArchiver) Flush() error { return a.writer.Flush() }
+
+func (a *zipArchiver) Close() error { return a.writer.Close() }
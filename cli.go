@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"fredon_to_pdf/archive"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/merge"
+	"fredon_to_pdf/queue"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Codes de sortie du programme
+const (
+	exitOK      = 0
+	exitError   = 1 // erreur fatale : configuration, dossiers, ZIP...
+	exitUsage   = 2 // arguments de ligne de commande invalides
+	exitPartial = 3 // au moins un fichier n'a pas pu être converti
+
+	exitInterrupted = 130 // arrêt demandé par Ctrl+C ou SIGTERM, comme un shell
+)
+
+// cliOptions regroupe les options de configuration et celles propres à une exécution
+type cliOptions struct {
+	config.Options
+	Command        string        // "" pour une conversion unique, sinon une sous-commande
+	Force          bool          // reconvertir tous les fichiers, même inchangés
+	Pause          bool          // attendre l'appui sur Entrée avant de quitter
+	Poll           bool          // surveillance par scrutation plutôt que par notifications du système
+	Listen         string        // adresse d'écoute du service HTTP
+	MaxUploadMB    int64         // taille maximale d'une requête du service HTTP
+	RequestTimeout time.Duration // durée maximale d'une conversion demandée au service HTTP
+	JobsAction     string        // jobs : list, retry ou purge
+	JobIDs         []string      // jobs : tâches visées
+	QueuePath      string        // jobs : base de la file de conversion, sinon celle du dossier de sortie
+	JobStatuses    []string      // jobs : états des tâches à lister ou purger
+	OlderThan      time.Duration // jobs purge : ancienneté minimale des tâches supprimées
+}
+
+// Sous-commandes
+const (
+	commandWatch = "watch"
+	commandServe = "serve"
+	commandJobs  = "jobs"
+)
+
+// Actions de la sous-commande jobs
+const (
+	jobsList  = "list"
+	jobsRetry = "retry"
+	jobsPurge = "purge"
+)
+
+// parseFlags analyse les arguments de la ligne de commande ; les valeurs fournies
+// priment sur config.json. Les erreurs sont affichées sur output.
+func parseFlags(args []string, output io.Writer) (cliOptions, error) {
+	var opts cliOptions
+	var zip, encrypt, stream, merged, toc, perSheet bool
+
+	if len(args) > 0 && (args[0] == commandWatch || args[0] == commandServe || args[0] == commandJobs) {
+		opts.Command = args[0]
+		args = args[1:]
+	}
+	if opts.Command == commandJobs && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		opts.JobsAction = args[0]
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("fredon_to_pdf", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigPath, "config", "./config.json", "chemin du fichier de configuration")
+	fs.StringVar(&opts.ExcelDir, "input", "", "dossier des fichiers Excel")
+	fs.StringVar(&opts.OutputDir, "output", "", "dossier de sortie des fichiers PDF")
+	fs.BoolVar(&zip, "zip", false, "archiver les PDF, au format --archive-format (--zip=false pour désactiver)")
+	fs.StringVar(&opts.ArchiveFormat, "archive-format", "", "format des archives : "+strings.Join(archive.Formats, ", "))
+	fs.StringVar(&opts.ZipName, "zip-name", "", "modèle du nom des archives ZIP, ex. "+archive.DefaultName+" (champs date, time, run, count, part)")
+	fs.BoolVar(&encrypt, "zip-encrypt", false, "chiffrer les archives ZIP en AES-256, mot de passe lu dans "+config.ZipPasswordEnv+", zip_password_file ou saisi (--zip-encrypt=false pour désactiver)")
+	fs.BoolVar(&stream, "zip-stream", false, "alimenter l'archive au fil des conversions plutôt qu'une fois toutes terminées (--zip-stream=false pour désactiver)")
+	fs.Int64Var(&opts.ZipMaxSize, "zip-max-size", 0, "taille maximale d'une archive ZIP en Mo, au-delà de laquelle elle est découpée")
+	fs.StringVar(&opts.Converter, "backend", "", "moteur de conversion : "+tools.AutoBackend+", "+strings.Join(tools.BackendNames(), ", "))
+	fs.Var((*stringList)(&opts.Include), "include", "motif doublestar des classeurs à convertir (répétable, ex. 2025/**/*.xls)")
+	fs.Var((*stringList)(&opts.Exclude), "exclude", "motif doublestar des classeurs ou dossiers à ignorer (répétable, ex. archives/**)")
+	fs.BoolVar(&merged, "merge", false, "fusionner les PDF en un seul document (--merge=false pour désactiver)")
+	fs.StringVar(&opts.MergeOrder, "merge-order", "", "ordre des documents fusionnés : "+strings.Join(merge.Orders, ", "))
+	fs.BoolVar(&toc, "merge-toc", false, "ajouter une page de sommaire au document fusionné (--merge-toc=false pour désactiver)")
+	fs.StringVar(&opts.MergeFile, "merge-file", "", "nom du document fusionné, dans le dossier de sortie (défaut "+merge.DefaultFileName+")")
+	fs.StringVar(&opts.Sheets, "sheets", "", "feuilles exportées : "+strings.Join(types.SheetModes, ", "))
+	fs.Var((*stringList)(&opts.SheetNames), "sheet", "feuille à exporter (répétable, implique --sheets "+types.SheetsNamed+")")
+	fs.BoolVar(&perSheet, "per-sheet", false, "un PDF par feuille, <fichier>_<feuille>.pdf (--per-sheet=false pour désactiver)")
+	fs.StringVar(&opts.PDFProfile, "pdf-profile", "", "profil des PDF : "+strings.Join(types.Profiles, ", ")+" (archivage)")
+	fs.StringVar(&opts.OutputName, "output-name", "", "modèle de nom des PDF, ex. {year}/{client}/{number}_{client} (champs du motif filename_pattern de config.json, name, dir, year, month, day)")
+	fs.StringVar(&opts.OverwritePolicy, "overwrite", "", "PDF existant ou produit par plusieurs classeurs : "+strings.Join(types.OverwritePolicies, ", "))
+	fs.StringVar(&opts.LogLevel, "log-level", "", "niveau de journalisation : "+strings.Join(helper.LogLevels, ", "))
+	fs.StringVar(&opts.LogFormat, "log-format", "", "format du fichier journal : "+strings.Join(helper.LogFormats, ", "))
+	fs.StringVar(&opts.LogDir, "log-dir", "", "dossier du fichier journal "+helper.LogFileName+" (défaut <dossier de sortie>/logs)")
+	fs.BoolVar(&opts.NoPrompt, "no-prompt", false, "ne jamais lire l'entrée standard (valeurs par défaut, --pause ignoré)")
+	fs.BoolVar(&opts.Pause, "pause", false, "attendre l'appui sur Entrée avant de quitter, pour garder la fenêtre ouverte (lancement par double-clic)")
+	fs.BoolVar(&opts.Yes, "yes", false, "répondre oui aux questions sans les poser")
+	fs.BoolVar(&opts.Force, "force", false, "reconvertir tous les fichiers, même ceux inchangés depuis la dernière exécution")
+	fs.BoolVar(&opts.Poll, "poll", false, "watch : scruter le dossier périodiquement (partages réseau) au lieu des notifications")
+	fs.StringVar(&opts.Listen, "listen", defaultListenAddr, "serve : adresse d'écoute du service HTTP")
+	fs.Int64Var(&opts.MaxUploadMB, "max-upload", defaultMaxUploadMB, "serve : taille maximale d'une requête, en Mo")
+	fs.DurationVar(&opts.RequestTimeout, "timeout", defaultRequestTimeout, "serve : durée maximale d'une conversion (ex. 2m)")
+	fs.StringVar(&opts.QueuePath, "queue", "", "jobs : base de la file de conversion (par défaut celle du dossier de sortie)")
+	fs.Var((*stringList)(&opts.JobStatuses), "status", "jobs : état des tâches à lister ou purger (répétable : "+jobStatusNames()+")")
+	fs.DurationVar(&opts.OlderThan, "older-than", 0, "jobs purge : ne supprimer que les tâches plus anciennes (ex. 168h)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage : fredon_to_pdf [watch|serve] [options]")
+		fmt.Fprintln(fs.Output(), "        fredon_to_pdf jobs list|retry|purge [options] [id...]")
+		fmt.Fprintln(fs.Output(), "  watch : surveille le dossier des fichiers Excel et convertit chaque nouveau classeur")
+		fmt.Fprintln(fs.Output(), "  serve : expose la conversion via une API HTTP")
+		fmt.Fprintln(fs.Output(), "  jobs  : liste, relance (retry) ou supprime (purge) les tâches de la file de conversion")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\nCodes de sortie : 0 succès, 1 erreur fatale, 2 arguments invalides, 3 fichiers en échec, 130 interruption")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if opts.Command == commandJobs {
+		if err := validateJobsOptions(&opts, fs.Args()); err != nil {
+			return opts, usageError(fs, "%v", err)
+		}
+	} else if fs.NArg() > 0 {
+		return opts, usageError(fs, "argument inattendu : %s", fs.Arg(0))
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "zip":
+			opts.CompressToZip = yesNo(zip)
+		case "zip-encrypt":
+			opts.ZipEncrypt = yesNo(encrypt)
+		case "zip-stream":
+			opts.ZipStream = yesNo(stream)
+		case "merge":
+			opts.Merge = yesNo(merged)
+		case "merge-toc":
+			opts.MergeTOC = yesNo(toc)
+		case "per-sheet":
+			opts.PerSheet = yesNo(perSheet)
+		}
+	})
+	if len(opts.SheetNames) > 0 && opts.Sheets == "" {
+		opts.Sheets = types.SheetsNamed
+	}
+
+	if opts.ZipName != "" {
+		if err := archive.ValidateName(opts.ZipName); err != nil {
+			return opts, usageError(fs, "%v", err)
+		}
+	}
+	if err := archive.ValidateFormat(opts.ArchiveFormat); err != nil {
+		return opts, usageError(fs, "%v", err)
+	}
+	if opts.ZipMaxSize < 0 {
+		return opts, usageError(fs, "--zip-max-size doit être positive")
+	}
+
+	if opts.MaxUploadMB <= 0 || opts.RequestTimeout <= 0 {
+		return opts, usageError(fs, "--max-upload et --timeout doivent être positifs")
+	}
+
+	if opts.MergeOrder != "" {
+		if err := merge.ValidOrder(opts.MergeOrder); err != nil {
+			return opts, usageError(fs, "%v", err)
+		}
+	}
+	if opts.MergeFile != "" && (filepath.Base(opts.MergeFile) != opts.MergeFile || !strings.EqualFold(filepath.Ext(opts.MergeFile), ".pdf")) {
+		return opts, usageError(fs, "--merge-file doit être un nom de fichier .pdf, sans dossier")
+	}
+
+	// Sans --sheet, les feuilles nommées sont celles de config.json
+	if (opts.Sheets != "" && opts.Sheets != types.SheetsNamed) || len(opts.SheetNames) > 0 {
+		export := types.ExportOptions{Sheets: opts.Sheets, SheetNames: opts.SheetNames}
+		if err := export.Validate(); err != nil {
+			return opts, usageError(fs, "%v", err)
+		}
+	}
+
+	if err := (types.ExportOptions{Profile: opts.PDFProfile}).Validate(); err != nil {
+		return opts, usageError(fs, "%v", err)
+	}
+
+	if opts.OverwritePolicy != "" && !slices.Contains(types.OverwritePolicies, opts.OverwritePolicy) {
+		return opts, usageError(fs, "politique inconnue : %s (valeurs : %s)", opts.OverwritePolicy, strings.Join(types.OverwritePolicies, ", "))
+	}
+
+	if opts.LogLevel != "" && !slices.Contains(helper.LogLevels, opts.LogLevel) {
+		return opts, usageError(fs, "niveau de journalisation inconnu : %s (valeurs : %s)", opts.LogLevel, strings.Join(helper.LogLevels, ", "))
+	}
+	if opts.LogFormat != "" && !slices.Contains(helper.LogFormats, opts.LogFormat) {
+		return opts, usageError(fs, "format de journal inconnu : %s (valeurs : %s)", opts.LogFormat, strings.Join(helper.LogFormats, ", "))
+	}
+
+	if opts.Converter != "" && opts.Converter != tools.AutoBackend && !slices.Contains(tools.BackendNames(), opts.Converter) {
+		return opts, usageError(fs, "moteur de conversion inconnu : %s (disponibles : %s)", opts.Converter, strings.Join(tools.BackendNames(), ", "))
+	}
+
+	return opts, nil
+}
+
+// validateJobsOptions vérifie l'action de la sous-commande jobs et ses arguments
+func validateJobsOptions(opts *cliOptions, args []string) error {
+	switch opts.JobsAction {
+	case jobsList, jobsPurge:
+		if len(args) > 0 {
+			return fmt.Errorf("argument inattendu : %s", args[0])
+		}
+	case jobsRetry:
+		opts.JobIDs = args
+	case "":
+		return fmt.Errorf("action manquante : %s, %s ou %s", jobsList, jobsRetry, jobsPurge)
+	default:
+		return fmt.Errorf("action inconnue : %s", opts.JobsAction)
+	}
+
+	for _, status := range opts.JobStatuses {
+		if _, err := queue.ParseStatus(status); err != nil {
+			return err
+		}
+	}
+	if opts.OlderThan < 0 {
+		return fmt.Errorf("--older-than doit être positif")
+	}
+	return nil
+}
+
+func jobStatusNames() string {
+	names := make([]string, len(queue.Statuses))
+	for i, status := range queue.Statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ", ")
+}
+
+// usageExitCode renvoie le code de sortie d'une erreur de parseFlags : l'aide
+// demandée par -h n'est pas une erreur
+func usageExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// yesNo convertit une option booléenne en réponse O/N de la configuration
+func yesNo(b bool) string {
+	if b {
+		return "O"
+	}
+	return "N"
+}
+
+// usageError affiche une erreur d'utilisation, comme le fait flag pour ses propres erreurs
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	fmt.Fprintln(fs.Output(), err)
+	fs.Usage()
+	return err
+}
+
+// stringList est une option répétable (--include a --include b)
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
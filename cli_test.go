@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		check func(t *testing.T, opts cliOptions)
+	}{
+		{
+			name: "conversion par défaut",
+			check: func(t *testing.T, opts cliOptions) {
+				if opts.Command != "" || opts.ConfigPath != "./config.json" || opts.Listen != defaultListenAddr {
+					t.Errorf("options = %+v", opts)
+				}
+				// Options booléennes absentes : config.json s'applique
+				if opts.CompressToZip != "" || opts.Merge != "" || opts.PerSheet != "" {
+					t.Errorf("options booléennes renseignées : %+v", opts.Options)
+				}
+			},
+		},
+		{
+			name: "options booléennes explicites",
+			args: []string{"--zip", "--zip-stream=false", "--merge-toc", "--per-sheet", "--no-prompt"},
+			check: func(t *testing.T, opts cliOptions) {
+				got := []string{opts.CompressToZip, opts.ZipStream, opts.MergeTOC, opts.PerSheet, opts.ZipEncrypt, opts.Merge}
+				if want := []string{"O", "N", "O", "O", "", ""}; !slices.Equal(got, want) {
+					t.Errorf("zip, zip-stream, merge-toc, per-sheet, zip-encrypt, merge = %q, attendu %q", got, want)
+				}
+				if !opts.NoPrompt {
+					t.Error("--no-prompt ignoré")
+				}
+			},
+		},
+		{
+			name: "options répétables",
+			args: []string{"--include", "2025/**", "--include", "*.xls", "--exclude", "archives/**", "--sheet", "Factures", "--sheet", "Détail"},
+			check: func(t *testing.T, opts cliOptions) {
+				if !slices.Equal(opts.Include, []string{"2025/**", "*.xls"}) || !slices.Equal(opts.Exclude, []string{"archives/**"}) {
+					t.Errorf("include = %q, exclude = %q", opts.Include, opts.Exclude)
+				}
+				if opts.Sheets != types.SheetsNamed || !slices.Equal(opts.SheetNames, []string{"Factures", "Détail"}) {
+					t.Errorf("sheets = %q %q", opts.Sheets, opts.SheetNames)
+				}
+			},
+		},
+		{
+			name: "surveillance",
+			args: []string{"watch", "--poll", "--input", "excel"},
+			check: func(t *testing.T, opts cliOptions) {
+				if opts.Command != commandWatch || !opts.Poll || opts.ExcelDir != "excel" {
+					t.Errorf("options = %+v", opts)
+				}
+			},
+		},
+		{
+			name: "service HTTP",
+			args: []string{"serve", "--listen", ":9000", "--max-upload", "5", "--timeout", "30s"},
+			check: func(t *testing.T, opts cliOptions) {
+				if opts.Command != commandServe || opts.Listen != ":9000" || opts.MaxUploadMB != 5 || opts.RequestTimeout != 30*time.Second {
+					t.Errorf("options = %+v", opts)
+				}
+			},
+		},
+		{
+			name: "jobs retry",
+			args: []string{"jobs", "retry", "--queue", "file.db", "a1", "b2"},
+			check: func(t *testing.T, opts cliOptions) {
+				if opts.Command != commandJobs || opts.JobsAction != jobsRetry || opts.QueuePath != "file.db" || !slices.Equal(opts.JobIDs, []string{"a1", "b2"}) {
+					t.Errorf("options = %+v", opts)
+				}
+			},
+		},
+		{
+			name: "jobs purge",
+			args: []string{"jobs", "purge", "--status", "echec", "--status", "annule", "--older-than", "168h"},
+			check: func(t *testing.T, opts cliOptions) {
+				if opts.JobsAction != jobsPurge || !slices.Equal(opts.JobStatuses, []string{"echec", "annule"}) || opts.OlderThan != 168*time.Hour {
+					t.Errorf("options = %+v", opts)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			opts, err := parseFlags(tt.args, &output)
+			if err != nil {
+				t.Fatalf("parseFlags(%q) = %v", tt.args, err)
+			}
+			if output.Len() > 0 {
+				t.Errorf("sortie inattendue : %s", output.String())
+			}
+			tt.check(t, opts)
+		})
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	tests := []struct {
+		args  []string
+		error string
+	}{
+		{[]string{"--inconnue"}, "flag provided but not defined"},
+		{[]string{"--zip-max-size", "dix"}, "invalid value"},
+		{[]string{"factures"}, "argument inattendu : factures"},
+		{[]string{"--zip-name", "{client}.zip"}, "champ inconnu dans le modèle de nom d'archive : {client}"},
+		{[]string{"--archive-format", "rar"}, "rar"},
+		{[]string{"--zip-max-size", "-1"}, "--zip-max-size doit être positive"},
+		{[]string{"serve", "--max-upload", "0"}, "--max-upload et --timeout doivent être positifs"},
+		{[]string{"serve", "--timeout", "-1s"}, "--max-upload et --timeout doivent être positifs"},
+		{[]string{"--merge-order", "taille"}, "ordre de fusion inconnu : taille"},
+		{[]string{"--merge-file", "fusion/mars.pdf"}, "--merge-file doit être un nom de fichier .pdf"},
+		{[]string{"--merge-file", "mars.docx"}, "--merge-file doit être un nom de fichier .pdf"},
+		{[]string{"--sheets", "quelques"}, "quelques"},
+		{[]string{"--pdf-profile", "pdfx"}, "pdfx"},
+		{[]string{"--overwrite", "jamais"}, "politique inconnue : jamais"},
+		{[]string{"--log-level", "bavard"}, "niveau de journalisation inconnu : bavard"},
+		{[]string{"--log-format", "xml"}, "format de journal inconnu : xml"},
+		{[]string{"--backend", "word"}, "moteur de conversion inconnu : word"},
+		{[]string{"jobs"}, "action manquante : list, retry ou purge"},
+		{[]string{"jobs", "--status", "echec"}, "action manquante"},
+		{[]string{"jobs", "relance"}, "action inconnue : relance"},
+		{[]string{"jobs", "list", "a1"}, "argument inattendu : a1"},
+		{[]string{"jobs", "purge", "a1"}, "argument inattendu : a1"},
+		{[]string{"jobs", "list", "--status", "perdu"}, "état de tâche inconnu : perdu"},
+		{[]string{"jobs", "purge", "--older-than", "-1h"}, "--older-than doit être positif"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var output bytes.Buffer
+			_, err := parseFlags(tt.args, &output)
+			if err == nil {
+				t.Fatalf("parseFlags(%q) accepté", tt.args)
+			}
+			if code := usageExitCode(err); code != exitUsage {
+				t.Errorf("code de sortie %d, attendu %d", code, exitUsage)
+			}
+			// L'erreur est suivie de l'aide, comme pour les erreurs de flag
+			if !strings.Contains(output.String(), tt.error) || !strings.Contains(output.String(), "Usage : fredon_to_pdf") {
+				t.Errorf("sortie = %s\nattendu %q et l'aide", output.String(), tt.error)
+			}
+		})
+	}
+}
+
+func TestParseFlagsHelp(t *testing.T) {
+	var output bytes.Buffer
+	_, err := parseFlags([]string{"-h"}, &output)
+	if code := usageExitCode(err); code != exitOK {
+		t.Errorf("code de sortie %d, attendu %d (%v)", code, exitOK, err)
+	}
+	if !strings.Contains(output.String(), "Codes de sortie : 0 succès, 1 erreur fatale, 2 arguments invalides, 3 fichiers en échec, 130 interruption") {
+		t.Errorf("aide = %s", output.String())
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	workbook, err := os.ReadFile(filepath.Join("tools", "testdata", "facture.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		files     map[string][]byte
+		cancelled bool
+		code      int
+	}{
+		{name: "aucun classeur", code: exitOK},
+		{name: "conversion réussie", files: map[string][]byte{"Facture.xlsx": workbook}, code: exitOK},
+		{
+			name:  "classeur en échec",
+			files: map[string][]byte{"Facture.xlsx": workbook, "Illisible.xlsx": []byte("pas un classeur")},
+			code:  exitPartial,
+		},
+		{
+			name:      "interruption",
+			files:     map[string][]byte{"Facture.xlsx": workbook},
+			cancelled: true,
+			code:      exitInterrupted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := runOptions(t)
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(opts.ExcelDir, name), content, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			code, err := run(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("code de sortie %d, attendu %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestRunFatalError(t *testing.T) {
+	// Une erreur renvoyée par run termine le programme par fatal, code exitError
+	opts := runOptions(t)
+	if err := os.WriteFile(opts.ConfigPath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "configuration") {
+		t.Errorf("run() = %v, attendu une erreur de configuration", err)
+	}
+}
+
+// runOptions renvoie les options d'une conversion sans question ni archive, avec
+// le moteur natif, dans des dossiers temporaires
+func runOptions(t *testing.T) cliOptions {
+	t.Helper()
+	dir := t.TempDir()
+	excelDir := filepath.Join(dir, "excel")
+	if err := os.Mkdir(excelDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(helper.CloseLogging)
+	return cliOptions{Options: config.Options{
+		ConfigPath:    filepath.Join(dir, "config.json"),
+		ExcelDir:      excelDir,
+		OutputDir:     filepath.Join(dir, "pdf"),
+		CompressToZip: "N",
+		Converter:     "native",
+		NoPrompt:      true,
+	}}
+}
+
+func TestUsageExitCode(t *testing.T) {
+	if code := usageExitCode(flag.ErrHelp); code != exitOK {
+		t.Errorf("usageExitCode(ErrHelp) = %d", code)
+	}
+	if code := usageExitCode(os.ErrInvalid); code != exitUsage {
+		t.Errorf("usageExitCode() = %d", code)
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"fredon_to_pdf/archive"
+	"fredon_to_pdf/helper"
+	"os"
+	"strings"
+	"time"
+)
+
+// ZipPasswordEnv désigne la variable d'environnement contenant le mot de passe
+// des archives chiffrées
+const ZipPasswordEnv = "FREDON_ZIP_PASSWORD"
+
+// ValidateArchive vérifie le format, le nom, la compression et la taille des
+// archives
+func (cfg *Config) ValidateArchive() error {
+	if err := archive.ValidateFormat(cfg.ArchiveFormat); err != nil {
+		return fmt.Errorf("archive_format : %v", err)
+	}
+	if err := archive.ValidateName(cfg.ZipName); err != nil {
+		return fmt.Errorf("zip_name : %v", err)
+	}
+	if err := archive.ValidateLevel(cfg.ZipCompression); err != nil {
+		return fmt.Errorf("zip_compression : %v", err)
+	}
+	if err := validateYesNo("zip_report", cfg.ZipReport); err != nil {
+		return err
+	}
+	if err := validateYesNo("zip_sources", cfg.ZipSources); err != nil {
+		return err
+	}
+	if err := validateYesNo("zip_encrypt", cfg.ZipEncrypt); err != nil {
+		return err
+	}
+	if err := validateYesNo("zip_stream", cfg.ZipStream); err != nil {
+		return err
+	}
+	if strings.ToLower(cfg.ZipEncrypt) == "o" && cfg.ArchiveFormat != archive.FormatZip {
+		return fmt.Errorf("zip_encrypt : le chiffrement n'est disponible qu'au format %s, pas %s", archive.FormatZip, cfg.ArchiveFormat)
+	}
+	if cfg.ZipMaxSize < 0 {
+		return fmt.Errorf("zip_max_size négative : %d", cfg.ZipMaxSize)
+	}
+	return nil
+}
+
+// ArchiveOptions renvoie les réglages des archives de l'exécution identifiée par
+// runID, commencée à la date donnée
+func (cfg *Config) ArchiveOptions(runID string, date time.Time) archive.Options {
+	return archive.Options{
+		Name:    cfg.ZipName,
+		Format:  cfg.ArchiveFormat,
+		Level:   cfg.ZipCompression,
+		MaxSize: cfg.ZipMaxSize << 20,
+		RunID:   runID,
+		Date:    date,
+	}
+}
+
+// ZipPassword renvoie le mot de passe des archives chiffrées, vide si elles ne le
+// sont pas. Il provient de la variable FREDON_ZIP_PASSWORD, sinon du fichier
+// zip_password_file, sinon d'une saisie confirmée, jamais de config.json.
+func (cfg *Config) ZipPassword(noPrompt bool) (string, error) {
+	if strings.ToLower(cfg.ZipEncrypt) != "o" {
+		return "", nil
+	}
+	if password := os.Getenv(ZipPasswordEnv); password != "" {
+		return password, nil
+	}
+	if cfg.ZipPasswordFile != "" {
+		data, err := os.ReadFile(cfg.ZipPasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("zip_password_file : %v", err)
+		}
+		password := strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("zip_password_file : fichier vide")
+		}
+		return password, nil
+	}
+	if noPrompt {
+		return "", fmt.Errorf("mot de passe des archives introuvable : définissez %s ou zip_password_file", ZipPasswordEnv)
+	}
+
+	password, err := helper.ReadPassword("Mot de passe des archives ZIP : ")
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", fmt.Errorf("mot de passe des archives vide")
+	}
+	confirmation, err := helper.ReadPassword("Confirmez le mot de passe : ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != password {
+		return "", fmt.Errorf("les mots de passe saisis diffèrent")
+	}
+	return password, nil
+}
+
+func validateYesNo(key, value string) error {
+	if v := strings.ToLower(value); v != "o" && v != "n" {
+		return fmt.Errorf("%s doit valoir O ou N : %s", key, value)
+	}
+	return nil
+}
@@ -3,7 +3,13 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"fredon_to_pdf/archive"
+	"fredon_to_pdf/discover"
 	"fredon_to_pdf/helper"
+	"fredon_to_pdf/merge"
+	"fredon_to_pdf/naming"
+	"fredon_to_pdf/types"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -12,75 +18,401 @@ const (
 	defaultExcelDir      = "./excel_files"
 	defaultOutputDir     = "./pdf_files"
 	defaultCompressToZip = "O"
+	defaultZipReport     = "O"
+	defaultZipSources    = "N"
+	defaultZipEncrypt    = "N"
+	defaultZipStream     = "N"
+	defaultConverter     = "auto"
+	defaultMerge         = "N"
+	defaultMergeTOC      = "N"
+	defaultPerSheet      = "N"
+	defaultLogMaxSize    = 10 // Mo
+	defaultLogMaxFiles   = 5
 	configFilePath       = "./config.json"
 )
 
 type Config struct {
-	ExcelDir      string `json:"excel_dir"`
-	OutputDir     string `json:"output_dir"`
-	CompressToZip string `json:"compress_to_zip"`
+	ExcelDir        string          `json:"excel_dir"`
+	OutputDir       string          `json:"output_dir"`
+	CompressToZip   string          `json:"compress_to_zip"`
+	ArchiveFormat   string          `json:"archive_format"`    // format des archives : zip, tar.gz, tar.zst
+	ZipName         string          `json:"zip_name"`          // modèle du nom des archives, ex. pdfs_{date}_{run}.zip
+	ZipCompression  string          `json:"zip_compression"`   // niveau de compression : aucune, rapide, normale, maximale
+	ZipReport       string          `json:"zip_report"`        // O/N : joindre le rapport de conversion à l'archive
+	ZipSources      string          `json:"zip_sources"`       // O/N : joindre les classeurs source, dans sources/
+	ZipMaxSize      int64           `json:"zip_max_size"`      // taille maximale d'une archive en Mo, sans limite si 0
+	ZipStream       string          `json:"zip_stream"`        // O/N : alimenter les archives pendant la conversion
+	ZipEncrypt      string          `json:"zip_encrypt"`       // O/N : chiffrer les archives en AES-256
+	ZipPasswordFile string          `json:"zip_password_file"` // fichier contenant le mot de passe des archives, à défaut de FREDON_ZIP_PASSWORD
+	Converter       string          `json:"converter"`         // moteur de conversion : auto, excel, libreoffice, native
+	Include         []string        `json:"include"`           // motifs des classeurs à convertir, relatifs à excel_dir (**/*.xls)
+	Exclude         []string        `json:"exclude"`           // motifs des classeurs ou dossiers à ignorer (archives/**)
+	Merge           string          `json:"merge"`             // O/N : fusionner les PDF en un seul document
+	MergeOrder      string          `json:"merge_order"`       // ordre des documents fusionnés : nom, client, facture, date
+	MergeTOC        string          `json:"merge_toc"`         // O/N : page de sommaire en tête du document fusionné
+	MergeFile       string          `json:"merge_file"`        // nom du document fusionné, dans output_dir
+	Sheets          string          `json:"sheets"`            // feuilles exportées : toutes, visibles, nommees
+	SheetNames      []string        `json:"sheet_names"`       // feuilles exportées en mode nommees
+	PerSheet        string          `json:"per_sheet"`         // O/N : un PDF par feuille, <fichier>_<feuille>.pdf
+	SheetRules      []SheetRule     `json:"sheet_rules"`       // réglages des feuilles propres à certains classeurs
+	PDFProfile      string          `json:"pdf_profile"`       // profil des PDF : standard, pdfa-2b (archivage)
+	PageSetup       types.PageSetup `json:"page_setup"`        // mise en page imposée à toutes les feuilles
+	PageSetupRules  []PageSetupRule `json:"page_setup_rules"`  // mises en page propres à certains classeurs
+	FilenamePattern string          `json:"filename_pattern"`  // expression régulière extrayant les champs du nom des classeurs
+	OutputName      string          `json:"output_name"`       // modèle de nom des PDF, ex. {year}/{client}/{number}_{client}
+	OverwritePolicy string          `json:"overwrite_policy"`  // PDF existant ou collision : overwrite, skip, rename-with-suffix, fail
+	LogLevel        string          `json:"log_level"`         // niveau de journalisation : debug, info, warn, error
+	LogFormat       string          `json:"log_format"`        // format du fichier journal : json, text
+	LogDir          string          `json:"log_dir"`           // dossier du fichier journal, <output_dir>/logs si vide
+	LogMaxSize      int64           `json:"log_max_size"`      // taille en Mo au-delà de laquelle le journal est renouvelé
+	LogMaxFiles     int             `json:"log_max_files"`     // nombre d'anciens journaux conservés
+
+	namer *naming.Namer // modèle de nom des PDF, compilé par ValidateExport
+}
+
+// Options regroupe les valeurs passées en ligne de commande ; elles priment sur
+// config.json sans y être enregistrées
+type Options struct {
+	ConfigPath      string
+	ExcelDir        string
+	OutputDir       string
+	CompressToZip   string
+	ZipName         string
+	ZipMaxSize      int64
+	ZipEncrypt      string
+	ZipStream       string
+	ArchiveFormat   string
+	Converter       string
+	Include         []string
+	Exclude         []string
+	Merge           string
+	MergeOrder      string
+	MergeTOC        string
+	MergeFile       string
+	Sheets          string
+	SheetNames      []string
+	PerSheet        string
+	PDFProfile      string
+	OutputName      string
+	OverwritePolicy string
+	LogLevel        string
+	LogFormat       string
+	LogDir          string
+	NoPrompt        bool      // aucune question posée : valeurs par défaut
+	Input           io.Reader // réponses aux questions, l'entrée standard si nil
+	Yes             bool      // réponse "oui" aux questions sans les poser
 }
 
-func NewConfig() *Config {
+// NewConfig charge config.json, pose les questions manquantes sauf avec NoPrompt,
+// puis complète la configuration des valeurs par défaut
+func NewConfig(opts Options) (*Config, error) {
+	if opts.ConfigPath == "" {
+		opts.ConfigPath = configFilePath
+	}
 	cfg := &Config{}
-	return cfg.configure(configFilePath)
+	return cfg.configure(opts)
 }
 
-func (cfg *Config) configure(configFilePath string) *Config {
-	if _, err := os.Stat(configFilePath); err == nil {
-		cfg, err = loadConfig(configFilePath)
+func (cfg *Config) configure(opts Options) (*Config, error) {
+	if _, err := os.Stat(opts.ConfigPath); err == nil {
+		cfg, err = loadConfig(opts.ConfigPath)
 		if err != nil {
-			helper.GFatalLn("Erreur lors du chargement de la configuration : %v", err)
+			return nil, fmt.Errorf("erreur lors du chargement de la configuration : %v", err)
 		}
 	}
 
+	// Les options de la ligne de commande priment sur le fichier
+	saved := *cfg
+	cfg.applyOptions(opts)
+
 	// Demander à l'utilisateur le dossier source (Excel files)
 	if cfg.ExcelDir == "" {
-		helper.GInfo("Veuillez entrer le chemin du dossier des fichiers Excel (ou appuyez sur Entrée pour utiliser '%s') : ", defaultExcelDir)
-		fmt.Scanln(&cfg.ExcelDir)
-		if cfg.ExcelDir == "" {
-			cfg.ExcelDir = defaultExcelDir
-		}
+		cfg.ExcelDir = ask(opts, "Veuillez entrer le chemin du dossier des fichiers Excel (ou appuyez sur Entrée pour utiliser '%s') : ", defaultExcelDir)
 	}
 
 	// Convert ExcelDir to absolute path
 	absExcelDir, err := filepath.Abs(cfg.ExcelDir)
 	if err != nil {
-		helper.GFatalLn("Erreur lors de la conversion du chemin Excel en absolu : %v", err)
+		return nil, fmt.Errorf("erreur lors de la conversion du chemin Excel en absolu : %v", err)
 	}
 	cfg.ExcelDir = absExcelDir
+	if opts.ExcelDir == "" {
+		saved.ExcelDir = cfg.ExcelDir
+	}
 
 	// Demander à l'utilisateur le dossier de sortie (PDF files)
 	if cfg.OutputDir == "" {
-		helper.GInfo("Veuillez entrer le chemin du dossier de sortie des fichiers PDF (ou appuyez sur Entrée pour utiliser '%s') : ", defaultOutputDir)
-		fmt.Scanln(&cfg.OutputDir)
-		if cfg.OutputDir == "" {
-			cfg.OutputDir = defaultOutputDir
-		}
+		cfg.OutputDir = ask(opts, "Veuillez entrer le chemin du dossier de sortie des fichiers PDF (ou appuyez sur Entrée pour utiliser '%s') : ", defaultOutputDir)
 	}
 
 	// Convert OutputDir to absolute path
 	absOutputDir, err := filepath.Abs(cfg.OutputDir)
 	if err != nil {
-		helper.GFatalLn("Erreur lors de la conversion du chemin de sortie en absolu : %v", err)
+		return nil, fmt.Errorf("erreur lors de la conversion du chemin de sortie en absolu : %v", err)
 	}
 	cfg.OutputDir = absOutputDir
+	if opts.OutputDir == "" {
+		saved.OutputDir = cfg.OutputDir
+	}
 
 	// Demander à l'utilisateur s'il souhaite compresser les fichiers PDF
 	if cfg.CompressToZip == "" {
-		helper.GInfo("Souhaitez-vous compresser les fichiers PDF en un fichier ZIP ? (O/N, défaut : %s) : ", defaultCompressToZip)
-		fmt.Scanln(&cfg.CompressToZip)
-		if cfg.CompressToZip == "" {
-			cfg.CompressToZip = defaultCompressToZip
+		if opts.Yes {
+			cfg.CompressToZip = "O"
+		} else {
+			cfg.CompressToZip = ask(opts, "Souhaitez-vous compresser les fichiers PDF en un fichier ZIP ? (O/N, défaut : %s) : ", defaultCompressToZip)
+			saved.CompressToZip = cfg.CompressToZip
+		}
+	}
+
+	// Archives : ZIP datées, compression normale, rapport joint
+	if cfg.ArchiveFormat == "" {
+		cfg.ArchiveFormat = archive.FormatZip
+		saved.ArchiveFormat = cfg.ArchiveFormat
+	}
+	if cfg.ZipName == "" {
+		cfg.ZipName = archive.DefaultName
+		saved.ZipName = cfg.ZipName
+	}
+	if cfg.ZipCompression == "" {
+		cfg.ZipCompression = archive.LevelNormal
+		saved.ZipCompression = cfg.ZipCompression
+	}
+	if cfg.ZipReport == "" {
+		cfg.ZipReport = defaultZipReport
+		saved.ZipReport = cfg.ZipReport
+	}
+	if cfg.ZipSources == "" {
+		cfg.ZipSources = defaultZipSources
+		saved.ZipSources = cfg.ZipSources
+	}
+	if cfg.ZipEncrypt == "" {
+		cfg.ZipEncrypt = defaultZipEncrypt
+		saved.ZipEncrypt = cfg.ZipEncrypt
+	}
+	if cfg.ZipStream == "" {
+		cfg.ZipStream = defaultZipStream
+		saved.ZipStream = cfg.ZipStream
+	}
+
+	// Moteur de conversion : sélection automatique par défaut
+	if cfg.Converter == "" {
+		cfg.Converter = defaultConverter
+		saved.Converter = cfg.Converter
+	}
+
+	// Sélection des fichiers : tous les classeurs, sous-dossiers compris
+	if cfg.Include == nil {
+		cfg.Include = discover.DefaultInclude
+		saved.Include = cfg.Include
+	}
+	if cfg.Exclude == nil {
+		cfg.Exclude = []string{}
+		saved.Exclude = cfg.Exclude
+	}
+
+	// Fusion des PDF : désactivée par défaut
+	if cfg.Merge == "" {
+		cfg.Merge = defaultMerge
+		saved.Merge = cfg.Merge
+	}
+	if cfg.MergeOrder == "" {
+		cfg.MergeOrder = merge.OrderName
+		saved.MergeOrder = cfg.MergeOrder
+	}
+	if cfg.MergeTOC == "" {
+		cfg.MergeTOC = defaultMergeTOC
+		saved.MergeTOC = cfg.MergeTOC
+	}
+	if cfg.MergeFile == "" {
+		cfg.MergeFile = merge.DefaultFileName
+		saved.MergeFile = cfg.MergeFile
+	}
+
+	// Sélection des feuilles : classeur complet par défaut
+	if cfg.Sheets == "" {
+		cfg.Sheets = types.SheetsAll
+		saved.Sheets = cfg.Sheets
+	}
+	if cfg.SheetNames == nil {
+		cfg.SheetNames = []string{}
+		saved.SheetNames = cfg.SheetNames
+	}
+	if cfg.PerSheet == "" {
+		cfg.PerSheet = defaultPerSheet
+		saved.PerSheet = cfg.PerSheet
+	}
+	if cfg.SheetRules == nil {
+		cfg.SheetRules = []SheetRule{}
+		saved.SheetRules = cfg.SheetRules
+	}
+
+	// Profil des PDF : tels que produits par le moteur par défaut
+	if cfg.PDFProfile == "" {
+		cfg.PDFProfile = types.ProfileStandard
+		saved.PDFProfile = cfg.PDFProfile
+	}
+
+	// Mise en page : celle de chaque classeur par défaut
+	if cfg.PageSetupRules == nil {
+		cfg.PageSetupRules = []PageSetupRule{}
+		saved.PageSetupRules = cfg.PageSetupRules
+	}
+
+	// Nom des PDF : celui du classeur, dans l'arborescence du dossier source
+	if cfg.FilenamePattern == "" {
+		cfg.FilenamePattern = naming.DefaultPattern
+		saved.FilenamePattern = cfg.FilenamePattern
+	}
+	if cfg.OutputName == "" {
+		cfg.OutputName = naming.DefaultTemplate
+		saved.OutputName = cfg.OutputName
+	}
+
+	// PDF existants : remplacés par défaut
+	if cfg.OverwritePolicy == "" {
+		cfg.OverwritePolicy = types.OverwriteReplace
+		saved.OverwritePolicy = cfg.OverwritePolicy
+	}
+
+	// Journal : événements d'information et au-delà, en JSON, dans le dossier de
+	// sortie sauf choix contraire
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = helper.LogInfo
+		saved.LogLevel = cfg.LogLevel
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = helper.LogJSON
+		saved.LogFormat = cfg.LogFormat
+	}
+	if cfg.LogMaxSize <= 0 {
+		cfg.LogMaxSize = defaultLogMaxSize
+		saved.LogMaxSize = cfg.LogMaxSize
+	}
+	if cfg.LogMaxFiles <= 0 {
+		cfg.LogMaxFiles = defaultLogMaxFiles
+		saved.LogMaxFiles = cfg.LogMaxFiles
+	}
+
+	// Sauvegarder la configuration, sans les options de la ligne de commande ;
+	// une exécution non interactive ne modifie pas le fichier
+	if !opts.NoPrompt {
+		if err := saveConfig(&saved, opts.ConfigPath); err != nil {
+			helper.GWarningLn("Impossible de sauvegarder la configuration : %v", err)
 		}
 	}
 
-	// Sauvegarder la configuration
-	if err := saveConfig(cfg, configFilePath); err != nil {
-		helper.GWarningLn("Impossible de sauvegarder la configuration : %v", err)
+	return cfg, nil
+}
+
+// applyOptions remplace les valeurs de la configuration par celles fournies en options
+func (cfg *Config) applyOptions(opts Options) {
+	if opts.ExcelDir != "" {
+		cfg.ExcelDir = opts.ExcelDir
+	}
+	if opts.OutputDir != "" {
+		cfg.OutputDir = opts.OutputDir
+	}
+	if opts.CompressToZip != "" {
+		cfg.CompressToZip = opts.CompressToZip
+	}
+	if opts.ZipName != "" {
+		cfg.ZipName = opts.ZipName
+	}
+	if opts.ZipMaxSize > 0 {
+		cfg.ZipMaxSize = opts.ZipMaxSize
+	}
+	if opts.ZipEncrypt != "" {
+		cfg.ZipEncrypt = opts.ZipEncrypt
+	}
+	if opts.ZipStream != "" {
+		cfg.ZipStream = opts.ZipStream
+	}
+	if opts.ArchiveFormat != "" {
+		cfg.ArchiveFormat = opts.ArchiveFormat
+	}
+	if opts.Converter != "" {
+		cfg.Converter = opts.Converter
+	}
+	if len(opts.Include) > 0 {
+		cfg.Include = opts.Include
+	}
+	if len(opts.Exclude) > 0 {
+		cfg.Exclude = opts.Exclude
+	}
+	if opts.Merge != "" {
+		cfg.Merge = opts.Merge
+	}
+	if opts.MergeOrder != "" {
+		cfg.MergeOrder = opts.MergeOrder
+	}
+	if opts.MergeTOC != "" {
+		cfg.MergeTOC = opts.MergeTOC
+	}
+	if opts.MergeFile != "" {
+		cfg.MergeFile = opts.MergeFile
 	}
+	if opts.Sheets != "" {
+		cfg.Sheets = opts.Sheets
+	}
+	if len(opts.SheetNames) > 0 {
+		cfg.SheetNames = opts.SheetNames
+	}
+	if opts.PerSheet != "" {
+		cfg.PerSheet = opts.PerSheet
+	}
+	if opts.PDFProfile != "" {
+		cfg.PDFProfile = opts.PDFProfile
+	}
+	if opts.OutputName != "" {
+		cfg.OutputName = opts.OutputName
+	}
+	if opts.OverwritePolicy != "" {
+		cfg.OverwritePolicy = opts.OverwritePolicy
+	}
+	if opts.LogLevel != "" {
+		cfg.LogLevel = opts.LogLevel
+	}
+	if opts.LogFormat != "" {
+		cfg.LogFormat = opts.LogFormat
+	}
+	if opts.LogDir != "" {
+		cfg.LogDir = opts.LogDir
+	}
+}
 
-	return cfg
+// LogOptions renvoie les réglages de la journalisation
+func (cfg *Config) LogOptions() helper.LogOptions {
+	dir := cfg.LogDir
+	if dir == "" {
+		dir = filepath.Join(cfg.OutputDir, "logs")
+	}
+	return helper.LogOptions{
+		Level:    cfg.LogLevel,
+		Format:   cfg.LogFormat,
+		Dir:      dir,
+		MaxSize:  cfg.LogMaxSize << 20,
+		MaxFiles: cfg.LogMaxFiles,
+	}
+}
+
+// ask pose une question à l'utilisateur et renvoie sa réponse, ou la valeur par
+// défaut s'il n'a rien saisi ou si les questions sont désactivées
+func ask(opts Options, question, defaultValue string) string {
+	if opts.NoPrompt {
+		return defaultValue
+	}
+	input := opts.Input
+	if input == nil {
+		input = os.Stdin
+	}
+	var answer string
+	helper.GInfo(question, defaultValue)
+	fmt.Fscanln(input, &answer)
+	if answer == "" {
+		return defaultValue
+	}
+	return answer
 }
 
 func saveConfig(config *Config, filePath string) error {
@@ -100,16 +432,22 @@ func saveConfig(config *Config, filePath string) error {
 
 // Charge les paramètres depuis un fichier de configuration
 func loadConfig(filePath string) (*Config, error) {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("impossible d'ouvrir le fichier de configuration : %v", err)
 	}
-	defer file.Close()
 
 	var config Config
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
+	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("impossible de lire le fichier de configuration : %v", err)
 	}
+
+	// Le mot de passe des archives ne doit jamais figurer en clair dans le fichier
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(data, &keys) == nil {
+		if _, ok := keys["zip_password"]; ok {
+			return nil, fmt.Errorf("zip_password ne doit pas figurer dans %s : utilisez la variable %s ou zip_password_file", filepath.Base(filePath), ZipPasswordEnv)
+		}
+	}
 	return &config, nil
 }
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fredon_to_pdf/discover"
+	"fredon_to_pdf/naming"
+)
+
+// NamedOutputs indique si les PDF sont nommés selon un modèle ; ils sont alors
+// placés relativement au dossier de sortie, l'arborescence du dossier source
+// n'étant reproduite que par le champ {dir}
+func (cfg *Config) NamedOutputs() bool {
+	return cfg.OutputName != naming.DefaultTemplate
+}
+
+// validateNaming compile le motif d'extraction et le modèle de nom des PDF, une
+// fois pour tous les classeurs
+func (cfg *Config) validateNaming() error {
+	namer, err := naming.New(cfg.FilenamePattern, cfg.OutputName)
+	if err != nil {
+		return err
+	}
+	cfg.namer = namer
+	return nil
+}
+
+// outputName renvoie le chemin du PDF d'un classeur relatif au dossier de sortie,
+// vide pour conserver le nom du classeur. Le modèle est compilé par
+// ValidateExport : sans validation, le classeur garde son nom.
+func (cfg *Config) outputName(file string) string {
+	if !cfg.NamedOutputs() || cfg.namer == nil {
+		return ""
+	}
+	return cfg.namer.Name(file, discover.Rel(cfg.ExcelDir, file))
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"fredon_to_pdf/discover"
+	"fredon_to_pdf/types"
+)
+
+// PageSetupRule complète la mise en page globale pour les classeurs dont le
+// chemin, relatif à excel_dir, correspond au motif doublestar. Les champs vides
+// reprennent les valeurs globales.
+type PageSetupRule struct {
+	Pattern string `json:"pattern"`
+	types.PageSetup
+}
+
+// validatePageSetup vérifie la mise en page globale et celle de chaque règle
+func (cfg *Config) validatePageSetup() error {
+	if err := cfg.PageSetup.Validate(); err != nil {
+		return fmt.Errorf("page_setup : %v", err)
+	}
+	for _, rule := range cfg.PageSetupRules {
+		if _, err := discover.MatchPattern(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("page_setup_rules : %v", err)
+		}
+		if err := cfg.PageSetup.Merge(rule.PageSetup).Validate(); err != nil {
+			return fmt.Errorf("page_setup_rules %q : %v", rule.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// pageSetup renvoie la mise en page d'un classeur, de chemin relatif rel : la
+// première règle dont le motif correspond complète la mise en page globale
+func (cfg *Config) pageSetup(rel string) *types.PageSetup {
+	setup := cfg.PageSetup
+	for _, rule := range cfg.PageSetupRules {
+		if ok, _ := discover.MatchPattern(rule.Pattern, rel); ok {
+			setup = setup.Merge(rule.PageSetup)
+			break
+		}
+	}
+	if setup.Empty() {
+		return nil
+	}
+	return &setup
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"fredon_to_pdf/discover"
+	"fredon_to_pdf/types"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SheetRule remplace la sélection des feuilles pour les classeurs dont le chemin,
+// relatif à excel_dir, correspond au motif doublestar (ex. "clients/**/*.xlsx").
+// Les champs vides reprennent les valeurs globales.
+type SheetRule struct {
+	Pattern    string   `json:"pattern"`
+	Sheets     string   `json:"sheets,omitempty"`
+	SheetNames []string `json:"sheet_names,omitempty"`
+	PerSheet   string   `json:"per_sheet,omitempty"`
+}
+
+// ValidateExport vérifie le profil des PDF, la sélection des feuilles, la mise en
+// page, globales et propres à chaque règle, le nommage des PDF et la politique
+// appliquée aux PDF existants
+func (cfg *Config) ValidateExport() error {
+	if err := cfg.validatePageSetup(); err != nil {
+		return err
+	}
+	if err := cfg.validateNaming(); err != nil {
+		return err
+	}
+	if !slices.Contains(types.OverwritePolicies, cfg.OverwritePolicy) {
+		return fmt.Errorf("overwrite_policy inconnue : %s (valeurs : %s)", cfg.OverwritePolicy, strings.Join(types.OverwritePolicies, ", "))
+	}
+	if err := validatePerSheet(cfg.PerSheet); err != nil {
+		return err
+	}
+	if err := cfg.globalExport().Validate(); err != nil {
+		return err
+	}
+	for _, rule := range cfg.SheetRules {
+		if _, err := discover.MatchPattern(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("sheet_rules : %v", err)
+		}
+		if err := validatePerSheet(rule.PerSheet); err != nil {
+			return fmt.Errorf("sheet_rules %q : %v", rule.Pattern, err)
+		}
+		if err := rule.apply(cfg.globalExport()).Validate(); err != nil {
+			return fmt.Errorf("sheet_rules %q : %v", rule.Pattern, err)
+		}
+	}
+	return nil
+}
+
+func validatePerSheet(perSheet string) error {
+	if p := strings.ToLower(perSheet); p != "" && p != "o" && p != "n" {
+		return fmt.Errorf("per_sheet doit valoir O ou N : %s", perSheet)
+	}
+	return nil
+}
+
+// ExportOptions renvoie les options d'export d'un classeur : la première règle
+// dont le motif correspond au fichier l'emporte sur les valeurs globales
+func (cfg *Config) ExportOptions(file string) types.ExportOptions {
+	opts := cfg.globalExport()
+
+	rel, err := filepath.Rel(cfg.ExcelDir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(file)
+	}
+	opts.PageSetup = cfg.pageSetup(rel)
+	opts.Name = cfg.outputName(file)
+	for _, rule := range cfg.SheetRules {
+		if ok, _ := discover.MatchPattern(rule.Pattern, rel); ok {
+			return rule.apply(opts)
+		}
+	}
+	return opts
+}
+
+func (cfg *Config) globalExport() types.ExportOptions {
+	return types.ExportOptions{
+		Sheets:     cfg.Sheets,
+		SheetNames: cfg.SheetNames,
+		PerSheet:   strings.ToLower(cfg.PerSheet) == "o",
+		Profile:    cfg.PDFProfile,
+	}
+}
+
+// apply remplace les options globales par celles renseignées dans la règle
+func (rule SheetRule) apply(opts types.ExportOptions) types.ExportOptions {
+	if rule.Sheets != "" {
+		opts.Sheets = rule.Sheets
+	}
+	if len(rule.SheetNames) > 0 {
+		opts.SheetNames = rule.SheetNames
+		if rule.Sheets == "" {
+			opts.Sheets = types.SheetsNamed
+		}
+	}
+	if rule.PerSheet != "" {
+		opts.PerSheet = strings.ToLower(rule.PerSheet) == "o"
+	}
+	return opts
+}
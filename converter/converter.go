@@ -0,0 +1,143 @@
+// Package converter convertit des classeurs Excel en PDF avec les moteurs de
+// conversion disponibles, en se repliant sur le suivant en cas d'échec. Il est
+// utilisable depuis d'autres programmes Go :
+//
+//	c, err := converter.New(converter.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	result := c.Convert(ctx, "factures/F1.xls", "pdf", types.ExportOptions{})
+//	if result.Err != nil {
+//		return result.Err
+//	}
+//	fmt.Println(result.PDFs())
+//
+// Il n'affiche rien, ne lit pas l'entrée standard et ne termine jamais le
+// programme : les erreurs sont renvoyées dans le résultat.
+package converter
+
+import (
+	"context"
+	"fmt"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/pdfa"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Options règle un convertisseur
+type Options struct {
+	// Moteur de conversion : "auto" ou le nom d'un moteur enregistré, comme
+	// "excel", "libreoffice" ou "native" ; auto si vide
+	Backend string
+}
+
+// Converter convertit des classeurs un par un. Les moteurs sont démarrés à la
+// première conversion ; un Converter n'est pas destiné à être partagé entre
+// plusieurs goroutines, chacune peut disposer du sien.
+type Converter struct {
+	backends  []tools.Backend
+	processor *tools.ChainProcessor
+}
+
+// New renvoie un convertisseur utilisant les moteurs disponibles correspondant à
+// opts.Backend
+func New(opts Options) (*Converter, error) {
+	backend := opts.Backend
+	if backend == "" {
+		backend = tools.AutoBackend
+	}
+	backends, err := tools.Candidates(backend)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithBackends(backends), nil
+}
+
+// NewWithBackends renvoie un convertisseur essayant les moteurs donnés dans
+// l'ordre
+func NewWithBackends(backends []tools.Backend) *Converter {
+	return &Converter{backends: backends, processor: tools.NewChainProcessor(backends)}
+}
+
+// Backends renvoie les moteurs essayés, dans l'ordre
+func (c *Converter) Backends() []tools.Backend {
+	return c.backends
+}
+
+// Convert convertit un classeur dans outputDir et décrit le résultat : PDF
+// produits, moteur utilisé, pages, empreinte du classeur. L'annulation du
+// contexte interrompt la conversion en cours.
+func (c *Converter) Convert(ctx context.Context, file, outputDir string, opts types.ExportOptions) (result types.ProcessResult) {
+	result = types.ProcessResult{
+		FileName:  filepath.Base(file),
+		InputPath: file,
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		result.Duration = time.Since(result.StartedAt)
+	}()
+
+	if err := checkFilePermissions(file); err != nil {
+		result.Err = fmt.Errorf("erreur de permissions : %v", err)
+		return result
+	}
+
+	// Empreinte du fichier source pour le rapport
+	size, sum, err := helper.FileSHA256(file)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.InputSize = size
+	result.InputSHA256 = sum
+
+	outputs, err := c.processor.ProcessFile(ctx, file, outputDir, opts)
+	result.Attempts = c.processor.Attempts()
+	if err != nil {
+		result.Err = err
+		result.Cancelled = ctx.Err() != nil
+		return result
+	}
+	result.Backend = c.processor.LastBackend()
+	result.Export = opts
+	result.PdfPath = outputs[0]
+	result.Outputs = outputs
+	if opts.PDFA() {
+		// Conformité vérifiée par la chaîne de moteurs
+		result.PDFA = pdfa.Conformance
+	}
+
+	// Taille et pages cumulées des PDF produits
+	for _, output := range outputs {
+		if info, err := os.Stat(output); err == nil {
+			result.OutputSize += info.Size()
+		}
+		if pages, err := pdf.PageCountFile(output); err == nil {
+			result.Pages += pages
+		}
+	}
+
+	return result
+}
+
+// Close arrête les moteurs démarrés, comme une instance d'Excel ou de
+// LibreOffice
+func (c *Converter) Close() error {
+	return c.processor.Close()
+}
+
+func checkFilePermissions(file string) error {
+	// Vérification des permissions en lecture
+	f, err := os.OpenFile(file, os.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("impossible d'ouvrir le fichier en lecture : %v", err)
+	}
+	f.Close()
+	return nil
+}
@@ -0,0 +1,275 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/pdfa"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubProcessor écrit un PDF d'une page par classeur, dessiné par draw
+type stubProcessor struct {
+	draw func(page *pdf.Page)
+}
+
+func (p *stubProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	doc := pdf.New()
+	doc.Title = opts.OutputName(inputFile)
+	p.draw(doc.AddPage(595, 842))
+	output := filepath.Join(outputDir, opts.OutputName(inputFile)+".pdf")
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := doc.Write(file); err != nil {
+		return nil, err
+	}
+	return []string{output}, nil
+}
+
+// stubBackend renvoie un moteur de test pour les classeurs .xlsx
+func stubBackend(name string, draw func(page *pdf.Page)) tools.Backend {
+	return tools.Backend{
+		Name:       name,
+		Extensions: []string{".xlsx"},
+		New: func() (tools.FileProcessor, error) {
+			return &stubProcessor{draw: draw}, nil
+		},
+	}
+}
+
+// Dessins des moteurs de test : le texte emploie une police standard non
+// incorporée, refusée par PDF/A
+var (
+	drawGraphics = func(page *pdf.Page) { page.FillRect(50, 700, 200, 40) }
+	drawText     = func(page *pdf.Page) { page.Text(pdf.FontFor(false, false), 11, 50, 700, "Facture") }
+)
+
+// workbook crée un classeur de test
+func workbook(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Client (2502).xlsx")
+	if err := os.WriteFile(path, []byte("classeur"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConvertPDFA(t *testing.T) {
+	pdfaOpts := types.ExportOptions{Profile: types.ProfilePDFA2B}
+
+	tests := []struct {
+		name     string
+		backends []tools.Backend
+		opts     types.ExportOptions
+		backend  string // moteur retenu, vide si la conversion échoue
+		attempts int
+		pdfa     string
+	}{
+		{
+			name:     "PDF conforme",
+			backends: []tools.Backend{stubBackend("graphique", drawGraphics)},
+			opts:     pdfaOpts,
+			backend:  "graphique",
+			attempts: 1,
+			pdfa:     pdfa.Conformance,
+		},
+		{
+			name:     "repli après un PDF non conforme",
+			backends: []tools.Backend{stubBackend("texte", drawText), stubBackend("graphique", drawGraphics)},
+			opts:     pdfaOpts,
+			backend:  "graphique",
+			attempts: 2,
+			pdfa:     pdfa.Conformance,
+		},
+		{
+			name:     "aucun PDF conforme",
+			backends: []tools.Backend{stubBackend("texte", drawText)},
+			opts:     pdfaOpts,
+			attempts: 1,
+		},
+		{
+			name:     "profil standard",
+			backends: []tools.Backend{stubBackend("texte", drawText)},
+			backend:  "texte",
+			attempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewWithBackends(tt.backends)
+			defer c.Close()
+			result := c.Convert(context.Background(), workbook(t), t.TempDir(), tt.opts)
+
+			if result.Backend != tt.backend || result.Attempts != tt.attempts {
+				t.Errorf("moteur %q en %d essais, attendu %q en %d", result.Backend, result.Attempts, tt.backend, tt.attempts)
+			}
+			if result.PDFA != tt.pdfa {
+				t.Errorf("PDFA = %q, attendu %q", result.PDFA, tt.pdfa)
+			}
+			if tt.backend == "" {
+				if result.Err == nil || !strings.Contains(result.Err.Error(), "police non incorporée") {
+					t.Errorf("erreur = %v, police non incorporée attendue", result.Err)
+				}
+				return
+			}
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+
+			issues, err := pdfa.Validate(result.PdfPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compliant := len(issues) == 0; compliant != (tt.pdfa != "") {
+				t.Errorf("manquements PDF/A = %v", issues)
+			}
+		})
+	}
+}
+
+// failingProcessor échoue sur chaque classeur et compte ses fermetures
+type failingProcessor struct {
+	err      error
+	closeErr error
+	closed   int
+}
+
+func (p *failingProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	return nil, p.err
+}
+
+func (p *failingProcessor) Close() error {
+	p.closed++
+	return p.closeErr
+}
+
+// failingBackend renvoie un moteur de test dont chaque conversion échoue
+func failingBackend(p *failingProcessor, name string) tools.Backend {
+	return tools.Backend{
+		Name:       name,
+		Extensions: []string{".xlsx"},
+		New: func() (tools.FileProcessor, error) {
+			return p, nil
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(Options{Backend: "native"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if backends := c.Backends(); len(backends) == 0 || backends[0].Name != "native" {
+		t.Errorf("Backends() = %v, moteur native attendu en premier", backends)
+	}
+
+	if _, err := New(Options{Backend: "inconnu"}); err == nil || !strings.Contains(err.Error(), "moteur de conversion inconnu : inconnu") {
+		t.Errorf("New(inconnu) = %v, attendu une erreur de moteur inconnu", err)
+	}
+}
+
+func TestNewWithBackends(t *testing.T) {
+	backends := []tools.Backend{stubBackend("texte", drawText), stubBackend("graphique", drawGraphics)}
+	c := NewWithBackends(backends)
+	defer c.Close()
+	var names []string
+	for _, b := range c.Backends() {
+		names = append(names, b.Name)
+	}
+	if strings.Join(names, ",") != "texte,graphique" {
+		t.Errorf("Backends() = %v, attendu l'ordre donné", names)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	input := workbook(t)
+	outputDir := t.TempDir()
+	c := NewWithBackends([]tools.Backend{stubBackend("texte", drawText)})
+	defer c.Close()
+
+	result := c.Convert(context.Background(), input, outputDir, types.ExportOptions{})
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	output := filepath.Join(outputDir, "Client (2502).pdf")
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("classeur"))
+	if result.FileName != "Client (2502).xlsx" || result.InputPath != input || result.InputSize != int64(len("classeur")) || result.InputSHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("source = %q %q %d %q", result.FileName, result.InputPath, result.InputSize, result.InputSHA256)
+	}
+	if result.PdfPath != output || len(result.Outputs) != 1 || result.Outputs[0] != output {
+		t.Errorf("PDF = %q %v, attendu %q", result.PdfPath, result.Outputs, output)
+	}
+	if result.Backend != "texte" || result.Attempts != 1 || result.Pages != 1 || result.OutputSize != info.Size() {
+		t.Errorf("moteur %q, %d essais, %d pages, %d octets", result.Backend, result.Attempts, result.Pages, result.OutputSize)
+	}
+	if result.StartedAt.IsZero() || result.Duration <= 0 {
+		t.Errorf("début %v, durée %v", result.StartedAt, result.Duration)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	locked := &failingProcessor{err: os.ErrPermission}
+	empty := &failingProcessor{err: os.ErrInvalid}
+	c := NewWithBackends([]tools.Backend{failingBackend(locked, "verrouillé"), failingBackend(empty, "vide")})
+	defer c.Close()
+
+	// Classeur absent : aucun moteur n'est essayé
+	result := c.Convert(context.Background(), filepath.Join(t.TempDir(), "absent.xlsx"), t.TempDir(), types.ExportOptions{})
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "erreur de permissions") || result.Attempts != 0 {
+		t.Errorf("classeur absent : %v en %d essais", result.Err, result.Attempts)
+	}
+
+	result = c.Convert(context.Background(), workbook(t), t.TempDir(), types.ExportOptions{})
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "échec de tous les moteurs") {
+		t.Fatalf("erreur = %v, échec de tous les moteurs attendu", result.Err)
+	}
+	if result.Attempts != 2 || result.Backend != "" || result.PdfPath != "" || result.Pages != 0 || result.Cancelled {
+		t.Errorf("résultat en échec = %+v", result)
+	}
+	if result.InputSHA256 == "" {
+		t.Error("empreinte du classeur absente après un échec")
+	}
+}
+
+func TestConvertCancelled(t *testing.T) {
+	c := NewWithBackends([]tools.Backend{failingBackend(&failingProcessor{err: context.Canceled}, "annulé")})
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := c.Convert(ctx, workbook(t), t.TempDir(), types.ExportOptions{}); result.Err == nil || !result.Cancelled {
+		t.Errorf("conversion annulée : %v, Cancelled = %v", result.Err, result.Cancelled)
+	}
+}
+
+func TestClose(t *testing.T) {
+	started := &failingProcessor{err: os.ErrInvalid}
+	broken := &failingProcessor{err: os.ErrInvalid, closeErr: os.ErrClosed}
+	unused := &failingProcessor{}
+	other := failingBackend(unused, "native")
+	other.Extensions = []string{".xls"}
+	c := NewWithBackends([]tools.Backend{failingBackend(started, "excel"), failingBackend(broken, "libreoffice"), other})
+	c.Convert(context.Background(), workbook(t), t.TempDir(), types.ExportOptions{})
+
+	// Seuls les moteurs démarrés sont fermés ; leurs erreurs sont renvoyées
+	err := c.Close()
+	if err == nil || !strings.Contains(err.Error(), "libreoffice : "+os.ErrClosed.Error()) {
+		t.Errorf("Close() = %v, attendu l'erreur de libreoffice", err)
+	}
+	if started.closed != 1 || broken.closed != 1 || unused.closed != 0 {
+		t.Errorf("fermetures = %d %d %d, attendu 1 1 0", started.closed, broken.closed, unused.closed)
+	}
+}
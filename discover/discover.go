@@ -0,0 +1,151 @@
+package discover
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Extensions liste les extensions des classeurs recherchés, en minuscules
+var Extensions = []string{".xls", ".xlsx"}
+
+// DefaultInclude sélectionne tous les classeurs, sous-dossiers compris
+var DefaultInclude = []string{"**"}
+
+// Filter sélectionne les classeurs d'un dossier selon des motifs doublestar
+// (**, *, ?, {a,b}, [abc]) appliqués au chemin relatif au dossier source, avec
+// des "/" comme séparateurs. La comparaison ignore la casse, comme l'explorateur
+// Windows.
+type Filter struct {
+	include []string
+	exclude []string
+}
+
+// NewFilter valide les motifs ; sans motif d'inclusion, tous les classeurs sont retenus
+func NewFilter(include, exclude []string) (*Filter, error) {
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	f := &Filter{}
+	for _, pattern := range include {
+		p, err := normalize(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, p)
+	}
+	for _, pattern := range exclude {
+		p, err := normalize(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, p)
+	}
+	return f, nil
+}
+
+func normalize(pattern string) (string, error) {
+	p := strings.ToLower(filepath.ToSlash(strings.TrimSpace(pattern)))
+	p = strings.TrimPrefix(p, "./")
+	if p == "" || !doublestar.ValidatePattern(p) {
+		return "", fmt.Errorf("motif de sélection invalide : %q", pattern)
+	}
+	return p, nil
+}
+
+// IsWorkbook indique si le nom désigne un classeur ; les fichiers de verrouillage
+// d'Office (~$classeur.xlsx) sont exclus
+func IsWorkbook(name string) bool {
+	name = filepath.Base(name)
+	if strings.HasPrefix(name, "~$") {
+		return false
+	}
+	return slices.Contains(Extensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// Match indique si le fichier, désigné par son chemin relatif au dossier source,
+// doit être converti
+func (f *Filter) Match(rel string) bool {
+	if !IsWorkbook(rel) {
+		return false
+	}
+	rel = strings.ToLower(filepath.ToSlash(rel))
+	return matchAny(f.include, rel) && !matchAny(f.exclude, rel)
+}
+
+// excludesDir indique si tout un sous-dossier est exclu (motif "archives/**")
+func (f *Filter) excludesDir(rel string) bool {
+	return matchAny(f.exclude, strings.ToLower(filepath.ToSlash(rel)))
+}
+
+// MatchPattern indique si le chemin relatif au dossier source correspond au
+// motif, selon les mêmes règles que Filter
+func MatchPattern(pattern, rel string) (bool, error) {
+	p, err := normalize(pattern)
+	if err != nil {
+		return false, err
+	}
+	return matchAny([]string{p}, strings.ToLower(filepath.ToSlash(rel))), nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if ok, _ := doublestar.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Find parcourt récursivement le dossier et renvoie, triés, les chemins absolus
+// des classeurs retenus par le filtre. Les dossiers listés dans skip (dossier de
+// sortie placé sous le dossier source par exemple) ne sont pas parcourus.
+func Find(root string, f *Filter, skip ...string) ([]string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de convertir le dossier en chemin absolu : %v", err)
+	}
+
+	var files []string
+	err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == absRoot {
+				return err
+			}
+			// Sous-dossier illisible : on continue avec le reste
+			return nil
+		}
+
+		rel, _ := filepath.Rel(absRoot, path)
+		if d.IsDir() {
+			if path != absRoot && (slices.Contains(skip, path) || f.excludesDir(rel)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && f.Match(rel) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la lecture du dossier %s : %v", root, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Rel renvoie le sous-dossier du fichier relatif au dossier source ("" à la racine)
+func Rel(root, file string) string {
+	rel, err := filepath.Rel(root, filepath.Dir(file))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return rel
+}
@@ -0,0 +1,243 @@
+package discover
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tree crée les fichiers donnés, chemins relatifs séparés par des "/"
+func tree(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("classeur"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestFind(t *testing.T) {
+	root := tree(t,
+		"Client.xls",
+		"Fournisseur.XLSX",
+		"~$Client.xlsx",
+		"notes.txt",
+		"classeur.xlsm",
+		"2024/Janvier/Facture F1.xlsx",
+		"2024/Janvier/~$Facture F1.xlsx",
+		"2024/Février/Facture F2.xls",
+		"2024/Brouillons/essai.xlsx",
+		"Archives/2019/ancien.xls",
+		"archives.xls",
+		"pdf/Client.xlsx",
+	)
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		skip    []string
+		want    []string
+	}{
+		{
+			name: "tous les classeurs",
+			want: []string{
+				"2024/Brouillons/essai.xlsx",
+				"2024/Février/Facture F2.xls",
+				"2024/Janvier/Facture F1.xlsx",
+				"Archives/2019/ancien.xls",
+				"Client.xls",
+				"Fournisseur.XLSX",
+				"archives.xls",
+				"pdf/Client.xlsx",
+			},
+		},
+		{
+			name:    "racine seulement",
+			include: []string{"*"},
+			want:    []string{"Client.xls", "Fournisseur.XLSX", "archives.xls"},
+		},
+		{
+			name:    "sous-dossiers à toute profondeur",
+			include: []string{"2024/**/facture*"},
+			want:    []string{"2024/Février/Facture F2.xls", "2024/Janvier/Facture F1.xlsx"},
+		},
+		{
+			name:    "casse ignorée",
+			include: []string{"FOURNISSEUR.xlsx", "2024/janvier/*"},
+			want:    []string{"2024/Janvier/Facture F1.xlsx", "Fournisseur.XLSX"},
+		},
+		{
+			name:    "alternatives et classes",
+			include: []string{"{client,fournisseur}.*", "2024/*/facture f[2-9].xls"},
+			want:    []string{"2024/Février/Facture F2.xls", "Client.xls", "Fournisseur.XLSX"},
+		},
+		{
+			name:    "préfixe ./ et espaces",
+			include: []string{" ./2024/Janvier/*.xlsx "},
+			want:    []string{"2024/Janvier/Facture F1.xlsx"},
+		},
+		{
+			name:    "exclusion d'un dossier et de ses sous-dossiers",
+			exclude: []string{"archives/**", "2024/brouillons/**", "pdf/**"},
+			want: []string{
+				"2024/Février/Facture F2.xls",
+				"2024/Janvier/Facture F1.xlsx",
+				"Client.xls",
+				"Fournisseur.XLSX",
+				"archives.xls",
+			},
+		},
+		{
+			// Le motif désigne le dossier lui-même : il n'est pas parcouru, alors
+			// qu'il ne correspond à aucun des classeurs qu'il contient
+			name:    "dossier exclu non parcouru",
+			include: []string{"**"},
+			exclude: []string{"archives", "2024/*"},
+			want:    []string{"Client.xls", "Fournisseur.XLSX", "archives.xls", "pdf/Client.xlsx"},
+		},
+		{
+			name:    "exclusion prioritaire",
+			include: []string{"**/*.xls"},
+			exclude: []string{"**/ancien*"},
+			want:    []string{"2024/Février/Facture F2.xls", "Client.xls", "archives.xls"},
+		},
+		{
+			name: "dossier de sortie ignoré",
+			skip: []string{"pdf"},
+			want: []string{
+				"2024/Brouillons/essai.xlsx",
+				"2024/Février/Facture F2.xls",
+				"2024/Janvier/Facture F1.xlsx",
+				"Archives/2019/ancien.xls",
+				"Client.xls",
+				"Fournisseur.XLSX",
+				"archives.xls",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var skip []string
+			for _, dir := range tt.skip {
+				skip = append(skip, filepath.Join(root, dir))
+			}
+			files, err := Find(root, f, skip...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, file := range files {
+				if !filepath.IsAbs(file) {
+					t.Errorf("chemin relatif : %s", file)
+				}
+				rel, _ := filepath.Rel(root, file)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Find() = %v\nattendu %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMissingDir(t *testing.T) {
+	f, err := NewFilter(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "absent")
+	if _, err := Find(missing, f); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Find() = %v, dossier absent non signalé", err)
+	}
+}
+
+func TestNewFilterInvalid(t *testing.T) {
+	for _, tt := range []struct{ include, exclude []string }{
+		{include: []string{"["}},
+		{include: []string{"  "}},
+		{exclude: []string{"{a,b"}},
+	} {
+		if _, err := NewFilter(tt.include, tt.exclude); err == nil {
+			t.Errorf("NewFilter(%q, %q) accepté", tt.include, tt.exclude)
+		}
+	}
+}
+
+func TestIsWorkbook(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Client.xls", true},
+		{"Client.XLSX", true},
+		{"factures/Client (2502).xlsx", true},
+		{"~$Client.xlsx", false},
+		{"factures/~$Client.xls", false},
+		{"Client.xlsm", false},
+		{"Client.csv", false},
+		{"xlsx", false},
+	}
+	for _, tt := range tests {
+		if got := IsWorkbook(tt.name); got != tt.want {
+			t.Errorf("IsWorkbook(%q) = %v, attendu %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"**", "2024/Janvier/F1.xlsx", true},
+		{"*.xls", "F1.XLS", true},
+		{"*.xls", "2024/F1.xls", false},
+		{"2024/**", filepath.Join("2024", "Janvier", "F1.xlsx"), true},
+		{"Clients/*", "clients/Dupont.xlsx", true},
+		{"?1.xls", "F1.xls", true},
+		{"?1.xls", "FF1.xls", false},
+	}
+	for _, tt := range tests {
+		got, err := MatchPattern(tt.pattern, tt.rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, attendu %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+	if _, err := MatchPattern("[", "F1.xls"); err == nil {
+		t.Error("motif invalide accepté")
+	}
+}
+
+func TestRel(t *testing.T) {
+	root := filepath.Join("données", "excel")
+	tests := []struct {
+		file, want string
+	}{
+		{filepath.Join(root, "Client.xls"), ""},
+		{filepath.Join(root, "2024", "Client.xls"), "2024"},
+		{filepath.Join(root, "2024", "Janvier", "Client.xls"), filepath.Join("2024", "Janvier")},
+		{filepath.Join("ailleurs", "Client.xls"), ""},
+	}
+	for _, tt := range tests {
+		if got := Rel(root, tt.file); got != tt.want {
+			t.Errorf("Rel(%q, %q) = %q, attendu %q", root, tt.file, got, tt.want)
+		}
+	}
+}
@@ -1,15 +1,13 @@
 package helper
 
 import (
-	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"fredon_to_pdf/types"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
-
-	"github.com/schollz/progressbar/v3"
 )
 
 // EnsureDirExists vérifie si un dossier existe et le crée si nécessaire
@@ -28,50 +26,18 @@ func SanitizeFilename(filename string) string {
 	return invalidChars.ReplaceAllString(filename, "_")
 }
 
-// CreateZipFile crée un fichier ZIP contenant les fichiers spécifiés
-func CreateZipFile(zipPath string, results []types.ProcessResult, bar *progressbar.ProgressBar) error {
-	// Création du fichier ZIP
-	zipFile, err := os.Create(zipPath)
+// FileSHA256 renvoie la taille et l'empreinte SHA-256 (hexadécimale) d'un fichier
+func FileSHA256(path string) (int64, string, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("impossible de créer le fichier ZIP : %v", err)
+		return 0, "", fmt.Errorf("impossible d'ouvrir le fichier %s : %v", filepath.Base(path), err)
 	}
-	defer zipFile.Close()
-
-	// Création du writer ZIP
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	// Ajout des fichiers au ZIP
-	for _, result := range results {
-		if result.Err != nil {
-			continue
-		}
+	defer file.Close()
 
-		// Ouverture du fichier source
-		file, err := os.Open(result.PdfPath)
-		if err != nil {
-			GWarningLn("Impossible d'ouvrir le fichier %s : %v", result.FileName, err)
-			continue
-		}
-
-		// Création de l'entrée ZIP
-		writer, err := zipWriter.Create(filepath.Base(result.PdfPath))
-		if err != nil {
-			file.Close()
-			GWarningLn("Impossible de créer l'entrée ZIP pour %s : %v", result.FileName, err)
-			continue
-		}
-
-		// Copie du contenu
-		if _, err := io.Copy(writer, file); err != nil {
-			file.Close()
-			GWarningLn("Impossible de copier le fichier %s dans le ZIP : %v", result.FileName, err)
-			continue
-		}
-
-		file.Close()
-		bar.Add(1)
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("impossible de lire le fichier %s : %v", filepath.Base(path), err)
 	}
-
-	return nil
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
 }
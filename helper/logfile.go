@@ -0,0 +1,94 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// rotatingFile est un fichier journal renouvelé lorsqu'il dépasse maxSize : il
+// devient <nom>.1.log, le précédent <nom>.2.log, et ainsi de suite jusqu'à
+// maxFiles anciens fichiers, les plus anciens étant supprimés
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxSize  int64 // sans renouvellement si 0
+	maxFiles int
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("impossible d'ouvrir le fichier journal : %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("impossible d'ouvrir le fichier journal : %v", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		// Un échec du renouvellement ne doit pas faire perdre l'événement
+		if err := r.rotate(); err != nil && r.file == nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate décale les anciens fichiers puis commence un nouveau fichier
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	os.Remove(r.backup(r.maxFiles))
+	for n := r.maxFiles - 1; n >= 1; n-- {
+		os.Rename(r.backup(n), r.backup(n+1))
+	}
+	if r.maxFiles > 0 {
+		os.Rename(r.path, r.backup(1))
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+// backup renvoie le chemin de l'ancien fichier numéro n
+func (r *rotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d.log", strings.TrimSuffix(r.path, ".log"), n)
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
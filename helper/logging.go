@@ -1,61 +1,315 @@
 package helper
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gookit/color"
+	"golang.org/x/term"
 )
 
 const logFormat string = "2006-01-02 15:04:05"
 
-var colors = map[string]func(a ...any) string{
-	"info":    color.FgGreen.Render,
-	"warning": color.FgYellow.Render,
-	"fatal":   color.FgRed.Render,
+// Niveaux de journalisation
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// LogLevels liste les niveaux de journalisation acceptés
+var LogLevels = []string{LogDebug, LogInfo, LogWarn, LogError}
+
+// Formats du fichier journal
+const (
+	LogJSON = "json" // une ligne JSON par événement, pour les outils d'analyse
+	LogText = "text" // lignes clé=valeur, lisibles telles quelles
+)
+
+// LogFormats liste les formats de fichier journal acceptés
+var LogFormats = []string{LogJSON, LogText}
+
+// LogFileName est le nom du fichier journal, dans le dossier choisi
+const LogFileName = "fredon_to_pdf.log"
+
+// LevelFatal désigne une erreur fatale, suivie de l'arrêt du programme
+const LevelFatal = slog.LevelError + 4
+
+var levels = map[string]slog.Level{
+	LogDebug: slog.LevelDebug,
+	LogInfo:  slog.LevelInfo,
+	LogWarn:  slog.LevelWarn,
+	LogError: slog.LevelError,
+}
+
+var colors = map[slog.Level]func(a ...any) string{
+	slog.LevelDebug: color.FgCyan.Render,
+	slog.LevelInfo:  color.FgGreen.Render,
+	slog.LevelWarn:  color.FgYellow.Render,
+	slog.LevelError: color.FgRed.Render,
+	LevelFatal:      color.FgRed.Render,
 }
 
-func GLog(msg string, msgType string, newLine bool) {
-	dt := time.Now()
-	nl := ""
-	if newLine {
-		nl = "\n"
+// LogOptions règle la journalisation
+type LogOptions struct {
+	Level    string // niveau minimal : debug, info, warn, error
+	Format   string // format du fichier journal : json, text
+	Dir      string // dossier du fichier journal, console seule si vide
+	MaxSize  int64  // taille en octets au-delà de laquelle le fichier est renouvelé
+	MaxFiles int    // nombre d'anciens fichiers journaux conservés
+}
+
+var (
+	level   = new(slog.LevelVar) // niveau minimal, info par défaut
+	colored = term.IsTerminal(int(os.Stdout.Fd()))
+
+	logMu   sync.Mutex
+	logger  = slog.New(&consoleHandler{w: os.Stdout, level: level}) // console et fichier
+	events  = slog.New(slog.NewTextHandler(io.Discard, nil))        // fichier seul
+	logFile io.Closer
+)
+
+// SetupLogging ouvre le fichier journal et applique le niveau demandé ; attrs
+// sont les champs de corrélation ajoutés à chaque événement du fichier, tel que
+// l'identifiant de l'exécution
+func SetupLogging(opts LogOptions, attrs ...any) error {
+	lvl, ok := levels[strings.ToLower(opts.Level)]
+	if opts.Level != "" && !ok {
+		return fmt.Errorf("niveau de journalisation inconnu : %s (valeurs : %s)", opts.Level, strings.Join(LogLevels, ", "))
+	}
+	if opts.Format != "" && !slices.Contains(LogFormats, opts.Format) {
+		return fmt.Errorf("format de journal inconnu : %s (valeurs : %s)", opts.Format, strings.Join(LogFormats, ", "))
+	}
+	level.Set(lvl)
+	if opts.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return fmt.Errorf("impossible de créer le dossier des journaux : %v", err)
 	}
-	fmt.Printf("[%s] [ %s ] %s%s", dt.Format(logFormat), colors[msgType](strings.ToUpper(msgType)), msg, nl)
+	file, err := openRotatingFile(filepath.Join(opts.Dir, LogFileName), opts.MaxSize, opts.MaxFiles)
+	if err != nil {
+		return err
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: fatalName}
+	var handler slog.Handler
+	if opts.Format == LogText {
+		handler = slog.NewTextHandler(file, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(file, handlerOpts)
+	}
+	handler = handler.WithAttrs(argsToAttrs(attrs))
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = file
+	logger = slog.New(multiHandler{&consoleHandler{w: os.Stdout, level: level}, handler})
+	events = slog.New(handler)
+	return nil
 }
 
-func GInfo(format string, args ...interface{}) {
-	GLog(fmt.Sprintf(format, args...), "info", false)
+// CloseLogging ferme le fichier journal ; la journalisation se poursuit sur la
+// console seule
+func CloseLogging() {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+	logger = slog.New(&consoleHandler{w: os.Stdout, level: level})
+	events = slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// Logger renvoie le journal des événements détaillés, tels que ceux de chaque
+// conversion. Ils ne sont écrits que dans le fichier journal : la console
+// affiche les barres de progression et le résumé.
+func Logger() *slog.Logger {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return events
+}
+
+type loggerKey struct{}
+
+// WithLogger renvoie un contexte portant le journal donné, pour que les
+// événements d'une conversion gardent ses champs de corrélation
+func WithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// LoggerFrom renvoie le journal porté par le contexte, sinon celui des
+// événements détaillés
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return Logger()
+}
+
+// Colored indique si la console accepte les couleurs : elles sont désactivées
+// lorsque la sortie standard est redirigée vers un fichier ou un autre programme
+func Colored() bool {
+	return colored
+}
+
+func currentLogger() *slog.Logger {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return logger
 }
 
-func GWarning(format string, args ...interface{}) {
-	GLog(fmt.Sprintf(format, args...), "warning", false)
+// GLog journalise un message au niveau donné, sur la console et dans le fichier
+// journal
+func GLog(msg string, lvl slog.Level) {
+	currentLogger().Log(context.Background(), lvl, msg)
 }
 
-func GFatal(format string, args ...interface{}) {
-	GLog(fmt.Sprintf(format, args...), "fatal", false)
-	GInfoLn("Appuyez sur une touche pour fermer...")
-	fmt.Scanln()
-	os.Exit(1)
+// consoleLine met en forme une ligne de la console
+func consoleLine(t time.Time, lvl slog.Level, msg string) string {
+	name := levelName(lvl)
+	if colored {
+		name = colors[lvl](name)
+	}
+	return fmt.Sprintf("[%s] [ %s ] %s", t.Format(logFormat), name, msg)
+}
+
+func GDebugLn(format string, args ...interface{}) {
+	GLog(fmt.Sprintf(format, args...), slog.LevelDebug)
+}
+
+// GInfo affiche une question sans retour à la ligne ; elle n'est pas journalisée
+func GInfo(format string, args ...interface{}) {
+	fmt.Print(consoleLine(time.Now(), slog.LevelInfo, fmt.Sprintf(format, args...)))
 }
 
 func GInfoLn(format string, args ...interface{}) {
-	GLog(fmt.Sprintf(format, args...), "info", true)
+	GLog(fmt.Sprintf(format, args...), slog.LevelInfo)
 }
 
 func GWarningLn(format string, args ...interface{}) {
-	GLog(fmt.Sprintf(format, args...), "warning", true)
+	GLog(fmt.Sprintf(format, args...), slog.LevelWarn)
+}
+
+func GErrorLn(format string, args ...interface{}) {
+	GLog(fmt.Sprintf(format, args...), slog.LevelError)
 }
 
+// GFatalLn journalise une erreur fatale ; l'arrêt du programme revient à
+// l'appelant
 func GFatalLn(format string, args ...interface{}) {
-	GLog(fmt.Sprintf(format, args...), "fatal", true)
-	GInfoLn("Appuyez sur une touche pour fermer...")
-	fmt.Scanln()
-	os.Exit(1)
+	GLog(fmt.Sprintf(format, args...), LevelFatal)
 }
 
 func GBlank() {
 	fmt.Println("")
 }
+
+// levelName renvoie le nom affiché d'un niveau : INFO, WARNING, ERROR, FATAL...
+func levelName(lvl slog.Level) string {
+	switch lvl {
+	case slog.LevelWarn:
+		return "WARNING"
+	case LevelFatal:
+		return "FATAL"
+	}
+	return lvl.String()
+}
+
+// fatalName nomme le niveau des erreurs fatales dans le fichier journal
+func fatalName(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if lvl, ok := a.Value.Any().(slog.Level); ok && lvl == LevelFatal {
+			a.Value = slog.StringValue("FATAL")
+		}
+	}
+	return a
+}
+
+// consoleHandler affiche les messages sur la console, précédés de leur date et
+// de leur niveau ; les champs de corrélation sont réservés au fichier journal
+type consoleHandler struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, consoleLine(r.Time, r.Level, r.Message))
+	return err
+}
+
+func (h *consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *consoleHandler) WithGroup(string) slog.Handler { return h }
+
+// multiHandler transmet chaque événement à plusieurs destinations
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// argsToAttrs convertit des paires clé, valeur en champs
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
@@ -0,0 +1,23 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ReadPassword lit un mot de passe sur le terminal, sans l'afficher
+func ReadPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("saisie du mot de passe impossible : l'entrée standard n'est pas un terminal")
+	}
+	GInfo(prompt)
+	password, err := term.ReadPassword(fd)
+	GBlank()
+	if err != nil {
+		return "", fmt.Errorf("saisie du mot de passe impossible : %v", err)
+	}
+	return string(password), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/queue"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// runJobs exécute la sous-commande jobs sur la file de conversion
+func runJobs(opts cliOptions) error {
+	path := opts.QueuePath
+	if path == "" {
+		// Configuration sans question : seul le dossier de sortie est utile
+		opts.NoPrompt = true
+		cfg, err := config.NewConfig(opts.Options)
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(cfg.OutputDir, queue.FileName)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("file de conversion introuvable : %s", path)
+	}
+
+	q, err := queue.Open(path)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	statuses := make([]queue.Status, len(opts.JobStatuses))
+	for i, status := range opts.JobStatuses {
+		statuses[i], _ = queue.ParseStatus(status)
+	}
+
+	switch opts.JobsAction {
+	case jobsRetry:
+		n, err := q.Retry(opts.JobIDs...)
+		if err != nil {
+			return err
+		}
+		helper.GInfoLn("%d tâche(s) remise(s) en attente ; elles seront traitées à la prochaine exécution", n)
+	case jobsPurge:
+		if len(statuses) == 0 {
+			statuses = []queue.Status{queue.Done}
+		}
+		n, err := q.Purge(statuses, time.Now().Add(-opts.OlderThan))
+		if err != nil {
+			return err
+		}
+		helper.GInfoLn("%d tâche(s) supprimée(s)", n)
+	default:
+		jobs, err := q.List(statuses...)
+		if err != nil {
+			return err
+		}
+		listJobs(jobs)
+	}
+	return nil
+}
+
+// listJobs affiche les tâches sous forme de tableau
+func listJobs(jobs []*queue.Job) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tÉTAT\tESSAIS\tMODIFIÉE LE\tFICHIER\tERREUR")
+	for _, job := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
+			job.ID, job.Status, job.Attempts, job.UpdatedAt.Format("2006-01-02 15:04:05"), job.Input, job.Error)
+	}
+	w.Flush()
+}
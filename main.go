@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"fredon_to_pdf/archive"
 	"fredon_to_pdf/config"
+	"fredon_to_pdf/discover"
 	"fredon_to_pdf/helper"
+	"fredon_to_pdf/manifest"
+	"fredon_to_pdf/merge"
+	"fredon_to_pdf/pdfa"
+	"fredon_to_pdf/queue"
+	"fredon_to_pdf/report"
 	"fredon_to_pdf/tools"
 	"fredon_to_pdf/types"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
+	"slices"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
@@ -22,56 +33,205 @@ var (
 )
 
 func main() {
-	if err := run(); err != nil {
-		helper.GFatalLn("Erreur fatale : %v", err)
-		os.Exit(1)
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		os.Exit(usageExitCode(err))
+	}
+
+	switch opts.Command {
+	case commandWatch:
+		if err := runWatch(opts); err != nil {
+			fatal(opts, err)
+		}
+		return
+	case commandServe:
+		if err := runServe(opts); err != nil {
+			fatal(opts, err)
+		}
+		return
+	case commandJobs:
+		opts.Pause = false
+		if err := runJobs(opts); err != nil {
+			fatal(opts, err)
+		}
+		return
+	}
+
+	// Ctrl+C ou SIGTERM : plus aucun fichier n'est lancé et les conversions en
+	// cours sont interrompues
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code, err := run(ctx, opts)
+	stop()
+	if err != nil {
+		fatal(opts, err)
 	}
+	helper.Logger().Info("fin de l'exécution", "exit_code", code)
+	helper.CloseLogging()
+
+	helper.GBlank()
+	pause(opts)
+	os.Exit(code)
 }
 
-func run() error {
+// fatal signale une erreur fatale puis quitte le programme avec le code exitError
+func fatal(opts cliOptions, err error) {
+	helper.GFatalLn("Erreur fatale : %v", err)
+	helper.CloseLogging()
+	pause(opts)
+	os.Exit(exitError)
+}
+
+// pause attend que l'utilisateur appuie sur Entrée, avec --pause uniquement :
+// la fenêtre d'un programme lancé par double-clic reste ouverte
+func pause(opts cliOptions) {
+	if !opts.Pause || opts.NoPrompt {
+		return
+	}
+	helper.GInfoLn("Appuyez sur Entrée pour fermer...")
+	fmt.Scanln()
+}
+
+// run exécute une conversion complète et renvoie le code de sortie
+func run(ctx context.Context, opts cliOptions) (int, error) {
 	displayHeader()
+	started := time.Now()
+	runID := newRunID()
 
 	// Initialisation de la configuration
-	cfg := config.NewConfig()
+	cfg, err := config.NewConfig(opts.Options)
+	if err != nil {
+		return 0, err
+	}
+	if err := setupLogging(cfg, "convert", runID); err != nil {
+		return 0, err
+	}
+	if err := cfg.ValidateExport(); err != nil {
+		return 0, err
+	}
+	if err := cfg.ValidateArchive(); err != nil {
+		return 0, err
+	}
+	// Le mot de passe des archives est demandé avant les conversions
+	archiveOpts := cfg.ArchiveOptions(runID, started)
+	if strings.ToLower(cfg.CompressToZip) == "o" {
+		password, err := cfg.ZipPassword(opts.NoPrompt)
+		if err != nil {
+			return 0, err
+		}
+		archiveOpts.Password = password
+	}
 	if err := initializeDirs(cfg); err != nil {
-		return err
+		return 0, err
 	}
 
 	// Récupération des fichiers
-	files, err := getExcelFiles(cfg.ExcelDir)
+	files, err := getExcelFiles(cfg)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(files) == 0 {
 		helper.GWarningLn("Aucun fichier Excel trouvé dans %s", cfg.ExcelDir)
-		return nil
+		return exitOK, nil
+	}
+
+	// Mode incrémental : seuls les classeurs nouveaux ou modifiés sont convertis
+	state := loadManifest(cfg)
+
+	// Doublons et collisions de noms de PDF, décidés avant toute conversion
+	files, plan, excluded := planOutputs(cfg, files, state)
+	files, results := selectChangedFiles(files, plan, state, opts.Force)
+	results = append(excluded, results...)
+
+	// File de conversion persistante : les tâches interrompues lors d'une
+	// exécution précédente sont reprises
+	q, err := openQueue(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer q.Close()
+
+	// En mode flux, les archives sont alimentées pendant les conversions, en
+	// commençant par les PDF déjà à jour ; elles ne sont conservées que complètes
+	var stream *archiveStream
+	if strings.ToLower(cfg.CompressToZip) == "o" && strings.ToLower(cfg.ZipStream) == "o" {
+		if stream, err = startArchiveStream(cfg, archiveOpts); err != nil {
+			return 0, err
+		}
+		defer stream.Abort()
+		for _, result := range results {
+			stream.Send(result)
+		}
 	}
 
 	// Traitement des fichiers
-	results, err := processFiles(cfg, files)
+	converted, err := processFiles(ctx, cfg, q, files, plan, stream)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if len(converted) == 0 {
+		helper.GBlank()
+		helper.GInfoLn("Aucun fichier nouveau ou modifié à convertir")
+	}
+	results = append(results, converted...)
+	plan.annotate(results)
+
+	// Mise à jour du fichier d'état
+	for _, result := range results {
+		if result.Err == nil && !result.Skipped && !result.Excluded {
+			if err := state.Record(result); err != nil {
+				helper.GWarningLn("%v", err)
+			}
+		}
+	}
+	if err := state.Save(); err != nil {
+		helper.GWarningLn("%v", err)
 	}
 
 	// Filtrer les résultats avec succès
 	successResults := filterSuccessResults(results)
 
-	// Gestion du ZIP si nécessaire et s'il y a des fichiers traités avec succès
-	if len(successResults) > 0 && strings.ToLower(cfg.CompressToZip) == "o" {
-		if err := handleZipCreation(cfg, successResults); err != nil {
-			return err
+	// Rapport de conversion pour l'audit
+	reports, err := report.Write(cfg.OutputDir, results, Version)
+	if err != nil {
+		helper.GWarningLn("Impossible d'écrire le rapport de conversion : %v", err)
+	}
+
+	// Fusion des PDF puis gestion du ZIP si nécessaire et s'il y a des fichiers
+	// traités avec succès ; aucun document incomplet n'est créé après une interruption
+	if ctx.Err() != nil {
+		helper.GBlank()
+		helper.GWarningLn("Traitement interrompu : le document fusionné et l'archive n'ont pas été créés")
+	} else if len(successResults) > 0 {
+		var extras []string
+		if strings.ToLower(cfg.ZipReport) == "o" {
+			extras = reports
+		}
+		if strings.ToLower(cfg.Merge) == "o" {
+			if merged, err := handleMerge(cfg, successResults); err != nil {
+				helper.GErrorLn("%v", err)
+			} else {
+				extras = append(extras, merged)
+			}
+		}
+		if strings.ToLower(cfg.CompressToZip) == "o" {
+			if err := handleArchiveCreation(cfg, successResults, extras, archiveOpts, stream); err != nil {
+				return 0, err
+			}
 		}
 	}
 
 	// Afficher le résumé
 	displaySummary(results)
 
-	helper.GBlank()
-	helper.GInfoLn("Appuyez sur une touche pour fermer...")
-	fmt.Scanln()
-
-	return nil
+	switch {
+	case ctx.Err() != nil:
+		return exitInterrupted, nil
+	case slices.ContainsFunc(results, func(r types.ProcessResult) bool { return r.Err != nil }):
+		return exitPartial, nil
+	default:
+		return exitOK, nil
+	}
 }
 
 func displayHeader() {
@@ -90,192 +250,365 @@ func initializeDirs(cfg *config.Config) error {
 	return nil
 }
 
-func getExcelFiles(dir string) ([]string, error) {
+func getExcelFiles(cfg *config.Config) ([]string, error) {
 	helper.GBlank()
 	helper.GInfoLn("Récupération des fichiers Excel..")
 
-	// Convert input directory to absolute path
-	absDir, err := filepath.Abs(dir)
+	filter, err := discover.NewFilter(cfg.Include, cfg.Exclude)
 	if err != nil {
-		return nil, fmt.Errorf("impossible de convertir le dossier en chemin absolu : %v", err)
+		return nil, err
 	}
 
-	var files []string
-	extensions := []string{"*.xls", "*.xlsx"}
+	// Le dossier de sortie peut se trouver dans le dossier source : on ne le parcourt pas
+	return discover.Find(cfg.ExcelDir, filter, cfg.OutputDir)
+}
 
-	for _, ext := range extensions {
-		matches, err := filepath.Glob(filepath.Join(absDir, ext))
-		if err != nil {
-			return nil, fmt.Errorf("erreur lors de la lecture des fichiers %s : %v", ext, err)
-		}
-		files = append(files, matches...)
+// outputDirFor renvoie le dossier du PDF, qui reproduit l'arborescence du dossier
+// source ; avec un modèle de nom, le PDF est rangé selon le modèle
+func outputDirFor(cfg *config.Config, file string) string {
+	if cfg.NamedOutputs() {
+		return cfg.OutputDir
 	}
+	return filepath.Join(cfg.OutputDir, discover.Rel(cfg.ExcelDir, file))
+}
 
-	return files, nil
+// loadManifest charge le fichier d'état du dossier de sortie ; s'il est illisible,
+// tous les fichiers seront reconvertis
+func loadManifest(cfg *config.Config) *manifest.Manifest {
+	statePath := filepath.Join(cfg.OutputDir, manifest.FileName)
+	state, err := manifest.Load(statePath)
+	if err != nil {
+		helper.GWarningLn("%v ; tous les fichiers seront convertis", err)
+		state = manifest.New(statePath)
+	}
+	return state
 }
 
-func processFiles(cfg *config.Config, files []string) ([]types.ProcessResult, error) {
-	helper.GBlank()
-	helper.GInfoLn("Traitement des fichiers Excel..")
-	helper.GBlank()
+// selectChangedFiles sépare les fichiers à convertir de ceux inchangés depuis leur
+// dernière conversion, pour lesquels un résultat "ignoré" est renvoyé
+func selectChangedFiles(files []string, plan outputPlan, state *manifest.Manifest, force bool) ([]string, []types.ProcessResult) {
+	if force {
+		return files, nil
+	}
 
-	// Création de la barre de progression
-	bar := progressbar.NewOptions(len(files),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(40),
-		progressbar.OptionSetDescription("[cyan]Conversion en cours...[reset]"),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]=[reset]",
-			SaucerHead:    "[green]>[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}))
-
-	// Nombre de workers basé sur le nombre de CPU
-	numWorkers := runtime.NumCPU()
-	if numWorkers > 4 {
-		numWorkers = 4 // Limite à 4 workers maximum pour éviter la surcharge
-	}
-
-	// Channels pour la gestion des tâches
-	jobs := make(chan string, len(files))
-	results := make(chan types.ProcessResult, len(files))
-	var wg sync.WaitGroup
-
-	// Démarrage des workers
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			// Création d'un nouveau processeur pour chaque goroutine
-			processor, err := tools.NewWindowsFileProcessor()
-			if err != nil {
-				results <- types.ProcessResult{
-					FileName: "initialization",
-					Err:      fmt.Errorf("erreur d'initialisation du processeur : %v", err),
-				}
-				return
-			}
+	var changed []string
+	var skipped []types.ProcessResult
+	for _, file := range files {
+		opts := plan.options[file]
+		entry, ok := state.Unchanged(file, opts)
+		if !ok {
+			changed = append(changed, file)
+			continue
+		}
+		skipped = append(skipped, types.ProcessResult{
+			FileName:    filepath.Base(file),
+			InputPath:   file,
+			PdfPath:     entry.PdfPath,
+			Outputs:     entry.Outputs,
+			Export:      opts,
+			Backend:     entry.Backend,
+			StartedAt:   entry.ConvertedAt,
+			InputSize:   entry.Size,
+			InputSHA256: entry.SHA256,
+			OutputSize:  entry.OutputSize,
+			Pages:       entry.Pages,
+			PDFA:        entry.PDFA,
+			Skipped:     true,
+		})
+	}
+	return changed, skipped
+}
 
-			for file := range jobs {
-				result := processFile(file, cfg.OutputDir, processor)
-				results <- result
-				bar.Add(1)
-				time.Sleep(100 * time.Millisecond) // Petit délai pour éviter la surcharge
-			}
-		}()
+// openQueue ouvre la file de conversion du dossier de sortie
+func openQueue(cfg *config.Config) (*queue.Queue, error) {
+	return queue.Open(filepath.Join(cfg.OutputDir, queue.FileName))
+}
+
+// processFiles ajoute les fichiers à la file de conversion puis traite toutes les
+// tâches en attente, y compris celles d'une exécution interrompue. Chaque résultat
+// est transmis à stream dès sa réception, s'il n'est pas nil.
+func processFiles(ctx context.Context, cfg *config.Config, q *queue.Queue, files []string, plan outputPlan, stream *archiveStream) ([]types.ProcessResult, error) {
+	// Sélection des moteurs de conversion
+	backends, err := tools.Candidates(cfg.Converter)
+	if err != nil {
+		return nil, err
 	}
 
-	// Envoi des fichiers aux workers
+	// Ajout des fichiers à la file
 	for _, file := range files {
-		jobs <- file
+		if _, err := q.Add(file, outputDirFor(cfg, file), queue.Options{Backend: cfg.Converter, Export: plan.options[file]}, ""); err != nil {
+			return nil, err
+		}
+	}
+	pending, err := q.List(queue.Pending)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if resumed := len(pending) - len(files); resumed > 0 {
+		helper.GBlank()
+		helper.GInfoLn("Reprise de %d tâche(s) laissée(s) en attente par une exécution précédente", resumed)
 	}
-	close(jobs)
 
-	// Attente de la fin du traitement
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	helper.GBlank()
+	helper.GInfoLn("Traitement des fichiers Excel..")
+	helper.GInfoLn("Moteurs de conversion : %s", backendNames(backends))
+	helper.GBlank()
+
+	// Création de la barre de progression
+	bar := newProgressBar(len(pending), "Conversion en cours...")
+
+	pool := newWorkerPool(ctx, q, cfg.Converter, backends, 0)
+	pool.Close()
 
 	// Collecte des résultats
 	var processResults []types.ProcessResult
-	for result := range results {
+	done := make(map[string]bool)
+	for result := range pool.Results() {
 		processResults = append(processResults, result)
+		done[result.InputPath] = true
+		if stream != nil {
+			stream.Send(result)
+		}
+		bar.Add(1)
+	}
+
+	// Après une interruption, les tâches non lancées restent en attente dans la file
+	ids := make([]string, len(pending))
+	for i, job := range pending {
+		ids[i] = job.ID
+		if !done[job.Input] {
+			processResults = append(processResults, cancelledResult(job.Input))
+		}
+	}
+
+	// Les tâches réussies figurent dans le rapport : elles sont retirées de la
+	// file, qui ne grossit pas d'une exécution à l'autre. Celles en échec ou
+	// annulées y restent pour jobs retry.
+	if err := q.RemoveDone(ids...); err != nil {
+		helper.GWarningLn("Impossible de nettoyer la file de conversion : %v", err)
 	}
 
 	helper.GBlank()
 	return processResults, nil
 }
 
-func processFile(file, outputDir string, processor tools.FileProcessor) types.ProcessResult {
-	result := types.ProcessResult{
-		FileName: filepath.Base(file),
+// newProgressBar crée une barre de progression, en couleur seulement si la
+// console l'accepte : redirigées, les balises de couleur seraient écrites telles
+// quelles
+func newProgressBar(total int, description string) *progressbar.ProgressBar {
+	theme := progressbar.Theme{
+		Saucer:        "=",
+		SaucerHead:    ">",
+		SaucerPadding: " ",
+		BarStart:      "[",
+		BarEnd:        "]",
 	}
-
-	if err := checkFilePermissions(file); err != nil {
-		result.Err = fmt.Errorf("erreur de permissions : %v", err)
-		return result
+	if helper.Colored() {
+		description = "[cyan]" + description + "[reset]"
+		theme.Saucer = "[green]=[reset]"
+		theme.SaucerHead = "[green]>[reset]"
 	}
+	return progressbar.NewOptions(total,
+		progressbar.OptionEnableColorCodes(helper.Colored()),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetWidth(40),
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetTheme(theme))
+}
 
-	if err := processor.ProcessFile(file, outputDir); err != nil {
-		result.Err = err
-		return result
+// createdPDFs décrit les PDF produits pour le journal
+func createdPDFs(result types.ProcessResult) string {
+	pdfs := result.PDFs()
+	names := make([]string, len(pdfs))
+	for i, pdf := range pdfs {
+		names[i] = filepath.Base(pdf)
 	}
-
-	// Construction du chemin PDF
-	pdfName := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) + ".pdf"
-	result.PdfPath = filepath.Join(outputDir, pdfName)
-
-	return result
+	if len(names) == 1 {
+		return "PDF créé : " + names[0]
+	}
+	return fmt.Sprintf("%d PDF créés : %s", len(names), strings.Join(names, ", "))
 }
 
-func checkFilePermissions(file string) error {
-	// Vérification des permissions en lecture
-	f, err := os.OpenFile(file, os.O_RDONLY, 0)
-	if err != nil {
-		return fmt.Errorf("impossible d'ouvrir le fichier en lecture : %v", err)
+func backendNames(backends []tools.Backend) string {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = b.Name
 	}
-	f.Close()
-	return nil
+	return strings.Join(names, " > ")
 }
 
 func filterSuccessResults(results []types.ProcessResult) []types.ProcessResult {
 	var successResults []types.ProcessResult
 	for _, result := range results {
-		if result.Err == nil {
+		if result.Err == nil && !result.Excluded {
 			successResults = append(successResults, result)
 		}
 	}
 	return successResults
 }
 
-func handleZipCreation(cfg *config.Config, results []types.ProcessResult) error {
+// handleMerge assemble les PDF en un seul document dans le dossier de sortie et
+// renvoie son chemin
+func handleMerge(cfg *config.Config, results []types.ProcessResult) (string, error) {
 	helper.GBlank()
-	helper.GInfoLn("Création du fichier ZIP..")
+	helper.GInfoLn("Fusion des PDF (ordre : %s)..", cfg.MergeOrder)
+
+	mergedPath := filepath.Join(cfg.OutputDir, cfg.MergeFile)
+	pages, err := merge.Merge(mergedPath, results, merge.Options{
+		Order: cfg.MergeOrder,
+		TOC:   strings.ToLower(cfg.MergeTOC) == "o",
+		Title: strings.TrimSuffix(cfg.MergeFile, filepath.Ext(cfg.MergeFile)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("erreur lors de la fusion des PDF : %v", err)
+	}
 
-	zipPath := filepath.Join(cfg.OutputDir, "pdfs.zip")
-	bar := progressbar.NewOptions(len(results),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(40),
-		progressbar.OptionSetDescription("[cyan]Compression en cours...[reset]"),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]=[reset]",
-			SaucerHead:    "[green]>[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}))
+	helper.GInfoLn("Document fusionné créé avec succès : %s (%d pages)", mergedPath, pages)
 
-	if err := helper.CreateZipFile(zipPath, results, bar); err != nil {
-		return fmt.Errorf("erreur lors de la création du ZIP : %v", err)
+	// Le document fusionné suit le profil d'archivage des PDF qui le composent ; le
+	// sommaire, en polices standard, l'empêche d'être conforme
+	if cfg.PDFProfile == types.ProfilePDFA2B {
+		if err := pdfa.Ensure(mergedPath); err != nil {
+			helper.GWarningLn("Document fusionné %v", err)
+		}
 	}
+	return mergedPath, nil
+}
+
+// handleArchiveCreation archive les PDF, les fichiers annexes (rapports, document
+// fusionné) et, si demandé, les classeurs source, au format archive_format. En
+// mode flux, seuls les fichiers annexes restent à ajouter aux archives de stream.
+func handleArchiveCreation(cfg *config.Config, results []types.ProcessResult, extras []string, opts archive.Options, stream *archiveStream) error {
+	helper.GBlank()
+	if stream != nil {
+		helper.GInfoLn("Finalisation de l'archive %s, alimentée pendant la conversion..", opts.Format)
+		paths, err := stream.Finish(extras)
+		if err != nil {
+			return fmt.Errorf("erreur lors de la création de l'archive : %v", err)
+		}
+		displayArchives(cfg, paths, opts)
+		return nil
+	}
+	helper.GInfoLn("Création de l'archive %s..", opts.Format)
+
+	files := append(archiveFiles(cfg, results), archive.Root(extras...)...)
 
+	bar := newProgressBar(len(files), "Compression en cours...")
+
+	paths, err := archive.Create(cfg.OutputDir, files, opts, func(archive.File) { bar.Add(1) })
+	if err != nil {
+		return fmt.Errorf("erreur lors de la création de l'archive : %v", err)
+	}
 	helper.GBlank()
-	helper.GInfoLn("Fichier ZIP créé avec succès : %s", zipPath)
+	displayArchives(cfg, paths, opts)
+	return nil
+}
+
+// archiveFiles renvoie les fichiers à archiver pour les conversions réussies :
+// leurs PDF puis, si demandé, leurs classeurs source
+func archiveFiles(cfg *config.Config, results []types.ProcessResult) []archive.File {
+	files := archive.PDFs(cfg.OutputDir, results)
+	if strings.ToLower(cfg.ZipSources) == "o" {
+		for _, result := range results {
+			files = append(files, archive.File{
+				Path: result.InputPath,
+				Name: "sources/" + archive.EntryName(cfg.ExcelDir, result.InputPath),
+			})
+		}
+	}
+	return files
+}
+
+// displayArchives affiche les archives créées
+func displayArchives(cfg *config.Config, paths []string, opts archive.Options) {
+	if opts.Password != "" {
+		helper.GInfoLn("Archives chiffrées en AES-256, déchiffrement vérifié")
+	}
+	if len(paths) == 1 {
+		helper.GInfoLn("Archive créée avec succès : %s", paths[0])
+		return
+	}
+	helper.GInfoLn("Archives créées avec succès (%d, au plus %d Mo chacun) :", len(paths), cfg.ZipMaxSize)
+	for _, path := range paths {
+		helper.GInfoLn("  - %s", path)
+	}
+}
+
+// setupLogging ouvre le journal de l'exécution ; chaque événement du fichier
+// porte l'identifiant runID
+func setupLogging(cfg *config.Config, command, runID string) error {
+	if err := helper.SetupLogging(cfg.LogOptions(), "run", runID); err != nil {
+		return err
+	}
+	helper.Logger().Info("démarrage", "command", command, "version", Version, "input_dir", cfg.ExcelDir, "output_dir", cfg.OutputDir, "converter", cfg.Converter)
 	return nil
 }
 
+// newRunID renvoie un identifiant court de l'exécution, repris dans le nom des
+// archives et dans le journal
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func displaySummary(results []types.ProcessResult) {
 	helper.GBlank()
 	helper.GInfoLn("Résumé de la conversion :")
 	helper.GBlank()
 
 	success := 0
+	skipped := 0
+	cancelled := 0
 	failed := 0
+	var duplicates, collisions []types.ProcessResult
+	byBackend := make(map[string]int)
 	for _, result := range results {
-		if result.Err == nil {
+		if result.Collision != "" {
+			collisions = append(collisions, result)
+		}
+		if result.Cancelled {
+			cancelled++
+			helper.GWarningLn("Annulé : %s", result.FileName)
+		} else if result.DuplicateOf != "" {
+			duplicates = append(duplicates, result)
+		} else if result.Excluded {
+			continue
+		} else if result.Skipped {
+			skipped++
+		} else if result.Err == nil {
 			success++
+			byBackend[result.Backend]++
 		} else {
 			failed++
-			helper.GFatalLn("Échec pour %s : %v", result.FileName, result.Err)
+			helper.GErrorLn("Échec pour %s : %v", result.FileName, result.Err)
 		}
 	}
 
 	helper.GInfoLn("Fichiers traités avec succès : %d", success)
+	for _, name := range tools.BackendNames() {
+		if n := byBackend[name]; n > 0 {
+			helper.GInfoLn("  - via %s : %d", name, n)
+		}
+	}
+	helper.GInfoLn("Fichiers ignorés (inchangés) : %d", skipped)
+	if cancelled > 0 {
+		helper.GInfoLn("Fichiers annulés : %d", cancelled)
+	}
 	helper.GInfoLn("Fichiers en échec : %d", failed)
+	if len(duplicates) > 0 {
+		helper.GInfoLn("Doublons écartés (contenu identique) : %d", len(duplicates))
+		for _, result := range duplicates {
+			helper.GInfoLn("  - %s : identique à %s", result.FileName, filepath.Base(result.DuplicateOf))
+		}
+	}
+	if len(collisions) > 0 {
+		helper.GWarningLn("Collisions de noms de PDF : %d", len(collisions))
+		for _, result := range collisions {
+			helper.GWarningLn("  - %s : %s", result.FileName, result.Collision)
+		}
+	}
 }
@@ -0,0 +1,208 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName est le nom du fichier d'état, enregistré dans le dossier de sortie
+const FileName = ".fredon_manifest.json"
+
+// Entry mémorise l'état d'un classeur lors de sa dernière conversion réussie
+type Entry struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	SHA256      string    `json:"sha256"`
+	PdfPath     string    `json:"pdf"`
+	Outputs     []string  `json:"pdfs,omitempty"`   // export par feuille : tous les PDF produits
+	Export      string    `json:"export,omitempty"` // options d'export (ExportOptions.Key)
+	OutputSize  int64     `json:"pdf_size"`
+	Pages       int       `json:"pages"`
+	PDFA        string    `json:"pdfa,omitempty"` // conformité d'archivage vérifiée
+	Backend     string    `json:"backend"`
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+// Manifest associe le chemin absolu de chaque classeur converti à son état
+type Manifest struct {
+	path    string
+	mu      sync.Mutex
+	Entries map[string]Entry `json:"files"`
+}
+
+// New crée un état vide qui sera enregistré dans path
+func New(path string) *Manifest {
+	return &Manifest{path: path, Entries: make(map[string]Entry)}
+}
+
+// Load charge le fichier d'état ; un fichier absent donne un état vide
+func Load(path string) (*Manifest, error) {
+	m := New(path)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lire le fichier d'état : %v", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("fichier d'état invalide : %v", err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]Entry)
+	}
+	return m, nil
+}
+
+// Unchanged indique si le classeur n'a pas changé depuis sa dernière conversion
+// avec les mêmes options d'export et si ses PDF existent toujours. La taille et
+// la date de modification suffisent dans le cas courant ; si seule la date
+// diffère (copie, restauration), le contenu est comparé par son empreinte
+// SHA-256.
+func (m *Manifest) Unchanged(inputFile string, opts types.ExportOptions) (Entry, bool) {
+	m.mu.Lock()
+	entry, ok := m.Entries[inputFile]
+	m.mu.Unlock()
+	if !ok || entry.Export != exportKey(opts) {
+		return Entry{}, false
+	}
+
+	info, err := os.Stat(inputFile)
+	if err != nil || info.Size() != entry.Size {
+		return Entry{}, false
+	}
+	for _, pdf := range entry.PDFs() {
+		if _, err := os.Stat(pdf); err != nil {
+			return Entry{}, false
+		}
+	}
+	if info.ModTime().Equal(entry.ModTime) {
+		return entry, true
+	}
+
+	_, sum, err := helper.FileSHA256(inputFile)
+	if err != nil || sum != entry.SHA256 {
+		return Entry{}, false
+	}
+
+	// Contenu identique : la nouvelle date évite de recalculer l'empreinte
+	entry.ModTime = info.ModTime()
+	m.mu.Lock()
+	m.Entries[inputFile] = entry
+	m.mu.Unlock()
+	return entry, true
+}
+
+// Lookup renvoie l'état enregistré d'un classeur
+func (m *Manifest) Lookup(inputFile string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Entries[inputFile]
+	return entry, ok
+}
+
+// SHA256 renvoie l'empreinte du contenu d'un classeur : celle enregistrée si sa
+// taille et sa date de modification n'ont pas changé, sinon elle est calculée
+func (m *Manifest) SHA256(inputFile string) (string, error) {
+	info, err := os.Stat(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("impossible de lire le fichier %s : %v", filepath.Base(inputFile), err)
+	}
+	if entry, ok := m.Lookup(inputFile); ok && entry.SHA256 != "" && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return entry.SHA256, nil
+	}
+	_, sum, err := helper.FileSHA256(inputFile)
+	return sum, err
+}
+
+// WithSHA256 renvoie les classeurs convertis dont le contenu avait l'empreinte donnée
+func (m *Manifest) WithSHA256(sum string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []string
+	for file, entry := range m.Entries {
+		if entry.SHA256 == sum {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// PDFs renvoie les PDF produits par la dernière conversion
+func (e Entry) PDFs() []string {
+	if len(e.Outputs) == 0 {
+		return []string{e.PdfPath}
+	}
+	return e.Outputs
+}
+
+// exportKey renvoie la clé des options ; vide pour les options par défaut, comme
+// dans les fichiers d'état antérieurs à la sélection des feuilles
+func exportKey(opts types.ExportOptions) string {
+	if key := opts.Key(); key != (types.ExportOptions{}).Key() {
+		return key
+	}
+	return ""
+}
+
+// Record enregistre une conversion réussie
+func (m *Manifest) Record(result types.ProcessResult) error {
+	info, err := os.Stat(result.InputPath)
+	if err != nil {
+		return fmt.Errorf("impossible de lire la date du fichier %s : %v", result.FileName, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[result.InputPath] = Entry{
+		Size:        result.InputSize,
+		ModTime:     info.ModTime(),
+		SHA256:      result.InputSHA256,
+		PdfPath:     result.PdfPath,
+		Outputs:     result.Outputs,
+		Export:      exportKey(result.Export),
+		OutputSize:  result.OutputSize,
+		Pages:       result.Pages,
+		PDFA:        result.PDFA,
+		Backend:     result.Backend,
+		ConvertedAt: result.StartedAt,
+	}
+	return nil
+}
+
+// Save écrit le fichier d'état via un fichier temporaire, pour ne jamais laisser
+// un état tronqué en cas d'interruption
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("impossible d'encoder le fichier d'état : %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), FileName+".tmp*")
+	if err != nil {
+		return fmt.Errorf("impossible de créer le fichier d'état : %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("impossible d'écrire le fichier d'état : %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("impossible d'écrire le fichier d'état : %v", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("impossible de remplacer le fichier d'état : %v", err)
+	}
+	return nil
+}
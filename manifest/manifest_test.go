@@ -0,0 +1,179 @@
+package manifest
+
+import (
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// converted crée un classeur et son PDF, puis enregistre leur conversion
+func converted(t *testing.T, m *Manifest, opts types.ExportOptions) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "Client (2502).xls")
+	pdf := filepath.Join(dir, "Client (2502).pdf")
+	if err := os.WriteFile(input, []byte("contenu du classeur"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pdf, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	size, sum, err := helper.FileSHA256(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := types.ProcessResult{
+		FileName:    filepath.Base(input),
+		InputPath:   input,
+		PdfPath:     pdf,
+		Export:      opts,
+		InputSize:   size,
+		InputSHA256: sum,
+	}
+	if err := m.Record(result); err != nil {
+		t.Fatal(err)
+	}
+	return input, pdf
+}
+
+func TestUnchanged(t *testing.T) {
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, input, pdf string)
+		opts   types.ExportOptions
+		want   bool
+	}{
+		{"classeur inchangé", nil, types.ExportOptions{}, true},
+		{"options par défaut explicites", nil, types.ExportOptions{Sheets: types.SheetsAll, Profile: types.ProfileStandard}, true},
+		{"autres options d'export", nil, types.ExportOptions{PerSheet: true}, false},
+		{
+			name: "date modifiée, contenu identique",
+			change: func(t *testing.T, input, pdf string) {
+				if err := os.Chtimes(input, later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: true,
+		},
+		{
+			name: "contenu modifié, même taille",
+			change: func(t *testing.T, input, pdf string) {
+				if err := os.WriteFile(input, []byte("contenu du classeuR"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(input, later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: false,
+		},
+		{
+			name: "taille modifiée",
+			change: func(t *testing.T, input, pdf string) {
+				if err := os.WriteFile(input, []byte("nouveau contenu"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: false,
+		},
+		{
+			name: "PDF supprimé",
+			change: func(t *testing.T, input, pdf string) {
+				if err := os.Remove(pdf); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: false,
+		},
+		{
+			name: "classeur supprimé",
+			change: func(t *testing.T, input, pdf string) {
+				if err := os.Remove(input); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(filepath.Join(t.TempDir(), FileName))
+			input, pdf := converted(t, m, types.ExportOptions{})
+			if tt.change != nil {
+				tt.change(t, input, pdf)
+			}
+			if _, got := m.Unchanged(input, tt.opts); got != tt.want {
+				t.Errorf("Unchanged() = %v, attendu %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnchangedUnknownFile(t *testing.T) {
+	m := New(filepath.Join(t.TempDir(), FileName))
+	if _, ok := m.Unchanged(filepath.Join(t.TempDir(), "inconnu.xls"), types.ExportOptions{}); ok {
+		t.Error("classeur jamais converti considéré inchangé")
+	}
+}
+
+func TestUnchangedRefreshesModTime(t *testing.T) {
+	m := New(filepath.Join(t.TempDir(), FileName))
+	input, _ := converted(t, m, types.ExportOptions{})
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(input, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.Unchanged(input, types.ExportOptions{}); !ok {
+		t.Fatal("contenu identique considéré modifié")
+	}
+	// La nouvelle date est mémorisée : l'empreinte n'est plus recalculée
+	if entry, _ := m.Lookup(input); !entry.ModTime.Equal(later) {
+		t.Errorf("date = %v, attendu %v", entry.ModTime, later)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	m := New(path)
+	input, _ := converted(t, m, types.ExportOptions{PerSheet: true})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Unchanged(input, types.ExportOptions{PerSheet: true}); !ok {
+		t.Error("état rechargé : classeur considéré modifié")
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("fichiers laissés : %v", entries)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Load(filepath.Join(dir, FileName))
+	if err != nil || len(m.Entries) != 0 {
+		t.Fatalf("fichier absent : %v, %v", m, err)
+	}
+
+	path := filepath.Join(dir, "invalide.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("fichier d'état invalide accepté")
+	}
+}
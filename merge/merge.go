@@ -0,0 +1,213 @@
+package merge
+
+import (
+	"cmp"
+	"fmt"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Critères d'ordre des documents fusionnés
+const (
+	OrderName    = "nom"     // nom du classeur
+	OrderClient  = "client"  // nom du client, puis numéro de facture
+	OrderInvoice = "facture" // numéro de facture
+	OrderModTime = "date"    // date de modification du classeur
+)
+
+// Orders liste les critères d'ordre acceptés
+var Orders = []string{OrderName, OrderClient, OrderInvoice, OrderModTime}
+
+// DefaultFileName est le nom par défaut du document fusionné
+const DefaultFileName = "fusion.pdf"
+
+// Options paramètre la fusion
+type Options struct {
+	Order string // critère d'ordre, OrderName par défaut
+	TOC   bool   // ajouter une page de sommaire en tête du document
+	Title string // titre du document et du sommaire
+}
+
+// invoiceRe reconnaît les noms de classeurs "Client ( 2502 ) ..." : le client
+// précède le numéro de facture entre parenthèses
+var invoiceRe = regexp.MustCompile(`^\s*(.*?)\s*\(\s*(\d+)\s*\)`)
+
+func init() {
+	// pdfcpu n'écrit pas de configuration dans le dossier de l'utilisateur
+	api.DisableConfigDir()
+}
+
+// ValidOrder vérifie un critère d'ordre
+func ValidOrder(order string) error {
+	if !slices.Contains(Orders, order) {
+		return fmt.Errorf("ordre de fusion inconnu : %s (disponibles : %s)", order, strings.Join(Orders, ", "))
+	}
+	return nil
+}
+
+// entry est un document à fusionner et ses clés de tri
+type entry struct {
+	result  types.ProcessResult
+	title   string
+	client  string
+	invoice int // -1 si le nom du classeur n'en contient pas
+	modTime time.Time
+	pages   []int // pages de chaque PDF du classeur (plusieurs en export par feuille)
+}
+
+func newEntry(result types.ProcessResult) entry {
+	e := entry{
+		result:  result,
+		title:   strings.TrimSuffix(result.FileName, filepath.Ext(result.FileName)),
+		invoice: -1,
+	}
+	e.client = strings.ToLower(e.title)
+	if m := invoiceRe.FindStringSubmatch(e.title); m != nil {
+		e.client = strings.ToLower(m[1])
+		e.invoice, _ = strconv.Atoi(m[2])
+	}
+	if info, err := os.Stat(result.InputPath); err == nil {
+		e.modTime = info.ModTime()
+	} else if info, err := os.Stat(result.PDFs()[0]); err == nil {
+		e.modTime = info.ModTime()
+	}
+	return e
+}
+
+// totalPages renvoie le nombre de pages du classeur dans le document fusionné
+func (e entry) totalPages() int {
+	total := 0
+	for _, pages := range e.pages {
+		total += pages
+	}
+	return total
+}
+
+// sheetTitle renvoie le nom de la feuille d'un PDF <nom>_<feuille>.pdf
+func (e entry) sheetTitle(pdf string) string {
+	name := strings.TrimSuffix(filepath.Base(pdf), filepath.Ext(pdf))
+	return strings.TrimPrefix(name, filepath.Base(e.result.Export.OutputName(e.result.InputPath))+"_")
+}
+
+// compare ordonne deux documents selon le critère ; le nom du classeur départage
+// les égalités
+func compare(order string, a, b entry) int {
+	var c int
+	switch order {
+	case OrderClient:
+		c = cmp.Or(strings.Compare(a.client, b.client), cmpInvoice(a, b))
+	case OrderInvoice:
+		c = cmpInvoice(a, b)
+	case OrderModTime:
+		c = a.modTime.Compare(b.modTime)
+	}
+	return cmp.Or(c, strings.Compare(strings.ToLower(a.title), strings.ToLower(b.title)), strings.Compare(a.result.InputPath, b.result.InputPath))
+}
+
+// cmpInvoice place les classeurs sans numéro de facture à la fin
+func cmpInvoice(a, b entry) int {
+	switch {
+	case a.invoice == b.invoice:
+		return 0
+	case a.invoice < 0:
+		return 1
+	case b.invoice < 0:
+		return -1
+	}
+	return cmp.Compare(a.invoice, b.invoice)
+}
+
+// Merge assemble les PDF des résultats dans un document unique, avec un signet
+// par classeur et une page de sommaire facultative. Renvoie le nombre de pages.
+func Merge(outPath string, results []types.ProcessResult, opts Options) (int, error) {
+	if opts.Order == "" {
+		opts.Order = OrderName
+	}
+	if err := ValidOrder(opts.Order); err != nil {
+		return 0, err
+	}
+
+	var entries []entry
+	for _, result := range results {
+		if result.Err == nil && len(result.PDFs()) > 0 {
+			entries = append(entries, newEntry(result))
+		}
+	}
+	if len(entries) == 0 {
+		return 0, fmt.Errorf("aucun PDF à fusionner")
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return compare(opts.Order, a, b)
+	})
+
+	for i := range entries {
+		for _, pdf := range entries[i].result.PDFs() {
+			pages, err := api.PageCountFile(pdf)
+			if err != nil {
+				return 0, fmt.Errorf("PDF illisible %s : %v", filepath.Base(pdf), err)
+			}
+			entries[i].pages = append(entries[i].pages, pages)
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(outPath), ".fusion_")
+	if err != nil {
+		return 0, fmt.Errorf("impossible de créer le dossier temporaire : %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Le sommaire occupe les premières pages : les documents sont décalés d'autant
+	var inFiles []string
+	var bookmarks []pdfcpu.Bookmark
+	page := 1
+	if opts.TOC {
+		tocPath := filepath.Join(tmpDir, "sommaire.pdf")
+		tocPages, err := writeTOC(tocPath, opts.Title, entries)
+		if err != nil {
+			return 0, err
+		}
+		inFiles = append(inFiles, tocPath)
+		bookmarks = append(bookmarks, pdfcpu.Bookmark{Title: "Sommaire", PageFrom: page})
+		page += tocPages
+	}
+	for _, e := range entries {
+		bookmark := pdfcpu.Bookmark{Title: e.title, PageFrom: page}
+		pdfs := e.result.PDFs()
+		for i, pdf := range pdfs {
+			inFiles = append(inFiles, pdf)
+			// Export par feuille : un signet enfant par feuille
+			if len(pdfs) > 1 {
+				bookmark.Kids = append(bookmark.Kids, pdfcpu.Bookmark{Title: e.sheetTitle(pdf), PageFrom: page})
+			}
+			page += e.pages[i]
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	merged := filepath.Join(tmpDir, "fusion.pdf")
+	if err := api.MergeCreateFile(inFiles, merged, false, conf); err != nil {
+		return 0, fmt.Errorf("erreur lors de la fusion des PDF : %v", err)
+	}
+
+	// Écriture à côté de la destination puis renommage : un document incomplet ne
+	// remplace jamais le précédent
+	bookmarked := filepath.Join(tmpDir, "signets.pdf")
+	if err := api.AddBookmarksFile(merged, bookmarked, bookmarks, true, conf); err != nil {
+		return 0, fmt.Errorf("erreur lors de l'ajout des signets : %v", err)
+	}
+	if err := os.Rename(bookmarked, outPath); err != nil {
+		return 0, fmt.Errorf("impossible d'enregistrer le document fusionné : %v", err)
+	}
+	return page - 1, nil
+}
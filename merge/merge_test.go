@@ -0,0 +1,316 @@
+package merge
+
+import (
+	"fmt"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+var (
+	showTextRe = regexp.MustCompile(`\(((?:\\.|[^\\)])*)\)\s*Tj`)
+	unescaper  = strings.NewReplacer(`\(`, "(", `\)`, ")", `\\`, `\`)
+)
+
+// writePDF écrit un PDF du nombre de pages donné
+func writePDF(t *testing.T, path string, pages int) {
+	t.Helper()
+	doc := pdf.New()
+	for i := range pages {
+		doc.AddPage(595, 842).Text(pdf.Helvetica, 12, 50, 790, fmt.Sprintf("%s page %d", filepath.Base(path), i+1))
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := doc.Write(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// converted crée un classeur et ses PDF : un PDF de pages[0] pages, ou un PDF
+// par feuille si sheets est renseigné
+func converted(t *testing.T, dir, name string, modTime time.Time, pages []int, sheets ...string) types.ProcessResult {
+	t.Helper()
+	input := filepath.Join(dir, name)
+	if err := os.WriteFile(input, []byte("classeur"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(input, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	result := types.ProcessResult{FileName: name, InputPath: input}
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if len(sheets) == 0 {
+		result.PdfPath = filepath.Join(dir, base+".pdf")
+		writePDF(t, result.PdfPath, pages[0])
+		return result
+	}
+	result.Export.PerSheet = true
+	for i, sheet := range sheets {
+		path := filepath.Join(dir, base+"_"+sheet+".pdf")
+		writePDF(t, path, pages[i])
+		result.Outputs = append(result.Outputs, path)
+	}
+	result.PdfPath = result.Outputs[0]
+	return result
+}
+
+// bookmarks lit les signets du document fusionné
+func bookmarks(t *testing.T, path string) []pdfcpu.Bookmark {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	marks, err := api.Bookmarks(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return marks
+}
+
+// outline résume les signets : « titre:page », les enfants entre crochets
+func outline(marks []pdfcpu.Bookmark) []string {
+	var items []string
+	for _, m := range marks {
+		item := fmt.Sprintf("%s:%d", m.Title, m.PageFrom)
+		if len(m.Kids) > 0 {
+			item += fmt.Sprintf("%v", outline(m.Kids))
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// pageText renvoie les textes affichés sur une page du document
+func pageText(t *testing.T, path string, page int) []string {
+	t.Helper()
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := pdfcpu.ExtractPageContent(ctx, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, m := range showTextRe.FindAllSubmatch(content, -1) {
+		texts = append(texts, unescaper.Replace(string(m[1])))
+	}
+	return texts
+}
+
+func TestMergeOrder(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	results := []types.ProcessResult{
+		converted(t, dir, "Martin ( 2502 ) mars.xls", day.Add(48*time.Hour), []int{1}),
+		converted(t, dir, "Durand (2510).xlsx", day, []int{2}),
+		converted(t, dir, "Avoir divers.xls", day.Add(24*time.Hour), []int{1}),
+		converted(t, dir, "durand ( 2490 ) relance.xls", day.Add(72*time.Hour), []int{1}),
+		converted(t, dir, "Durand (2480) avoir.xls", day.Add(96*time.Hour), []int{1}),
+		{FileName: "Erreur.xls", InputPath: filepath.Join(dir, "Erreur.xls"), Err: fmt.Errorf("classeur illisible")},
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"", []string{"Avoir divers:1", "durand ( 2490 ) relance:2", "Durand (2480) avoir:3", "Durand (2510):4", "Martin ( 2502 ) mars:6"}},
+		{OrderName, []string{"Avoir divers:1", "durand ( 2490 ) relance:2", "Durand (2480) avoir:3", "Durand (2510):4", "Martin ( 2502 ) mars:6"}},
+		{OrderClient, []string{"Avoir divers:1", "Durand (2480) avoir:2", "durand ( 2490 ) relance:3", "Durand (2510):4", "Martin ( 2502 ) mars:6"}},
+		{OrderInvoice, []string{"Durand (2480) avoir:1", "durand ( 2490 ) relance:2", "Martin ( 2502 ) mars:3", "Durand (2510):4", "Avoir divers:6"}},
+		{OrderModTime, []string{"Durand (2510):1", "Avoir divers:3", "Martin ( 2502 ) mars:4", "durand ( 2490 ) relance:5", "Durand (2480) avoir:6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), DefaultFileName)
+			pages, err := Merge(out, results, Options{Order: tt.order})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pages != 6 {
+				t.Errorf("Merge() = %d pages, attendu 6", pages)
+			}
+			if n, err := api.PageCountFile(out); err != nil || n != 6 {
+				t.Errorf("document fusionné : %d pages, %v", n, err)
+			}
+			if got := outline(bookmarks(t, out)); !slices.Equal(got, tt.want) {
+				t.Errorf("signets = %v\nattendu %v", got, tt.want)
+			}
+			// Le document commence par le premier classeur dans l'ordre demandé
+			first := strings.SplitN(tt.want[0], ":", 2)[0]
+			if text := pageText(t, out, 1); len(text) == 0 || !strings.HasPrefix(text[0], first) {
+				t.Errorf("première page = %v, attendu %s", text, first)
+			}
+		})
+	}
+}
+
+func TestMergeTOC(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	results := []types.ProcessResult{
+		converted(t, dir, "Durand (2510).xlsx", day, []int{2}),
+		converted(t, dir, "Martin (2502).xlsx", day, []int{1, 2}, "Facture", "Détail"),
+		converted(t, dir, "Petit (2520).xls", day, []int{1}),
+	}
+	out := filepath.Join(t.TempDir(), DefaultFileName)
+	pages, err := Merge(out, results, Options{TOC: true, Title: "Factures de mars"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != 7 {
+		t.Errorf("Merge() = %d pages, attendu 7", pages)
+	}
+
+	// Sommaire en page 1, un signet enfant par feuille
+	want := []string{
+		"Sommaire:1",
+		"Durand (2510):2",
+		"Martin (2502):4[Facture:4 Détail:5]",
+		"Petit (2520):7",
+	}
+	if got := outline(bookmarks(t, out)); !slices.Equal(got, want) {
+		t.Errorf("signets = %v\nattendu %v", got, want)
+	}
+
+	// Le sommaire donne la page de début de chaque classeur
+	var lines []string
+	for _, text := range pageText(t, out, 1) {
+		if !strings.HasPrefix(text, ". ") {
+			lines = append(lines, text)
+		}
+	}
+	wantLines := []string{"Factures de mars", "Durand (2510)", "2", "Martin (2502)", "4", "Petit (2520)", "7"}
+	if !slices.Equal(lines, wantLines) {
+		t.Errorf("sommaire = %q\nattendu %q", lines, wantLines)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("fichiers laissés : %v", entries)
+	}
+}
+
+func TestMergeLongTOC(t *testing.T) {
+	first, _ := tocLines()
+	dir := t.TempDir()
+	day := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	var results []types.ProcessResult
+	for i := range first + 1 {
+		results = append(results, converted(t, dir, fmt.Sprintf("Client (%d).xls", 1000+i), day, []int{1}))
+	}
+
+	// Un classeur de plus que la première page n'en contient : deux pages de
+	// sommaire, qui décalent tous les documents
+	out := filepath.Join(t.TempDir(), DefaultFileName)
+	pages, err := Merge(out, results, Options{TOC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != first+3 {
+		t.Errorf("Merge() = %d pages, attendu %d", pages, first+3)
+	}
+	marks := bookmarks(t, out)
+	if got := outline(marks[:2]); !slices.Equal(got, []string{"Sommaire:1", "Client (1000):3"}) {
+		t.Errorf("signets = %v", got)
+	}
+	last := pageText(t, out, 2)
+	if !slices.Contains(last, fmt.Sprintf("Client (%d)", 1000+first)) || !slices.Contains(last, strconv.Itoa(first+3)) {
+		t.Errorf("seconde page du sommaire = %q", last)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, DefaultFileName)
+	failed := []types.ProcessResult{{FileName: "Erreur.xls", Err: fmt.Errorf("classeur illisible")}}
+	if _, err := Merge(out, failed, Options{}); err == nil || !strings.Contains(err.Error(), "aucun PDF") {
+		t.Errorf("Merge() = %v, attendu aucun PDF à fusionner", err)
+	}
+
+	valid := []types.ProcessResult{converted(t, dir, "Client.xls", time.Now(), []int{1})}
+	if _, err := Merge(out, valid, Options{Order: "taille"}); err == nil || !strings.Contains(err.Error(), "ordre de fusion inconnu") {
+		t.Errorf("Merge() = %v, attendu ordre inconnu", err)
+	}
+
+	corrupted := filepath.Join(dir, "Corrompu.pdf")
+	if err := os.WriteFile(corrupted, []byte("%PDF-1.4 tronqué"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := append(valid, types.ProcessResult{FileName: "Corrompu.xls", PdfPath: corrupted})
+	if _, err := Merge(out, invalid, Options{}); err == nil || !strings.Contains(err.Error(), "Corrompu.pdf") {
+		t.Errorf("Merge() = %v, attendu PDF illisible", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("document fusionné créé malgré l'erreur : %v", err)
+	}
+}
+
+func TestTOCPageCount(t *testing.T) {
+	first, other := tocLines()
+	tests := []struct {
+		entries, want int
+	}{
+		{0, 1},
+		{1, 1},
+		{first, 1},
+		{first + 1, 2},
+		{first + other, 2},
+		{first + other + 1, 3},
+	}
+	for _, tt := range tests {
+		if got := tocPageCount(tt.entries); got != tt.want {
+			t.Errorf("tocPageCount(%d) = %d, attendu %d", tt.entries, got, tt.want)
+		}
+	}
+}
+
+func TestFitText(t *testing.T) {
+	short := "Durand (2510)"
+	if got := fitText(short, 200); got != short {
+		t.Errorf("fitText(%q) = %q", short, got)
+	}
+	long := strings.Repeat("Société Fredon ", 10)
+	got := fitText(long, 200)
+	if !strings.HasSuffix(got, "...") || !strings.HasPrefix(long, strings.TrimSuffix(got, "...")) {
+		t.Errorf("fitText() = %q", got)
+	}
+	if width := pdf.Helvetica.Width(got, tocFontSize); width > 200 {
+		t.Errorf("largeur %.1f, maximum 200", width)
+	}
+}
+
+func TestValidOrder(t *testing.T) {
+	for _, order := range Orders {
+		if err := ValidOrder(order); err != nil {
+			t.Errorf("ValidOrder(%q) = %v", order, err)
+		}
+	}
+	if err := ValidOrder("Nom"); err == nil {
+		t.Error("ordre inconnu accepté")
+	}
+}
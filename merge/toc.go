@@ -0,0 +1,103 @@
+package merge
+
+import (
+	"fmt"
+	"fredon_to_pdf/pdf"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mise en page du sommaire : A4 portrait, en points
+const (
+	tocWidth      = 595.28
+	tocHeight     = 841.89
+	tocMargin     = 56.0
+	tocTitleSize  = 18.0
+	tocTitleSpace = 40.0 // hauteur réservée au titre sur la première page
+	tocFontSize   = 10.0
+	tocLeading    = 16.0
+)
+
+// tocLines renvoie le nombre de lignes de sommaire de la première page et des suivantes
+func tocLines() (first, other int) {
+	usable := tocHeight - 2*tocMargin
+	return int((usable - tocTitleSpace) / tocLeading), int(usable / tocLeading)
+}
+
+// tocPageCount renvoie le nombre de pages nécessaires au sommaire
+func tocPageCount(entries int) int {
+	first, other := tocLines()
+	if entries <= first {
+		return 1
+	}
+	return 1 + (entries-first+other-1)/other
+}
+
+// writeTOC écrit le sommaire : une ligne par classeur avec la page où commence
+// son document. Renvoie le nombre de pages du sommaire.
+func writeTOC(path, title string, entries []entry) (int, error) {
+	if title == "" {
+		title = "Sommaire"
+	}
+	pages := tocPageCount(len(entries))
+	first, other := tocLines()
+
+	doc := pdf.New()
+	doc.Title = title
+	page := doc.AddPage(tocWidth, tocHeight)
+	page.Text(pdf.HelveticaBold, tocTitleSize, tocMargin, tocHeight-tocMargin-tocTitleSize, title)
+	y := tocHeight - tocMargin - tocTitleSpace
+	remaining := first
+
+	start := pages + 1
+	for _, e := range entries {
+		if remaining == 0 {
+			page = doc.AddPage(tocWidth, tocHeight)
+			y = tocHeight - tocMargin
+			remaining = other
+		}
+		y -= tocLeading
+		remaining--
+
+		number := strconv.Itoa(start)
+		numberWidth := pdf.Helvetica.Width(number, tocFontSize)
+		label := fitText(e.title, tocWidth-2*tocMargin-numberWidth-20)
+		page.Text(pdf.Helvetica, tocFontSize, tocMargin, y, label)
+		page.Text(pdf.Helvetica, tocFontSize, tocWidth-tocMargin-numberWidth, y, number)
+
+		// Points de conduite entre le libellé et le numéro de page
+		leaderStart := tocMargin + pdf.Helvetica.Width(label, tocFontSize) + 6
+		leaderEnd := tocWidth - tocMargin - numberWidth - 6
+		dotWidth := pdf.Helvetica.Width(". ", tocFontSize)
+		if n := int((leaderEnd - leaderStart) / dotWidth); n > 0 {
+			page.Text(pdf.Helvetica, tocFontSize, leaderEnd-float64(n)*dotWidth, y, strings.Repeat(". ", n))
+		}
+		start += e.totalPages()
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("impossible de créer le sommaire : %v", err)
+	}
+	if err := doc.Write(file); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("impossible d'écrire le sommaire : %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("impossible d'écrire le sommaire : %v", err)
+	}
+	return doc.PageCount(), nil
+}
+
+// fitText tronque un libellé trop long pour la largeur disponible
+func fitText(s string, width float64) string {
+	if pdf.Helvetica.Width(s, tocFontSize) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.Helvetica.Width(string(runes)+"...", tocFontSize) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,147 @@
+// Package naming extrait les informations contenues dans le nom des classeurs
+// (client, numéro de facture...) et construit le nom des PDF selon un modèle
+// tel que "{year}/{client}/{number}_{client}".
+package naming
+
+import (
+	"fmt"
+	"fredon_to_pdf/helper"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultPattern reconnaît les noms de classeurs Fredon, "Andrieux V ( 2502 ) (1)" :
+// client, initiale facultative, numéro de facture ou de période entre
+// parenthèses, puis compteur de copie facultatif
+const DefaultPattern = `^(?P<client>.+?)(?:\s+(?P<initial>\p{Lu})\.?)?\s*\(\s*(?P<number>\d+)\s*\)(?:\s*\(\s*(?P<counter>\d+)\s*\))?\s*$`
+
+// DefaultTemplate conserve le nom du classeur et l'arborescence du dossier source
+const DefaultTemplate = "{dir}/{name}"
+
+// Champs toujours disponibles dans un modèle ; un groupe nommé du motif portant
+// le même nom les remplace
+var builtins = []string{
+	"name",  // nom du classeur, sans extension
+	"dir",   // sous-dossier du classeur, relatif au dossier source
+	"year",  // année de modification du classeur
+	"month", // mois de modification (01 à 12)
+	"day",   // jour de modification (01 à 31)
+}
+
+var placeholderRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Namer construit le nom des PDF à partir du nom des classeurs
+type Namer struct {
+	pattern  *regexp.Regexp
+	template string
+	fields   []string // champs utilisés par le modèle et extraits par le motif
+}
+
+// New compile le motif d'extraction et vérifie que le modèle n'utilise que des
+// champs connus : groupes nommés du motif ou champs prédéfinis
+func New(pattern, template string) (*Namer, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("motif de nom de fichier invalide : %v", err)
+	}
+	if strings.TrimSpace(template) == "" {
+		return nil, fmt.Errorf("modèle de nom de PDF vide")
+	}
+	if strings.Count(template, "{") != strings.Count(template, "}") {
+		return nil, fmt.Errorf("modèle de nom de PDF invalide : accolades non appariées (%s)", template)
+	}
+
+	// Les dossiers du modèle peuvent être séparés par des "\" sous Windows
+	template = strings.ReplaceAll(template, `\`, "/")
+
+	groups := re.SubexpNames()
+	n := &Namer{pattern: re, template: template}
+	for _, m := range placeholderRe.FindAllStringSubmatch(template, -1) {
+		field := m[1]
+		switch {
+		case slices.Contains(groups[1:], field):
+			n.fields = append(n.fields, field)
+		case !slices.Contains(builtins, field):
+			known := append(slices.DeleteFunc(slices.Clone(groups[1:]), func(g string) bool { return g == "" }), builtins...)
+			return nil, fmt.Errorf("champ inconnu dans le modèle de nom de PDF : {%s} (disponibles : %s)", field, strings.Join(known, ", "))
+		}
+	}
+	return n, nil
+}
+
+// Fields renvoie les champs d'un classeur, dir étant son sous-dossier dans le
+// dossier source : les champs prédéfinis, complétés des groupes nommés du motif
+// si le nom du classeur y correspond
+func (n *Namer) Fields(file, dir string) (fields map[string]string, matched bool) {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	fields = map[string]string{
+		"name": name,
+		"dir":  filepath.ToSlash(dir),
+	}
+	if info, err := os.Stat(file); err == nil {
+		modTime := info.ModTime()
+		fields["year"] = modTime.Format("2006")
+		fields["month"] = modTime.Format("01")
+		fields["day"] = modTime.Format("02")
+	}
+
+	m := n.pattern.FindStringSubmatch(name)
+	if m == nil {
+		return fields, false
+	}
+	for i, group := range n.pattern.SubexpNames() {
+		// Un groupe facultatif absent ne remplace pas un champ prédéfini
+		if group != "" && (m[i] != "" || fields[group] == "") {
+			fields[group] = strings.TrimSpace(m[i])
+		}
+	}
+	return fields, true
+}
+
+// Name renvoie le chemin du PDF d'un classeur, relatif au dossier de sortie, sans
+// extension et avec des "/" pour séparateurs. Un classeur dont le nom ne
+// correspond pas au motif garde son nom et son sous-dossier si le modèle utilise
+// des champs du motif.
+func (n *Namer) Name(file, dir string) string {
+	fields, matched := n.Fields(file, dir)
+	if !matched && len(n.fields) > 0 {
+		return render(DefaultTemplate, fields)
+	}
+	return render(n.template, fields)
+}
+
+// render remplace les champs du modèle puis nettoie chaque élément du chemin : les
+// éléments vides sont supprimés et les caractères interdits remplacés
+func render(template string, fields map[string]string) string {
+	var segments []string
+	for _, segment := range strings.Split(template, "/") {
+		// Le champ {dir} peut contenir plusieurs dossiers
+		if segment == "{dir}" {
+			for _, d := range strings.Split(fields["dir"], "/") {
+				segments = appendSegment(segments, d)
+			}
+			continue
+		}
+		value := placeholderRe.ReplaceAllStringFunc(segment, func(p string) string {
+			return helper.SanitizeFilename(fields[p[1:len(p)-1]])
+		})
+		segments = appendSegment(segments, value)
+	}
+	if len(segments) == 0 {
+		return helper.SanitizeFilename(fields["name"])
+	}
+	return strings.Join(segments, "/")
+}
+
+// appendSegment ajoute un élément de chemin ; Windows refuse les noms terminés
+// par un point ou une espace
+func appendSegment(segments []string, segment string) []string {
+	segment = strings.TrimSpace(strings.TrimRight(helper.SanitizeFilename(segment), ". "))
+	if segment == "" {
+		return segments
+	}
+	return append(segments, segment)
+}
@@ -0,0 +1,162 @@
+package naming
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		template string
+		wantErr  string
+	}{
+		{"modèle par défaut", DefaultPattern, DefaultTemplate, ""},
+		{"champs du motif", DefaultPattern, "{year}/{client}/{number}_{client}", ""},
+		{"motif invalide", `(?P<client>`, DefaultTemplate, "motif de nom de fichier invalide"},
+		{"modèle vide", DefaultPattern, "  ", "modèle de nom de PDF vide"},
+		{"accolades non appariées", DefaultPattern, "{client", "accolades non appariées"},
+		{"champ inconnu", DefaultPattern, "{client}/{facture}", "champ inconnu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.pattern, tt.template)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("erreur = %v, attendu %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2025, 3, 7, 10, 0, 0, 0, time.Local)
+	file := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		template string
+		file     string
+		dir      string
+		want     string
+	}{
+		{
+			name:     "modèle par défaut",
+			template: DefaultTemplate,
+			file:     "Andrieux V ( 2502 ) (1).xls",
+			dir:      "2025/mars",
+			want:     "2025/mars/Andrieux V ( 2502 ) (1)",
+		},
+		{
+			name:     "champs du motif et de la date",
+			template: "{year}/{client}/{number}_{client}_{month}{day}",
+			file:     "Andrieux V ( 2502 ) (1).xls",
+			want:     "2025/Andrieux/2502_Andrieux_0307",
+		},
+		{
+			name:     "classeur hors motif",
+			template: "{client}/{number}",
+			file:     "Relevé.xls",
+			dir:      "divers",
+			want:     "divers/Relevé",
+		},
+		{
+			name:     "caractères interdits",
+			pattern:  `^(?P<client>.+) - (?P<number>\d+)$`,
+			template: "{client}/{number}",
+			file:     "Dupont: SARL? - 12.xls",
+			want:     "Dupont_ SARL_/12",
+		},
+		{
+			name:     "remontée de dossier supprimée",
+			pattern:  `^(?P<client>[^_]+)_(?P<number>\d+)$`,
+			template: "{client}/../{number}",
+			file:     ".._42.xls",
+			want:     "42",
+		},
+		{
+			name:     "dossier source avec remontée",
+			template: DefaultTemplate,
+			file:     "Relevé.xls",
+			dir:      "../..",
+			want:     "Relevé",
+		},
+		{
+			name:     "points et espaces finaux retirés",
+			pattern:  `^(?P<client>.+)_(?P<number>\d+)$`,
+			template: "{client}/{number}",
+			file:     "Martin . _7.xls",
+			want:     "Martin/7",
+		},
+		{
+			name:     "modèle entièrement vide",
+			pattern:  `^(?P<client>.*)_(?P<number>\d+)$`,
+			template: "{client}",
+			file:     "_7.xls",
+			want:     "_7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern := tt.pattern
+			if pattern == "" {
+				pattern = DefaultPattern
+			}
+			namer, err := New(pattern, tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := namer.Name(file(tt.file), tt.dir); got != tt.want {
+				t.Errorf("Name() = %q, attendu %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	namer, err := New(DefaultPattern, DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file    string
+		matched bool
+		want    map[string]string
+	}{
+		{"Andrieux V ( 2502 ) (1).xls", true, map[string]string{"client": "Andrieux", "initial": "V", "number": "2502", "counter": "1"}},
+		{"Bernard (2503).xlsx", true, map[string]string{"client": "Bernard", "initial": "", "number": "2503", "counter": ""}},
+		{"Relevé.xls", false, map[string]string{"name": "Relevé"}},
+	}
+	for _, tt := range tests {
+		fields, matched := namer.Fields(tt.file, "")
+		if matched != tt.matched {
+			t.Errorf("%s : correspondance = %v, attendu %v", tt.file, matched, tt.matched)
+		}
+		for k, v := range tt.want {
+			if fields[k] != v {
+				t.Errorf("%s : {%s} = %q, attendu %q", tt.file, k, fields[k], v)
+			}
+		}
+	}
+}
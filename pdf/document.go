@@ -0,0 +1,147 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const producer = "FredonToPDF"
+
+// Document est un document PDF construit page par page
+type Document struct {
+	Title string
+	pages []*Page
+}
+
+// Page est une page du document ; les coordonnées sont en points,
+// origine en bas à gauche comme dans le modèle PDF
+type Page struct {
+	Width, Height float64
+	content       bytes.Buffer
+	fonts         map[Font]bool
+}
+
+// New crée un document vide
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage ajoute une page aux dimensions données
+func (d *Document) AddPage(width, height float64) *Page {
+	p := &Page{Width: width, Height: height, fonts: make(map[Font]bool)}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// PageCount renvoie le nombre de pages du document
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// SetFillColor définit la couleur de remplissage (et du texte)
+func (p *Page) SetFillColor(r, g, b uint8) {
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", num(float64(r)/255), num(float64(g)/255), num(float64(b)/255))
+}
+
+// SetStrokeColor définit la couleur des traits
+func (p *Page) SetStrokeColor(r, g, b uint8) {
+	fmt.Fprintf(&p.content, "%s %s %s RG\n", num(float64(r)/255), num(float64(g)/255), num(float64(b)/255))
+}
+
+// SetLineWidth définit l'épaisseur des traits
+func (p *Page) SetLineWidth(w float64) {
+	fmt.Fprintf(&p.content, "%s w\n", num(w))
+}
+
+// SetDash définit un motif de pointillés ; on = 0 rétablit un trait plein
+func (p *Page) SetDash(on, off float64) {
+	if on == 0 {
+		p.content.WriteString("[] 0 d\n")
+		return
+	}
+	fmt.Fprintf(&p.content, "[%s %s] 0 d\n", num(on), num(off))
+}
+
+// FillRect remplit un rectangle avec la couleur de remplissage courante
+func (p *Page) FillRect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(y), num(w), num(h))
+}
+
+// Line trace un segment avec la couleur et l'épaisseur courantes
+func (p *Page) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "%s %s m %s %s l S\n", num(x1), num(y1), num(x2), num(y2))
+}
+
+// PushClip sauvegarde l'état graphique et restreint le dessin au rectangle donné
+func (p *Page) PushClip(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "q %s %s %s %s re W n\n", num(x), num(y), num(w), num(h))
+}
+
+// PopClip restaure l'état graphique sauvegardé par PushClip
+func (p *Page) PopClip() {
+	p.content.WriteString("Q\n")
+}
+
+// Text écrit un texte dont la ligne de base commence en (x, y)
+func (p *Page) Text(font Font, size, x, y float64, s string) {
+	p.fonts[font] = true
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		int(font)+1, num(size), num(x), num(y), escapeString(string(encodeWinAnsi(s))))
+}
+
+// Write sérialise le document au format PDF
+func (d *Document) Write(w io.Writer) error {
+	if len(d.pages) == 0 {
+		return fmt.Errorf("le document ne contient aucune page")
+	}
+
+	var b builder
+	catalog := b.reserve()
+	pagesNum := b.reserve()
+
+	// Polices réellement utilisées, partagées par toutes les pages
+	used := make(map[Font]bool)
+	for _, p := range d.pages {
+		for f := range p.fonts {
+			used[f] = true
+		}
+	}
+	var fonts []Font
+	for f := range used {
+		fonts = append(fonts, f)
+	}
+	sort.Slice(fonts, func(i, j int) bool { return fonts[i] < fonts[j] })
+	fontRefs := make(map[Font]int)
+	for _, f := range fonts {
+		fontRefs[f] = b.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[f])))
+	}
+
+	var kids []string
+	for _, p := range d.pages {
+		content := b.addStream(p.content.Bytes(), "")
+
+		var res strings.Builder
+		for _, f := range fonts {
+			if p.fonts[f] {
+				fmt.Fprintf(&res, " /F%d %d 0 R", int(f)+1, fontRefs[f])
+			}
+		}
+		page := b.add([]byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font <<%s >> >> /Contents %d 0 R >>",
+			pagesNum, num(p.Width), num(p.Height), res.String(), content)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+
+	b.set(pagesNum, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))))
+	b.set(catalog, []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesNum)))
+
+	info := fmt.Sprintf("<< /Producer %s", textString(producer))
+	if d.Title != "" {
+		info += " /Title " + textString(d.Title)
+	}
+	infoNum := b.add([]byte(info + " >>"))
+
+	return b.write(w, catalog, infoNum)
+}
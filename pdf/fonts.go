@@ -0,0 +1,129 @@
+package pdf
+
+// Font désigne l'une des polices standard Helvetica, disponibles dans tous les lecteurs PDF
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	HelveticaOblique
+	HelveticaBoldOblique
+)
+
+var fontNames = [...]string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"}
+
+// FontFor renvoie la variante d'Helvetica correspondant aux attributs demandés
+func FontFor(bold, italic bool) Font {
+	switch {
+	case bold && italic:
+		return HelveticaBoldOblique
+	case bold:
+		return HelveticaBold
+	case italic:
+		return HelveticaOblique
+	}
+	return Helvetica
+}
+
+// Chasses des caractères ASCII 32 à 126 (métriques AFM, unité 1/1000 em)
+var helveticaASCII = [95]uint16{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldASCII = [95]uint16{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Chasses des caractères WinAnsi hors ASCII qui ne dérivent pas d'une lettre de base
+var extendedWidths = map[byte]uint16{
+	0x80: 556, 0x82: 222, 0x84: 333, 0x85: 1000, 0x86: 556, 0x87: 556, 0x89: 1000,
+	0x8C: 1000, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333, 0x95: 350, 0x96: 556,
+	0x97: 1000, 0x99: 1000, 0x9C: 944, 0xA0: 278, 0xA7: 556, 0xA9: 737, 0xAB: 556,
+	0xAE: 737, 0xB0: 400, 0xB2: 333, 0xB3: 333, 0xB5: 556, 0xBB: 556, 0xBD: 834,
+	0xC6: 1000, 0xD7: 584, 0xDF: 611, 0xE6: 889, 0xF7: 584,
+}
+
+// Lettre de base utilisée pour estimer la chasse des lettres accentuées
+var accentBase = map[byte]byte{}
+
+func init() {
+	bases := map[byte]string{
+		'A': "\xC0\xC1\xC2\xC3\xC4\xC5", 'C': "\xC7", 'E': "\xC8\xC9\xCA\xCB", 'I': "\xCC\xCD\xCE\xCF",
+		'N': "\xD1", 'O': "\xD2\xD3\xD4\xD5\xD6\xD8", 'U': "\xD9\xDA\xDB\xDC", 'Y': "\xDD\x9F",
+		'S': "\x8A", 'Z': "\x8E", 'a': "\xE0\xE1\xE2\xE3\xE4\xE5", 'c': "\xE7", 'e': "\xE8\xE9\xEA\xEB",
+		'i': "\xEC\xED\xEE\xEF", 'n': "\xF1", 'o': "\xF2\xF3\xF4\xF5\xF6\xF8", 'u': "\xF9\xFA\xFB\xFC",
+		'y': "\xFD\xFF", 's': "\x9A", 'z': "\x9E",
+	}
+	for base, accented := range bases {
+		for i := 0; i < len(accented); i++ {
+			accentBase[accented[i]] = base
+		}
+	}
+}
+
+// charWidth renvoie la chasse d'un octet WinAnsi en 1/1000 em
+func (f Font) charWidth(c byte) uint16 {
+	table := &helveticaASCII
+	if f == HelveticaBold || f == HelveticaBoldOblique {
+		table = &helveticaBoldASCII
+	}
+	if base, ok := accentBase[c]; ok {
+		c = base
+	}
+	if c >= 32 && c <= 126 {
+		return table[c-32]
+	}
+	if w, ok := extendedWidths[c]; ok {
+		return w
+	}
+	return 556
+}
+
+// Width renvoie la largeur d'un texte en points pour la taille donnée
+func (f Font) Width(s string, size float64) float64 {
+	total := 0
+	for _, c := range encodeWinAnsi(s) {
+		total += int(f.charWidth(c))
+	}
+	return float64(total) * size / 1000
+}
+
+// Correspondance des caractères Unicode spécifiques à la plage 0x80-0x9F de WinAnsi
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F, ' ': 0xA0,
+}
+
+// encodeWinAnsi convertit une chaîne UTF-8 en WinAnsiEncoding ('?' pour les caractères absents)
+func encodeWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r < 0x20:
+			// Caractères de contrôle ignorés
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiSpecials[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
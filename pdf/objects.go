@@ -0,0 +1,136 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// builder accumule des objets indirects numérotés puis les sérialise
+// avec leur table de références croisées
+type builder struct {
+	objects [][]byte
+}
+
+// reserve réserve un numéro d'objet dont le contenu sera fourni plus tard
+func (b *builder) reserve() int {
+	b.objects = append(b.objects, nil)
+	return len(b.objects)
+}
+
+// set définit le contenu d'un objet réservé
+func (b *builder) set(num int, body []byte) {
+	b.objects[num-1] = body
+}
+
+// add ajoute un objet et renvoie son numéro
+func (b *builder) add(body []byte) int {
+	num := b.reserve()
+	b.set(num, body)
+	return num
+}
+
+// addStream ajoute un flux compressé, avec d'éventuelles entrées de dictionnaire supplémentaires
+func (b *builder) addStream(data []byte, extra string) int {
+	return b.add(streamObject(data, extra, true))
+}
+
+func streamObject(data []byte, extra string, compress bool) []byte {
+	filter := ""
+	if compress {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		data = buf.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+	var obj bytes.Buffer
+	fmt.Fprintf(&obj, "<< /Length %d%s%s >>\nstream\n", len(data), filter, extra)
+	obj.Write(data)
+	obj.WriteString("\nendstream")
+	return obj.Bytes()
+}
+
+// write sérialise le document complet : en-tête, objets, xref et trailer
+func (b *builder) write(w io.Writer, root, info int) error {
+	bw := bufio.NewWriter(w)
+	var offset int64
+	out := func(s string) {
+		n, _ := bw.WriteString(s)
+		offset += int64(n)
+	}
+
+	// Identifiant déterministe calculé à partir du contenu
+	hash := md5.New()
+	for _, obj := range b.objects {
+		hash.Write(obj)
+	}
+	id := fmt.Sprintf("%X", hash.Sum(nil))
+
+	out("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int64, len(b.objects))
+	for i, obj := range b.objects {
+		if obj == nil {
+			return fmt.Errorf("objet %d réservé mais jamais défini", i+1)
+		}
+		offsets[i] = offset
+		out(fmt.Sprintf("%d 0 obj\n", i+1))
+		n, _ := bw.Write(obj)
+		offset += int64(n)
+		out("\nendobj\n")
+	}
+
+	xref := offset
+	var table strings.Builder
+	fmt.Fprintf(&table, "xref\n0 %d\n0000000000 65535 f \n", len(b.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&table, "%010d 00000 n \n", off)
+	}
+	out(table.String())
+
+	trailer := fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R", len(b.objects)+1, root)
+	if info > 0 {
+		trailer += fmt.Sprintf(" /Info %d 0 R", info)
+	}
+	trailer += fmt.Sprintf(" /ID [<%s> <%s>] >>\nstartxref\n%d\n%%%%EOF\n", id, id, xref)
+	out(trailer)
+
+	return bw.Flush()
+}
+
+// escapeString échappe une chaîne littérale PDF
+func escapeString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// textString encode une chaîne de métadonnées en UTF-16BE avec BOM
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, r := range s {
+		if r > 0xFFFF {
+			r -= 0x10000
+			fmt.Fprintf(&b, "%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			continue
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// num formate un nombre réel de façon compacte
+func num(v float64) string {
+	s := fmt.Sprintf("%.3f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
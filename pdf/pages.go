@@ -0,0 +1,74 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+var (
+	// /Type /Page, sans correspondre à /Type /Pages
+	pageTypeRe = regexp.MustCompile(`/Type\s*/Page\b`)
+	streamRe   = regexp.MustCompile(`stream\r?\n`)
+)
+
+// PageCountFile compte les pages d'un fichier PDF
+func PageCountFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("impossible de lire le PDF : %v", err)
+	}
+	return PageCount(data)
+}
+
+// CheckFile vérifie qu'un fichier PDF est complet : en-tête, marque de fin %%EOF
+// et au moins une page. Le nombre de pages est renvoyé.
+func CheckFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("impossible de lire le PDF : %v", err)
+	}
+	// La marque de fin peut être suivie de quelques octets (fin de ligne, zéros)
+	tail := data[max(0, len(data)-1024):]
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return 0, fmt.Errorf("PDF tronqué : marque de fin %%%%EOF absente")
+	}
+	return PageCount(data)
+}
+
+// PageCount compte les pages d'un PDF en dénombrant ses objets /Type /Page,
+// y compris ceux rangés dans des flux d'objets compressés (PDF 1.5 et plus),
+// sans analyser la structure complète du document
+func PageCount(data []byte) (int, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return 0, fmt.Errorf("en-tête PDF absent")
+	}
+
+	count := len(pageTypeRe.FindAllIndex(data, -1))
+	for _, loc := range streamRe.FindAllIndex(data, -1) {
+		// Le dictionnaire du flux se trouve entre "obj" et "stream"
+		dictStart := bytes.LastIndex(data[:loc[0]], []byte("obj"))
+		if dictStart < 0 || !bytes.Contains(data[dictStart:loc[0]], []byte("/ObjStm")) {
+			continue
+		}
+		end := bytes.Index(data[loc[1]:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data[loc[1] : loc[1]+end]))
+		if err != nil {
+			continue
+		}
+		objects, _ := io.ReadAll(zr)
+		zr.Close()
+		count += len(pageTypeRe.FindAllIndex(objects, -1))
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("aucune page trouvée")
+	}
+	return count, nil
+}
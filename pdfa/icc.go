@@ -0,0 +1,136 @@
+package pdfa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Profil de sortie déclaré dans l'OutputIntent
+const (
+	outputCondition = "sRGB IEC61966-2.1"
+	iccComponents   = 3
+)
+
+// Primaires sRGB adaptées à l'illuminant D50 (Bradford), colonnes X, Y, Z
+var (
+	d50         = [3]float64{0.9642, 1.0, 0.8249}
+	sRGBRed     = [3]float64{0.4361, 0.2225, 0.0139}
+	sRGBGreen   = [3]float64{0.3851, 0.7169, 0.0971}
+	sRGBBlue    = [3]float64{0.1431, 0.0606, 0.7141}
+	curvePoints = 1024
+)
+
+// iccTag est une entrée de la table des balises d'un profil ICC
+type iccTag struct {
+	signature string
+	data      []byte
+}
+
+// sRGBProfile construit un profil ICC v2 « moniteur » sRGB : primaires,
+// point blanc et courbe de transfert échantillonnée. Le profil est généré plutôt
+// que distribué avec le programme ; il suffit à l'OutputIntent PDF/A.
+func sRGBProfile() []byte {
+	trc := curveTag()
+	tags := []iccTag{
+		{"desc", descTag(outputCondition)},
+		{"cprt", textTag("No copyright, use freely")},
+		{"wtpt", xyzTag(d50)},
+		{"rXYZ", xyzTag(sRGBRed)},
+		{"gXYZ", xyzTag(sRGBGreen)},
+		{"bXYZ", xyzTag(sRGBBlue)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	// Les données suivent l'en-tête (128 octets) et la table des balises, alignées
+	// sur 4 octets ; les courbes identiques partagent le même emplacement
+	offset := 128 + 4 + 12*len(tags)
+	var table, data bytes.Buffer
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	offsets := make(map[*byte]int)
+	for _, tag := range tags {
+		at, shared := offsets[&tag.data[0]]
+		if !shared {
+			at = offset + data.Len()
+			offsets[&tag.data[0]] = at
+			data.Write(tag.data)
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+		}
+		table.WriteString(tag.signature)
+		binary.Write(&table, binary.BigEndian, uint32(at))
+		binary.Write(&table, binary.BigEndian, uint32(len(tag.data)))
+	}
+
+	size := 128 + table.Len() + data.Len()
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	for i, v := range []uint16{2025, 1, 1, 0, 0, 0} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	for i, v := range d50 {
+		binary.BigEndian.PutUint32(header[68+4*i:], s15Fixed16(v))
+	}
+
+	profile := bytes.NewBuffer(header)
+	profile.Write(table.Bytes())
+	profile.Write(data.Bytes())
+	return profile.Bytes()
+}
+
+// s15Fixed16 encode un nombre signé en virgule fixe 16.16
+func s15Fixed16(v float64) uint32 {
+	return uint32(int32(math.Round(v * 65536)))
+}
+
+func xyzTag(xyz [3]float64) []byte {
+	b := make([]byte, 20)
+	copy(b, "XYZ ")
+	for i, v := range xyz {
+		binary.BigEndian.PutUint32(b[8+4*i:], s15Fixed16(v))
+	}
+	return b
+}
+
+// curveTag échantillonne la fonction de transfert sRGB
+func curveTag() []byte {
+	b := make([]byte, 12+2*curvePoints)
+	copy(b, "curv")
+	binary.BigEndian.PutUint32(b[8:], uint32(curvePoints))
+	for i := 0; i < curvePoints; i++ {
+		x := float64(i) / float64(curvePoints-1)
+		y := x / 12.92
+		if x > 0.04045 {
+			y = math.Pow((x+0.055)/1.055, 2.4)
+		}
+		binary.BigEndian.PutUint16(b[12+2*i:], uint16(math.Round(y*65535)))
+	}
+	return b
+}
+
+func textTag(s string) []byte {
+	b := make([]byte, 8, 8+len(s)+1)
+	copy(b, "text")
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// descTag encode un textDescriptionType ICC v2, sans variantes Unicode ni ScriptCode
+func descTag(s string) []byte {
+	var b bytes.Buffer
+	b.WriteString("desc")
+	b.Write(make([]byte, 4))
+	binary.Write(&b, binary.BigEndian, uint32(len(s)+1))
+	b.WriteString(s)
+	b.WriteByte(0)
+	b.Write(make([]byte, 4+4+2+1+67))
+	return b.Bytes()
+}
@@ -0,0 +1,264 @@
+// Package pdfa rend les PDF produits conformes au profil d'archivage PDF/A-2b et
+// vérifie cette conformité.
+//
+// La conversion ne réécrit pas le document : une mise à jour incrémentale ajoute
+// les métadonnées XMP, l'OutputIntent sRGB et un dictionnaire Info cohérent. Les
+// polices doivent déjà être incorporées par le moteur de conversion.
+package pdfa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Conformance est le niveau de conformité produit et vérifié
+const Conformance = "PDF/A-2b"
+
+// defaultProducer renseigne le producteur d'un document qui n'en déclare pas
+const defaultProducer = "FredonToPDF"
+
+func init() {
+	// pdfcpu ne doit pas créer de dossier de configuration utilisateur
+	api.DisableConfigDir()
+}
+
+// Error liste les manquements d'un document au profil PDF/A-2b
+type Error struct {
+	Path   string
+	Issues []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("non conforme %s : %s", Conformance, strings.Join(e.Issues, " ; "))
+}
+
+// Ensure rend le document conforme si nécessaire puis vérifie le résultat ; une
+// *Error est renvoyée si le document reste non conforme
+func Ensure(path string) error {
+	issues, err := Validate(path)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	if err := Convert(path); err != nil {
+		return err
+	}
+	issues, err = Validate(path)
+	if err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return &Error{Path: path, Issues: issues}
+	}
+	return nil
+}
+
+// Convert ajoute au document les éléments d'identification PDF/A-2b par une mise
+// à jour incrémentale. Les métadonnées et l'OutputIntent existants sont remplacés.
+func Convert(path string) error {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return fmt.Errorf("PDF illisible : %v", err)
+	}
+	if ctx.Encrypt != nil {
+		return fmt.Errorf("PDF chiffré : conversion %s impossible", Conformance)
+	}
+	if ctx.Root == nil || ctx.RootDict == nil || ctx.Size == nil {
+		return fmt.Errorf("PDF sans catalogue")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("impossible de lire le PDF : %v", err)
+	}
+	prev, err := lastXRef(data)
+	if err != nil {
+		return err
+	}
+
+	info := readInfo(ctx)
+	u := &update{size: *ctx.Size, offset: len(data)}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		u.buf.WriteByte('\n')
+	}
+
+	// Profil ICC, métadonnées XMP et dictionnaire Info : nouveaux objets
+	iccNum := u.addStream(sRGBProfile(), fmt.Sprintf("/N %d", iccComponents), true)
+	metaNum := u.addStream(xmpPacket(info), "/Type /Metadata /Subtype /XML", false)
+	infoNum := u.add(infoDict(info))
+
+	// Catalogue remplacé sous le même numéro
+	catalog := ctx.RootDict.Clone().(types.Dict)
+	catalog.Update("Metadata", *types.NewIndirectRef(metaNum, 0))
+	catalog.Update("OutputIntents", types.Array{types.Dict{
+		"Type":                      types.Name("OutputIntent"),
+		"S":                         types.Name("GTS_PDFA1"),
+		"OutputConditionIdentifier": types.StringLiteral(outputCondition),
+		"Info":                      types.StringLiteral(outputCondition),
+		"DestOutputProfile":         *types.NewIndirectRef(iccNum, 0),
+	}})
+	u.set(ctx.Root.ObjectNumber.Value(), ctx.Root.GenerationNumber.Value(), catalog.PDFString())
+
+	// Le premier identifiant du fichier est conservé, le second change à chaque version
+	first := randomID()
+	if len(ctx.ID) == 2 {
+		if id, err := fileID(ctx.ID[0]); err == nil {
+			first = id
+		}
+	}
+	trailer := fmt.Sprintf("/Root %d %d R /Info %d 0 R /Prev %d /ID [<%s> <%s>]",
+		ctx.Root.ObjectNumber.Value(), ctx.Root.GenerationNumber.Value(), infoNum, prev, first, randomID())
+
+	if ctx.Read.UsingXRefStreams {
+		u.writeXRefStream(trailer)
+	} else {
+		u.writeXRefTable(trailer)
+	}
+
+	// Le document complété est écrit à côté puis remplace l'original : une
+	// interruption ne laisse jamais de PDF à moitié mis à jour
+	file, err := os.CreateTemp(filepath.Dir(path), ".~"+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("impossible de modifier le PDF : %v", err)
+	}
+	defer os.Remove(file.Name())
+	if info, err := os.Stat(path); err == nil {
+		file.Chmod(info.Mode())
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("impossible de modifier le PDF : %v", err)
+	}
+	if _, err := file.Write(u.buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("impossible de modifier le PDF : %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("impossible de modifier le PDF : %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("impossible de modifier le PDF : %v", err)
+	}
+	return nil
+}
+
+// readInfo reprend les propriétés du dictionnaire Info ; la date de modification
+// est celle de la conversion
+func readInfo(ctx *model.Context) docInfo {
+	now := time.Now().Truncate(time.Second)
+	info := docInfo{Producer: defaultProducer, Created: now, Modified: now}
+	if ctx.Info == nil {
+		return info
+	}
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || d == nil {
+		return info
+	}
+
+	text := func(key string) string {
+		o, found := d.Find(key)
+		if !found {
+			return ""
+		}
+		s, err := ctx.DereferenceText(o)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(s)
+	}
+	info.Title = text("Title")
+	info.Author = text("Author")
+	info.Subject = text("Subject")
+	info.Keywords = text("Keywords")
+	info.Creator = text("Creator")
+	if producer := text("Producer"); producer != "" {
+		info.Producer = producer
+	}
+	if created, ok := types.DateTime(text("CreationDate"), true); ok {
+		info.Created = created
+	}
+	return info
+}
+
+// infoDict écrit le dictionnaire Info correspondant aux métadonnées XMP
+func infoDict(info docInfo) string {
+	var b strings.Builder
+	b.WriteString("<<")
+	for _, entry := range []struct{ key, value string }{
+		{"Title", info.Title},
+		{"Author", info.Author},
+		{"Subject", info.Subject},
+		{"Keywords", info.Keywords},
+		{"Creator", info.Creator},
+		{"Producer", info.Producer},
+	} {
+		if entry.value != "" {
+			fmt.Fprintf(&b, " /%s %s", entry.key, textString(entry.value))
+		}
+	}
+	fmt.Fprintf(&b, " /CreationDate (%s) /ModDate (%s) >>", types.DateString(info.Created), types.DateString(info.Modified))
+	return b.String()
+}
+
+// textString encode une chaîne de texte en UTF-16BE hexadécimal
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// fileID renvoie en hexadécimal un élément de l'identifiant du fichier
+func fileID(o types.Object) (string, error) {
+	switch id := o.(type) {
+	case types.HexLiteral:
+		return strings.ToUpper(id.Value()), nil
+	case types.StringLiteral:
+		b, err := types.Unescape(id.Value())
+		if err != nil {
+			return "", err
+		}
+		return strings.ToUpper(hex.EncodeToString(b)), nil
+	}
+	return "", fmt.Errorf("identifiant invalide")
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// lastXRef renvoie la position de la dernière table de références croisées
+func lastXRef(data []byte) (int, error) {
+	i := bytes.LastIndex(data, []byte("startxref"))
+	if i < 0 {
+		return 0, fmt.Errorf("PDF sans table de références croisées")
+	}
+	fields := strings.Fields(string(data[i+len("startxref"):]))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("PDF sans table de références croisées")
+	}
+	offset, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, fmt.Errorf("position de la table de références croisées invalide : %v", err)
+	}
+	return offset, nil
+}
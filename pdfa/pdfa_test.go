@@ -0,0 +1,526 @@
+package pdfa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"fredon_to_pdf/pdf"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Manquements attendus des PDF du moteur natif, qui emploie les polices
+// standard sans les incorporer
+var nativeFontIssues = []string{
+	"police non incorporée : Helvetica",
+	"police non incorporée : Helvetica-Bold",
+}
+
+// graphicsPDF écrit un PDF sans texte, donc sans police à incorporer
+func graphicsPDF(t *testing.T, title string) string {
+	t.Helper()
+	doc := pdf.New()
+	doc.Title = title
+	for range 2 {
+		page := doc.AddPage(595, 842)
+		page.SetFillColor(0x1F, 0x4E, 0x78)
+		page.FillRect(50, 700, 200, 40)
+		page.SetStrokeColor(0, 0, 0)
+		page.Line(50, 690, 545, 690)
+	}
+	path := filepath.Join(t.TempDir(), "Client (2502).pdf")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := doc.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// nativePDF copie un PDF produit par le moteur natif
+func nativePDF(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "tools", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// amend ajoute au document une mise à jour incrémentale construite par edit,
+// qui peut aussi remplacer le catalogue reçu
+func amend(t *testing.T, path string, edit func(u *update, catalog types.Dict)) {
+	t.Helper()
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, err := lastXRef(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &update{size: *ctx.Size, offset: len(data)}
+	catalog := ctx.RootDict.Clone().(types.Dict)
+	edit(u, catalog)
+	u.set(ctx.Root.ObjectNumber.Value(), ctx.Root.GenerationNumber.Value(), catalog.PDFString())
+
+	trailer := fmt.Sprintf("/Root %d %d R /Prev %d", ctx.Root.ObjectNumber.Value(), ctx.Root.GenerationNumber.Value(), prev)
+	if ctx.Info != nil {
+		trailer += fmt.Sprintf(" /Info %d 0 R", ctx.Info.ObjectNumber.Value())
+	}
+	if len(ctx.ID) == 2 {
+		id, err := fileID(ctx.ID[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		trailer += fmt.Sprintf(" /ID [<%s> <%s>]", id, id)
+	}
+	u.writeXRefTable(trailer)
+	if err := os.WriteFile(path, append(data, u.buf.Bytes()...), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addToPage rattache une ressource à toutes les pages, sous la catégorie donnée
+func addToPage(t *testing.T, path, category, name string, num int) {
+	t.Helper()
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := ctx.DereferenceDict(ctx.RootDict["Pages"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kids, err := ctx.DereferenceArray(pages["Kids"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	amend(t, path, func(u *update, catalog types.Dict) {
+		for _, kid := range kids {
+			ref := kid.(types.IndirectRef)
+			page, err := ctx.DereferenceDict(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page = page.Clone().(types.Dict)
+			resources, _ := ctx.DereferenceDict(page["Resources"])
+			if resources == nil {
+				resources = types.Dict{}
+			}
+			resources = resources.Clone().(types.Dict)
+			entries, _ := ctx.DereferenceDict(resources[category])
+			if entries == nil {
+				entries = types.Dict{}
+			}
+			entries = entries.Clone().(types.Dict)
+			entries[name] = *types.NewIndirectRef(num, 0)
+			resources[category] = entries
+			page["Resources"] = resources
+			u.set(ref.ObjectNumber.Value(), ref.GenerationNumber.Value(), page.PDFString())
+		}
+	})
+}
+
+func TestEnsure(t *testing.T) {
+	path := graphicsPDF(t, "Relevé « été » <2502> & co")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := Validate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"OutputIntent PDF/A (GTS_PDFA1) absent", "métadonnées XMP absentes"} {
+		if !slices.Contains(issues, want) {
+			t.Errorf("manquements = %v, %q attendu", issues, want)
+		}
+	}
+
+	if err := Ensure(path); err != nil {
+		t.Fatalf("Ensure() = %v", err)
+	}
+	if issues, err := Validate(path); err != nil || len(issues) > 0 {
+		t.Fatalf("Validate() = %v, %v après Ensure", issues, err)
+	}
+
+	// Mise à jour incrémentale : le document d'origine est conservé tel quel
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, original) {
+		t.Error("document d'origine réécrit")
+	}
+	if n, err := pdf.CheckFile(path); err != nil || n != 2 {
+		t.Errorf("CheckFile() = %d, %v, attendu 2 pages", n, err)
+	}
+
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := readInfo(ctx); info.Title != "Relevé « été » <2502> & co" {
+		t.Errorf("titre = %q", info.Title)
+	}
+
+	// Un document déjà conforme n'est pas modifié
+	if err := Ensure(path); err != nil {
+		t.Fatalf("second Ensure() = %v", err)
+	}
+	again, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Error("document conforme modifié")
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("fichiers laissés : %v", entries)
+	}
+}
+
+func TestEnsureKeepsFileID(t *testing.T) {
+	path := graphicsPDF(t, "")
+	before, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Ensure(path); err != nil {
+		t.Fatal(err)
+	}
+	after, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := fileID(before.ID[0])
+	if got, _ := fileID(after.ID[0]); got != first {
+		t.Errorf("identifiant = %s, attendu %s", got, first)
+	}
+	if second, _ := fileID(after.ID[1]); second == first {
+		t.Error("second identifiant inchangé")
+	}
+}
+
+func TestEnsureXRefStream(t *testing.T) {
+	// pdfcpu réécrit le document avec des flux de références et d'objets
+	src := graphicsPDF(t, "Flux")
+	path := filepath.Join(t.TempDir(), "flux.pdf")
+	conf := model.NewDefaultConfiguration()
+	conf.WriteXRefStream = true
+	conf.WriteObjectStream = true
+	if err := api.OptimizeFile(src, path, conf); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ctx.Read.UsingXRefStreams {
+		t.Skip("pdfcpu n'a pas écrit de flux de références")
+	}
+
+	if err := Ensure(path); err != nil {
+		t.Fatalf("Ensure() = %v", err)
+	}
+	if issues, err := Validate(path); err != nil || len(issues) > 0 {
+		t.Fatalf("Validate() = %v, %v après Ensure", issues, err)
+	}
+	if n, err := pdf.CheckFile(path); err != nil || n != 2 {
+		t.Errorf("CheckFile() = %d, %v, attendu 2 pages", n, err)
+	}
+}
+
+func TestEnsureNativePDF(t *testing.T) {
+	for _, name := range []string{"facture.pdf", "releve.pdf"} {
+		t.Run(name, func(t *testing.T) {
+			path := nativePDF(t, name)
+			pages, err := pdf.CheckFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Métadonnées et OutputIntent sont ajoutés, mais les polices
+			// standard ne sont pas incorporées : le document reste refusé
+			err = Ensure(path)
+			var pdfaErr *Error
+			if !errors.As(err, &pdfaErr) {
+				t.Fatalf("Ensure() = %v, *Error attendue", err)
+			}
+			if pdfaErr.Path != path || !slices.Equal(pdfaErr.Issues, nativeFontIssues) {
+				t.Errorf("manquements = %v, attendu %v", pdfaErr.Issues, nativeFontIssues)
+			}
+			if !strings.Contains(err.Error(), Conformance) {
+				t.Errorf("erreur = %v, niveau de conformité non mentionné", err)
+			}
+			if n, err := pdf.CheckFile(path); err != nil || n != pages {
+				t.Errorf("CheckFile() = %d, %v, attendu %d pages", n, err, pages)
+			}
+		})
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(t *testing.T, path string)
+		issue string
+	}{
+		{
+			name: "opacité",
+			edit: func(t *testing.T, path string) {
+				var num int
+				amend(t, path, func(u *update, catalog types.Dict) {
+					num = u.add("<< /Type /ExtGState /ca 0.5 >>")
+				})
+				addToPage(t, path, "ExtGState", "GS1", num)
+			},
+			issue: "transparence interdite (opacité ca)",
+		},
+		{
+			name: "mode de fusion",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					u.add("<< /Type /ExtGState /BM /Multiply >>")
+				})
+			},
+			issue: "transparence interdite (mode de fusion)",
+		},
+		{
+			name: "masque de transparence",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					u.add("<< /Type /ExtGState /SMask << /S /Luminosity >> >>")
+				})
+			},
+			issue: "transparence interdite (masque de transparence)",
+		},
+		{
+			name: "image avec masque alpha",
+			edit: func(t *testing.T, path string) {
+				var num int
+				amend(t, path, func(u *update, catalog types.Dict) {
+					mask := u.addStream([]byte{0x80}, "/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8", false)
+					num = u.addStream([]byte{0xFF, 0, 0}, fmt.Sprintf("/Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /SMask %d 0 R", mask), false)
+				})
+				addToPage(t, path, "XObject", "Im1", num)
+			},
+			issue: "transparence interdite (image avec masque alpha)",
+		},
+		{
+			name: "OutputIntent absent",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					delete(catalog, "OutputIntents")
+				})
+			},
+			issue: "OutputIntent PDF/A (GTS_PDFA1) absent",
+		},
+		{
+			name: "OutputIntent d'un autre profil",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					intents := catalog["OutputIntents"].(types.Array)
+					intent := intents[0].(types.Dict).Clone().(types.Dict)
+					intent["S"] = types.Name("GTS_PDFX")
+					catalog["OutputIntents"] = types.Array{intent}
+				})
+			},
+			issue: "OutputIntent PDF/A (GTS_PDFA1) absent",
+		},
+		{
+			name: "métadonnées absentes",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					delete(catalog, "Metadata")
+				})
+			},
+			issue: "métadonnées XMP absentes",
+		},
+		{
+			name: "police non incorporée",
+			edit: func(t *testing.T, path string) {
+				var num int
+				amend(t, path, func(u *update, catalog types.Dict) {
+					num = u.add("<< /Type /Font /Subtype /TrueType /BaseFont /Arial /Encoding /WinAnsiEncoding >>")
+				})
+				addToPage(t, path, "Font", "F1", num)
+			},
+			issue: "police non incorporée : Arial",
+		},
+		{
+			name: "police descendante non incorporée",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					descendant := u.add("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /Calibri /FontDescriptor << /Type /FontDescriptor /FontName /Calibri /Flags 32 >> >>")
+					u.add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /Calibri /Encoding /Identity-H /DescendantFonts [%d 0 R] >>", descendant))
+				})
+			},
+			issue: "police non incorporée : Calibri",
+		},
+		{
+			name: "JavaScript",
+			edit: func(t *testing.T, path string) {
+				amend(t, path, func(u *update, catalog types.Dict) {
+					catalog["OpenAction"] = types.Dict{"S": types.Name("JavaScript"), "JS": types.StringLiteral("app.alert(1)")}
+				})
+			},
+			issue: "JavaScript interdit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := graphicsPDF(t, "Client")
+			if err := Ensure(path); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(t, path)
+
+			issues, err := Validate(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(issues, tt.issue) {
+				t.Errorf("manquements = %v, %q attendu", issues, tt.issue)
+			}
+		})
+	}
+}
+
+func TestValidateAcceptsEmbeddedFont(t *testing.T) {
+	path := graphicsPDF(t, "Client")
+	if err := Ensure(path); err != nil {
+		t.Fatal(err)
+	}
+	var num int
+	amend(t, path, func(u *update, catalog types.Dict) {
+		file := u.addStream([]byte("police"), "/Length1 6", true)
+		num = u.add(fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /ABCDEF+Arial /Encoding /WinAnsiEncoding"+
+			" /FirstChar 32 /LastChar 32 /Widths [278] /FontDescriptor << /Type /FontDescriptor /FontName /ABCDEF+Arial"+
+			" /Flags 32 /FontBBox [-665 -325 2000 1040] /ItalicAngle 0 /Ascent 905 /Descent -212 /CapHeight 716 /StemV 80"+
+			" /FontFile2 %d 0 R >> >>", file))
+	})
+	addToPage(t, path, "Font", "F1", num)
+
+	if issues, err := Validate(path); err != nil || len(issues) > 0 {
+		t.Errorf("Validate() = %v, %v", issues, err)
+	}
+}
+
+func TestValidateUnreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tronqué.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4\n1 0 obj\n<<"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Validate(path); err == nil {
+		t.Error("PDF illisible accepté")
+	}
+	if err := Ensure(path); err == nil {
+		t.Error("Ensure() sans erreur sur un PDF illisible")
+	}
+}
+
+func TestXMPPacket(t *testing.T) {
+	created := time.Date(2022, 7, 20, 9, 30, 0, 0, time.UTC)
+	info := docInfo{
+		Title:    "Devis <n°2502> & « remise »",
+		Author:   "Société d'Étude",
+		Producer: defaultProducer,
+		Created:  created,
+		Modified: created.Add(time.Hour),
+	}
+	packet := xmpPacket(info)
+
+	// Le paquet est du XML bien formé
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("XML invalide : %v", err)
+		}
+		if data, ok := token.(xml.CharData); ok {
+			text.Write(data)
+		}
+	}
+	for _, want := range []string{info.Title, info.Author, "2022-07-20T09:30:00+00:00", "2022-07-20T10:30:00+00:00"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("%q absent du paquet XMP", want)
+		}
+	}
+	if !partRe.Match(packet) || !conformanceRe.Match(packet) {
+		t.Error("identification PDF/A-2 absente")
+	}
+}
+
+func TestSRGBProfile(t *testing.T) {
+	profile := sRGBProfile()
+	if size := binary.BigEndian.Uint32(profile); int(size) != len(profile) {
+		t.Errorf("taille déclarée %d, attendu %d", size, len(profile))
+	}
+	header := []struct {
+		offset int
+		want   string
+	}{
+		{12, "mntr"}, // classe moniteur
+		{16, "RGB "},
+		{20, "XYZ "},
+		{36, "acsp"},
+	}
+	for _, h := range header {
+		if got := string(profile[h.offset : h.offset+4]); got != h.want {
+			t.Errorf("octets %d = %q, attendu %q", h.offset, got, h.want)
+		}
+	}
+	if profile[8] != 2 {
+		t.Errorf("version %d, attendu 2", profile[8])
+	}
+
+	// Chaque balise tient dans le profil
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+	var signatures []string
+	for i := range count {
+		entry := profile[132+12*i:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if int(offset+size) > len(profile) {
+			t.Errorf("balise %s hors du profil", entry[:4])
+		}
+		signatures = append(signatures, string(entry[:4]))
+	}
+	for _, want := range []string{"desc", "wtpt", "rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"} {
+		if !slices.Contains(signatures, want) {
+			t.Errorf("balise %s absente : %v", want, signatures)
+		}
+	}
+}
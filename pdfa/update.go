@@ -0,0 +1,107 @@
+package pdfa
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// update accumule les objets d'une mise à jour incrémentale, ajoutée à la fin du
+// fichier existant
+type update struct {
+	buf     bytes.Buffer
+	size    int // prochain numéro d'objet libre
+	offset  int // taille du fichier avant la mise à jour
+	entries []xrefEntry
+}
+
+type xrefEntry struct {
+	num, gen, offset int
+}
+
+// add écrit un nouvel objet et renvoie son numéro
+func (u *update) add(body string) int {
+	num := u.size
+	u.size++
+	u.set(num, 0, body)
+	return num
+}
+
+// addStream écrit un nouveau flux, compressé si demandé, et renvoie son numéro
+func (u *update) addStream(data []byte, extra string, compress bool) int {
+	num := u.size
+	u.size++
+	u.entries = append(u.entries, xrefEntry{num, 0, u.offset + u.buf.Len()})
+	filter := ""
+	if compress {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write(data)
+		w.Close()
+		data = z.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+	fmt.Fprintf(&u.buf, "%d 0 obj\n<< %s%s /Length %d >>\nstream\n", num, extra, filter, len(data))
+	u.buf.Write(data)
+	u.buf.WriteString("\nendstream\nendobj\n")
+	return num
+}
+
+// set écrit un objet sous un numéro donné, nouveau ou remplacé
+func (u *update) set(num, gen int, body string) {
+	u.entries = append(u.entries, xrefEntry{num, gen, u.offset + u.buf.Len()})
+	fmt.Fprintf(&u.buf, "%d %d obj\n%s\nendobj\n", num, gen, body)
+}
+
+// subsections regroupe les entrées par numéros consécutifs
+func (u *update) subsections() [][]xrefEntry {
+	sort.Slice(u.entries, func(i, j int) bool { return u.entries[i].num < u.entries[j].num })
+	var sections [][]xrefEntry
+	for i, e := range u.entries {
+		if i > 0 && e.num == u.entries[i-1].num+1 {
+			sections[len(sections)-1] = append(sections[len(sections)-1], e)
+			continue
+		}
+		sections = append(sections, []xrefEntry{e})
+	}
+	return sections
+}
+
+// writeXRefTable termine la mise à jour par une table de références classique
+func (u *update) writeXRefTable(trailer string) {
+	start := u.offset + u.buf.Len()
+	u.buf.WriteString("xref\n")
+	for _, section := range u.subsections() {
+		fmt.Fprintf(&u.buf, "%d %d\n", section[0].num, len(section))
+		for _, e := range section {
+			fmt.Fprintf(&u.buf, "%010d %05d n\r\n", e.offset, e.gen)
+		}
+	}
+	fmt.Fprintf(&u.buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", u.size, trailer, start)
+}
+
+// writeXRefStream termine la mise à jour par un flux de références, pour les
+// documents qui en utilisent déjà
+func (u *update) writeXRefStream(trailer string) {
+	num := u.size
+	u.size++
+	start := u.offset + u.buf.Len()
+	u.entries = append(u.entries, xrefEntry{num, 0, start})
+
+	var index bytes.Buffer
+	var data bytes.Buffer
+	for _, section := range u.subsections() {
+		fmt.Fprintf(&index, " %d %d", section[0].num, len(section))
+		for _, e := range section {
+			data.WriteByte(1)
+			binary.Write(&data, binary.BigEndian, uint32(e.offset))
+			binary.Write(&data, binary.BigEndian, uint16(e.gen))
+		}
+	}
+	fmt.Fprintf(&u.buf, "%d 0 obj\n<< /Type /XRef /Size %d /Index [%s ] /W [1 4 2] %s /Length %d >>\nstream\n",
+		num, u.size, index.String(), trailer, data.Len())
+	u.buf.Write(data.Bytes())
+	fmt.Fprintf(&u.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+}
@@ -0,0 +1,301 @@
+package pdfa
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Identification PDF/A dans le paquet XMP, sous forme d'élément ou d'attribut.
+// Les niveaux A et U de PDF/A-2 incluent le niveau B.
+var (
+	partRe        = regexp.MustCompile(`pdfaid:part\s*(?:=\s*["']|>)\s*2\s*["'<]`)
+	conformanceRe = regexp.MustCompile(`pdfaid:conformance\s*(?:=\s*["']|>)\s*[ABUabu]\s*["'<]`)
+)
+
+// Propriétés XMP correspondant aux entrées du dictionnaire Info
+var xmpProperties = map[string]string{
+	"Title":        "dc:title",
+	"Author":       "dc:creator",
+	"Subject":      "dc:description",
+	"Keywords":     "pdf:Keywords",
+	"Creator":      "xmp:CreatorTool",
+	"Producer":     "pdf:Producer",
+	"CreationDate": "xmp:CreateDate",
+	"ModDate":      "xmp:ModifyDate",
+}
+
+// Validate vérifie les principales exigences PDF/A-2b et renvoie les manquements
+// constatés, vide si le document est conforme : version, chiffrement,
+// identifiant, métadonnées XMP, OutputIntent, polices incorporées, actions et
+// filtres interdits. L'archivage exige de plus l'absence de transparence.
+//
+// Ce contrôle ne remplace pas un validateur complet comme veraPDF.
+func Validate(path string) ([]string, error) {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("PDF illisible : %v", err)
+	}
+
+	v := &validator{ctx: ctx, issues: make(map[string]bool)}
+	v.checkDocument()
+	for num, entry := range ctx.Table {
+		if num == 0 || entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		v.checkObject(entry.Object)
+	}
+
+	issues := make([]string, 0, len(v.issues))
+	for issue := range v.issues {
+		issues = append(issues, issue)
+	}
+	sort.Strings(issues)
+	return issues, nil
+}
+
+type validator struct {
+	ctx    *model.Context
+	issues map[string]bool
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.issues[fmt.Sprintf(format, args...)] = true
+}
+
+// checkDocument vérifie l'en-tête, le trailer et le catalogue
+func (v *validator) checkDocument() {
+	ctx := v.ctx
+	if ctx.HeaderVersion != nil && *ctx.HeaderVersion > model.V17 {
+		v.fail("version PDF %s postérieure à 1.7", ctx.HeaderVersion)
+	}
+	if ctx.Encrypt != nil {
+		v.fail("document chiffré")
+	}
+	if len(ctx.ID) != 2 {
+		v.fail("identifiant de fichier (ID) absent du trailer")
+	}
+	if ctx.RootDict == nil {
+		v.fail("catalogue absent")
+		return
+	}
+
+	xmp := v.metadata()
+	if xmp == "" {
+		v.fail("métadonnées XMP absentes")
+	} else {
+		if !partRe.MatchString(xmp) || !conformanceRe.MatchString(xmp) {
+			v.fail("identification PDF/A-2 absente des métadonnées XMP")
+		}
+		v.checkInfo(xmp)
+	}
+	v.checkOutputIntents()
+
+	if names, _ := ctx.DereferenceDict(ctx.RootDict["Names"]); names != nil {
+		if _, found := names.Find("JavaScript"); found {
+			v.fail("JavaScript interdit")
+		}
+		if _, found := names.Find("EmbeddedFiles"); found {
+			v.fail("fichiers joints interdits")
+		}
+	}
+}
+
+// metadata renvoie le paquet XMP du catalogue
+func (v *validator) metadata() string {
+	sd, _, err := v.ctx.DereferenceStreamDict(v.ctx.RootDict["Metadata"])
+	if err != nil || sd == nil {
+		return ""
+	}
+	if _, found := sd.Find("Filter"); found {
+		v.fail("flux de métadonnées XMP compressé")
+	}
+	if err := sd.Decode(); err != nil {
+		return ""
+	}
+	return string(sd.Content)
+}
+
+// checkInfo vérifie que chaque entrée du dictionnaire Info a son équivalent XMP
+func (v *validator) checkInfo(xmp string) {
+	if v.ctx.Info == nil {
+		return
+	}
+	info, err := v.ctx.DereferenceDict(*v.ctx.Info)
+	if err != nil || info == nil {
+		return
+	}
+	for key, property := range xmpProperties {
+		if _, found := info.Find(key); !found {
+			continue
+		}
+		if !regexp.MustCompile(`<` + regexp.QuoteMeta(property) + `[\s>]|` + regexp.QuoteMeta(property) + `\s*=`).MatchString(xmp) {
+			v.fail("entrée Info %s sans équivalent XMP (%s)", key, property)
+		}
+	}
+}
+
+// checkOutputIntents vérifie la présence d'un profil de sortie PDF/A unique
+func (v *validator) checkOutputIntents() {
+	intents, _ := v.ctx.DereferenceArray(v.ctx.RootDict["OutputIntents"])
+	var profiles []types.Object
+	for _, o := range intents {
+		intent, _ := v.ctx.DereferenceDict(o)
+		if intent == nil || intent.NameEntry("S") == nil || *intent.NameEntry("S") != "GTS_PDFA1" {
+			continue
+		}
+		profile, _, err := v.ctx.DereferenceStreamDict(intent["DestOutputProfile"])
+		if err != nil || profile == nil {
+			v.fail("OutputIntent sans profil ICC")
+			continue
+		}
+		if n := profile.IntEntry("N"); n == nil || (*n != 1 && *n != 3 && *n != 4) {
+			v.fail("profil ICC de l'OutputIntent invalide")
+		}
+		profiles = append(profiles, intent["DestOutputProfile"])
+	}
+	switch {
+	case len(profiles) == 0:
+		v.fail("OutputIntent PDF/A (GTS_PDFA1) absent")
+	case len(profiles) > 1:
+		for _, p := range profiles[1:] {
+			if p.String() != profiles[0].String() {
+				v.fail("plusieurs OutputIntents PDF/A différents")
+			}
+		}
+	}
+}
+
+// checkObject contrôle un objet et les dictionnaires directs qu'il contient
+func (v *validator) checkObject(o types.Object) {
+	switch obj := o.(type) {
+	case types.Dict:
+		v.checkDict(obj, false)
+		for _, child := range obj {
+			v.checkObject(child)
+		}
+	case types.StreamDict:
+		v.checkDict(obj.Dict, true)
+		for _, child := range obj.Dict {
+			v.checkObject(child)
+		}
+	case types.Array:
+		for _, child := range obj {
+			v.checkObject(child)
+		}
+	}
+}
+
+func (v *validator) checkDict(d types.Dict, stream bool) {
+	name := func(key string) string {
+		if n := d.NameEntry(key); n != nil {
+			return *n
+		}
+		return ""
+	}
+
+	// Filtres
+	if stream {
+		if filter, found := d.Find("Filter"); found && containsName(filter, "LZWDecode") {
+			v.fail("filtre LZW interdit")
+		}
+	}
+
+	// Actions
+	switch name("S") {
+	case "JavaScript":
+		v.fail("JavaScript interdit")
+	case "Launch", "Sound", "Movie", "ResetForm", "ImportData":
+		v.fail("action %s interdite", name("S"))
+	}
+	if _, found := d.Find("JS"); found {
+		v.fail("JavaScript interdit")
+	}
+
+	switch {
+	case name("Type") == "Font" && name("Subtype") != "Type3":
+		v.checkFont(d)
+	case name("Type") == "ExtGState":
+		v.checkExtGState(d)
+	case stream && name("Subtype") == "Image":
+		if smask, found := d.Find("SMask"); found && !isName(smask, "None") {
+			v.fail("transparence interdite (image avec masque alpha)")
+		}
+	}
+}
+
+// checkFont vérifie que la police, ou sa police descendante, est incorporée
+func (v *validator) checkFont(font types.Dict) {
+	base := ""
+	if n := font.NameEntry("BaseFont"); n != nil {
+		base = *n
+	}
+	if subtype := font.NameEntry("Subtype"); subtype != nil && *subtype == "Type0" {
+		descendants, _ := v.ctx.DereferenceArray(font["DescendantFonts"])
+		if len(descendants) == 0 {
+			v.fail("police %s sans police descendante", base)
+			return
+		}
+		font, _ = v.ctx.DereferenceDict(descendants[0])
+		if font == nil {
+			v.fail("police %s sans police descendante", base)
+			return
+		}
+	}
+
+	descriptor, _ := v.ctx.DereferenceDict(font["FontDescriptor"])
+	if descriptor != nil {
+		for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+			if _, found := descriptor.Find(key); found {
+				return
+			}
+		}
+	}
+	v.fail("police non incorporée : %s", base)
+}
+
+// checkExtGState refuse les effets de transparence : masques, opacité et modes
+// de fusion
+func (v *validator) checkExtGState(d types.Dict) {
+	if smask, found := d.Find("SMask"); found && !isName(smask, "None") {
+		v.fail("transparence interdite (masque de transparence)")
+	}
+	for _, key := range []string{"CA", "ca"} {
+		if o, found := d.Find(key); found {
+			if alpha, err := v.ctx.DereferenceNumber(o); err == nil && alpha < 1 {
+				v.fail("transparence interdite (opacité %s)", key)
+			}
+		}
+	}
+	if bm, found := d.Find("BM"); found && !isName(bm, "Normal") && !isName(bm, "Compatible") {
+		v.fail("transparence interdite (mode de fusion)")
+	}
+}
+
+func isName(o types.Object, name string) bool {
+	switch n := o.(type) {
+	case types.Name:
+		return n.Value() == name
+	case types.Array:
+		return len(n) > 0 && isName(n[0], name)
+	}
+	return false
+}
+
+func containsName(o types.Object, name string) bool {
+	switch n := o.(type) {
+	case types.Name:
+		return n.Value() == name
+	case types.Array:
+		for _, item := range n {
+			if containsName(item, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
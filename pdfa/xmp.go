@@ -0,0 +1,77 @@
+package pdfa
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// docInfo regroupe les propriétés du document, écrites à l'identique dans le
+// dictionnaire Info et dans les métadonnées XMP comme l'exige PDF/A
+type docInfo struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+	Producer string
+	Created  time.Time
+	Modified time.Time
+}
+
+// xmpDate est le format des dates XMP (ISO 8601 avec fuseau)
+const xmpDate = "2006-01-02T15:04:05-07:00"
+
+// xmpPacket construit le paquet XMP déclarant la conformité PDF/A-2b
+func xmpPacket(info docInfo) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about=""` +
+		` xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/"` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:xmp="http://ns.adobe.com/xap/1.0/"` +
+		` xmlns:pdf="http://ns.adobe.com/pdf/1.3/">` + "\n")
+
+	element(&b, "pdfaid:part", "2")
+	element(&b, "pdfaid:conformance", "B")
+	element(&b, "dc:format", "application/pdf")
+	if info.Title != "" {
+		b.WriteString("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">" + escape(info.Title) + "</rdf:li></rdf:Alt></dc:title>\n")
+	}
+	if info.Author != "" {
+		b.WriteString("<dc:creator><rdf:Seq><rdf:li>" + escape(info.Author) + "</rdf:li></rdf:Seq></dc:creator>\n")
+	}
+	if info.Subject != "" {
+		b.WriteString("<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">" + escape(info.Subject) + "</rdf:li></rdf:Alt></dc:description>\n")
+	}
+	element(&b, "pdf:Keywords", info.Keywords)
+	element(&b, "pdf:Producer", info.Producer)
+	element(&b, "xmp:CreatorTool", info.Creator)
+	element(&b, "xmp:CreateDate", info.Created.Format(xmpDate))
+	element(&b, "xmp:ModifyDate", info.Modified.Format(xmpDate))
+	element(&b, "xmp:MetadataDate", info.Modified.Format(xmpDate))
+
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	// Remplissage recommandé pour une modification sur place du paquet
+	for i := 0; i < 20; i++ {
+		b.WriteString("                                                                                \n")
+	}
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.Bytes()
+}
+
+// element écrit une propriété XMP simple ; les valeurs vides sont omises
+func element(b *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteString("<" + name + ">" + escape(value) + "</" + name + ">\n")
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
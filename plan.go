@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/manifest"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// outputPlan décrit les PDF d'un lot, décidés avant toute conversion
+type outputPlan struct {
+	options    map[string]types.ExportOptions // options d'export de chaque classeur retenu, nom du PDF compris
+	collisions map[string]string              // collisions de noms résolues, par classeur converti
+}
+
+// planOutputs prépare un lot avant toute conversion. Parmi les classeurs au
+// contenu identique, un seul est converti, de préférence celui déjà converti ou
+// au nom le plus court ("Client.xls" plutôt que "Client (1).xls"). Un classeur dont
+// le PDF existe sans en provenir, ou serait aussi produit par un autre classeur
+// du lot, est traité selon la politique overwrite_policy. Les classeurs retenus
+// sont renvoyés dans l'ordre d'origine ; les autres le sont sous forme de résultats.
+func planOutputs(cfg *config.Config, files []string, state *manifest.Manifest) ([]string, outputPlan, []types.ProcessResult) {
+	plan := outputPlan{
+		options:    make(map[string]types.ExportOptions, len(files)),
+		collisions: make(map[string]string),
+	}
+	kept, results := removeDuplicates(files, state)
+
+	// Les classeurs propriétaires de leur PDF le réservent en premier, puis les
+	// autres dans l'ordre des chemins
+	type candidate struct {
+		file  string
+		opts  types.ExportOptions
+		dir   string
+		stem  string // chemin du PDF sans extension
+		entry manifest.Entry
+		known bool
+	}
+	candidates := make([]candidate, len(kept))
+	for i, file := range kept {
+		c := candidate{file: file, opts: cfg.ExportOptions(file), dir: outputDirFor(cfg, file)}
+		c.stem = filepath.Join(c.dir, c.opts.OutputName(file))
+		c.entry, c.known = state.Lookup(file)
+		candidates[i] = c
+	}
+	owner := func(c candidate) bool { return c.known && owns(c.entry, c.stem) }
+	order := slices.Clone(candidates)
+	slices.SortStableFunc(order, func(a, b candidate) int {
+		if owner(a) != owner(b) {
+			if owner(a) {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.file, b.file)
+	})
+
+	claims := make(map[string]string) // chemin du PDF sans extension, en minuscules -> classeur
+	excluded := make(map[string]bool)
+	for _, c := range order {
+		conflict := ""
+		if other, ok := claims[strings.ToLower(c.stem)]; ok {
+			conflict = "même PDF que " + filepath.Base(other)
+		} else if pdfExists(c.stem, c.opts.PerSheet) && !owner(c) {
+			conflict = "PDF existant"
+		}
+		if conflict == "" {
+			claims[strings.ToLower(c.stem)] = c.file
+			plan.options[c.file] = c.opts
+			continue
+		}
+
+		switch cfg.OverwritePolicy {
+		case types.OverwriteSkip:
+			excluded[c.file] = true
+			results = append(results, excludedResult(c.file, conflict+", non converti"))
+		case types.OverwriteFail:
+			excluded[c.file] = true
+			result := excludedResult(c.file, conflict)
+			result.Excluded = false
+			result.Err = fmt.Errorf("collision de PDF : %s (overwrite_policy %s)", conflict, types.OverwriteFail)
+			results = append(results, result)
+		case types.OverwriteRename:
+			// Le suffixe attribué lors d'une exécution précédente est conservé
+			for n := 2; ; n++ {
+				stem := fmt.Sprintf("%s (%d)", c.stem, n)
+				if _, claimed := claims[strings.ToLower(stem)]; claimed {
+					continue
+				}
+				if c.known && owns(c.entry, stem) || !pdfExists(stem, c.opts.PerSheet) {
+					c.opts.Name = filepath.ToSlash(fmt.Sprintf("%s (%d)", c.opts.OutputName(c.file), n))
+					c.stem = stem
+					break
+				}
+			}
+			claims[strings.ToLower(c.stem)] = c.file
+			plan.options[c.file] = c.opts
+			plan.collisions[c.file] = fmt.Sprintf("%s, renommé en %s.pdf", conflict, filepath.Base(c.stem))
+		default:
+			claims[strings.ToLower(c.stem)] = c.file
+			plan.options[c.file] = c.opts
+			// Le remplacement d'un PDF existant est le comportement attendu ; seule
+			// la collision entre classeurs du lot est signalée
+			if conflict != "PDF existant" {
+				plan.collisions[c.file] = conflict + ", PDF écrasé"
+			}
+		}
+	}
+
+	var planned []string
+	for _, file := range kept {
+		if !excluded[file] {
+			planned = append(planned, file)
+		}
+	}
+	return planned, plan, results
+}
+
+// annotate reporte sur les résultats les collisions résolues avant la conversion
+func (p outputPlan) annotate(results []types.ProcessResult) {
+	for i := range results {
+		if collision, ok := p.collisions[results[i].InputPath]; ok {
+			results[i].Collision = collision
+		}
+	}
+}
+
+// removeDuplicates écarte les classeurs dont le contenu est identique à celui d'un
+// autre classeur du lot ou d'un classeur déjà converti
+func removeDuplicates(files []string, state *manifest.Manifest) ([]string, []types.ProcessResult) {
+	sums := make(map[string]string, len(files))
+	groups := make(map[string][]string)
+	for _, file := range files {
+		// Un classeur illisible est laissé à la conversion, qui signalera l'erreur
+		sum, err := state.SHA256(file)
+		if err != nil {
+			continue
+		}
+		sums[file] = sum
+		groups[sum] = append(groups[sum], file)
+	}
+
+	var kept []string
+	var results []types.ProcessResult
+	for _, file := range files {
+		sum, ok := sums[file]
+		if !ok {
+			kept = append(kept, file)
+			continue
+		}
+		keeper := duplicateKeeper(groups[sum], state.WithSHA256(sum), state)
+		if keeper == file {
+			kept = append(kept, file)
+			continue
+		}
+		result := excludedResult(file, "")
+		result.InputSHA256 = sum
+		result.DuplicateOf = keeper
+		results = append(results, result)
+	}
+	return kept, results
+}
+
+// duplicateKeeper choisit le classeur converti parmi ceux au contenu identique :
+// un classeur déjà converti dont les PDF existent, sinon celui du lot au nom le
+// plus court
+func duplicateKeeper(group, converted []string, state *manifest.Manifest) string {
+	slices.Sort(converted)
+	for _, file := range converted {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		entry, _ := state.Lookup(file)
+		if allExist(entry.PDFs()) {
+			return file
+		}
+	}
+	return slices.MinFunc(group, func(a, b string) int {
+		if la, lb := len(filepath.Base(a)), len(filepath.Base(b)); la != lb {
+			return la - lb
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// excludedResult renvoie le résultat d'un classeur écarté avant la conversion
+func excludedResult(file, collision string) types.ProcessResult {
+	result := types.ProcessResult{
+		FileName:  filepath.Base(file),
+		InputPath: file,
+		StartedAt: time.Now(),
+		Excluded:  true,
+		Collision: collision,
+	}
+	if info, err := os.Stat(file); err == nil {
+		result.InputSize = info.Size()
+	}
+	return result
+}
+
+// owns indique si la dernière conversion du classeur a produit le PDF de chemin
+// stem (sans extension), ou ses PDF par feuille
+func owns(entry manifest.Entry, stem string) bool {
+	stem = strings.ToLower(stem)
+	for _, pdf := range entry.PDFs() {
+		name := strings.ToLower(strings.TrimSuffix(pdf, filepath.Ext(pdf)))
+		if name == stem || strings.HasPrefix(name, stem+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfExists indique si le PDF de chemin stem (sans extension) existe, ou en export
+// par feuille l'un des PDF <stem>_<feuille>.pdf
+func pdfExists(stem string, perSheet bool) bool {
+	if _, err := os.Stat(stem + ".pdf"); err == nil {
+		return true
+	}
+	if !perSheet {
+		return false
+	}
+	entries, err := os.ReadDir(filepath.Dir(stem))
+	if err != nil {
+		return false
+	}
+	prefix := strings.ToLower(filepath.Base(stem)) + "_"
+	for _, e := range entries {
+		name := strings.ToLower(e.Name())
+		if !e.IsDir() && strings.HasPrefix(name, prefix) && filepath.Ext(name) == ".pdf" {
+			return true
+		}
+	}
+	return false
+}
+
+func allExist(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/manifest"
+	"fredon_to_pdf/naming"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// planFixture décrit un lot : classeurs, PDF déjà présents et conversions passées
+type planFixture struct {
+	inputs map[string]string // nom du classeur -> contenu
+	pdfs   []string          // PDF présents dans le dossier de sortie
+	owners map[string]string // classeur -> PDF produit par sa dernière conversion
+}
+
+// setup crée le lot et renvoie sa configuration, ses classeurs triés et son état
+func (f planFixture) setup(t *testing.T, policy string) (*config.Config, []string, *manifest.Manifest) {
+	t.Helper()
+	cfg := &config.Config{
+		ExcelDir:        t.TempDir(),
+		OutputDir:       t.TempDir(),
+		OutputName:      naming.DefaultTemplate,
+		OverwritePolicy: policy,
+	}
+	var files []string
+	for name, content := range f.inputs {
+		path := filepath.Join(cfg.ExcelDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	for _, name := range f.pdfs {
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, name), []byte("%PDF-1.4"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := manifest.New(filepath.Join(cfg.OutputDir, manifest.FileName))
+	for input, pdf := range f.owners {
+		result := types.ProcessResult{
+			InputPath: filepath.Join(cfg.ExcelDir, input),
+			PdfPath:   filepath.Join(cfg.OutputDir, pdf),
+		}
+		if err := state.Record(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slices.Sort(files)
+	return cfg, files, state
+}
+
+func TestPlanOutputsCollisions(t *testing.T) {
+	// Client.xls et Client.xlsx produiraient tous deux Client.pdf
+	batch := planFixture{inputs: map[string]string{"Client.xls": "xls", "Client.xlsx": "xlsx"}}
+	existing := planFixture{inputs: map[string]string{"Client.xls": "xls"}, pdfs: []string{"Client.pdf"}}
+
+	tests := []struct {
+		name      string
+		fixture   planFixture
+		policy    string
+		planned   map[string]string // classeur retenu -> nom du PDF
+		collision string            // collision signalée pour Client.xlsx ou, seul, Client.xls
+		excluded  bool              // classeur écarté sans erreur
+		failed    bool              // classeur en échec
+	}{
+		{
+			name:      "lot, remplacement",
+			fixture:   batch,
+			policy:    types.OverwriteReplace,
+			planned:   map[string]string{"Client.xls": "Client", "Client.xlsx": "Client"},
+			collision: "même PDF que Client.xls, PDF écrasé",
+		},
+		{
+			name:      "lot, politique par défaut",
+			fixture:   batch,
+			planned:   map[string]string{"Client.xls": "Client", "Client.xlsx": "Client"},
+			collision: "même PDF que Client.xls, PDF écrasé",
+		},
+		{
+			name:      "lot, classeur écarté",
+			fixture:   batch,
+			policy:    types.OverwriteSkip,
+			planned:   map[string]string{"Client.xls": "Client"},
+			collision: "même PDF que Client.xls, non converti",
+			excluded:  true,
+		},
+		{
+			name:    "lot, échec",
+			fixture: batch,
+			policy:  types.OverwriteFail,
+			planned: map[string]string{"Client.xls": "Client"},
+			failed:  true,
+		},
+		{
+			name:      "lot, suffixe",
+			fixture:   batch,
+			policy:    types.OverwriteRename,
+			planned:   map[string]string{"Client.xls": "Client", "Client.xlsx": "Client (2)"},
+			collision: "même PDF que Client.xls, renommé en Client (2).pdf",
+		},
+		{
+			name: "lot, casse différente",
+			fixture: planFixture{
+				inputs: map[string]string{"Client.xls": "xls", "client.xlsx": "xlsx"},
+			},
+			policy:    types.OverwriteRename,
+			planned:   map[string]string{"Client.xls": "Client", "client.xlsx": "client (2)"},
+			collision: "même PDF que Client.xls, renommé en client (2).pdf",
+		},
+		{
+			name:    "PDF existant remplacé sans signalement",
+			fixture: existing,
+			policy:  types.OverwriteReplace,
+			planned: map[string]string{"Client.xls": "Client"},
+		},
+		{
+			name:      "PDF existant conservé",
+			fixture:   existing,
+			policy:    types.OverwriteSkip,
+			planned:   map[string]string{},
+			collision: "PDF existant, non converti",
+			excluded:  true,
+		},
+		{
+			name:    "PDF existant, échec",
+			fixture: existing,
+			policy:  types.OverwriteFail,
+			planned: map[string]string{},
+			failed:  true,
+		},
+		{
+			name: "PDF existant, premier suffixe libre",
+			fixture: planFixture{
+				inputs: map[string]string{"Client.xls": "xls"},
+				pdfs:   []string{"Client.pdf", "Client (2).pdf"},
+			},
+			policy:    types.OverwriteRename,
+			planned:   map[string]string{"Client.xls": "Client (3)"},
+			collision: "PDF existant, renommé en Client (3).pdf",
+		},
+		{
+			name: "PDF produit par le classeur lui-même",
+			fixture: planFixture{
+				inputs: map[string]string{"Client.xls": "xls"},
+				pdfs:   []string{"Client.pdf"},
+				owners: map[string]string{"Client.xls": "Client.pdf"},
+			},
+			policy:  types.OverwriteFail,
+			planned: map[string]string{"Client.xls": "Client"},
+		},
+		{
+			name: "suffixe attribué précédemment conservé",
+			fixture: planFixture{
+				inputs: map[string]string{"Client.xls": "xls"},
+				pdfs:   []string{"Client.pdf", "Client (2).pdf", "Client (3).pdf"},
+				owners: map[string]string{"Client.xls": "Client (3).pdf"},
+			},
+			policy:    types.OverwriteRename,
+			planned:   map[string]string{"Client.xls": "Client (3)"},
+			collision: "PDF existant, renommé en Client (3).pdf",
+		},
+		{
+			name: "propriétaire du PDF prioritaire",
+			fixture: planFixture{
+				inputs: map[string]string{"Client.xls": "xls", "Client.xlsx": "xlsx"},
+				pdfs:   []string{"Client.pdf"},
+				owners: map[string]string{"Client.xlsx": "Client.pdf"},
+			},
+			policy:  types.OverwriteSkip,
+			planned: map[string]string{"Client.xlsx": "Client"},
+			// Client.xls, premier dans l'ordre des chemins, cède le PDF
+			collision: "même PDF que Client.xlsx, non converti",
+			excluded:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, files, state := tt.fixture.setup(t, tt.policy)
+			planned, plan, results := planOutputs(cfg, files, state)
+
+			if len(planned) != len(tt.planned) {
+				t.Errorf("classeurs retenus = %v, attendu %v", baseNames(planned), tt.planned)
+			}
+			for _, file := range planned {
+				want, ok := tt.planned[filepath.Base(file)]
+				if !ok {
+					t.Errorf("%s retenu", filepath.Base(file))
+					continue
+				}
+				if got := plan.options[file].OutputName(file); got != want {
+					t.Errorf("%s : PDF %q, attendu %q", filepath.Base(file), got, want)
+				}
+			}
+
+			var collisions []string
+			for _, collision := range plan.collisions {
+				collisions = append(collisions, collision)
+			}
+			for _, result := range results {
+				if result.Excluded != tt.excluded {
+					t.Errorf("%s : écarté = %v, attendu %v", result.FileName, result.Excluded, tt.excluded)
+				}
+				if (result.Err != nil) != tt.failed {
+					t.Errorf("%s : erreur = %v", result.FileName, result.Err)
+				}
+				if result.Err != nil && !strings.Contains(result.Err.Error(), types.OverwriteFail) {
+					t.Errorf("%s : erreur = %v, politique non mentionnée", result.FileName, result.Err)
+				}
+				if result.Collision != "" {
+					collisions = append(collisions, result.Collision)
+				}
+			}
+			if wantResults := len(tt.fixture.inputs) - len(tt.planned); len(results) != wantResults {
+				t.Errorf("%d résultats, attendu %d", len(results), wantResults)
+			}
+			if tt.collision == "" && !tt.failed && len(collisions) > 0 {
+				t.Errorf("collisions = %v, aucune attendue", collisions)
+			}
+			if tt.collision != "" && (len(collisions) != 1 || collisions[0] != tt.collision) {
+				t.Errorf("collisions = %v, attendu %q", collisions, tt.collision)
+			}
+		})
+	}
+}
+
+func TestPlanOutputsDuplicates(t *testing.T) {
+	fixture := planFixture{inputs: map[string]string{
+		"Client (1).xls": "même contenu",
+		"Client.xls":     "même contenu",
+		"Autre.xls":      "autre contenu",
+	}}
+	cfg, files, state := fixture.setup(t, types.OverwriteFail)
+
+	planned, _, results := planOutputs(cfg, files, state)
+	if got := baseNames(planned); strings.Join(got, ",") != "Autre.xls,Client.xls" {
+		t.Errorf("classeurs retenus = %v", got)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d résultats, attendu 1", len(results))
+	}
+	if result := results[0]; result.FileName != "Client (1).xls" || !result.Excluded || filepath.Base(result.DuplicateOf) != "Client.xls" {
+		t.Errorf("doublon = %+v", result)
+	}
+}
+
+func TestPlanAnnotate(t *testing.T) {
+	plan := outputPlan{collisions: map[string]string{"b.xls": "même PDF que a.xls, PDF écrasé"}}
+	results := []types.ProcessResult{{InputPath: "a.xls"}, {InputPath: "b.xls"}}
+	plan.annotate(results)
+	if results[0].Collision != "" || results[1].Collision != "même PDF que a.xls, PDF écrasé" {
+		t.Errorf("collisions = %q, %q", results[0].Collision, results[1].Collision)
+	}
+}
+
+// baseNames renvoie le nom des fichiers, pour les messages d'erreur
+func baseNames(files []string) []string {
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	return names
+}
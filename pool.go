@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fredon_to_pdf/converter"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/queue"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// workerPool convertit en parallèle les tâches de la file de conversion ; chaque
+// worker dispose de ses propres convertisseurs, les moteurs n'étant pas partagés
+// entre goroutines
+type workerPool struct {
+	ctx       context.Context
+	queue     *queue.Queue
+	converter string          // moteur demandé par défaut
+	backends  []tools.Backend // moteurs correspondant à converter
+	timeout   time.Duration   // durée maximale d'une conversion, sans limite si nulle
+	results   chan types.ProcessResult
+	wg        sync.WaitGroup
+
+	closing   chan struct{}
+	stopping  chan struct{}
+	closeOnce sync.Once
+	stopOnce  sync.Once
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // conversions en cours, par tâche
+}
+
+// newWorkerPool démarre les workers, qui traitent les tâches en attente dans la
+// file, y compris celles laissées par une exécution précédente. L'annulation du
+// contexte interrompt les conversions en cours, remises en attente pour la
+// prochaine exécution.
+func newWorkerPool(ctx context.Context, q *queue.Queue, converter string, backends []tools.Backend, timeout time.Duration) *workerPool {
+	p := &workerPool{
+		ctx:       ctx,
+		queue:     q,
+		converter: converter,
+		backends:  backends,
+		timeout:   timeout,
+		results:   make(chan types.ProcessResult),
+		closing:   make(chan struct{}),
+		stopping:  make(chan struct{}),
+		running:   make(map[string]context.CancelFunc),
+	}
+
+	for i := 0; i < workerCount(backends); i++ {
+		p.wg.Add(1)
+		go func(worker int) {
+			defer p.wg.Done()
+			p.work(worker)
+		}(i + 1)
+	}
+
+	// Fermeture des résultats une fois tous les workers terminés
+	go func() {
+		p.wg.Wait()
+		close(p.results)
+	}()
+
+	return p
+}
+
+// workerCount renvoie le nombre de workers adapté aux moteurs et au nombre de CPU
+func workerCount(backends []tools.Backend) int {
+	if !tools.ThreadSafe(backends) {
+		return 1 // Un des moteurs ne supporte pas les conversions simultanées
+	}
+	return min(runtime.NumCPU(), 4) // Limite à 4 workers maximum pour éviter la surcharge
+}
+
+// work traite les tâches jusqu'à l'arrêt du pool ; worker identifie le worker
+// dans le journal
+func (p *workerPool) work(worker int) {
+	// Un convertisseur par moteur demandé ; les moteurs sont initialisés à la
+	// première conversion
+	converters := make(map[string]*converter.Converter)
+	defer func() {
+		for _, c := range converters {
+			c.Close()
+		}
+	}()
+
+	for {
+		changed := p.queue.Changed()
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.stopping:
+			return
+		default:
+		}
+
+		job, err := p.queue.Claim()
+		if err != nil {
+			helper.GErrorLn("%v", err)
+		}
+		if job == nil {
+			select {
+			case <-changed:
+			case <-p.closing:
+				return // File vide après Close
+			case <-p.stopping:
+				return
+			case <-p.ctx.Done():
+				return
+			}
+			continue
+		}
+
+		p.results <- p.run(job, converters, worker)
+		time.Sleep(100 * time.Millisecond) // Petit délai pour éviter la surcharge
+	}
+}
+
+// run convertit le classeur d'une tâche et enregistre son résultat dans la file.
+// Les événements de la conversion sont journalisés avec le nom du fichier, le
+// worker et le numéro de la tentative.
+func (p *workerPool) run(job *queue.Job, converters map[string]*converter.Converter, worker int) types.ProcessResult {
+	c := p.converterFor(job.Options.Backend, converters)
+	log := helper.Logger().With("file", filepath.Base(job.Input), "job", job.ID, "worker", worker, "attempt", job.Attempts)
+	log.Debug("conversion lancée", "input", job.Input, "output_dir", job.OutputDir)
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(p.ctx, p.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(p.ctx)
+	}
+	defer cancel()
+	p.mu.Lock()
+	p.running[job.ID] = cancel
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.running, job.ID)
+		p.mu.Unlock()
+	}()
+
+	result := c.Convert(helper.WithLogger(ctx, log), job.Input, job.OutputDir, job.Options.Export)
+	logResult(log, result)
+	switch {
+	case result.Cancelled && p.ctx.Err() != nil:
+		// Arrêt du programme : la tâche sera reprise à la prochaine exécution
+		if err := p.queue.Release(job.ID); err != nil {
+			helper.GWarningLn("%v", err)
+		}
+		return result
+	case result.Cancelled && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Cancelled = false
+	}
+	p.finish(job, result)
+	return result
+}
+
+// logResult journalise l'issue d'une conversion
+func logResult(log *slog.Logger, result types.ProcessResult) {
+	switch {
+	case result.Err == nil:
+		log.Info("conversion réussie", "backend", result.Backend, "pdfs", result.PDFs(), "pages", result.Pages, "duration", result.Duration)
+	case result.Cancelled:
+		log.Warn("conversion interrompue", "error", result.Err, "duration", result.Duration)
+	default:
+		log.Error("échec de la conversion", "error", result.Err, "engines", result.Attempts, "duration", result.Duration)
+	}
+}
+
+func (p *workerPool) finish(job *queue.Job, result types.ProcessResult) {
+	if err := p.queue.Finish(job.ID, result); err != nil {
+		helper.GWarningLn("%v", err)
+	}
+}
+
+// converterFor renvoie le convertisseur du moteur demandé par une tâche
+func (p *workerPool) converterFor(backend string, converters map[string]*converter.Converter) *converter.Converter {
+	if backend == "" {
+		backend = p.converter
+	}
+	if c, ok := converters[backend]; ok {
+		return c
+	}
+
+	// Moteur demandé lors d'une exécution précédente : s'il n'est plus disponible,
+	// les moteurs de l'exécution en cours prennent le relais
+	backends := p.backends
+	if backend != p.converter {
+		if candidates, err := tools.Candidates(backend); err == nil {
+			backends = candidates
+		} else {
+			helper.GWarningLn("%v ; moteurs utilisés : %s", err, backendNames(p.backends))
+		}
+	}
+	converters[backend] = converter.NewWithBackends(backends)
+	return converters[backend]
+}
+
+// cancelledResult décrit un fichier dont la conversion n'a pas été lancée
+func cancelledResult(file string) types.ProcessResult {
+	return types.ProcessResult{
+		FileName:  filepath.Base(file),
+		InputPath: file,
+		StartedAt: time.Now(),
+		Cancelled: true,
+		Err:       fmt.Errorf("conversion non lancée : traitement interrompu"),
+	}
+}
+
+// Submit ajoute un fichier à convertir dans le dossier de sortie donné ; son
+// résultat sera disponible sur Results
+func (p *workerPool) Submit(file, outputDir string, group string, opts queue.Options) (*queue.Job, error) {
+	if opts.Backend == "" {
+		opts.Backend = p.converter
+	}
+	return p.queue.Add(file, outputDir, opts, group)
+}
+
+// Cancel annule les tâches désignées, qu'elles soient en attente ou en cours
+func (p *workerPool) Cancel(jobs []*queue.Job) {
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	if err := p.queue.Cancel(ids...); err != nil {
+		helper.GWarningLn("%v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range ids {
+		if cancel, ok := p.running[id]; ok {
+			cancel()
+		}
+	}
+}
+
+// Results renvoie le canal des résultats, fermé une fois les workers arrêtés ; il
+// doit être lu en continu
+func (p *workerPool) Results() <-chan types.ProcessResult {
+	return p.results
+}
+
+// Close arrête les workers une fois la file vide
+func (p *workerPool) Close() {
+	p.closeOnce.Do(func() { close(p.closing) })
+}
+
+// Stop arrête les workers après leur conversion en cours ; les tâches en attente
+// restent dans la file pour la prochaine exécution
+func (p *workerPool) Stop() {
+	p.stopOnce.Do(func() { close(p.stopping) })
+}
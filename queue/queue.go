@@ -0,0 +1,659 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fredon_to_pdf/types"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// FileName est le nom de la base de la file de conversion, enregistrée dans le
+// dossier de sortie
+const FileName = ".fredon_queue.db"
+
+// Status est l'état d'une tâche de conversion
+type Status string
+
+const (
+	Pending   Status = "en_attente"
+	Running   Status = "en_cours"
+	Done      Status = "termine"
+	Failed    Status = "echec"
+	Cancelled Status = "annule"
+)
+
+// Statuses liste les états dans l'ordre du cycle de vie d'une tâche
+var Statuses = []Status{Pending, Running, Done, Failed, Cancelled}
+
+// Finished indique si la tâche ne sera plus traitée sans intervention
+func (s Status) Finished() bool {
+	return s == Done || s == Failed || s == Cancelled
+}
+
+// ParseStatus valide un état saisi par l'utilisateur
+func ParseStatus(s string) (Status, error) {
+	for _, status := range Statuses {
+		if string(status) == s {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("état de tâche inconnu : %s", s)
+}
+
+// Options regroupe les paramètres de conversion d'une tâche, conservés pour
+// qu'une tâche reprise après un redémarrage soit traitée à l'identique
+type Options struct {
+	Backend string `json:"backend,omitempty"` // moteur demandé, "auto" ou vide par défaut
+	Archive bool   `json:"archive,omitempty"` // serve : résultat du groupe livré en ZIP
+
+	Export types.ExportOptions `json:"export,omitempty"` // sélection des feuilles exportées
+}
+
+// Result conserve le détail d'une conversion terminée, pour les rapports
+type Result struct {
+	PdfPath     string        `json:"pdf,omitempty"`
+	Outputs     []string      `json:"pdfs,omitempty"` // export par feuille : tous les PDF produits
+	Backend     string        `json:"backend,omitempty"`
+	Engines     int           `json:"engines"` // nombre de moteurs essayés
+	StartedAt   time.Time     `json:"started_at"`
+	Duration    time.Duration `json:"duration"`
+	InputSize   int64         `json:"input_size"`
+	InputSHA256 string        `json:"sha256,omitempty"`
+	OutputSize  int64         `json:"pdf_size,omitempty"`
+	Pages       int           `json:"pages,omitempty"`
+	PDFA        string        `json:"pdfa,omitempty"`
+}
+
+// Job est une tâche de conversion d'un classeur
+type Job struct {
+	ID        string    `json:"id"`
+	Group     string    `json:"group,omitempty"` // tâches soumises ensemble (requête du service HTTP)
+	Input     string    `json:"input"`
+	OutputDir string    `json:"output_dir"`
+	Options   Options   `json:"options"`
+	Status    Status    `json:"status"`
+	Attempts  int       `json:"attempts"` // nombre de traitements lancés
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Error     string    `json:"error,omitempty"`
+	Result    *Result   `json:"result,omitempty"`
+}
+
+// ProcessResult reconstruit le résultat de conversion de la tâche
+func (j *Job) ProcessResult() types.ProcessResult {
+	result := types.ProcessResult{
+		FileName:  filepath.Base(j.Input),
+		InputPath: j.Input,
+		StartedAt: j.CreatedAt,
+		Export:    j.Options.Export,
+		Cancelled: j.Status == Cancelled,
+	}
+	if r := j.Result; r != nil {
+		result.PdfPath = r.PdfPath
+		result.Outputs = r.Outputs
+		result.Backend = r.Backend
+		result.Attempts = r.Engines
+		result.StartedAt = r.StartedAt
+		result.Duration = r.Duration
+		result.InputSize = r.InputSize
+		result.InputSHA256 = r.InputSHA256
+		result.OutputSize = r.OutputSize
+		result.Pages = r.Pages
+		result.PDFA = r.PDFA
+	}
+	switch {
+	case j.Status == Done:
+	case j.Error != "":
+		result.Err = errors.New(j.Error)
+	default:
+		result.Err = fmt.Errorf("conversion %s", j.Status)
+	}
+	return result
+}
+
+var (
+	jobsBucket    = []byte("jobs")
+	pendingBucket = []byte("pending") // index des tâches en attente, dans l'ordre d'arrivée
+	activeBucket  = []byte("active")  // index des tâches non terminées par classeur et dossier
+)
+
+// ErrNotFound est renvoyée pour un identifiant de tâche inconnu
+var ErrNotFound = errors.New("tâche inconnue")
+
+// Queue est une file de conversion persistante : les tâches survivent à l'arrêt
+// du programme et sont reprises au démarrage suivant
+type Queue struct {
+	db *bolt.DB
+
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// Open ouvre la file enregistrée dans path, en la créant si besoin. Les tâches
+// interrompues par un arrêt du programme sont remises en attente.
+func Open(path string) (*Queue, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("file de conversion %s utilisée par un autre processus", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir la file de conversion : %v", err)
+	}
+
+	q := &Queue{db: db, changed: make(chan struct{})}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(jobsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(pendingBucket); err != nil {
+			return err
+		}
+		if err := indexActive(tx); err != nil {
+			return err
+		}
+		return saveAll(tx, func(job *Job) bool {
+			if job.Status != Running {
+				return false
+			}
+			job.Status = Pending
+			return true
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("file de conversion invalide : %v", err)
+	}
+	return q, nil
+}
+
+// Close ferme la base
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Changed renvoie un canal fermé à la prochaine modification de la file
+func (q *Queue) Changed() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.changed
+}
+
+func (q *Queue) notify() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// update exécute fn dans une transaction et signale la modification
+func (q *Queue) update(fn func(tx *bolt.Tx) error) error {
+	if err := q.db.Update(fn); err != nil {
+		return fmt.Errorf("erreur de la file de conversion : %v", err)
+	}
+	q.notify()
+	return nil
+}
+
+// Add ajoute un classeur à convertir ; si le même classeur est déjà en attente
+// ou en cours de conversion vers ce dossier, la tâche existante est renvoyée.
+// Une tâche encore en attente reprend alors les options demandées.
+func (q *Queue) Add(input, outputDir string, opts Options, group string) (*Job, error) {
+	var job *Job
+	err := q.update(func(tx *bolt.Tx) error {
+		if k := tx.Bucket(activeBucket).Get(activeKey(input, outputDir)); k != nil {
+			var err error
+			if job, err = get(tx, k); err != nil {
+				return err
+			}
+		}
+		if job != nil {
+			if job.Status != Pending || reflect.DeepEqual(job.Options, opts) {
+				return nil
+			}
+			job.Options = opts
+			return save(tx, job)
+		}
+
+		seq, err := tx.Bucket(jobsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		job = &Job{
+			ID:        strconv.FormatUint(seq, 10),
+			Group:     group,
+			Input:     input,
+			OutputDir: outputDir,
+			Options:   opts,
+			Status:    Pending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		return save(tx, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Claim réserve la plus ancienne tâche en attente et la passe en cours ; nil est
+// renvoyé si aucune tâche n'est en attente
+func (q *Queue) Claim() (*Job, error) {
+	// Pas de signalement si la file est vide : les workers en attente seraient
+	// réveillés sans fin
+	var job *Job
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(pendingBucket).Cursor().First()
+		if k == nil {
+			return nil
+		}
+		var err error
+		if job, err = get(tx, k); err != nil {
+			return err
+		}
+		job.Status = Running
+		job.Attempts++
+		job.Error = ""
+		return save(tx, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur de la file de conversion : %v", err)
+	}
+	if job != nil {
+		q.notify()
+	}
+	return job, nil
+}
+
+// Finish enregistre le résultat de la conversion d'une tâche
+func (q *Queue) Finish(id string, result types.ProcessResult) error {
+	return q.modify(id, func(job *Job) {
+		job.Status = Done
+		job.Error = ""
+		switch {
+		case result.Cancelled:
+			job.Status = Cancelled
+		case result.Err != nil:
+			job.Status = Failed
+		}
+		if result.Err != nil {
+			job.Error = result.Err.Error()
+		}
+		job.Result = &Result{
+			PdfPath:     result.PdfPath,
+			Outputs:     result.Outputs,
+			Backend:     result.Backend,
+			Engines:     result.Attempts,
+			StartedAt:   result.StartedAt,
+			Duration:    result.Duration,
+			InputSize:   result.InputSize,
+			InputSHA256: result.InputSHA256,
+			OutputSize:  result.OutputSize,
+			Pages:       result.Pages,
+			PDFA:        result.PDFA,
+		}
+	})
+}
+
+// Release remet en attente une tâche interrompue par l'arrêt du programme
+func (q *Queue) Release(id string) error {
+	return q.modify(id, func(job *Job) {
+		if job.Status == Running {
+			job.Status = Pending
+		}
+	})
+}
+
+// Cancel annule les tâches encore en attente parmi ids ; les tâches en cours
+// doivent être interrompues par celui qui les traite
+func (q *Queue) Cancel(ids ...string) error {
+	for _, id := range ids {
+		err := q.modify(id, func(job *Job) {
+			if job.Status == Pending {
+				job.Status = Cancelled
+				job.Error = "conversion annulée avant son lancement"
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retry remet en attente les tâches en échec ou annulées désignées par ids, ou
+// toutes celles en échec si ids est vide. Renvoie le nombre de tâches remises.
+func (q *Queue) Retry(ids ...string) (int, error) {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	count := 0
+	err := q.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if _, err := get(tx, key(id)); err != nil {
+				return fmt.Errorf("%s : %v", id, err)
+			}
+		}
+		return saveAll(tx, func(job *Job) bool {
+			retry := job.Status == Failed
+			if len(ids) > 0 {
+				retry = wanted[job.ID] && (job.Status == Failed || job.Status == Cancelled)
+			}
+			if !retry {
+				return false
+			}
+			job.Status = Pending
+			count++
+			return true
+		})
+	})
+	return count, err
+}
+
+// Purge supprime les tâches terminées dans l'un des états donnés et dont la
+// dernière modification est antérieure à before. Renvoie le nombre de tâches
+// supprimées.
+func (q *Queue) Purge(statuses []Status, before time.Time) (int, error) {
+	count := 0
+	err := q.update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		err := each(tx, func(job *Job) error {
+			for _, status := range statuses {
+				if job.Status == status && status.Finished() && job.UpdatedAt.Before(before) {
+					keys = append(keys, key(job.ID))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := remove(tx, k); err != nil {
+				return err
+			}
+		}
+		count = len(keys)
+		return nil
+	})
+	return count, err
+}
+
+// Remove supprime des tâches, quel que soit leur état
+func (q *Queue) Remove(ids ...string) error {
+	return q.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			if err := remove(tx, key(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveDone supprime les tâches réussies parmi ids ; les tâches en échec ou
+// annulées sont conservées pour jobs retry ou jobs purge, les autres pour être
+// reprises
+func (q *Queue) RemoveDone(ids ...string) error {
+	return q.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			job, err := get(tx, key(id))
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if job.Status != Done {
+				continue
+			}
+			if err := remove(tx, key(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get renvoie une tâche
+func (q *Queue) Get(id string) (*Job, error) {
+	var job *Job
+	err := q.db.View(func(tx *bolt.Tx) error {
+		var err error
+		job, err = get(tx, key(id))
+		return err
+	})
+	return job, err
+}
+
+// List renvoie les tâches dans l'ordre d'arrivée, limitées aux états donnés
+// s'il y en a
+func (q *Queue) List(statuses ...Status) ([]*Job, error) {
+	return q.find(func(job *Job) bool {
+		if len(statuses) == 0 {
+			return true
+		}
+		for _, status := range statuses {
+			if job.Status == status {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Group renvoie les tâches soumises ensemble
+func (q *Queue) Group(group string) ([]*Job, error) {
+	return q.find(func(job *Job) bool {
+		return job.Group == group
+	})
+}
+
+// Counts renvoie le nombre de tâches par état
+func (q *Queue) Counts() (map[Status]int, error) {
+	counts := make(map[Status]int)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return each(tx, func(job *Job) error {
+			counts[job.Status]++
+			return nil
+		})
+	})
+	return counts, err
+}
+
+// Wait attend que toutes les tâches du groupe soient terminées et les renvoie
+func (q *Queue) Wait(ctx context.Context, group string) ([]*Job, error) {
+	for {
+		changed := q.Changed()
+		jobs, err := q.Group(group)
+		if err != nil {
+			return nil, err
+		}
+		if len(jobs) == 0 {
+			return nil, ErrNotFound
+		}
+		finished := true
+		for _, job := range jobs {
+			finished = finished && job.Status.Finished()
+		}
+		if finished {
+			return jobs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return jobs, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (q *Queue) find(match func(job *Job) bool) ([]*Job, error) {
+	var jobs []*Job
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return each(tx, func(job *Job) error {
+			if match(job) {
+				jobs = append(jobs, job)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur de la file de conversion : %v", err)
+	}
+	return jobs, nil
+}
+
+// modify applique fn à une tâche existante
+func (q *Queue) modify(id string, fn func(job *Job)) error {
+	return q.update(func(tx *bolt.Tx) error {
+		job, err := get(tx, key(id))
+		if err != nil {
+			return err
+		}
+		fn(job)
+		return save(tx, job)
+	})
+}
+
+// key convertit un identifiant en clé ; les clés big-endian conservent l'ordre
+// d'arrivée des tâches
+func key(id string) []byte {
+	seq, _ := strconv.ParseUint(id, 10, 64)
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
+
+func get(tx *bolt.Tx, k []byte) (*Job, error) {
+	data := tx.Bucket(jobsBucket).Get(k)
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// activeKey identifie un classeur à convertir vers un dossier ; le séparateur
+// ne peut figurer dans aucun chemin
+func activeKey(input, outputDir string) []byte {
+	return []byte(outputDir + "\x00" + input)
+}
+
+// indexActive construit l'index des tâches non terminées, absent des files
+// créées par une version précédente
+func indexActive(tx *bolt.Tx) error {
+	if tx.Bucket(activeBucket) != nil {
+		return nil
+	}
+	active, err := tx.CreateBucket(activeBucket)
+	if err != nil {
+		return err
+	}
+	return each(tx, func(job *Job) error {
+		if job.Status.Finished() {
+			return nil
+		}
+		return active.Put(activeKey(job.Input, job.OutputDir), key(job.ID))
+	})
+}
+
+// save enregistre la tâche et tient à jour les index des tâches en attente et
+// des tâches non terminées
+func save(tx *bolt.Tx, job *Job) error {
+	job.UpdatedAt = time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	k := key(job.ID)
+	if err := tx.Bucket(jobsBucket).Put(k, data); err != nil {
+		return err
+	}
+
+	active := tx.Bucket(activeBucket)
+	ak := activeKey(job.Input, job.OutputDir)
+	if !job.Status.Finished() {
+		if err := active.Put(ak, k); err != nil {
+			return err
+		}
+	} else if bytes.Equal(active.Get(ak), k) {
+		if err := active.Delete(ak); err != nil {
+			return err
+		}
+	}
+
+	if job.Status == Pending {
+		return tx.Bucket(pendingBucket).Put(k, []byte{})
+	}
+	return tx.Bucket(pendingBucket).Delete(k)
+}
+
+// remove supprime une tâche et ses entrées d'index
+func remove(tx *bolt.Tx, k []byte) error {
+	job, err := get(tx, k)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	active := tx.Bucket(activeBucket)
+	ak := activeKey(job.Input, job.OutputDir)
+	if bytes.Equal(active.Get(ak), k) {
+		if err := active.Delete(ak); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(jobsBucket).Delete(k); err != nil {
+		return err
+	}
+	return tx.Bucket(pendingBucket).Delete(k)
+}
+
+func each(tx *bolt.Tx, fn func(job *Job) error) error {
+	return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+		job := &Job{}
+		if err := json.Unmarshal(v, job); err != nil {
+			return err
+		}
+		return fn(job)
+	})
+}
+
+// saveAll enregistre les tâches modifiées par fn, qui renvoie true pour chacune
+// d'elles ; la base ne peut pas être modifiée pendant son parcours
+func saveAll(tx *bolt.Tx, fn func(job *Job) bool) error {
+	var modified []*Job
+	err := each(tx, func(job *Job) error {
+		if fn(job) {
+			modified = append(modified, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, job := range modified {
+		if err := save(tx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package queue
+
+import (
+	"errors"
+	"fredon_to_pdf/types"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestQueue(t *testing.T) (*Queue, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	q, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q, path
+}
+
+func add(t *testing.T, q *Queue, input string) *Job {
+	t.Helper()
+	job, err := q.Add(input, "out", Options{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func claim(t *testing.T, q *Queue) *Job {
+	t.Helper()
+	job, err := q.Claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func status(t *testing.T, q *Queue, id string) Status {
+	t.Helper()
+	job, err := q.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job.Status
+}
+
+func TestClaimOrder(t *testing.T) {
+	q, _ := openTestQueue(t)
+	a := add(t, q, "a.xlsx")
+	b := add(t, q, "b.xlsx")
+
+	for _, want := range []*Job{a, b} {
+		job := claim(t, q)
+		if job == nil || job.ID != want.ID {
+			t.Fatalf("tâche réservée = %v, attendu %s", job, want.ID)
+		}
+		if job.Status != Running || job.Attempts != 1 {
+			t.Errorf("tâche %s : état %s, %d tentatives", job.ID, job.Status, job.Attempts)
+		}
+	}
+	if job := claim(t, q); job != nil {
+		t.Fatalf("tâche réservée dans une file vide : %s", job.ID)
+	}
+}
+
+func TestAddDeduplicates(t *testing.T) {
+	q, _ := openTestQueue(t)
+	first := add(t, q, "a.xlsx")
+
+	// Même classeur en attente : la tâche reprend les nouvelles options
+	opts := Options{Backend: "native"}
+	again, err := q.Add("a.xlsx", "out", opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || again.Options.Backend != opts.Backend {
+		t.Fatalf("tâche = %s %+v, attendu %s %+v", again.ID, again.Options, first.ID, opts)
+	}
+
+	// Autre dossier de sortie : nouvelle tâche
+	other, err := q.Add("a.xlsx", "ailleurs", Options{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == first.ID {
+		t.Fatal("tâche partagée entre deux dossiers de sortie")
+	}
+
+	// Tâche en cours : renvoyée sans modification
+	claim(t, q)
+	running, err := q.Add("a.xlsx", "out", Options{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running.ID != first.ID || running.Options.Backend != opts.Backend {
+		t.Fatalf("tâche en cours remplacée : %s %+v", running.ID, running.Options)
+	}
+
+	// Tâche terminée : une nouvelle conversion est mise en attente
+	if err := q.Finish(first.ID, types.ProcessResult{}); err != nil {
+		t.Fatal(err)
+	}
+	next := add(t, q, "a.xlsx")
+	if next.ID == first.ID || next.Status != Pending {
+		t.Fatalf("tâche terminée réutilisée : %s %s", next.ID, next.Status)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		name   string
+		result types.ProcessResult
+		want   Status
+	}{
+		{"réussite", types.ProcessResult{PdfPath: "out/a.pdf", Pages: 2}, Done},
+		{"échec", types.ProcessResult{Err: errors.New("classeur illisible")}, Failed},
+		{"annulation", types.ProcessResult{Err: errors.New("conversion annulée"), Cancelled: true}, Cancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := openTestQueue(t)
+			job := add(t, q, "a.xlsx")
+			claim(t, q)
+			if err := q.Finish(job.ID, tt.result); err != nil {
+				t.Fatal(err)
+			}
+
+			job, err := q.Get(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Status != tt.want {
+				t.Fatalf("état = %s, attendu %s", job.Status, tt.want)
+			}
+			result := job.ProcessResult()
+			if (result.Err == nil) != (tt.result.Err == nil) || result.PdfPath != tt.result.PdfPath || result.Pages != tt.result.Pages {
+				t.Errorf("résultat = %+v, attendu %+v", result, tt.result)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	q, _ := openTestQueue(t)
+	job := add(t, q, "a.xlsx")
+	claim(t, q)
+
+	if err := q.Release(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(t, q, job.ID); s != Pending {
+		t.Fatalf("état après libération = %s, attendu %s", s, Pending)
+	}
+	again := claim(t, q)
+	if again == nil || again.ID != job.ID || again.Attempts != 2 {
+		t.Fatalf("tâche reprise = %+v", again)
+	}
+
+	// Une tâche terminée n'est pas remise en attente
+	if err := q.Finish(job.ID, types.ProcessResult{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Release(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(t, q, job.ID); s != Done {
+		t.Fatalf("état après libération d'une tâche terminée = %s", s)
+	}
+}
+
+func TestReopenResumesRunningJobs(t *testing.T) {
+	q, path := openTestQueue(t)
+	job := add(t, q, "a.xlsx")
+	claim(t, q)
+	q.Close()
+
+	q, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if s := status(t, q, job.ID); s != Pending {
+		t.Fatalf("état après redémarrage = %s, attendu %s", s, Pending)
+	}
+	if again := add(t, q, "a.xlsx"); again.ID != job.ID {
+		t.Fatalf("tâche dupliquée après redémarrage : %s, attendu %s", again.ID, job.ID)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	q, _ := openTestQueue(t)
+	running := add(t, q, "a.xlsx")
+	pending := add(t, q, "b.xlsx")
+	claim(t, q)
+
+	if err := q.Cancel(running.ID, pending.ID); err != nil {
+		t.Fatal(err)
+	}
+	// La tâche en cours doit être interrompue par son worker
+	if s := status(t, q, running.ID); s != Running {
+		t.Errorf("tâche en cours : état %s, attendu %s", s, Running)
+	}
+	if s := status(t, q, pending.ID); s != Cancelled {
+		t.Errorf("tâche en attente : état %s, attendu %s", s, Cancelled)
+	}
+	if job := claim(t, q); job != nil {
+		t.Fatalf("tâche annulée réservée : %s", job.ID)
+	}
+	if err := q.Cancel("999"); err == nil {
+		t.Fatal("annulation d'une tâche inconnue acceptée")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	q, _ := openTestQueue(t)
+	failed := add(t, q, "a.xlsx")
+	cancelled := add(t, q, "b.xlsx")
+	done := add(t, q, "c.xlsx")
+	for range 3 {
+		claim(t, q)
+	}
+	q.Finish(failed.ID, types.ProcessResult{Err: errors.New("échec")})
+	q.Finish(cancelled.ID, types.ProcessResult{Err: errors.New("annulée"), Cancelled: true})
+	q.Finish(done.ID, types.ProcessResult{})
+
+	// Sans identifiant, seules les tâches en échec sont reprises
+	n, err := q.Retry()
+	if err != nil || n != 1 {
+		t.Fatalf("Retry() = %d, %v, attendu 1", n, err)
+	}
+	if s := status(t, q, failed.ID); s != Pending {
+		t.Errorf("tâche en échec : état %s, attendu %s", s, Pending)
+	}
+
+	// Une tâche annulée est reprise si elle est désignée, pas une tâche réussie
+	n, err = q.Retry(cancelled.ID, done.ID)
+	if err != nil || n != 1 {
+		t.Fatalf("Retry(annulée, réussie) = %d, %v, attendu 1", n, err)
+	}
+	if s := status(t, q, cancelled.ID); s != Pending {
+		t.Errorf("tâche annulée : état %s, attendu %s", s, Pending)
+	}
+	if s := status(t, q, done.ID); s != Done {
+		t.Errorf("tâche réussie : état %s, attendu %s", s, Done)
+	}
+
+	if _, err := q.Retry("999"); err == nil {
+		t.Fatal("reprise d'une tâche inconnue acceptée")
+	}
+}
+
+func TestRemoveDoneAndPurge(t *testing.T) {
+	q, _ := openTestQueue(t)
+	done := add(t, q, "a.xlsx")
+	failed := add(t, q, "b.xlsx")
+	pending := add(t, q, "c.xlsx")
+	claim(t, q)
+	claim(t, q)
+	q.Finish(done.ID, types.ProcessResult{})
+	q.Finish(failed.ID, types.ProcessResult{Err: errors.New("échec")})
+
+	if err := q.RemoveDone(done.ID, failed.ID, pending.ID, "999"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Get(done.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("tâche réussie conservée : %v", err)
+	}
+	if s := status(t, q, failed.ID); s != Failed {
+		t.Errorf("tâche en échec : état %s, attendu %s", s, Failed)
+	}
+	if s := status(t, q, pending.ID); s != Pending {
+		t.Errorf("tâche en attente : état %s, attendu %s", s, Pending)
+	}
+
+	// Purge limitée aux états et à l'ancienneté demandés
+	n, err := q.Purge([]Status{Failed}, time.Now().Add(-time.Hour))
+	if err != nil || n != 0 {
+		t.Fatalf("Purge(récentes) = %d, %v, attendu 0", n, err)
+	}
+	n, err = q.Purge([]Status{Failed, Pending}, time.Now().Add(time.Second))
+	if err != nil || n != 1 {
+		t.Fatalf("Purge() = %d, %v, attendu 1", n, err)
+	}
+	jobs, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != pending.ID {
+		t.Fatalf("tâches restantes = %v", jobs)
+	}
+
+	// La tâche en attente, toujours indexée, n'est pas dupliquée
+	if again := add(t, q, "c.xlsx"); again.ID != pending.ID {
+		t.Fatalf("tâche dupliquée : %s, attendu %s", again.ID, pending.ID)
+	}
+}
+
+// Une exécution en ligne de commande retire de la file ses tâches réussies ; celles
+// en échec ou annulées restent visibles et peuvent être relancées
+func TestFailedJobsKeptForRetry(t *testing.T) {
+	q, path := openTestQueue(t)
+	done := add(t, q, "a.xlsx")
+	failed := add(t, q, "b.xlsx")
+	cancelled := add(t, q, "c.xlsx")
+	claim(t, q)
+	claim(t, q)
+	q.Finish(done.ID, types.ProcessResult{})
+	q.Finish(failed.ID, types.ProcessResult{Err: errors.New("classeur illisible")})
+	if err := q.Cancel(cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.RemoveDone(done.ID, failed.ID, cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exécution suivante : jobs list --status echec puis jobs retry
+	q.Close()
+	q, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	jobs, err := q.List(Failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != failed.ID || jobs[0].Error != "classeur illisible" {
+		t.Fatalf("tâches en échec = %v", jobs)
+	}
+	if jobs, err := q.List(Cancelled); err != nil || len(jobs) != 1 {
+		t.Fatalf("tâches annulées = %v, %v", jobs, err)
+	}
+
+	if n, err := q.Retry(); err != nil || n != 1 {
+		t.Fatalf("Retry() = %d, %v, attendu 1", n, err)
+	}
+	if job := claim(t, q); job == nil || job.ID != failed.ID || job.Attempts != 2 {
+		t.Fatalf("tâche relancée = %+v, attendu %s", job, failed.ID)
+	}
+	if n, err := q.Retry(cancelled.ID); err != nil || n != 1 {
+		t.Fatalf("Retry(annulée) = %d, %v, attendu 1", n, err)
+	}
+}
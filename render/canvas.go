@@ -0,0 +1,98 @@
+package render
+
+import (
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/workbook"
+)
+
+// canvas convertit les coordonnées de la feuille (points, origine en haut à gauche
+// de la zone imprimée) en coordonnées de page PDF, en appliquant l'échelle
+type canvas struct {
+	page      *pdf.Page
+	scale     float64
+	left, top float64
+}
+
+func (c *canvas) x(v float64) float64 {
+	return c.left + v*c.scale
+}
+
+func (c *canvas) y(v float64) float64 {
+	return c.top - v*c.scale
+}
+
+func (c *canvas) setFill(color workbook.Color) {
+	c.page.SetFillColor(color.R, color.G, color.B)
+}
+
+func (c *canvas) setStroke(color workbook.Color) {
+	c.page.SetStrokeColor(color.R, color.G, color.B)
+}
+
+func (c *canvas) setLineWidth(w float64) {
+	c.page.SetLineWidth(max(w*c.scale, 0.1))
+}
+
+func (c *canvas) fillRect(x, y, w, h float64) {
+	c.page.FillRect(c.x(x), c.y(y+h), w*c.scale, h*c.scale)
+}
+
+func (c *canvas) line(x1, y1, x2, y2 float64) {
+	c.page.Line(c.x(x1), c.y(y1), c.x(x2), c.y(y2))
+}
+
+func (c *canvas) clip(x, y, w, h float64) {
+	c.page.PushClip(c.x(x), c.y(y+h), w*c.scale, h*c.scale)
+}
+
+func (c *canvas) unclip() {
+	c.page.PopClip()
+}
+
+func (c *canvas) text(font pdf.Font, size, x, baseline float64, s string) {
+	c.page.Text(font, size*c.scale, c.x(x), c.y(baseline), s)
+}
+
+// edge trace un côté de bordure selon son style
+func (c *canvas) edge(e workbook.Edge, x1, y1, x2, y2 float64) {
+	if e.Style == workbook.BorderNone {
+		return
+	}
+	c.setStroke(e.Color)
+
+	width := 0.5
+	switch e.Style {
+	case workbook.BorderHair:
+		width = 0.25
+	case workbook.BorderMedium:
+		width = 1
+	case workbook.BorderThick:
+		width = 1.5
+	}
+	c.setLineWidth(width)
+
+	switch e.Style {
+	case workbook.BorderDashed:
+		c.page.SetDash(3*c.scale, 1.5*c.scale)
+	case workbook.BorderDotted:
+		c.page.SetDash(1*c.scale, 1*c.scale)
+	}
+
+	if e.Style == workbook.BorderDouble {
+		// Deux traits fins de part et d'autre de la ligne de séparation
+		const gap = 0.75
+		if y1 == y2 {
+			c.line(x1, y1-gap, x2, y2-gap)
+			c.line(x1, y1+gap, x2, y2+gap)
+		} else {
+			c.line(x1-gap, y1, x2-gap, y2)
+			c.line(x1+gap, y1, x2+gap, y2)
+		}
+	} else {
+		c.line(x1, y1, x2, y2)
+	}
+
+	if e.Style == workbook.BorderDashed || e.Style == workbook.BorderDotted {
+		c.page.SetDash(0, 0)
+	}
+}
@@ -0,0 +1,413 @@
+package render
+
+import (
+	"errors"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/workbook"
+	"strings"
+)
+
+const (
+	cellPadding = 2.0 // marge intérieure horizontale des cellules, en points
+	lineSpacing = 1.2 // interligne du texte renvoyé à la ligne
+)
+
+// gridColor est la couleur du quadrillage imprimé
+var gridColor = workbook.Color{R: 0xC0, G: 0xC0, B: 0xC0}
+
+// Workbook dessine toutes les feuilles visibles du classeur, comme l'export PDF d'Excel
+func Workbook(wb *workbook.Workbook) (*pdf.Document, error) {
+	var sheets []*workbook.Sheet
+	for _, s := range wb.Sheets {
+		if !s.Hidden {
+			sheets = append(sheets, s)
+		}
+	}
+	return Sheets(wb, sheets)
+}
+
+// ErrEmpty est renvoyée lorsque les feuilles ne contiennent rien à imprimer
+var ErrEmpty = errors.New("aucune donnée à imprimer")
+
+// Sheets dessine les feuilles données, dans l'ordre, dans un même document
+func Sheets(wb *workbook.Workbook, sheets []*workbook.Sheet) (*pdf.Document, error) {
+	doc := pdf.New()
+	for _, s := range sheets {
+		renderSheet(doc, s, wb.Date1904)
+	}
+	if doc.PageCount() == 0 {
+		return nil, ErrEmpty
+	}
+	return doc, nil
+}
+
+// layout décrit la position des lignes et colonnes imprimées d'une feuille
+type layout struct {
+	sheet     *workbook.Sheet
+	date1904  bool
+	area      workbook.Range
+	colPos    []float64 // position cumulée des colonnes de la zone (len = nb colonnes + 1)
+	rowPos    []float64
+	merges    map[workbook.Ref]workbook.Range // plages fusionnées indexées par cellule couverte
+	gridlines bool
+}
+
+func renderSheet(doc *pdf.Document, s *workbook.Sheet, date1904 bool) {
+	var area workbook.Range
+	if s.PageSetup.PrintArea != nil {
+		area = *s.PageSetup.PrintArea
+	} else {
+		used, ok := s.UsedRange()
+		if !ok {
+			return
+		}
+		area = used
+	}
+
+	l := &layout{sheet: s, date1904: date1904, area: area, merges: make(map[workbook.Ref]workbook.Range)}
+	l.colPos = make([]float64, 1, area.Last.Col-area.First.Col+2)
+	for c := area.First.Col; c <= area.Last.Col; c++ {
+		l.colPos = append(l.colPos, l.colPos[len(l.colPos)-1]+s.ColWidthPoints(c))
+	}
+	l.rowPos = make([]float64, 1, area.Last.Row-area.First.Row+2)
+	for r := area.First.Row; r <= area.Last.Row; r++ {
+		l.rowPos = append(l.rowPos, l.rowPos[len(l.rowPos)-1]+s.RowHeightPoints(r))
+	}
+	for _, m := range s.Merges {
+		for r := m.First.Row; r <= m.Last.Row; r++ {
+			for c := m.First.Col; c <= m.Last.Col; c++ {
+				l.merges[workbook.Ref{Row: r, Col: c}] = m
+			}
+		}
+	}
+
+	ps := s.PageSetup
+	l.gridlines = ps.Gridlines
+	pageW, pageH := ps.PaperDimensions()
+	printW := pageW - ps.Margins.Left - ps.Margins.Right
+	printH := pageH - ps.Margins.Top - ps.Margins.Bottom
+	totalW := l.colPos[len(l.colPos)-1]
+	totalH := l.rowPos[len(l.rowPos)-1]
+	if totalW <= 0 || totalH <= 0 || printW <= 0 || printH <= 0 {
+		return
+	}
+
+	scale := 1.0
+	if ps.Scale > 0 {
+		scale = float64(ps.Scale) / 100
+	}
+	if ps.FitToPage {
+		scale = 1
+		if ps.FitToWidth > 0 {
+			scale = min(scale, printW*float64(ps.FitToWidth)/totalW)
+		}
+		if ps.FitToHeight > 0 {
+			scale = min(scale, printH*float64(ps.FitToHeight)/totalH)
+		}
+	}
+	scale = max(scale, 0.1)
+
+	// Lignes répétées en haut des pages qui ne les contiennent pas déjà, limitées
+	// à la zone imprimée et ignorées si elles ne laissent pas de place au reste
+	var title [2]int
+	titleH := 0.0
+	if t := ps.TitleRows; t != nil {
+		first := max(t[0], area.First.Row) - area.First.Row
+		last := min(t[1], area.Last.Row) - area.First.Row + 1
+		if first < last && l.rowPos[last]-l.rowPos[first] < printH/scale {
+			title = [2]int{first, last}
+			titleH = l.rowPos[last] - l.rowPos[first]
+		}
+	}
+	repeat := func(start int) bool { return titleH > 0 && start >= title[1] }
+
+	// Découpage en pages : vers le bas, puis vers la droite (ordre par défaut d'Excel)
+	colBands := splitBands(l.colPos, func(int) float64 { return printW / scale })
+	rowBands := splitBands(l.rowPos, func(start int) float64 {
+		if repeat(start) {
+			return printH/scale - titleH
+		}
+		return printH / scale
+	})
+	for _, cb := range colBands {
+		for _, rb := range rowBands {
+			page := doc.AddPage(pageW, pageH)
+			top := pageH - ps.Margins.Top
+			page.PushClip(ps.Margins.Left, ps.Margins.Bottom, printW, printH)
+			if repeat(rb[0]) {
+				l.drawBand(&canvas{
+					page:  page,
+					scale: scale,
+					left:  ps.Margins.Left - l.colPos[cb[0]]*scale,
+					top:   top + l.rowPos[title[0]]*scale,
+				}, cb, title)
+				top -= titleH * scale
+			}
+			c := &canvas{
+				page:  page,
+				scale: scale,
+				left:  ps.Margins.Left - l.colPos[cb[0]]*scale,
+				top:   top + l.rowPos[rb[0]]*scale,
+			}
+			l.drawBand(c, cb, rb)
+			page.PopClip()
+		}
+	}
+}
+
+// splitBands découpe une suite de positions cumulées en tranches tenant chacune
+// dans la longueur renvoyée par limit pour sa première position
+func splitBands(pos []float64, limit func(start int) float64) [][2]int {
+	var bands [][2]int
+	n := len(pos) - 1
+	start := 0
+	for start < n {
+		end := start + 1
+		for end < n && pos[end+1]-pos[start] <= limit(start) {
+			end++
+		}
+		bands = append(bands, [2]int{start, end})
+		start = end
+	}
+	return bands
+}
+
+// rect renvoie la position d'une plage de cellules relativement à la zone imprimée
+func (l *layout) rect(r workbook.Range) (x, y, w, h float64) {
+	clampCol := func(c int) int { return max(0, min(c-l.area.First.Col, len(l.colPos)-1)) }
+	clampRow := func(r int) int { return max(0, min(r-l.area.First.Row, len(l.rowPos)-1)) }
+	x = l.colPos[clampCol(r.First.Col)]
+	y = l.rowPos[clampRow(r.First.Row)]
+	w = l.colPos[clampCol(r.Last.Col+1)] - x
+	h = l.rowPos[clampRow(r.Last.Row+1)] - y
+	return
+}
+
+// drawBand dessine les cellules d'une page : fonds, textes puis bordures
+func (l *layout) drawBand(c *canvas, cols, rows [2]int) {
+	type cellBox struct {
+		rng  workbook.Range
+		cell *workbook.Cell
+	}
+
+	var boxes []cellBox
+	var all []cellBox
+	var grid []workbook.Range // cellules du quadrillage, plages fusionnées comprises
+	seen := make(map[workbook.Range]bool)
+	for ri := rows[0]; ri < rows[1]; ri++ {
+		for ci := cols[0]; ci < cols[1]; ci++ {
+			ref := workbook.Ref{Row: l.area.First.Row + ri, Col: l.area.First.Col + ci}
+			cell := l.sheet.Cells[ref]
+			all = append(all, cellBox{rng: workbook.Range{First: ref, Last: ref}, cell: cell})
+
+			// Une plage fusionnée est dessinée une seule fois, avec le contenu de sa première cellule
+			if m, ok := l.merges[ref]; ok {
+				if seen[m] {
+					continue
+				}
+				seen[m] = true
+				boxes = append(boxes, cellBox{rng: m, cell: l.sheet.Cells[m.First]})
+				grid = append(grid, m)
+				continue
+			}
+			grid = append(grid, workbook.Range{First: ref, Last: ref})
+			if cell != nil {
+				boxes = append(boxes, cellBox{rng: workbook.Range{First: ref, Last: ref}, cell: cell})
+			}
+		}
+	}
+
+	for _, b := range boxes {
+		style := workbook.StyleOf(b.cell)
+		if style.Fill != nil {
+			x, y, w, h := l.rect(b.rng)
+			c.setFill(*style.Fill)
+			c.fillRect(x, y, w, h)
+		}
+	}
+
+	// Quadrillage gris clair, sous le texte et les bordures
+	if l.gridlines {
+		c.setStroke(gridColor)
+		c.setLineWidth(0.25)
+		for _, rng := range grid {
+			x, y, w, h := l.rect(rng)
+			if w <= 0 || h <= 0 {
+				continue
+			}
+			c.line(x, y, x+w, y)
+			c.line(x, y+h, x+w, y+h)
+			c.line(x, y, x, y+h)
+			c.line(x+w, y, x+w, y+h)
+		}
+	}
+
+	for _, b := range boxes {
+		if b.cell == nil || b.cell.Type == workbook.CellEmpty {
+			continue
+		}
+		l.drawText(c, b.rng, b.cell, cols)
+	}
+
+	for _, b := range all {
+		if b.cell == nil || b.cell.Style == nil {
+			continue
+		}
+		x, y, w, h := l.rect(b.rng)
+		border := b.cell.Style.Border
+		c.edge(border.Top, x, y, x+w, y)
+		c.edge(border.Bottom, x, y+h, x+w, y+h)
+		c.edge(border.Left, x, y, x, y+h)
+		c.edge(border.Right, x+w, y, x+w, y+h)
+	}
+}
+
+func (l *layout) drawText(c *canvas, rng workbook.Range, cell *workbook.Cell, cols [2]int) {
+	style := workbook.StyleOf(cell)
+	text := cell.Text(l.date1904)
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	font := pdf.FontFor(style.Font.Bold, style.Font.Italic)
+	size := style.Font.Size
+	x, y, w, h := l.rect(rng)
+
+	align := style.HAlign
+	if align == workbook.AlignGeneral {
+		switch cell.Type {
+		case workbook.CellNumber:
+			align = workbook.AlignRight
+		case workbook.CellBool, workbook.CellError:
+			align = workbook.AlignCenter
+		default:
+			align = workbook.AlignLeft
+		}
+	}
+
+	var lines []string
+	if style.Wrap {
+		lines = wrapText(text, font, size, w-2*cellPadding)
+	} else {
+		lines = []string{strings.ReplaceAll(text, "\n", " ")}
+	}
+
+	// Débordement sur les cellules vides voisines pour le texte non renvoyé à la ligne
+	clipX, clipW := x, w
+	if !style.Wrap && rng.First == rng.Last && cell.Type == workbook.CellString {
+		textW := font.Width(lines[0], size) + 2*cellPadding
+		if textW > w {
+			clipX, clipW = l.overflow(rng.First, x, w, textW, align, cols)
+		}
+	}
+
+	lineH := size * lineSpacing
+	blockH := lineH * float64(len(lines))
+	var baseline float64
+	switch style.VAlign {
+	case workbook.AlignTop:
+		baseline = y + size
+	case workbook.AlignMiddle:
+		baseline = y + (h-blockH)/2 + size
+	default:
+		baseline = y + h - blockH + size - size*0.05
+	}
+
+	c.clip(clipX, y, clipW, h)
+	c.setFill(style.Font.Color)
+	c.setStroke(style.Font.Color)
+	for i, line := range lines {
+		lineW := font.Width(line, size)
+		var tx float64
+		switch align {
+		case workbook.AlignRight:
+			tx = x + w - cellPadding - lineW
+		case workbook.AlignCenter, workbook.AlignCenterContinuous:
+			tx = x + (w-lineW)/2
+		default:
+			tx = x + cellPadding
+		}
+		ty := baseline + float64(i)*lineH
+		c.text(font, size, tx, ty, line)
+		if style.Font.Underline {
+			c.setLineWidth(size / 18)
+			c.line(tx, ty+size*0.12, tx+lineW, ty+size*0.12)
+		}
+	}
+	c.unclip()
+}
+
+// overflow étend la zone de texte sur les cellules vides adjacentes, comme le fait Excel
+func (l *layout) overflow(ref workbook.Ref, x, w, textW float64, align workbook.HAlign, cols [2]int) (float64, float64) {
+	free := func(col int) bool {
+		other := workbook.Ref{Row: ref.Row, Col: col}
+		if _, merged := l.merges[other]; merged {
+			return false
+		}
+		cell := l.sheet.Cells[other]
+		return cell == nil || cell.Type == workbook.CellEmpty
+	}
+
+	left, right := x, x+w
+	firstCol := l.area.First.Col + cols[0]
+	lastCol := l.area.First.Col + cols[1] - 1
+	grow := func(toRight bool, need float64) {
+		col := ref.Col
+		for need > 0 {
+			if toRight {
+				col++
+				if col > lastCol || !free(col) {
+					return
+				}
+			} else {
+				col--
+				if col < firstCol || !free(col) {
+					return
+				}
+			}
+			other := workbook.Ref{Row: ref.Row, Col: col}
+			cx, _, width, _ := l.rect(workbook.Range{First: other, Last: other})
+			if toRight {
+				right = cx + width
+			} else {
+				left = cx
+			}
+			need -= width
+		}
+	}
+
+	switch align {
+	case workbook.AlignRight:
+		grow(false, textW-w)
+	case workbook.AlignCenter, workbook.AlignCenterContinuous:
+		grow(false, (textW-w)/2)
+		grow(true, (textW-w)/2)
+	default:
+		grow(true, textW-w)
+	}
+	return left, right - left
+}
+
+// wrapText découpe un texte en lignes tenant dans la largeur donnée
+func wrapText(text string, font pdf.Font, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		current := words[0]
+		for _, word := range words[1:] {
+			candidate := current + " " + word
+			if font.Width(candidate, size) <= width {
+				current = candidate
+				continue
+			}
+			lines = append(lines, current)
+			current = word
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
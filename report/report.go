@@ -0,0 +1,196 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Noms des rapports écrits à côté des PDF
+const (
+	JSONFileName = "report.json"
+	CSVFileName  = "report.csv"
+)
+
+const (
+	statusOK        = "ok"
+	statusSkipped   = "ignore"
+	statusDuplicate = "doublon"
+	statusCancelled = "annule"
+	statusFailed    = "echec"
+)
+
+// Entry décrit la conversion d'un fichier
+type Entry struct {
+	File        string `json:"file"`
+	Input       string `json:"input"`
+	Pdf         string `json:"pdf,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Backend     string `json:"backend,omitempty"`
+	Attempts    int    `json:"attempts"`
+	StartedAt   string `json:"started_at"`
+	DurationMs  int64  `json:"duration_ms"`
+	InputSize   int64  `json:"input_size"`
+	InputSHA256 string `json:"input_sha256,omitempty"`
+	OutputSize  int64  `json:"output_size,omitempty"`
+	Pages       int    `json:"pages,omitempty"`
+	PDFA        string `json:"pdfa,omitempty"`         // conformité d'archivage vérifiée
+	DuplicateOf string `json:"duplicate_of,omitempty"` // classeur converti au contenu identique
+	Collision   string `json:"collision,omitempty"`    // collision de nom de PDF et sa résolution
+}
+
+// Report est le rapport complet d'une exécution
+type Report struct {
+	GeneratedAt string  `json:"generated_at"`
+	Version     string  `json:"version"`
+	Total       int     `json:"total"`
+	Success     int     `json:"success"`
+	Skipped     int     `json:"skipped"`
+	Duplicates  int     `json:"duplicates"`
+	Cancelled   int     `json:"cancelled"`
+	Failed      int     `json:"failed"`
+	Files       []Entry `json:"files"`
+}
+
+// New construit le rapport à partir des résultats, triés par chemin ; les PDF
+// sont désignés par leur chemin relatif au dossier de sortie
+func New(results []types.ProcessResult, version, outputDir string) *Report {
+	r := &Report{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Version:     version,
+		Total:       len(results),
+	}
+
+	for _, result := range results {
+		entry := Entry{
+			File:        result.FileName,
+			Input:       result.InputPath,
+			Status:      statusOK,
+			Backend:     result.Backend,
+			Attempts:    result.Attempts,
+			StartedAt:   result.StartedAt.Format(time.RFC3339),
+			DurationMs:  result.Duration.Milliseconds(),
+			InputSize:   result.InputSize,
+			InputSHA256: result.InputSHA256,
+			DuplicateOf: result.DuplicateOf,
+			Collision:   result.Collision,
+		}
+		switch {
+		case result.Cancelled:
+			entry.Status = statusCancelled
+			entry.Error = result.Err.Error()
+			r.Cancelled++
+		case result.Err != nil:
+			entry.Status = statusFailed
+			entry.Error = result.Err.Error()
+			r.Failed++
+		case result.DuplicateOf != "":
+			entry.Status = statusDuplicate
+			r.Duplicates++
+		case result.Skipped || result.Excluded:
+			entry.Status = statusSkipped
+			r.Skipped++
+		default:
+			r.Success++
+		}
+		if result.Err == nil && !result.Excluded {
+			// Export par feuille : tous les PDF du classeur
+			var pdfs []string
+			for _, pdf := range result.PDFs() {
+				pdfs = append(pdfs, relPath(outputDir, pdf))
+			}
+			entry.Pdf = strings.Join(pdfs, ", ")
+			entry.OutputSize = result.OutputSize
+			entry.Pages = result.Pages
+			entry.PDFA = result.PDFA
+		}
+		r.Files = append(r.Files, entry)
+	}
+
+	sort.Slice(r.Files, func(i, j int) bool {
+		return r.Files[i].Input < r.Files[j].Input
+	})
+	return r
+}
+
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Write écrit report.json et report.csv dans le dossier donné et renvoie leurs chemins
+func Write(dir string, results []types.ProcessResult, version string) ([]string, error) {
+	r := New(results, version, dir)
+
+	jsonPath := filepath.Join(dir, JSONFileName)
+	if err := r.WriteJSON(jsonPath); err != nil {
+		return nil, err
+	}
+	csvPath := filepath.Join(dir, CSVFileName)
+	if err := r.WriteCSV(csvPath); err != nil {
+		return nil, err
+	}
+	return []string{jsonPath, csvPath}, nil
+}
+
+// WriteJSON écrit le rapport au format JSON
+func (r *Report) WriteJSON(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("impossible de créer le rapport JSON : %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("impossible d'écrire le rapport JSON : %v", err)
+	}
+	return file.Close()
+}
+
+// WriteCSV écrit le rapport au format CSV, avec le séparateur « ; » et l'indicateur
+// d'ordre des octets UTF-8 attendus par Excel en français
+func (r *Report) WriteCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("impossible de créer le rapport CSV : %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString("\ufeff"); err != nil {
+		return fmt.Errorf("impossible d'écrire le rapport CSV : %v", err)
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Comma = ';'
+	writer.Write([]string{
+		"fichier", "source", "pdf", "statut", "erreur", "moteur", "tentatives",
+		"debut", "duree_ms", "taille_source", "sha256_source", "taille_pdf", "pages", "pdfa",
+		"doublon_de", "collision",
+	})
+	for _, e := range r.Files {
+		writer.Write([]string{
+			e.File, e.Input, e.Pdf, e.Status, e.Error, e.Backend, strconv.Itoa(e.Attempts),
+			e.StartedAt, strconv.FormatInt(e.DurationMs, 10), strconv.FormatInt(e.InputSize, 10),
+			e.InputSHA256, strconv.FormatInt(e.OutputSize, 10), strconv.Itoa(e.Pages), e.PDFA,
+			e.DuplicateOf, e.Collision,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("impossible d'écrire le rapport CSV : %v", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,261 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var started = time.Date(2025, 3, 14, 9, 30, 0, 0, time.UTC)
+
+// batch renvoie les résultats d'un lot couvrant chaque statut ; les noms
+// contiennent les caractères à échapper en CSV
+func batch(outputDir string) []types.ProcessResult {
+	input := func(name string) string { return filepath.Join("excel", name) }
+	return []types.ProcessResult{
+		{
+			FileName:    `Dupont; Fils "SARL" (2502).xlsx`,
+			InputPath:   input(`Dupont; Fils "SARL" (2502).xlsx`),
+			PdfPath:     filepath.Join(outputDir, `Dupont; Fils "SARL" (2502).pdf`),
+			Backend:     "native",
+			Attempts:    1,
+			StartedAt:   started,
+			Duration:    1500 * time.Millisecond,
+			InputSize:   2048,
+			InputSHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			OutputSize:  4096,
+			Pages:       2,
+			PDFA:        "PDF/A-2b",
+			Collision:   "PDF existant, renommé en Dupont; Fils \"SARL\" (2502).pdf",
+		},
+		{
+			FileName:  "Élise Martin.xls",
+			InputPath: input("Élise Martin.xls"),
+			StartedAt: started,
+			Attempts:  2,
+			Pages:     4, // reste d'une conversion précédente
+			Err:       fmt.Errorf("échec de tous les moteurs (excel : fichier verrouillé ;\nnative : feuille vide)"),
+		},
+		{
+			FileName:  "Annulé.xls",
+			InputPath: input("Annulé.xls"),
+			StartedAt: started,
+			Cancelled: true,
+			Err:       fmt.Errorf("conversion non lancée : traitement interrompu"),
+		},
+		{
+			FileName:    "Copie.xls",
+			InputPath:   input("Copie.xls"),
+			PdfPath:     filepath.Join(outputDir, "Dupont.pdf"),
+			StartedAt:   started,
+			DuplicateOf: input("Dupont.xls"),
+		},
+		{
+			FileName:  "Inchangé.xlsx",
+			InputPath: input("Inchangé.xlsx"),
+			PdfPath:   filepath.Join(outputDir, "2024", "Inchangé.pdf"),
+			StartedAt: started,
+			Skipped:   true,
+		},
+		{
+			FileName:  "Client.xls",
+			InputPath: input("Client.xls"),
+			StartedAt: started,
+			Excluded:  true,
+			Collision: "même PDF que Client.xlsx, non converti",
+		},
+		{
+			FileName:  "Feuilles.xlsx",
+			InputPath: input("Feuilles.xlsx"),
+			PdfPath:   filepath.Join(outputDir, "Feuilles_Janvier.pdf"),
+			Outputs:   []string{filepath.Join(outputDir, "Feuilles_Janvier.pdf"), filepath.Join(outputDir, "Feuilles_Février.pdf")},
+			Export:    types.ExportOptions{PerSheet: true},
+			StartedAt: started,
+			Pages:     3,
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	outputDir := filepath.Join("sortie", "pdf")
+	r := New(batch(outputDir), "1.4.0", outputDir)
+
+	counts := []int{r.Total, r.Success, r.Skipped, r.Duplicates, r.Cancelled, r.Failed}
+	if want := []int{7, 2, 2, 1, 1, 1}; !slices.Equal(counts, want) {
+		t.Errorf("total, réussis, ignorés, doublons, annulés, échecs = %v, attendu %v", counts, want)
+	}
+	if r.Version != "1.4.0" {
+		t.Errorf("version = %q", r.Version)
+	}
+
+	tests := []struct {
+		file, status, pdf, error string
+	}{
+		{"Annulé.xls", statusCancelled, "", "conversion non lancée : traitement interrompu"},
+		{"Client.xls", statusSkipped, "", ""},
+		{"Copie.xls", statusDuplicate, "Dupont.pdf", ""},
+		{`Dupont; Fils "SARL" (2502).xlsx`, statusOK, `Dupont; Fils "SARL" (2502).pdf`, ""},
+		{"Feuilles.xlsx", statusOK, "Feuilles_Janvier.pdf, Feuilles_Février.pdf", ""},
+		{"Inchangé.xlsx", statusSkipped, "2024/Inchangé.pdf", ""},
+		{"Élise Martin.xls", statusFailed, "", "échec de tous les moteurs (excel : fichier verrouillé ;\nnative : feuille vide)"},
+	}
+	if len(r.Files) != len(tests) {
+		t.Fatalf("%d entrées, attendu %d", len(r.Files), len(tests))
+	}
+	// Entrées triées par chemin du classeur
+	for i, tt := range tests {
+		e := r.Files[i]
+		if e.File != tt.file || e.Status != tt.status || e.Pdf != tt.pdf || e.Error != tt.error {
+			t.Errorf("entrée %d = %q %q %q %q, attendu %q %q %q %q", i, e.File, e.Status, e.Pdf, e.Error, tt.file, tt.status, tt.pdf, tt.error)
+		}
+	}
+
+	// Un échec ne reprend ni la taille ni les pages d'une conversion précédente
+	for _, e := range r.Files {
+		if e.Status == statusFailed && (e.OutputSize != 0 || e.Pages != 0 || e.PDFA != "") {
+			t.Errorf("%s : échec avec un PDF décrit : %+v", e.File, e)
+		}
+	}
+}
+
+func TestNewPDFOutsideOutputDir(t *testing.T) {
+	results := []types.ProcessResult{{FileName: "Client.xls", InputPath: "Client.xls", PdfPath: filepath.Join("ailleurs", "Client.pdf")}}
+	if r := New(results, "dev", "sortie"); r.Files[0].Pdf != "Client.pdf" {
+		t.Errorf("pdf = %q, attendu le nom seul", r.Files[0].Pdf)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	dir := t.TempDir()
+	paths, err := Write(dir, batch(dir), "1.4.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, JSONFileName), filepath.Join(dir, CSVFileName)}; !slices.Equal(paths, want) {
+		t.Errorf("Write() = %v, attendu %v", paths, want)
+	}
+
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := New(batch(dir), "1.4.0", dir)
+	got.GeneratedAt, want.GeneratedAt = "", ""
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("rapport relu :\n%s\nattendu :\n%s", gotJSON, wantJSON)
+	}
+
+	// Champs publiés : une entrée complète et une entrée en échec
+	var raw struct {
+		Files []map[string]any `json:"files"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	fields := []struct {
+		index int
+		keys  []string
+	}{
+		{3, []string{
+			"attempts", "backend", "collision", "duration_ms", "file", "input", "input_sha256",
+			"input_size", "output_size", "pages", "pdf", "pdfa", "started_at", "status",
+		}},
+		{6, []string{"attempts", "duration_ms", "error", "file", "input", "input_size", "started_at", "status"}},
+	}
+	for _, f := range fields {
+		var keys []string
+		for key := range raw.Files[f.index] {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, f.keys) {
+			t.Errorf("champs de %v = %v\nattendu %v", raw.Files[f.index]["file"], keys, f.keys)
+		}
+	}
+	if started := raw.Files[3]["started_at"]; started != "2025-03-14T09:30:00Z" {
+		t.Errorf("started_at = %v", started)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	dir := t.TempDir()
+	r := New(batch(dir), "1.4.0", dir)
+	path := filepath.Join(dir, CSVFileName)
+	if err := r.WriteCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Indicateur d'ordre des octets pour Excel, puis noms à échapper entre
+	// guillemets, guillemets doublés
+	if !bytes.HasPrefix(data, []byte("\ufefffichier;source;pdf;statut;")) {
+		t.Errorf("en-tête = %q", data[:min(len(data), 40)])
+	}
+	if !bytes.Contains(data, []byte(`"Dupont; Fils ""SARL"" (2502).xlsx"`)) {
+		t.Error("nom contenant « ; » et des guillemets mal échappé")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.Comma = ';'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{
+		"fichier", "source", "pdf", "statut", "erreur", "moteur", "tentatives",
+		"debut", "duree_ms", "taille_source", "sha256_source", "taille_pdf", "pages", "pdfa",
+		"doublon_de", "collision",
+	}
+	if !slices.Equal(records[0], header) {
+		t.Errorf("en-tête = %v\nattendu %v", records[0], header)
+	}
+	if len(records) != len(r.Files)+1 {
+		t.Fatalf("%d lignes, attendu %d", len(records), len(r.Files)+1)
+	}
+
+	wantOK := []string{
+		`Dupont; Fils "SARL" (2502).xlsx`, filepath.Join("excel", `Dupont; Fils "SARL" (2502).xlsx`),
+		`Dupont; Fils "SARL" (2502).pdf`, statusOK, "", "native", "1", "2025-03-14T09:30:00Z", "1500", "2048",
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", "4096", "2", "PDF/A-2b",
+		"", "PDF existant, renommé en Dupont; Fils \"SARL\" (2502).pdf",
+	}
+	if !slices.Equal(records[4], wantOK) {
+		t.Errorf("ligne = %q\nattendu %q", records[4], wantOK)
+	}
+	// Message d'erreur sur plusieurs lignes conservé dans un seul champ
+	if failed := records[7]; failed[3] != statusFailed || failed[4] != r.Files[6].Error {
+		t.Errorf("ligne en échec = %q", failed)
+	}
+	if duplicate := records[3]; duplicate[14] != filepath.Join("excel", "Dupont.xls") {
+		t.Errorf("doublon_de = %q", duplicate[14])
+	}
+	for i, record := range records[1:] {
+		if record[0] != r.Files[i].File || record[1] != r.Files[i].Input {
+			t.Errorf("ligne %d = %q, attendu %s", i+1, record[:2], r.Files[i].File)
+		}
+	}
+}
+
+func TestWriteUnwritableDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "absent")
+	if _, err := Write(dir, batch(dir), "dev"); err == nil || !strings.Contains(err.Error(), "rapport JSON") {
+		t.Errorf("Write() = %v, attendu une erreur de création", err)
+	}
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fredon_to_pdf/archive"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/discover"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/queue"
+	"fredon_to_pdf/report"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultListenAddr     = ":8080"
+	defaultMaxUploadMB    = 20
+	defaultRequestTimeout = 2 * time.Minute
+
+	serveStateDir      = ".fredon_serve" // dans le dossier de sortie : file de conversion et fichiers reçus
+	jobRetention       = time.Hour       // durée de conservation des résultats des conversions asynchrones
+	jobCleanupInterval = 5 * time.Minute
+	shutdownTimeout    = 30 * time.Second
+	multipartMemory    = 8 << 20 // au-delà, les fichiers reçus sont écrits sur disque
+)
+
+// server expose les moteurs de conversion via HTTP :
+//
+//	POST /convert          un classeur (champ "file") -> PDF
+//	POST /batch            plusieurs classeurs -> ZIP des PDF et du rapport
+//	GET  /jobs/{id}         état d'une conversion lancée avec ?async=1
+//	GET  /jobs/{id}/result  résultat d'une conversion asynchrone terminée
+//	GET  /healthz           état du service
+//
+// Chaque requête forme un groupe de tâches de la file de conversion persistante :
+// les conversions asynchrones reprennent après un redémarrage du service.
+type server struct {
+	pool      *workerPool
+	queue     *queue.Queue
+	backends  []tools.Backend
+	config    *config.Config
+	stateDir  string
+	maxUpload int64
+	timeout   time.Duration
+
+	mu sync.Mutex // création des archives ZIP
+	wg sync.WaitGroup
+}
+
+// serveJob décrit l'état d'une requête de conversion
+type serveJob struct {
+	ID         string         `json:"id"`
+	Status     queue.Status   `json:"status"`
+	Files      []string       `json:"files"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Report     *report.Report `json:"report,omitempty"`
+}
+
+// runServe démarre le service HTTP jusqu'à l'arrêt du programme ; les requêtes
+// en cours sont alors menées à terme, les conversions en attente seront reprises
+// au prochain démarrage
+func runServe(opts cliOptions) error {
+	displayHeader()
+
+	// Initialisation de la configuration
+	cfg, err := config.NewConfig(opts.Options)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ValidateExport(); err != nil {
+		return err
+	}
+	if err := setupLogging(cfg, commandServe, newRunID()); err != nil {
+		return err
+	}
+
+	// Le service ne se délègue pas ses propres conversions
+	backends, err := tools.Candidates(cfg.Converter)
+	if err != nil {
+		return err
+	}
+	backends = tools.Without(backends, tools.RemoteBackend)
+	if len(backends) == 0 {
+		return fmt.Errorf("aucun moteur de conversion local disponible")
+	}
+
+	stateDir := filepath.Join(cfg.OutputDir, serveStateDir)
+	if err := helper.EnsureDirExists(stateDir); err != nil {
+		return fmt.Errorf("impossible de créer le dossier de travail : %v", err)
+	}
+	q, err := queue.Open(filepath.Join(stateDir, queue.FileName))
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Les conversions ne sont pas liées au signal d'arrêt : celles en cours se
+	// terminent pendant l'arrêt du serveur
+	poolCtx, cancelPool := context.WithCancel(context.Background())
+	defer cancelPool()
+
+	s := &server{
+		pool:      newWorkerPool(poolCtx, q, cfg.Converter, backends, opts.RequestTimeout),
+		queue:     q,
+		backends:  backends,
+		config:    cfg,
+		stateDir:  stateDir,
+		maxUpload: opts.MaxUploadMB << 20,
+		timeout:   opts.RequestTimeout,
+	}
+	go s.cleanupJobs(ctx)
+
+	// Journal des conversions
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		for result := range s.pool.Results() {
+			switch {
+			case result.Cancelled:
+				helper.GWarningLn("Annulé : %s", result.FileName)
+			case result.Err != nil:
+				helper.GErrorLn("Échec pour %s : %v", result.FileName, result.Err)
+			default:
+				helper.GInfoLn("%s (%s)", createdPDFs(result), result.Backend)
+			}
+		}
+	}()
+
+	httpServer := &http.Server{
+		Addr:              opts.Listen,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       5 * time.Minute,
+		WriteTimeout:      opts.RequestTimeout + time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	helper.GInfoLn("Service de conversion à l'écoute sur %s (Ctrl+C pour arrêter)", opts.Listen)
+	helper.GInfoLn("Moteurs de conversion : %s", backendNames(backends))
+	if pending, err := q.List(queue.Pending); err == nil && len(pending) > 0 {
+		helper.GInfoLn("Reprise de %d tâche(s) en attente", len(pending))
+	}
+
+	select {
+	case err := <-serveErr:
+		s.pool.Stop()
+		return fmt.Errorf("erreur du service HTTP : %v", err)
+	case <-ctx.Done():
+	}
+
+	helper.GBlank()
+	helper.GInfoLn("Arrêt demandé, fin des requêtes en cours..")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		helper.GWarningLn("Arrêt du service HTTP incomplet : %v", err)
+	}
+
+	// Les tâches en attente restent dans la file pour le prochain démarrage
+	s.pool.Stop()
+	<-logged
+	s.wg.Wait()
+	return nil
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("POST /convert", s.handleConvert)
+	mux.HandleFunc("POST /batch", s.handleBatch)
+	mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /jobs/{id}/result", s.handleJobResult)
+	return mux
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, len(s.backends))
+	for i, b := range s.backends {
+		names[i] = b.Name
+	}
+	counts, err := s.queue.Counts()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":   "ok",
+		"version":  Version,
+		"backends": names,
+		"queue":    counts,
+	})
+}
+
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	s.handleUpload(w, r, false)
+}
+
+func (s *server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	s.handleUpload(w, r, true)
+}
+
+// handleUpload reçoit les classeurs et les ajoute à la file de conversion, puis
+// attend leur conversion, sauf si la requête contient ?async=1
+func (s *server) handleUpload(w http.ResponseWriter, r *http.Request, batch bool) {
+	group := newJobID()
+	dir := filepath.Join(s.stateDir, group)
+
+	inputs, status, err := s.saveUploads(w, r, filepath.Join(dir, "in"), batch)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, status, err.Error())
+		return
+	}
+
+	var jobs []*queue.Job
+	for _, input := range inputs {
+		export, err := s.exportOptions(r, input)
+		if err != nil {
+			s.discard(group)
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		job, err := s.pool.Submit(input, filepath.Join(dir, "out"), group, queue.Options{Archive: batch, Export: export})
+		if err != nil {
+			s.discard(group)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		w.Header().Set("Location", "/jobs/"+group)
+		writeJSON(w, http.StatusAccepted, s.status(group, jobs))
+		return
+	}
+	// Le résultat d'une requête synchrone n'est pas conservé
+	defer s.discard(group)
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	jobs, err = s.queue.Wait(ctx, group)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			writeError(w, http.StatusGatewayTimeout, "délai de conversion dépassé")
+		}
+		return
+	}
+
+	result, err := s.result(group, jobs)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	serveResult(w, r, result)
+}
+
+// conversionResult est le fichier produit pour une requête
+type conversionResult struct {
+	path        string
+	contentType string
+	report      *report.Report
+}
+
+// exportOptions renvoie la sélection des feuilles d'un classeur reçu : celle de la
+// configuration, remplacée par les champs "sheets", "sheet", "per_sheet" et
+// "profile" du formulaire s'ils sont fournis. Le champ "page_setup", en JSON,
+// complète la mise en page de la configuration ; "name" donne le nom des PDF.
+func (s *server) exportOptions(r *http.Request, input string) (types.ExportOptions, error) {
+	opts := s.config.ExportOptions(input)
+	form := r.MultipartForm.Value
+
+	if names := form["sheet"]; len(names) > 0 {
+		opts.Sheets = types.SheetsNamed
+		opts.SheetNames = names
+	}
+	if mode := form["sheets"]; len(mode) > 0 && mode[0] != "" {
+		opts.Sheets = mode[0]
+	}
+	if perSheet := form["per_sheet"]; len(perSheet) > 0 {
+		b, err := strconv.ParseBool(perSheet[0])
+		if err != nil {
+			return opts, fmt.Errorf("per_sheet invalide : %s", perSheet[0])
+		}
+		opts.PerSheet = b
+	}
+	if profile := form["profile"]; len(profile) > 0 && profile[0] != "" {
+		opts.Profile = profile[0]
+	}
+	if name := form["name"]; len(name) > 0 && name[0] != "" {
+		// Nom du PDF sans dossier : il reste dans le dossier de la requête
+		opts.Name = helper.SanitizeFilename(name[0])
+	}
+	if layout := form["page_setup"]; len(layout) > 0 && layout[0] != "" {
+		var over types.PageSetup
+		if err := json.Unmarshal([]byte(layout[0]), &over); err != nil {
+			return opts, fmt.Errorf("page_setup invalide : %v", err)
+		}
+		merged := opts.Layout().Merge(over)
+		opts.PageSetup = &merged
+	}
+	return opts, opts.Validate()
+}
+
+// result renvoie le fichier produit par un groupe de tâches terminées : le PDF
+// d'un fichier seul, ou un ZIP contenant les PDF réussis et le rapport pour un
+// lot ou un export par feuille
+func (s *server) result(group string, jobs []*queue.Job) (*conversionResult, error) {
+	outputDir := filepath.Join(s.stateDir, group, "out")
+	results := make([]types.ProcessResult, len(jobs))
+	for i, job := range jobs {
+		results[i] = job.ProcessResult()
+	}
+
+	rep := report.New(results, Version, outputDir)
+	successResults := filterSuccessResults(results)
+	zipped := jobs[0].Options.Archive
+	if len(successResults) == 0 {
+		if !zipped {
+			return nil, results[0].Err
+		}
+		return nil, fmt.Errorf("aucun fichier n'a pu être converti : %v", firstError(results))
+	}
+
+	// En export par feuille, le ZIP conserve le nom des PDF de chaque feuille
+	if !zipped && !successResults[0].Export.PerSheet {
+		return &conversionResult{path: successResults[0].PdfPath, contentType: "application/pdf", report: rep}, nil
+	}
+
+	// L'archive est créée à la première demande
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	zipPath := filepath.Join(s.stateDir, group, "pdfs.zip")
+	if _, err := os.Stat(zipPath); err != nil {
+		reports, err := report.Write(outputDir, results, Version)
+		if err != nil {
+			return nil, err
+		}
+		files := append(archive.PDFs(outputDir, successResults), archive.Root(reports...)...)
+		if _, err := archive.Create(filepath.Dir(zipPath), files, archive.Options{Name: filepath.Base(zipPath)}, nil); err != nil {
+			return nil, fmt.Errorf("erreur lors de la création du ZIP : %v", err)
+		}
+	}
+	return &conversionResult{path: zipPath, contentType: "application/zip", report: rep}, nil
+}
+
+// status résume l'état des tâches d'un groupe
+func (s *server) status(group string, jobs []*queue.Job) serveJob {
+	job := serveJob{ID: group, CreatedAt: jobs[0].CreatedAt}
+	counts := make(map[queue.Status]int)
+	var finishedAt time.Time
+	for _, j := range jobs {
+		job.Files = append(job.Files, filepath.Base(j.Input))
+		counts[j.Status]++
+		if j.UpdatedAt.After(finishedAt) {
+			finishedAt = j.UpdatedAt
+		}
+	}
+
+	switch {
+	case counts[queue.Running] > 0:
+		job.Status = queue.Running
+	case counts[queue.Pending] > 0:
+		job.Status = queue.Pending
+	case counts[queue.Done] > 0:
+		job.Status = queue.Done
+	default:
+		job.Status = queue.Failed
+	}
+	if !job.Status.Finished() {
+		return job
+	}
+
+	results := make([]types.ProcessResult, len(jobs))
+	for i, j := range jobs {
+		results[i] = j.ProcessResult()
+	}
+	job.FinishedAt = &finishedAt
+	job.Report = report.New(results, Version, filepath.Join(s.stateDir, group, "out"))
+	// Des tâches annulées n'ont pas forcément d'erreur
+	if err := firstError(results); job.Status == queue.Failed && err != nil {
+		job.Error = err.Error()
+	}
+	return job
+}
+
+// discard annule les tâches d'un groupe puis supprime leurs fichiers
+func (s *server) discard(group string) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		jobs, err := s.queue.Group(group)
+		if err == nil && len(jobs) > 0 {
+			s.pool.Cancel(jobs)
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if _, err := s.queue.Wait(ctx, group); err == nil {
+				ids := make([]string, len(jobs))
+				for i, job := range jobs {
+					ids[i] = job.ID
+				}
+				if err := s.queue.Remove(ids...); err != nil {
+					helper.GWarningLn("%v", err)
+				}
+			}
+		}
+		os.RemoveAll(filepath.Join(s.stateDir, group))
+	}()
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	jobs, err := s.queue.Group(id)
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case len(jobs) == 0:
+		writeError(w, http.StatusNotFound, "conversion inconnue ou expirée")
+	default:
+		writeJSON(w, http.StatusOK, s.status(id, jobs))
+	}
+}
+
+func (s *server) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	jobs, err := s.queue.Group(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(jobs) == 0 {
+		writeError(w, http.StatusNotFound, "conversion inconnue ou expirée")
+		return
+	}
+	if !s.status(id, jobs).Status.Finished() {
+		writeError(w, http.StatusConflict, "conversion en cours")
+		return
+	}
+
+	result, err := s.result(id, jobs)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	serveResult(w, r, result)
+}
+
+// cleanupJobs supprime périodiquement les conversions asynchrones terminées depuis
+// plus de jobRetention, avec leurs fichiers
+func (s *server) cleanupJobs(ctx context.Context) {
+	ticker := time.NewTicker(jobCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			jobs, err := s.queue.List()
+			if err != nil {
+				helper.GWarningLn("%v", err)
+				continue
+			}
+			groups := make(map[string][]*queue.Job)
+			for _, job := range jobs {
+				groups[job.Group] = append(groups[job.Group], job)
+			}
+			for group, jobs := range groups {
+				if group == "" {
+					continue
+				}
+				status := s.status(group, jobs)
+				if status.FinishedAt == nil || now.Sub(*status.FinishedAt) < jobRetention {
+					continue
+				}
+				s.discard(group)
+			}
+		}
+	}
+}
+
+// saveUploads enregistre les classeurs reçus dans dir et renvoie leurs chemins,
+// ou le code HTTP adapté à l'erreur rencontrée
+func (s *server) saveUploads(w http.ResponseWriter, r *http.Request, dir string, batch bool) ([]string, int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("requête trop volumineuse (maximum %d Mo)", s.maxUpload>>20)
+		}
+		return nil, http.StatusBadRequest, fmt.Errorf("formulaire multipart attendu : %v", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var headers []*multipart.FileHeader
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		headers = append(headers, r.MultipartForm.File[field]...)
+	}
+
+	switch {
+	case len(headers) == 0:
+		return nil, http.StatusBadRequest, fmt.Errorf("aucun fichier reçu")
+	case !batch && len(headers) > 1:
+		return nil, http.StatusBadRequest, fmt.Errorf("un seul fichier attendu : utilisez /batch pour un lot")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("impossible de créer le dossier de travail")
+	}
+
+	var inputs []string
+	used := make(map[string]bool)
+	for _, header := range headers {
+		name := helper.SanitizeFilename(filepath.Base(header.Filename))
+		if !discover.IsWorkbook(name) {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("format non supporté : %s", header.Filename)
+		}
+		// Deux fichiers du même nom dans un lot : le second est préfixé
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%d_%s", i, helper.SanitizeFilename(filepath.Base(header.Filename)))
+		}
+		used[name] = true
+
+		path := filepath.Join(dir, name)
+		if err := saveUpload(header, path); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		inputs = append(inputs, path)
+	}
+	return inputs, http.StatusOK, nil
+}
+
+func saveUpload(header *multipart.FileHeader, path string) error {
+	src, err := header.Open()
+	if err != nil {
+		return fmt.Errorf("impossible de lire %s : %v", header.Filename, err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("impossible d'enregistrer %s : %v", header.Filename, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("impossible d'enregistrer %s : %v", header.Filename, err)
+	}
+	return dst.Close()
+}
+
+// serveResult envoie le fichier produit en pièce jointe
+func serveResult(w http.ResponseWriter, r *http.Request, result *conversionResult) {
+	file, err := os.Open(result.path)
+	if err != nil {
+		writeError(w, http.StatusGone, "résultat indisponible")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "résultat illisible")
+		return
+	}
+	if result.report != nil && result.report.Failed > 0 {
+		w.Header().Set("X-Fredon-Failed", strconv.Itoa(result.report.Failed))
+	}
+	w.Header().Set("Content-Type", result.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(result.path)))
+	http.ServeContent(w, r, filepath.Base(result.path), info.ModTime(), file)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// firstError renvoie la première erreur d'un lot, pour un message concis
+func firstError(results []types.ProcessResult) error {
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/naming"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/queue"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubProcessor écrit un PDF d'une page par classeur. Les classeurs dont le nom
+// contient « illisible » échouent, ceux contenant « attente » ne sont convertis
+// qu'à l'annulation de la conversion.
+type stubProcessor struct{}
+
+func (stubProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	name := filepath.Base(inputFile)
+	switch {
+	case strings.Contains(name, "illisible"):
+		return nil, fmt.Errorf("classeur illisible : %s", name)
+	case strings.Contains(name, "attente"):
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+	doc := pdf.New()
+	doc.AddPage(595, 842).FillRect(50, 700, 200, 40)
+	output := filepath.Join(outputDir, opts.OutputName(inputFile)+".pdf")
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := doc.Write(file); err != nil {
+		return nil, err
+	}
+	return []string{output}, nil
+}
+
+// newTestServer démarre le service sur un moteur de test, avec une limite de
+// taille des requêtes en Mo
+func newTestServer(t *testing.T, maxUploadMB int64) (*server, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		ExcelDir:   filepath.Join(dir, "excel"),
+		OutputDir:  filepath.Join(dir, "pdf"),
+		OutputName: naming.DefaultTemplate,
+	}
+	stateDir := filepath.Join(cfg.OutputDir, serveStateDir)
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	q, err := queue.Open(filepath.Join(stateDir, queue.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := []tools.Backend{{
+		Name:       "test",
+		Extensions: []string{".xls", ".xlsx"},
+		New:        func() (tools.FileProcessor, error) { return stubProcessor{}, nil },
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &server{
+		pool:      newWorkerPool(ctx, q, "test", backends, 0),
+		queue:     q,
+		backends:  backends,
+		config:    cfg,
+		stateDir:  stateDir,
+		maxUpload: maxUploadMB << 20,
+		timeout:   10 * time.Second,
+	}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for range s.pool.Results() {
+		}
+	}()
+
+	ts := httptest.NewServer(s.routes())
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+		<-drained
+		s.wg.Wait()
+		q.Close()
+	})
+	return s, ts
+}
+
+// upload envoie des classeurs au service, sous le champ "file"
+func upload(t *testing.T, url string, files map[string][]byte) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		part, err := form.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(files[name])
+	}
+	form.Close()
+
+	resp, err := http.Post(url, form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decode lit une réponse JSON
+func decode(t *testing.T, resp *http.Response, v any) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("réponse JSON invalide : %v", err)
+	}
+}
+
+// waitJob interroge l'état d'une conversion asynchrone jusqu'à sa fin
+func waitJob(t *testing.T, ts *httptest.Server, location string) serveJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(ts.URL + location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var job serveJob
+		decode(t, resp, &job)
+		resp.Body.Close()
+		if job.Status.Finished() {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s : conversion non terminée", location)
+	return serveJob{}
+}
+
+func TestServeConvert(t *testing.T) {
+	s, ts := newTestServer(t, 1)
+	resp := upload(t, ts.URL+"/convert", map[string][]byte{"Client (2502).xlsx": []byte("classeur")})
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("statut %d : %s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := resp.Header.Get("Content-Disposition"); !strings.Contains(got, `"Client (2502).pdf"`) {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if n, err := pdf.PageCount(body); err != nil || n != 1 {
+		t.Errorf("PDF reçu : %d pages, %v", n, err)
+	}
+
+	// Le résultat d'une requête synchrone n'est pas conservé
+	s.wg.Wait()
+	if jobs, err := s.queue.List(); err != nil || len(jobs) != 0 {
+		t.Errorf("tâches restantes : %v, %v", jobs, err)
+	}
+	entries, err := os.ReadDir(s.stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Errorf("dossier de requête conservé : %s", entry.Name())
+		}
+	}
+}
+
+func TestServeBatch(t *testing.T) {
+	_, ts := newTestServer(t, 1)
+	resp := upload(t, ts.URL+"/batch", map[string][]byte{
+		"Client.xlsx":           []byte("classeur"),
+		"Fournisseur.xls":       []byte("classeur"),
+		"Relevé illisible.xlsx": []byte("classeur"),
+	})
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("statut %d : %s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("X-Fredon-Failed"); got != "1" {
+		t.Errorf("X-Fredon-Failed = %q, attendu 1", got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	for _, want := range []string{"Client.pdf", "Fournisseur.pdf"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("%s absent de l'archive : %v", want, names)
+		}
+	}
+	if len(names) <= 2 {
+		t.Errorf("rapport absent de l'archive : %v", names)
+	}
+}
+
+func TestServeUploadRejected(t *testing.T) {
+	_, ts := newTestServer(t, 1)
+
+	tests := []struct {
+		name   string
+		files  map[string][]byte
+		status int
+		error  string
+	}{
+		{
+			name:   "requête trop volumineuse",
+			files:  map[string][]byte{"Client.xlsx": bytes.Repeat([]byte("x"), 2<<20)},
+			status: http.StatusRequestEntityTooLarge,
+			error:  "requête trop volumineuse (maximum 1 Mo)",
+		},
+		{
+			name:   "format non supporté",
+			files:  map[string][]byte{"Client.docx": []byte("document")},
+			status: http.StatusUnsupportedMediaType,
+			error:  "format non supporté : Client.docx",
+		},
+		{
+			name:   "plusieurs fichiers hors lot",
+			files:  map[string][]byte{"A.xlsx": []byte("a"), "B.xlsx": []byte("b")},
+			status: http.StatusBadRequest,
+			error:  "un seul fichier attendu",
+		},
+		{
+			name:   "conversion en échec",
+			files:  map[string][]byte{"Client illisible.xlsx": []byte("classeur")},
+			status: http.StatusUnprocessableEntity,
+			error:  "classeur illisible : Client illisible.xlsx",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := upload(t, ts.URL+"/convert", tt.files)
+			if resp.StatusCode != tt.status {
+				t.Errorf("statut %d, attendu %d", resp.StatusCode, tt.status)
+			}
+			var body map[string]string
+			decode(t, resp, &body)
+			if !strings.Contains(body["error"], tt.error) {
+				t.Errorf("erreur = %q, attendu %q", body["error"], tt.error)
+			}
+		})
+	}
+}
+
+func TestServeUnknownJob(t *testing.T) {
+	_, ts := newTestServer(t, 1)
+	for _, path := range []string{"/jobs/inconnu", "/jobs/inconnu/result"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]string
+		decode(t, resp, &body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound || body["error"] == "" {
+			t.Errorf("%s : statut %d, %v, attendu %d", path, resp.StatusCode, body, http.StatusNotFound)
+		}
+	}
+}
+
+func TestServeAsync(t *testing.T) {
+	_, ts := newTestServer(t, 1)
+	resp := upload(t, ts.URL+"/convert?async=1", map[string][]byte{"Client.xlsx": []byte("classeur")})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("statut %d, attendu %d", resp.StatusCode, http.StatusAccepted)
+	}
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, "/jobs/") {
+		t.Fatalf("Location = %q", location)
+	}
+
+	job := waitJob(t, ts, location)
+	if job.Status != queue.Done || job.Report == nil || job.Report.Success != 1 {
+		t.Fatalf("conversion = %+v", job)
+	}
+	result, err := http.Get(ts.URL + location + "/result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Body.Close()
+	if result.StatusCode != http.StatusOK || result.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("résultat : statut %d, %s", result.StatusCode, result.Header.Get("Content-Type"))
+	}
+}
+
+func TestServeFailedBatch(t *testing.T) {
+	s, ts := newTestServer(t, 1)
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		// cancel annule les tâches du groupe une fois la première lancée
+		cancel bool
+		error  string
+	}{
+		{
+			name: "tous les classeurs en échec",
+			files: map[string][]byte{
+				"A illisible.xlsx": []byte("a"),
+				"B illisible.xlsx": []byte("b"),
+			},
+			error: "classeur illisible : A illisible.xlsx",
+		},
+		{
+			// Conversion interrompue et conversion jamais lancée
+			name: "lot annulé",
+			files: map[string][]byte{
+				"A attente.xlsx": []byte("a"),
+				"B attente.xlsx": []byte("b"),
+			},
+			cancel: true,
+			error:  "context canceled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := upload(t, ts.URL+"/batch?async=1", tt.files)
+			if resp.StatusCode != http.StatusAccepted {
+				t.Fatalf("statut %d, attendu %d", resp.StatusCode, http.StatusAccepted)
+			}
+			var accepted serveJob
+			decode(t, resp, &accepted)
+
+			if tt.cancel {
+				jobs := waitRunning(t, s, accepted.ID)
+				s.pool.Cancel(jobs)
+			}
+			job := waitJob(t, ts, resp.Header.Get("Location"))
+			if job.Status != queue.Failed {
+				t.Errorf("statut %q, attendu %q", job.Status, queue.Failed)
+			}
+			if !strings.Contains(job.Error, tt.error) {
+				t.Errorf("erreur = %q, attendu %q", job.Error, tt.error)
+			}
+			if job.Report == nil || job.Report.Failed+job.Report.Cancelled != len(tt.files) {
+				t.Errorf("rapport = %+v", job.Report)
+			}
+
+			result, err := http.Get(ts.URL + resp.Header.Get("Location") + "/result")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body map[string]string
+			decode(t, result, &body)
+			result.Body.Close()
+			if result.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body["error"], "aucun fichier n'a pu être converti") {
+				t.Errorf("résultat : statut %d, %v", result.StatusCode, body)
+			}
+		})
+	}
+}
+
+// waitRunning attend le lancement d'une conversion du groupe et renvoie ses tâches
+func waitRunning(t *testing.T, s *server, group string) []*queue.Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		jobs, err := s.queue.Group(group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, job := range jobs {
+			if job.Status == queue.Running {
+				return jobs
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("conversion non lancée")
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fredon_to_pdf/archive"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/types"
+	"sync"
+)
+
+// archiveStream alimente les archives au fil des conversions : une goroutine
+// unique y écrit les fichiers de chaque conversion réussie dès sa réception, de
+// sorte que les archives sont prêtes dès la fin du dernier PDF, sans relire les
+// PDF une fois toutes les conversions terminées
+type archiveStream struct {
+	cfg     *config.Config
+	writer  *archive.Writer
+	results chan types.ProcessResult
+	done    chan struct{}
+	once    sync.Once
+	err     error // première erreur d'écriture ; les résultats suivants sont ignorés
+}
+
+// startArchiveStream démarre la goroutine d'écriture des archives
+func startArchiveStream(cfg *config.Config, opts archive.Options) (*archiveStream, error) {
+	writer, err := archive.NewWriter(cfg.OutputDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &archiveStream{
+		cfg:     cfg,
+		writer:  writer,
+		results: make(chan types.ProcessResult, 64),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *archiveStream) run() {
+	defer close(s.done)
+	for result := range s.results {
+		if s.err != nil {
+			continue
+		}
+		for _, f := range archiveFiles(s.cfg, []types.ProcessResult{result}) {
+			if err := s.writer.Add(f); err != nil {
+				s.err = err
+				break
+			}
+		}
+	}
+}
+
+// Send transmet un résultat à la goroutine d'écriture ; seuls les fichiers des
+// conversions réussies sont archivés
+func (s *archiveStream) Send(result types.ProcessResult) {
+	if result.Err == nil && !result.Excluded {
+		s.results <- result
+	}
+}
+
+// stop attend que la goroutine d'écriture ait traité tous les résultats
+func (s *archiveStream) stop() {
+	s.once.Do(func() {
+		close(s.results)
+		<-s.done
+	})
+}
+
+// Finish ajoute les fichiers annexes (rapports, document fusionné) puis termine
+// les archives, dont le chemin est renvoyé
+func (s *archiveStream) Finish(extras []string) ([]string, error) {
+	s.stop()
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, f := range archive.Root(extras...) {
+		if err := s.writer.Add(f); err != nil {
+			return nil, err
+		}
+	}
+	return s.writer.Close()
+}
+
+// Abort abandonne les archives en cours, sans effet après Finish
+func (s *archiveStream) Abort() {
+	s.stop()
+	s.writer.Abort()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"fredon_to_pdf/archive"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// streamFixture crée un lot converti : deux PDF réussis, dont un dans un
+// sous-dossier, un échec et un classeur exclu
+func streamFixture(t *testing.T) (*config.Config, []types.ProcessResult) {
+	t.Helper()
+	cfg := &config.Config{ExcelDir: t.TempDir(), OutputDir: t.TempDir(), ZipSources: "O"}
+	result := func(name string, err error) types.ProcessResult {
+		input := filepath.Join(cfg.ExcelDir, name+".xlsx")
+		pdf := filepath.Join(cfg.OutputDir, name+".pdf")
+		for _, path := range []string{input, pdf} {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return types.ProcessResult{FileName: filepath.Base(input), InputPath: input, PdfPath: pdf, Err: err}
+	}
+	excluded := result("Client", nil)
+	excluded.Excluded = true
+	return cfg, []types.ProcessResult{
+		result("Dupont (2502)", nil),
+		result(filepath.Join("2024", "Martin (2410)"), nil),
+		result("Illisible", fmt.Errorf("classeur illisible")),
+		excluded,
+	}
+}
+
+// outputEntries renvoie les fichiers présents à la racine du dossier de sortie
+func outputEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestArchiveStreamFinish(t *testing.T) {
+	cfg, results := streamFixture(t)
+	stream, err := startArchiveStream(cfg, archive.Options{Name: "pdfs_{run}.zip", RunID: "a1b2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		stream.Send(result)
+	}
+	extra := filepath.Join(cfg.OutputDir, "rapport.json")
+	if err := os.WriteFile(extra, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := stream.Finish([]string{extra})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(cfg.OutputDir, "pdfs_a1b2.zip")
+	if !slices.Equal(paths, []string{want}) {
+		t.Fatalf("Finish() = %v, attendu %v", paths, []string{want})
+	}
+	// Abort après Finish ne supprime pas l'archive terminée
+	stream.Abort()
+
+	r, err := zip.OpenReader(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	wantNames := []string{
+		"2024/Martin (2410).pdf", "Dupont (2502).pdf", archive.ManifestName, "rapport.json",
+		"sources/2024/Martin (2410).xlsx", "sources/Dupont (2502).xlsx",
+	}
+	slices.Sort(wantNames)
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("contenu de l'archive = %q\nattendu %q", names, wantNames)
+	}
+
+	// Aucun fichier temporaire laissé à côté de l'archive
+	if entries := outputEntries(t, cfg.OutputDir); !slices.Equal(entries, []string{"Client.pdf", "Dupont (2502).pdf", "Illisible.pdf", "pdfs_a1b2.zip", "rapport.json"}) {
+		t.Errorf("dossier de sortie = %q", entries)
+	}
+}
+
+func TestArchiveStreamAbort(t *testing.T) {
+	cfg, results := streamFixture(t)
+	before := outputEntries(t, cfg.OutputDir)
+	stream, err := startArchiveStream(cfg, archive.Options{Name: archive.DefaultName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		stream.Send(result)
+	}
+
+	// Interruption après des envois : l'archive commencée est supprimée
+	stream.Abort()
+	if entries := outputEntries(t, cfg.OutputDir); !slices.Equal(entries, before) {
+		t.Errorf("dossier de sortie après Abort = %q, attendu %q", entries, before)
+	}
+}
+
+func TestArchiveStreamWriteError(t *testing.T) {
+	cfg, results := streamFixture(t)
+	before := outputEntries(t, cfg.OutputDir)
+	stream, err := startArchiveStream(cfg, archive.Options{Name: archive.DefaultName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Abort()
+
+	// PDF supprimé avant son archivage : les résultats suivants sont ignorés
+	if err := os.Remove(results[0].PdfPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		stream.Send(result)
+	}
+	paths, err := stream.Finish(nil)
+	if err == nil || !strings.Contains(err.Error(), "Dupont (2502).pdf") {
+		t.Errorf("Finish() = %v, %v, attendu une erreur d'ajout", paths, err)
+	}
+	before = slices.DeleteFunc(before, func(name string) bool { return name == "Dupont (2502).pdf" })
+	if entries := outputEntries(t, cfg.OutputDir); !slices.Equal(entries, before) {
+		t.Errorf("dossier de sortie après l'échec = %q, attendu %q", entries, before)
+	}
+}
+
+func TestStartArchiveStreamInvalidName(t *testing.T) {
+	if _, err := startArchiveStream(&config.Config{OutputDir: t.TempDir()}, archive.Options{Name: "{client}.zip"}); err == nil {
+		t.Error("modèle de nom invalide accepté")
+	}
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"fmt"
+	"fredon_to_pdf/pdf"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeAtomic produit le PDF de chemin path sous un nom temporaire du même dossier,
+// puis le met en place une fois vérifié : un export interrompu ne laisse jamais de
+// PDF incomplet sous son nom définitif
+func writeAtomic(path string, write func(tmp string) error) error {
+	tmp, err := tempPDF(path)
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return commitPDF(tmp, path)
+}
+
+// tempPDF réserve un fichier temporaire, caché et terminé par .pdf (extension
+// attendue par Excel), à côté du PDF de chemin path
+func tempPDF(path string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	file, err := os.CreateTemp(filepath.Dir(path), ".~"+name+".*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("impossible de créer le fichier PDF temporaire : %v", err)
+	}
+	file.Close()
+	// Droits habituels d'un PDF plutôt que ceux, restreints, d'un fichier temporaire
+	os.Chmod(file.Name(), 0644)
+	return file.Name(), nil
+}
+
+// commitPDF vérifie le PDF temporaire puis le renomme en path ; il est supprimé
+// s'il est incomplet
+func commitPDF(tmp, path string) error {
+	if _, err := pdf.CheckFile(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("PDF produit invalide : %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("impossible de mettre le PDF en place : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"fredon_to_pdf/types"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Noms possibles de l'exécutable LibreOffice, par ordre de préférence
+var sofficeBinaries = []string{"soffice", "libreoffice"}
+
+// pdfA2Filter demande à LibreOffice (7.4 et suivants) un export PDF/A-2b
+const pdfA2Filter = `pdf:calc_pdf_Export:{"SelectPdfVersion":{"type":"long","value":"2"}}`
+
+// LibreOfficeFileProcessor convertit les classeurs via "soffice --headless".
+// Chaque instance utilise son propre profil utilisateur afin que plusieurs
+// workers puissent lancer LibreOffice en parallèle sans se bloquer.
+type LibreOfficeFileProcessor struct {
+	binary     string
+	profileDir string
+}
+
+func init() {
+	Register(Backend{
+		Name:       "libreoffice",
+		Extensions: []string{".xls", ".xlsx", ".xlsm", ".ods"},
+		ThreadSafe: true,
+		Priority:   20,
+		New: func() (FileProcessor, error) {
+			p, err := NewLibreOfficeFileProcessor()
+			if err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	})
+}
+
+func NewLibreOfficeFileProcessor() (*LibreOfficeFileProcessor, error) {
+	binary, err := findSoffice()
+	if err != nil {
+		return nil, err
+	}
+
+	profileDir, err := os.MkdirTemp("", "fredon_lo_profile_")
+	if err != nil {
+		return nil, fmt.Errorf("impossible de créer le profil LibreOffice : %v", err)
+	}
+
+	return &LibreOfficeFileProcessor{
+		binary:     binary,
+		profileDir: profileDir,
+	}, nil
+}
+
+func (p *LibreOfficeFileProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	// soffice exporte les feuilles visibles du classeur, sans sélection possible :
+	// un autre moteur prend le relais
+	if opts.Sheets == types.SheetsNamed || opts.PerSheet {
+		return nil, fmt.Errorf("sélection des feuilles et export par feuille non pris en charge")
+	}
+	if !opts.Layout().Empty() {
+		return nil, fmt.Errorf("mise en page imposée non prise en charge")
+	}
+
+	// Vérification des chemins
+	if err := validatePaths(inputFile, outputDir, opts); err != nil {
+		return nil, fmt.Errorf("erreur de validation des chemins : %v", err)
+	}
+
+	// soffice nomme le PDF d'après le classeur et l'écrit dans un dossier
+	// temporaire ; il est mis en place sous le nom demandé une fois vérifié
+	path := pdfPath(outputDir, inputFile, opts)
+
+	var lastErr error
+	for i := 0; i < maxRetries; i++ {
+		if i > 0 {
+			if err := sleepContext(ctx, retryDelay); err != nil {
+				return nil, interrupted(ctx, err)
+			}
+		}
+
+		// Chaque tentative dispose de son propre timeout
+		attemptCtx, cancel := context.WithTimeout(ctx, operationTimeout)
+		err := p.attempt(attemptCtx, inputFile, path, opts.PDFA())
+		cancel()
+		if err == nil {
+			return []string{path}, nil
+		}
+		if ctx.Err() != nil {
+			return nil, interrupted(ctx, err)
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("échec de la conversion LibreOffice après %d tentatives : %v", maxRetries, lastErr)
+}
+
+// attempt convertit le classeur dans un dossier temporaire placé à côté du PDF,
+// puis met le PDF en place sous le nom demandé
+func (p *LibreOfficeFileProcessor) attempt(ctx context.Context, inputFile, path string, archive bool) error {
+	workDir, err := os.MkdirTemp(filepath.Dir(path), ".~soffice-*")
+	if err != nil {
+		return fmt.Errorf("impossible de créer le dossier temporaire : %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	if err := p.convert(ctx, inputFile, workDir, archive); err != nil {
+		return err
+	}
+	return commitPDF(pdfPath(workDir, inputFile, types.ExportOptions{}), path)
+}
+
+// Close supprime le profil utilisateur privé du processeur
+func (p *LibreOfficeFileProcessor) Close() error {
+	return os.RemoveAll(p.profileDir)
+}
+
+func (p *LibreOfficeFileProcessor) convert(ctx context.Context, inputFile, outputDir string, archive bool) error {
+	absInput, err := filepath.Abs(inputFile)
+	if err != nil {
+		return fmt.Errorf("impossible de convertir en chemin absolu : %v", err)
+	}
+	absOutput, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("impossible de convertir en chemin absolu : %v", err)
+	}
+
+	format := "pdf"
+	if archive {
+		format = pdfA2Filter
+	}
+
+	started := time.Now()
+	cmd := exec.CommandContext(ctx, p.binary,
+		"-env:UserInstallation="+fileURL(p.profileDir),
+		"--headless",
+		"--norestore",
+		"--nologo",
+		"--convert-to", format,
+		"--outdir", absOutput,
+		absInput,
+	)
+	killProcessTree(cmd)
+	cmd.WaitDelay = 5 * time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timeout lors de la conversion LibreOffice")
+	}
+	if err != nil {
+		return fmt.Errorf("échec de soffice : %v (%s)", err, strings.TrimSpace(string(output)))
+	}
+
+	// soffice renvoie 0 même lorsqu'il n'a rien converti : on vérifie que le PDF
+	// a bien été (ré)écrit pendant cette tentative
+	info, err := os.Stat(pdfPath(absOutput, inputFile, types.ExportOptions{}))
+	if err != nil || info.ModTime().Before(started.Add(-time.Second)) {
+		return fmt.Errorf("soffice n'a produit aucun PDF (%s)", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func findSoffice() (string, error) {
+	for _, name := range sofficeBinaries {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("LibreOffice introuvable (exécutables recherchés : %s)", strings.Join(sofficeBinaries, ", "))
+}
+
+// fileURL convertit un chemin local en URL file:// comprise par LibreOffice
+func fileURL(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
@@ -0,0 +1,236 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sofficeStub simule soffice : il relève le profil et le dossier de sortie
+// demandés, crée le profil comme le ferait LibreOffice, puis exécute action
+const sofficeStub = `#!/bin/sh
+for arg; do
+	case "$prev" in --outdir) outdir="$arg" ;; esac
+	case "$arg" in -env:UserInstallation=file://*) profile="${arg#-env:UserInstallation=file://}" ;; esac
+	prev="$arg"
+	input="$arg"
+done
+mkdir -p "$profile/user"
+name=$(basename "$input")
+name="${name%.*}"
+`
+
+// installSoffice place un faux soffice en tête du PATH
+func installSoffice(t *testing.T, action string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := sofficeStub + action + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "soffice"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// writeSamplePDF écrit un PDF d'une page, recopié par le faux soffice
+func writeSamplePDF(t *testing.T) string {
+	t.Helper()
+	doc := pdf.New()
+	doc.AddPage(595, 842)
+	path := filepath.Join(t.TempDir(), "sample.pdf")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := doc.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLibreOfficeFileProcessor(t *testing.T) {
+	t.Setenv("STUB_PDF", writeSamplePDF(t))
+
+	tests := []struct {
+		name    string
+		action  string
+		wantErr string
+	}{
+		{
+			name:   "conversion",
+			action: `cp "$STUB_PDF" "$outdir/$name.pdf"`,
+		},
+		{
+			name:    "code de sortie non nul",
+			action:  `echo "erreur simulée" >&2; exit 3`,
+			wantErr: "erreur simulée",
+		},
+		{
+			name:    "aucun PDF produit",
+			action:  `exit 0`,
+			wantErr: "aucun PDF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installSoffice(t, tt.action)
+
+			input := filepath.Join(t.TempDir(), "Classeur.xlsx")
+			if err := os.WriteFile(input, []byte("classeur"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			outputDir := t.TempDir()
+
+			p, err := NewLibreOfficeFileProcessor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs, err := p.ProcessFile(context.Background(), input, outputDir, types.ExportOptions{})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("erreur = %v, attendu %q", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := filepath.Join(outputDir, "Classeur.pdf")
+				if len(outputs) != 1 || outputs[0] != want {
+					t.Fatalf("PDF produits = %v, attendu %s", outputs, want)
+				}
+				if _, err := pdf.CheckFile(want); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Le dossier de travail de chaque tentative est supprimé
+			entries, _ := os.ReadDir(outputDir)
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".~") {
+					t.Errorf("fichier temporaire laissé : %s", entry.Name())
+				}
+			}
+
+			// Le profil privé, créé par soffice, est supprimé à la fermeture
+			if _, err := os.Stat(filepath.Join(p.profileDir, "user")); err != nil {
+				t.Fatalf("profil LibreOffice non utilisé : %v", err)
+			}
+			if err := p.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(p.profileDir); !os.IsNotExist(err) {
+				t.Fatalf("profil LibreOffice non supprimé : %v", err)
+			}
+		})
+	}
+}
+
+func TestLibreOfficeCancellation(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		timeout bool
+		wantErr string
+	}{
+		{
+			// soffice lance ses propres processus : toute l'arborescence est arrêtée
+			name:    "conversion en cours",
+			action:  `sleep 30 & echo $! > "$STUB_PID"; wait`,
+			wantErr: "conversion annulée",
+		},
+		{
+			name:    "délai dépassé",
+			action:  `sleep 30 & echo $! > "$STUB_PID"; wait`,
+			timeout: true,
+			wantErr: "timeout de la conversion",
+		},
+		{
+			// L'annulation interrompt aussi l'attente entre deux tentatives
+			name:    "attente avant nouvelle tentative",
+			action:  `echo $$ > "$STUB_PID"; exit 3`,
+			wantErr: "conversion annulée",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "pid")
+			t.Setenv("STUB_PID", pidFile)
+			installSoffice(t, tt.action)
+
+			input := filepath.Join(t.TempDir(), "Classeur.xlsx")
+			if err := os.WriteFile(input, []byte("classeur"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewLibreOfficeFileProcessor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout {
+				ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			}
+			defer cancel()
+			go func() {
+				// Annulation une fois le faux soffice lancé
+				for ctx.Err() == nil {
+					if _, err := os.Stat(pidFile); err == nil && !tt.timeout {
+						time.Sleep(100 * time.Millisecond)
+						cancel()
+					}
+					time.Sleep(20 * time.Millisecond)
+				}
+			}()
+
+			start := time.Now()
+			_, err = p.ProcessFile(ctx, input, t.TempDir(), types.ExportOptions{})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("erreur = %v, attendu %q", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > retryDelay+time.Second {
+				t.Errorf("interruption en %v", elapsed)
+			}
+
+			data, err := os.ReadFile(pidFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if processRunning(pid) {
+				t.Errorf("processus %d lancé par soffice toujours actif", pid)
+			}
+		})
+	}
+}
+
+// processRunning indique si le processus existe encore, un processus terminé mais
+// non récupéré par son parent ne comptant pas
+func processRunning(pid int) bool {
+	for range 20 {
+		if syscall.Kill(pid, 0) != nil {
+			return false
+		}
+		if stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil && strings.Contains(string(stat), ") Z ") {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
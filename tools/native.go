@@ -0,0 +1,144 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fredon_to_pdf/render"
+	"fredon_to_pdf/types"
+	"fredon_to_pdf/workbook"
+	"fredon_to_pdf/xls"
+	"fredon_to_pdf/xlsx"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NativeFileProcessor convertit les classeurs sans aucune suite bureautique :
+// le fichier est lu et mis en page directement en Go
+type NativeFileProcessor struct{}
+
+func init() {
+	Register(Backend{
+		Name:       "native",
+		Extensions: []string{".xls", ".xlsx", ".xlsm"},
+		ThreadSafe: true,
+		Priority:   30,
+		New: func() (FileProcessor, error) {
+			p, err := NewNativeFileProcessor()
+			if err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	})
+}
+
+func NewNativeFileProcessor() (*NativeFileProcessor, error) {
+	return &NativeFileProcessor{}, nil
+}
+
+func (p *NativeFileProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	// Les polices standard utilisées ne sont pas incorporées, ce qu'exige PDF/A :
+	// un autre moteur prend le relais
+	if opts.PDFA() {
+		return nil, fmt.Errorf("profil %s non pris en charge (polices non incorporées)", types.ProfilePDFA2B)
+	}
+
+	// Vérification des chemins
+	if err := validatePaths(inputFile, outputDir, opts); err != nil {
+		return nil, fmt.Errorf("erreur de validation des chemins : %v", err)
+	}
+
+	wb, err := openWorkbook(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("erreur de lecture du classeur : %v", err)
+	}
+
+	// Sélection des feuilles
+	infos := make([]sheetInfo, len(wb.Sheets))
+	for i, sheet := range wb.Sheets {
+		infos[i] = sheetInfo{Name: sheet.Name, Hidden: sheet.Hidden}
+	}
+	indexes, err := selectSheets(infos, opts)
+	if err != nil {
+		return nil, err
+	}
+	sheets := make([]*workbook.Sheet, len(indexes))
+	for i, index := range indexes {
+		sheets[i] = wb.Sheets[index]
+		if err := setSheetPageSetup(sheets[i], opts.Layout()); err != nil {
+			return nil, fmt.Errorf("feuille %q : %v", sheets[i].Name, err)
+		}
+	}
+
+	title := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	if !opts.PerSheet {
+		if err := p.writePDF(ctx, wb, sheets, title, pdfPath(outputDir, inputFile, opts)); err != nil {
+			return nil, err
+		}
+		return []string{pdfPath(outputDir, inputFile, opts)}, nil
+	}
+
+	// Un PDF par feuille ; les feuilles sans données à imprimer sont ignorées
+	var outputs []string
+	for _, sheet := range sheets {
+		path := sheetPDFPath(outputDir, inputFile, sheet.Name, opts)
+		err := p.writePDF(ctx, wb, []*workbook.Sheet{sheet}, title+" - "+sheet.Name, path)
+		if errors.Is(err, render.ErrEmpty) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, path)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("erreur de mise en page : %v", render.ErrEmpty)
+	}
+	return outputs, nil
+}
+
+// writePDF met en page les feuilles données dans un PDF
+func (p *NativeFileProcessor) writePDF(ctx context.Context, wb *workbook.Workbook, sheets []*workbook.Sheet, title, pdfPath string) error {
+	if err := ctx.Err(); err != nil {
+		return interrupted(ctx, err)
+	}
+
+	doc, err := render.Sheets(wb, sheets)
+	if errors.Is(err, render.ErrEmpty) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("erreur de mise en page : %v", err)
+	}
+	doc.Title = title
+
+	if err := ctx.Err(); err != nil {
+		return interrupted(ctx, err)
+	}
+
+	return writeAtomic(pdfPath, func(tmp string) error {
+		file, err := os.Create(tmp)
+		if err != nil {
+			return fmt.Errorf("impossible de créer le fichier PDF : %v", err)
+		}
+		if err := doc.Write(file); err != nil {
+			file.Close()
+			return fmt.Errorf("erreur d'écriture du PDF : %v", err)
+		}
+		return file.Close()
+	})
+}
+
+// openWorkbook charge un classeur selon son extension
+func openWorkbook(inputFile string) (*workbook.Workbook, error) {
+	switch strings.ToLower(filepath.Ext(inputFile)) {
+	case ".xlsx", ".xlsm":
+		return xlsx.Open(inputFile)
+	case ".xls":
+		return xls.Open(inputFile)
+	default:
+		return nil, fmt.Errorf("format non supporté par le moteur natif : %s", filepath.Ext(inputFile))
+	}
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"flag"
+	"fredon_to_pdf/pdf"
+	"fredon_to_pdf/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// go test ./tools -run Native -update régénère les PDF de référence
+var update = flag.Bool("update", false, "régénère les PDF de référence de testdata")
+
+var (
+	flateStreamRe = regexp.MustCompile(`/FlateDecode[^>]*>>\s*stream\r?\n`)
+	showTextRe    = regexp.MustCompile(`\(((?:\\.|[^\\)])*)\) Tj`)
+)
+
+// pdfText renvoie les textes affichés par les flux de contenu d'un PDF, dans
+// l'ordre du document
+func pdfText(t *testing.T, data []byte) []string {
+	t.Helper()
+	var texts []string
+	for _, loc := range flateStreamRe.FindAllIndex(data, -1) {
+		end := bytes.Index(data[loc[1]:], []byte("endstream"))
+		if end < 0 {
+			t.Fatal("flux PDF non terminé")
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data[loc[1] : loc[1]+end]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(zr)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Fatal(err)
+		}
+		for _, m := range showTextRe.FindAllSubmatch(content, -1) {
+			texts = append(texts, latin1(unescapeString(string(m[1]))))
+		}
+	}
+	return texts
+}
+
+// latin1 décode un texte WinAnsi, identique à Latin-1 hors des caractères 0x80
+// à 0x9F, absents des classeurs de test
+func latin1(s string) string {
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+// unescapeString inverse escapeString
+func unescapeString(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\(`, `(`, `\)`, `)`, `\r`, "\r", `\n`, "\n")
+	return r.Replace(s)
+}
+
+func TestNativeGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages int
+		texts []string // textes attendus, en plus de la comparaison au PDF de référence
+	}{
+		{name: "facture", pages: 1, texts: []string{"Facture N° 2502", "1\u00a0350,00", "21/01/2025", "1\u00a0470,50"}},
+		{name: "releve", pages: 4, texts: []string{"Valeur", "180,00", "Synthèse", "Nombre de lignes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join("testdata", tt.name+".xlsx")
+			golden := filepath.Join("testdata", tt.name+".pdf")
+			outputDir := t.TempDir()
+
+			p, err := NewNativeFileProcessor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs, err := p.ProcessFile(context.Background(), input, outputDir, types.ExportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(outputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("PDF de référence absent (go test -update pour le créer) : %v", err)
+			}
+
+			gotPages, err := pdf.PageCount(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantPages, err := pdf.PageCount(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotPages != wantPages || gotPages != tt.pages {
+				t.Errorf("pages = %d, référence %d, attendu %d", gotPages, wantPages, tt.pages)
+			}
+
+			gotText, wantText := pdfText(t, got), pdfText(t, want)
+			if !slices.Equal(gotText, wantText) {
+				t.Errorf("texte différent de la référence :\n%q\nattendu :\n%q", gotText, wantText)
+			}
+			for _, text := range tt.texts {
+				if !slices.Contains(gotText, text) {
+					t.Errorf("texte %q absent du PDF", text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/go-ole/go-ole"
+)
+
+// excelProcessID n'a de sens que sous Windows
+func excelProcessID(excel *ole.IDispatch) int {
+	return 0
+}
+
+// killProcess termine un processus, sans effet si pid vaut 0
+func killProcess(pid int) {
+	if pid == 0 {
+		return
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// killProcessTree place la commande dans son propre groupe de processus afin que
+// son annulation termine aussi les processus qu'elle a lancés (soffice lance
+// oosplash puis soffice.bin)
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
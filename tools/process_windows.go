@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+var (
+	user32                       = syscall.NewLazyDLL("user32.dll")
+	procGetWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+)
+
+// excelProcessID renvoie le PID du processus EXCEL.EXE piloté, à partir du
+// handle de sa fenêtre principale (0 s'il ne peut être déterminé)
+func excelProcessID(excel *ole.IDispatch) int {
+	hwnd, err := oleutil.GetProperty(excel, "Hwnd")
+	if err != nil {
+		return 0
+	}
+	defer hwnd.Clear()
+
+	var pid uint32
+	procGetWindowThreadProcessId.Call(uintptr(hwnd.Val), uintptr(unsafe.Pointer(&pid)))
+	return int(pid)
+}
+
+// killProcess termine un processus, sans effet si pid vaut 0
+func killProcess(pid int) {
+	if pid == 0 {
+		return
+	}
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Kill()
+	}
+}
+
+// killProcessTree fait en sorte que l'annulation de la commande termine aussi les
+// processus qu'elle a lancés (soffice.exe délègue la conversion à soffice.bin)
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/pdfa"
+	"fredon_to_pdf/types"
+	"io"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AutoBackend sélectionne automatiquement les moteurs disponibles par ordre de priorité
+const AutoBackend = "auto"
+
+// Backend décrit un moteur de conversion et ses capacités
+type Backend struct {
+	Name       string
+	Extensions []string    // extensions prises en charge, en minuscules (".xls")
+	OS         []string    // systèmes supportés (vide = tous)
+	ThreadSafe bool        // plusieurs instances peuvent convertir en parallèle
+	Priority   int         // ordre de préférence en sélection automatique (plus petit d'abord)
+	Enabled    func() bool // moteur soumis à configuration (nil = toujours actif)
+	New        func() (FileProcessor, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Backend)
+)
+
+// Register enregistre un moteur ; appelée depuis la fonction init de chaque moteur
+func Register(b Backend) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if b.Name == "" || b.New == nil {
+		panic("tools: moteur de conversion sans nom ou sans constructeur")
+	}
+	if _, dup := registry[b.Name]; dup {
+		panic("tools: moteur de conversion enregistré deux fois : " + b.Name)
+	}
+	registry[b.Name] = b
+}
+
+// Backends renvoie tous les moteurs enregistrés, triés par priorité
+func Backends() []Backend {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	backends := make([]Backend, 0, len(registry))
+	for _, b := range registry {
+		backends = append(backends, b)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		if backends[i].Priority != backends[j].Priority {
+			return backends[i].Priority < backends[j].Priority
+		}
+		return backends[i].Name < backends[j].Name
+	})
+	return backends
+}
+
+// BackendNames renvoie les noms des moteurs enregistrés, triés par priorité
+func BackendNames() []string {
+	var names []string
+	for _, b := range Backends() {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+// Supports indique si le moteur sait convertir un fichier de cette extension
+func (b Backend) Supports(ext string) bool {
+	return slices.Contains(b.Extensions, strings.ToLower(ext))
+}
+
+// Available indique si le moteur peut fonctionner sur le système courant et s'il est configuré
+func (b Backend) Available() bool {
+	if len(b.OS) > 0 && !slices.Contains(b.OS, runtime.GOOS) {
+		return false
+	}
+	return b.Enabled == nil || b.Enabled()
+}
+
+// Candidates renvoie les moteurs à essayer dans l'ordre : le moteur demandé
+// en premier, puis les autres moteurs disponibles en repli
+func Candidates(preferred string) ([]Backend, error) {
+	var candidates []Backend
+	var others []Backend
+	found := preferred == "" || preferred == AutoBackend
+
+	for _, b := range Backends() {
+		switch {
+		case b.Name == preferred:
+			if !b.Available() {
+				return nil, fmt.Errorf("le moteur %s n'est pas disponible sur %s ou n'est pas configuré", b.Name, runtime.GOOS)
+			}
+			candidates = append(candidates, b)
+			found = true
+		case b.Available():
+			others = append(others, b)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("moteur de conversion inconnu : %s (disponibles : %s)", preferred, strings.Join(BackendNames(), ", "))
+	}
+
+	candidates = append(candidates, others...)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("aucun moteur de conversion disponible sur %s", runtime.GOOS)
+	}
+	return candidates, nil
+}
+
+// Without retire un moteur de la liste (le service HTTP ne doit pas se déléguer
+// ses propres conversions)
+func Without(backends []Backend, name string) []Backend {
+	var kept []Backend
+	for _, b := range backends {
+		if b.Name != name {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+// ThreadSafe indique si tous les moteurs donnés supportent la conversion en parallèle
+func ThreadSafe(backends []Backend) bool {
+	for _, b := range backends {
+		if !b.ThreadSafe {
+			return false
+		}
+	}
+	return true
+}
+
+// ChainProcessor essaie successivement plusieurs moteurs jusqu'au premier succès.
+// Les moteurs sont instanciés à la première utilisation ; une instance n'est pas
+// destinée à être partagée entre plusieurs goroutines.
+type ChainProcessor struct {
+	backends    []Backend
+	processors  map[string]FileProcessor
+	initErrors  map[string]error
+	lastBackend string
+	attempts    int
+}
+
+func NewChainProcessor(backends []Backend) *ChainProcessor {
+	return &ChainProcessor{
+		backends:   backends,
+		processors: make(map[string]FileProcessor),
+		initErrors: make(map[string]error),
+	}
+}
+
+// ProcessFile convertit le classeur avec le premier moteur qui réussit et renvoie
+// les PDF produits
+func (c *ChainProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	c.lastBackend = ""
+	c.attempts = 0
+	ext := filepath.Ext(inputFile)
+
+	var errs []string
+	for _, b := range c.backends {
+		if !b.Supports(ext) {
+			continue
+		}
+		// Inutile de se replier sur un autre moteur après une annulation
+		if err := ctx.Err(); err != nil {
+			return nil, interrupted(ctx, err)
+		}
+		c.attempts++
+		log := helper.LoggerFrom(ctx).With("backend", b.Name, "engine", c.attempts)
+		processor, err := c.processor(b)
+		if err != nil {
+			log.Warn("moteur indisponible", "error", err)
+			errs = append(errs, fmt.Sprintf("%s : %v", b.Name, err))
+			continue
+		}
+		log.Debug("conversion par le moteur")
+		outputs, err := processor.ProcessFile(ctx, inputFile, outputDir, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Warn("échec du moteur", "error", err)
+			errs = append(errs, fmt.Sprintf("%s : %v", b.Name, err))
+			continue
+		}
+		if len(outputs) == 0 {
+			errs = append(errs, fmt.Sprintf("%s : aucun PDF produit", b.Name))
+			continue
+		}
+		// Profil d'archivage : un PDF non conforme fait essayer le moteur suivant
+		if opts.PDFA() {
+			if err := ensurePDFA(outputs); err != nil {
+				log.Warn("PDF non conforme PDF/A", "error", err)
+				errs = append(errs, fmt.Sprintf("%s : %v", b.Name, err))
+				continue
+			}
+		}
+		c.lastBackend = b.Name
+		return outputs, nil
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("aucun moteur ne prend en charge l'extension %s", ext)
+	}
+	return nil, fmt.Errorf("échec de tous les moteurs (%s)", strings.Join(errs, " ; "))
+}
+
+// ensurePDFA met les PDF produits en conformité PDF/A-2b et la vérifie
+func ensurePDFA(outputs []string) error {
+	for _, output := range outputs {
+		if err := pdfa.Ensure(output); err != nil {
+			return fmt.Errorf("%s : %v", filepath.Base(output), err)
+		}
+	}
+	return nil
+}
+
+// LastBackend renvoie le nom du moteur ayant réussi la dernière conversion
+func (c *ChainProcessor) LastBackend() string {
+	return c.lastBackend
+}
+
+// Attempts renvoie le nombre de moteurs essayés lors de la dernière conversion
+func (c *ChainProcessor) Attempts() int {
+	return c.attempts
+}
+
+// Close libère les moteurs qui détiennent des ressources
+func (c *ChainProcessor) Close() error {
+	var errs []string
+	for name, p := range c.processors {
+		if closer, ok := p.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Sprintf("%s : %v", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("erreur de fermeture des moteurs (%s)", strings.Join(errs, " ; "))
+	}
+	return nil
+}
+
+// processor instancie un moteur à la demande ; un échec d'initialisation est mémorisé
+func (c *ChainProcessor) processor(b Backend) (FileProcessor, error) {
+	if p, ok := c.processors[b.Name]; ok {
+		return p, nil
+	}
+	if err, ok := c.initErrors[b.Name]; ok {
+		return nil, err
+	}
+	p, err := b.New()
+	if err != nil {
+		err = fmt.Errorf("initialisation impossible : %v", err)
+		c.initErrors[b.Name] = err
+		return nil, err
+	}
+	c.processors[b.Name] = p
+	return p, nil
+}
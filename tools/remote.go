@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RemoteURLEnv désigne la variable d'environnement contenant l'adresse d'un
+// service "fredon_to_pdf serve" ; le moteur distant n'est actif que si elle est définie
+const RemoteURLEnv = "FREDON_REMOTE_URL"
+
+// RemoteBackend est le nom du moteur distant dans le registre
+const RemoteBackend = "remote"
+
+// RemoteFileProcessor délègue la conversion à un service HTTP, typiquement un
+// poste Windows équipé d'Excel exécutant "fredon_to_pdf serve"
+type RemoteFileProcessor struct {
+	endpoint string
+	client   *http.Client
+}
+
+func init() {
+	Register(Backend{
+		Name:       RemoteBackend,
+		Extensions: []string{".xls", ".xlsx", ".xlsm"},
+		ThreadSafe: true,
+		Priority:   40,
+		Enabled: func() bool {
+			return os.Getenv(RemoteURLEnv) != ""
+		},
+		New: func() (FileProcessor, error) {
+			p, err := NewRemoteFileProcessor(os.Getenv(RemoteURLEnv))
+			if err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	})
+}
+
+func NewRemoteFileProcessor(baseURL string) (*RemoteFileProcessor, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("adresse du service de conversion invalide : %q", baseURL)
+	}
+	return &RemoteFileProcessor{
+		endpoint: strings.TrimSuffix(u.String(), "/") + "/convert",
+		// Pas de timeout global : la durée est bornée par le contexte de chaque conversion
+		client: &http.Client{},
+	}, nil
+}
+
+func (p *RemoteFileProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	// Vérification des chemins
+	if err := validatePaths(inputFile, outputDir, opts); err != nil {
+		return nil, fmt.Errorf("erreur de validation des chemins : %v", err)
+	}
+
+	// Envoi du classeur en flux, sans le charger en mémoire
+	body, contentType := multipartFile(inputFile, opts)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("requête invalide : %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, interrupted(ctx, fmt.Errorf("service de conversion injoignable : %v", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return nil, fmt.Errorf("le service de conversion a refusé le fichier : %s", apiErr.Error)
+	}
+
+	// Plusieurs PDF (export par feuille) sont renvoyés dans une archive ZIP
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/zip") {
+		outputs, err := receiveZip(resp.Body, filepath.Dir(pdfPath(outputDir, inputFile, opts)))
+		if err != nil {
+			return nil, interrupted(ctx, err)
+		}
+		return outputs, nil
+	}
+
+	path := pdfPath(outputDir, inputFile, opts)
+	if err := receiveFile(resp.Body, path); err != nil {
+		return nil, interrupted(ctx, err)
+	}
+	return []string{path}, nil
+}
+
+// receiveFile enregistre le PDF reçu, mis en place une fois complet
+func receiveFile(r io.Reader, path string) error {
+	return writeAtomic(path, func(tmp string) error {
+		file, err := os.Create(tmp)
+		if err != nil {
+			return fmt.Errorf("impossible de créer le fichier PDF : %v", err)
+		}
+		if _, err := io.Copy(file, r); err != nil {
+			file.Close()
+			return fmt.Errorf("erreur de réception du PDF : %v", err)
+		}
+		return file.Close()
+	})
+}
+
+// receiveZip extrait les PDF de l'archive reçue dans outputDir
+func receiveZip(r io.Reader, outputDir string) ([]string, error) {
+	tmp, err := os.CreateTemp(outputDir, ".remote_*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("impossible de recevoir l'archive : %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return nil, fmt.Errorf("erreur de réception de l'archive : %v", err)
+	}
+
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return nil, fmt.Errorf("archive reçue invalide : %v", err)
+	}
+	var outputs []string
+	for _, entry := range archive.File {
+		// Seul le nom de l'entrée est conservé : l'archive ne peut pas écrire
+		// hors du dossier de sortie
+		name := helper.SanitizeFilename(path.Base(entry.Name))
+		if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+			continue
+		}
+		src, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("archive reçue invalide : %v", err)
+		}
+		output := filepath.Join(outputDir, name)
+		err = receiveFile(src, output)
+		src.Close()
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("l'archive reçue ne contient aucun PDF")
+	}
+	return outputs, nil
+}
+
+// multipartFile construit un corps multipart contenant les options d'export puis
+// le fichier dans le champ "file"
+func multipartFile(path string, opts types.ExportOptions) (io.ReadCloser, string) {
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	go func() {
+		file, err := os.Open(path)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		defer file.Close()
+
+		if opts.Sheets != "" {
+			err = form.WriteField("sheets", opts.Sheets)
+		}
+		for _, name := range opts.SheetNames {
+			if err == nil {
+				err = form.WriteField("sheet", name)
+			}
+		}
+		if err == nil && opts.PerSheet {
+			err = form.WriteField("per_sheet", "1")
+		}
+		if err == nil && opts.Profile != "" {
+			err = form.WriteField("profile", opts.Profile)
+		}
+		if err == nil && !opts.Layout().Empty() {
+			var layout []byte
+			if layout, err = json.Marshal(opts.PageSetup); err == nil {
+				err = form.WriteField("page_setup", string(layout))
+			}
+		}
+
+		// Les PDF renvoyés portent le nom demandé, sans ses dossiers
+		if err == nil && opts.Name != "" {
+			err = form.WriteField("name", filepath.Base(opts.OutputName(path)))
+		}
+
+		var part io.Writer
+		if err == nil {
+			part, err = form.CreateFormFile("file", filepath.Base(path))
+		}
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	return reader, form.FormDataContentType()
+}
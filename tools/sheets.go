@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"fmt"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
+	"fredon_to_pdf/workbook"
+	"path/filepath"
+	"strings"
+)
+
+// sheetInfo décrit une feuille du classeur pour la sélection des feuilles exportées
+type sheetInfo struct {
+	Name   string
+	Hidden bool
+}
+
+// selectSheets renvoie les indices des feuilles à exporter, dans l'ordre du
+// classeur. Les feuilles masquées ne sont exportées que si elles sont nommées.
+func selectSheets(sheets []sheetInfo, opts types.ExportOptions) ([]int, error) {
+	var selected []int
+	if opts.Sheets == types.SheetsNamed {
+		for i, sheet := range sheets {
+			for _, name := range opts.SheetNames {
+				if strings.EqualFold(strings.TrimSpace(name), sheet.Name) {
+					selected = append(selected, i)
+					break
+				}
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("aucune des feuilles demandées n'existe (%s)", strings.Join(opts.SheetNames, ", "))
+		}
+		return selected, nil
+	}
+
+	for i, sheet := range sheets {
+		if !sheet.Hidden {
+			selected = append(selected, i)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("aucune feuille visible à exporter")
+	}
+	return selected, nil
+}
+
+// pdfPath renvoie le chemin du PDF d'un classeur, selon le nom demandé dans les
+// options ou à défaut celui du classeur
+func pdfPath(outputDir, inputFile string, opts types.ExportOptions) string {
+	return filepath.Join(outputDir, opts.OutputName(inputFile)+".pdf")
+}
+
+// sheetPDFPath renvoie le chemin du PDF d'une feuille en export par feuille :
+// <fichier>_<feuille>.pdf
+func sheetPDFPath(outputDir, inputFile, sheet string, opts types.ExportOptions) string {
+	return filepath.Join(outputDir, opts.OutputName(inputFile)+"_"+helper.SanitizeFilename(sheet)+".pdf")
+}
+
+// cmToPoints convertit des centimètres en points (1/72 de pouce)
+func cmToPoints(cm float64) float64 {
+	return cm / 2.54 * 72
+}
+
+// setSheetPageSetup remplace la mise en page d'une feuille lue par le moteur natif
+func setSheetPageSetup(sheet *workbook.Sheet, layout types.PageSetup) error {
+	ps := &sheet.PageSetup
+	switch layout.Orientation {
+	case types.OrientationPortrait:
+		ps.Orientation = workbook.Portrait
+	case types.OrientationLandscape:
+		ps.Orientation = workbook.Landscape
+	}
+	if code := layout.PaperCode(); code != 0 {
+		ps.PaperSize = code
+	}
+	if m := layout.Margins; m != nil {
+		ps.Margins = workbook.Margins{
+			Left:   cmToPoints(m.Left),
+			Right:  cmToPoints(m.Right),
+			Top:    cmToPoints(m.Top),
+			Bottom: cmToPoints(m.Bottom),
+		}
+	}
+	if layout.FitToWidth > 0 {
+		ps.FitToPage = true
+		ps.FitToWidth = layout.FitToWidth
+		ps.FitToHeight = layout.FitToHeight
+	}
+	if layout.PrintArea != "" {
+		area, err := workbook.ParseRange(layout.PrintArea)
+		if err != nil {
+			return fmt.Errorf("zone d'impression invalide : %v", err)
+		}
+		ps.PrintArea = &area
+	}
+	if first, last, ok := layout.TitleRowRange(); ok {
+		ps.TitleRows = &[2]int{first - 1, last - 1}
+	}
+	if layout.Gridlines != nil {
+		ps.Gridlines = *layout.Gridlines
+	}
+	return nil
+}
@@ -1,21 +1,77 @@
 package tools
 
 import (
+	"context"
 	"fmt"
-	"runtime"
+	"fredon_to_pdf/types"
+	"os"
+	"path/filepath"
+	"time"
 )
 
-// Interface FileProcessor qui définit la méthode ProcessFile
+// Interface FileProcessor qui définit la méthode ProcessFile ; l'annulation du
+// contexte doit interrompre la conversion et libérer ses ressources. Les PDF
+// produits sont renvoyés : un seul, sauf en export par feuille.
 type FileProcessor interface {
-	ProcessFile(inputFile, outputDir string) error
+	ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error)
 }
 
-// Fonction pour obtenir le bon FileProcessor selon l'OS
-func GetFileProcessor() (FileProcessor, error) {
-	switch runtime.GOOS {
-	case "windows":
-		return &WindowsFileProcessor{}, nil
+// GetFileProcessor renvoie un processeur qui utilise le moteur demandé ("auto"
+// ou vide pour laisser faire la priorité) puis se replie sur les autres moteurs
+// disponibles en cas d'échec
+func GetFileProcessor(backend string) (*ChainProcessor, error) {
+	candidates, err := Candidates(backend)
+	if err != nil {
+		return nil, err
+	}
+	return NewChainProcessor(candidates), nil
+}
+
+// validatePaths vérifie le fichier d'entrée et crée si nécessaire le dossier du
+// PDF, que le nom demandé dans les options peut placer sous le dossier de sortie
+func validatePaths(inputFile, outputDir string, opts types.ExportOptions) error {
+	outputDir = filepath.Dir(pdfPath(outputDir, inputFile, opts))
+
+	// Vérification du fichier d'entrée
+	if _, err := os.Stat(inputFile); err != nil {
+		return fmt.Errorf("le fichier d'entrée n'existe pas : %v", err)
+	}
+
+	// Vérification du dossier de sortie
+	if _, err := os.Stat(outputDir); err != nil {
+		if os.IsNotExist(err) {
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return fmt.Errorf("impossible de créer le dossier de sortie : %v", err)
+			}
+		} else {
+			return fmt.Errorf("erreur lors de la vérification du dossier de sortie : %v", err)
+		}
+	}
+
+	return nil
+}
+
+// sleepContext attend la durée donnée, sauf annulation du contexte
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// interrupted remplace l'erreur d'une conversion interrompue par la cause de
+// l'interruption, plus parlante que l'erreur de l'outil qui a été arrêté
+func interrupted(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return fmt.Errorf("conversion annulée")
+	case context.DeadlineExceeded:
+		return fmt.Errorf("timeout de la conversion")
 	default:
-		return nil, fmt.Errorf("système d'exploitation non supporté: %s", runtime.GOOS)
+		return err
 	}
 }
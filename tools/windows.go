@@ -3,7 +3,8 @@ package tools
 import (
 	"context"
 	"fmt"
-	"os"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/types"
 	"path/filepath"
 	"strings"
 	"time"
@@ -22,6 +23,23 @@ type WindowsFileProcessor struct {
 	initialized bool
 }
 
+func init() {
+	Register(Backend{
+		Name:       "excel",
+		Extensions: []string{".xls", ".xlsx", ".xlsm"},
+		OS:         []string{"windows"},
+		ThreadSafe: true,
+		Priority:   10,
+		New: func() (FileProcessor, error) {
+			p, err := NewWindowsFileProcessor()
+			if err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
+	})
+}
+
 func NewWindowsFileProcessor() (*WindowsFileProcessor, error) {
 	processor := &WindowsFileProcessor{}
 	if err := processor.initializeCOM(); err != nil {
@@ -30,66 +48,58 @@ func NewWindowsFileProcessor() (*WindowsFileProcessor, error) {
 	return processor, nil
 }
 
-func (p *WindowsFileProcessor) ProcessFile(inputFile, outputDir string) error {
+func (p *WindowsFileProcessor) ProcessFile(ctx context.Context, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
 	// Vérification des chemins
-	if err := p.validatePaths(inputFile, outputDir); err != nil {
-		return fmt.Errorf("erreur de validation des chemins : %v", err)
+	if err := validatePaths(inputFile, outputDir, opts); err != nil {
+		return nil, fmt.Errorf("erreur de validation des chemins : %v", err)
 	}
 
-	// Création du contexte avec timeout
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	// Création du contexte avec timeout, annulé aussi en cas d'arrêt du traitement
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
 	defer cancel()
 
 	// Création de l'application Excel avec retries
 	excel, err := p.createExcelApp(ctx)
 	if err != nil {
-		return fmt.Errorf("erreur de création de l'application Excel : %v", err)
+		return nil, fmt.Errorf("erreur de création de l'application Excel : %v", err)
 	}
 	defer safeReleaseWithRetry(excel)
+	// Excel est quitté dans tous les cas, pour ne pas laisser d'EXCEL.EXE orphelin
+	defer p.quitExcel(excel)
+
+	// Les appels COM sont bloquants : en cas d'annulation ou de timeout, le
+	// processus Excel est terminé pour les interrompre
+	pid := excelProcessID(excel)
+	stop := context.AfterFunc(ctx, func() {
+		killProcess(pid)
+	})
+	defer stop()
 
 	// Configuration de l'application Excel
 	if err := p.configureExcel(excel); err != nil {
-		return fmt.Errorf("erreur de configuration d'Excel : %v", err)
+		return nil, fmt.Errorf("erreur de configuration d'Excel : %v", err)
 	}
 
 	// Ouverture du classeur
 	workbook, err := p.openWorkbook(excel, inputFile)
 	if err != nil {
-		return fmt.Errorf("erreur d'ouverture du classeur : %v", err)
+		return nil, interrupted(ctx, fmt.Errorf("erreur d'ouverture du classeur : %v", err))
 	}
 	defer safeReleaseWithRetry(workbook)
 
 	// Export en PDF
-	if err := p.exportToPDF(workbook, inputFile, outputDir); err != nil {
-		return fmt.Errorf("erreur d'export en PDF : %v", err)
-	}
-
-	// Fermeture du classeur
-	if err := p.closeWorkbook(workbook, excel); err != nil {
-		return fmt.Errorf("erreur de fermeture du classeur : %v", err)
-	}
-
-	return nil
-}
-
-func (p *WindowsFileProcessor) validatePaths(inputFile, outputDir string) error {
-	// Vérification du fichier d'entrée
-	if _, err := os.Stat(inputFile); err != nil {
-		return fmt.Errorf("le fichier d'entrée n'existe pas : %v", err)
+	outputs, err := p.exportToPDF(ctx, workbook, inputFile, outputDir, opts)
+	if err != nil {
+		p.closeWorkbook(workbook)
+		return nil, interrupted(ctx, fmt.Errorf("erreur d'export en PDF : %v", err))
 	}
 
-	// Vérification du dossier de sortie
-	if _, err := os.Stat(outputDir); err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(outputDir, 0755); err != nil {
-				return fmt.Errorf("impossible de créer le dossier de sortie : %v", err)
-			}
-		} else {
-			return fmt.Errorf("erreur lors de la vérification du dossier de sortie : %v", err)
-		}
+	// Fermeture du classeur, sans enregistrer la visibilité modifiée des feuilles
+	if err := p.closeWorkbook(workbook); err != nil {
+		return nil, fmt.Errorf("erreur de fermeture du classeur : %v", err)
 	}
 
-	return nil
+	return outputs, nil
 }
 
 func (p *WindowsFileProcessor) initializeCOM() error {
@@ -121,27 +131,26 @@ func (p *WindowsFileProcessor) createExcelApp(ctx context.Context) (*ole.IDispat
 	var lastErr error
 
 	for i := 0; i < maxRetries; i++ {
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("timeout lors de la création de l'application Excel")
-		default:
-			unknown, err := oleutil.CreateObject("Excel.Application")
-			if err != nil {
-				lastErr = err
-				time.Sleep(retryDelay)
-				continue
+		if i > 0 {
+			if err := sleepContext(ctx, retryDelay); err != nil {
+				return nil, interrupted(ctx, err)
 			}
+		}
 
-			excel, err = unknown.QueryInterface(ole.IID_IDispatch)
-			if err != nil {
-				unknown.Release()
-				lastErr = err
-				time.Sleep(retryDelay)
-				continue
-			}
+		unknown, err := oleutil.CreateObject("Excel.Application")
+		if err != nil {
+			lastErr = err
+			continue
+		}
 
-			return excel, nil
+		excel, err = unknown.QueryInterface(ole.IID_IDispatch)
+		if err != nil {
+			unknown.Release()
+			lastErr = err
+			continue
 		}
+
+		return excel, nil
 	}
 
 	return nil, fmt.Errorf("échec de la création de l'application Excel après %d tentatives : %v", maxRetries, lastErr)
@@ -150,7 +159,7 @@ func (p *WindowsFileProcessor) createExcelApp(ctx context.Context) (*ole.IDispat
 func (p *WindowsFileProcessor) configureExcel(excel *ole.IDispatch) error {
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("Récupération d'une panique lors de la configuration d'Excel : %v\n", r)
+			helper.GWarningLn("Récupération d'une panique lors de la configuration d'Excel : %v", r)
 		}
 	}()
 
@@ -193,33 +202,288 @@ func (p *WindowsFileProcessor) openWorkbook(excel *ole.IDispatch, inputFile stri
 	return workbook.ToIDispatch(), nil
 }
 
-func (p *WindowsFileProcessor) exportToPDF(workbook *ole.IDispatch, inputFile, outputDir string) error {
-	var lastErr error
-	for i := 0; i < maxRetries; i++ {
-		pdfPath := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))+".pdf")
-		if _, err := oleutil.CallMethod(workbook, "ExportAsFixedFormat", 0, pdfPath); err != nil {
-			lastErr = err
-			time.Sleep(retryDelay)
-			continue
+// Visibilité d'une feuille (XlSheetVisibility)
+const (
+	xlSheetVisible = -1
+	xlSheetHidden  = 0
+)
+
+func (p *WindowsFileProcessor) exportToPDF(ctx context.Context, workbook *ole.IDispatch, inputFile, outputDir string, opts types.ExportOptions) ([]string, error) {
+	// Export complet du classeur, tel qu'Excel l'imprime
+	layout := opts.Layout()
+	if opts.Default() && layout.Empty() {
+		path := pdfPath(outputDir, inputFile, opts)
+		if err := exportFixedFormat(ctx, workbook, path, opts.PDFA()); err != nil {
+			return nil, err
 		}
-		return nil
+		return []string{path}, nil
 	}
 
-	return fmt.Errorf("échec de l'export PDF après %d tentatives : %v", maxRetries, lastErr)
+	sheets, err := workbookSheets(workbook)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseSheets(sheets)
+
+	infos := make([]sheetInfo, len(sheets))
+	for i, sheet := range sheets {
+		infos[i] = sheet.sheetInfo
+	}
+	selected, err := selectSheets(infos, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mise en page imposée, appliquée aux seules feuilles exportées
+	if !layout.Empty() {
+		for _, i := range selected {
+			if err := setExcelPageSetup(sheets[i].dispatch, layout); err != nil {
+				return nil, fmt.Errorf("feuille %q : %v", sheets[i].Name, err)
+			}
+		}
+	}
+
+	// Une feuille masquée n'est pas exportée par Excel : les feuilles retenues sont
+	// rendues visibles avant de masquer les autres, un classeur devant toujours en
+	// garder au moins une visible
+	keep := make(map[int]bool, len(selected))
+	for _, i := range selected {
+		keep[i] = true
+		if err := setSheetVisible(sheets[i].dispatch, xlSheetVisible); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.PerSheet {
+		outputs := make([]string, 0, len(selected))
+		for _, i := range selected {
+			path := sheetPDFPath(outputDir, inputFile, sheets[i].Name, opts)
+			if err := exportFixedFormat(ctx, sheets[i].dispatch, path, opts.PDFA()); err != nil {
+				return nil, fmt.Errorf("feuille %q : %v", sheets[i].Name, err)
+			}
+			outputs = append(outputs, path)
+		}
+		return outputs, nil
+	}
+
+	for i, sheet := range sheets {
+		if !keep[i] {
+			if err := setSheetVisible(sheet.dispatch, xlSheetHidden); err != nil {
+				return nil, err
+			}
+		}
+	}
+	path := pdfPath(outputDir, inputFile, opts)
+	if err := exportFixedFormat(ctx, workbook, path, opts.PDFA()); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
 }
 
-func (p *WindowsFileProcessor) closeWorkbook(workbook, excel *ole.IDispatch) error {
-	if _, err := oleutil.CallMethod(workbook, "Close", false); err != nil {
-		return fmt.Errorf("impossible de fermer le classeur : %v", err)
+// Constantes de mise en page Excel
+const (
+	xlWorksheet = -4167 // XlSheetType d'une feuille de calcul
+	xlPortrait  = 1     // XlPageOrientation
+	xlLandscape = 2
+)
+
+// setExcelPageSetup remplace la mise en page d'une feuille. Les feuilles graphiques
+// n'ont ni zone d'impression, ni lignes à répéter, ni quadrillage : seuls
+// l'orientation, le format et les marges leur sont appliqués.
+func setExcelPageSetup(sheet *ole.IDispatch, layout types.PageSetup) error {
+	kind, err := oleutil.GetProperty(sheet, "Type")
+	if err != nil {
+		return fmt.Errorf("impossible de lire le type de la feuille : %v", err)
+	}
+	worksheet := toInt(kind.Value()) == xlWorksheet
+
+	result, err := oleutil.GetProperty(sheet, "PageSetup")
+	if err != nil {
+		return fmt.Errorf("impossible de lire la mise en page : %v", err)
+	}
+	setup := result.ToIDispatch()
+	defer safeReleaseWithRetry(setup)
+
+	type property struct {
+		name  string
+		value interface{}
+	}
+	var props []property
+	set := func(name string, value interface{}) {
+		props = append(props, property{name, value})
+	}
+
+	switch layout.Orientation {
+	case types.OrientationPortrait:
+		set("Orientation", xlPortrait)
+	case types.OrientationLandscape:
+		set("Orientation", xlLandscape)
+	}
+	if code := layout.PaperCode(); code != 0 {
+		set("PaperSize", code)
+	}
+	if m := layout.Margins; m != nil {
+		set("LeftMargin", cmToPoints(m.Left))
+		set("RightMargin", cmToPoints(m.Right))
+		set("TopMargin", cmToPoints(m.Top))
+		set("BottomMargin", cmToPoints(m.Bottom))
+	}
+	if worksheet {
+		if layout.FitToWidth > 0 {
+			// Zoom doit être désactivé pour que l'ajustement s'applique ; False
+			// en hauteur laisse le nombre de pages libre
+			set("Zoom", false)
+			set("FitToPagesWide", layout.FitToWidth)
+			if layout.FitToHeight > 0 {
+				set("FitToPagesTall", layout.FitToHeight)
+			} else {
+				set("FitToPagesTall", false)
+			}
+		}
+		if layout.PrintArea != "" {
+			set("PrintArea", strings.TrimSpace(layout.PrintArea))
+		}
+		if first, last, ok := layout.TitleRowRange(); ok {
+			set("PrintTitleRows", fmt.Sprintf("$%d:$%d", first, last))
+		}
+		if layout.Gridlines != nil {
+			set("PrintGridlines", *layout.Gridlines)
+		}
+	}
+
+	for _, prop := range props {
+		if _, err := oleutil.PutProperty(setup, prop.name, prop.value); err != nil {
+			return fmt.Errorf("impossible d'appliquer la mise en page (%s) : %v", prop.name, err)
+		}
+	}
+	return nil
+}
+
+// Qualité d'export (XlFixedFormatQuality)
+const xlQualityStandard = 0
+
+// exportFixedFormat exporte un classeur ou une feuille en PDF, avec retries. Pour
+// l'archivage, les propriétés du classeur (titre, auteur) sont incluses afin
+// d'alimenter les métadonnées PDF/A ajoutées ensuite ; Excel incorpore les polices.
+// Excel écrit dans un fichier temporaire, mis en place une fois le PDF vérifié.
+func exportFixedFormat(ctx context.Context, dispatch *ole.IDispatch, path string, archive bool) error {
+	return writeAtomic(path, func(tmp string) error {
+		args := []interface{}{0, tmp}
+		if archive {
+			// Quality, IncludeDocProperties, IgnorePrintAreas
+			args = append(args, xlQualityStandard, true, false)
+		}
+
+		var lastErr error
+		for i := 0; i < maxRetries; i++ {
+			if i > 0 {
+				if err := sleepContext(ctx, retryDelay); err != nil {
+					return err
+				}
+			}
+			if _, err := oleutil.CallMethod(dispatch, "ExportAsFixedFormat", args...); err != nil {
+				lastErr = err
+				continue
+			}
+			return nil
+		}
+
+		return fmt.Errorf("échec de l'export PDF après %d tentatives : %v", maxRetries, lastErr)
+	})
+}
+
+// excelSheet associe une feuille du classeur à son objet COM
+type excelSheet struct {
+	sheetInfo
+	dispatch *ole.IDispatch
+}
+
+// workbookSheets énumère les feuilles du classeur, graphiques compris
+func workbookSheets(workbook *ole.IDispatch) ([]excelSheet, error) {
+	collection, err := oleutil.GetProperty(workbook, "Sheets")
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lister les feuilles : %v", err)
 	}
+	items := collection.ToIDispatch()
+	defer safeReleaseWithRetry(items)
 
-	if _, err := oleutil.CallMethod(excel, "Quit"); err != nil {
-		return fmt.Errorf("impossible de quitter Excel : %v", err)
+	count, err := oleutil.GetProperty(items, "Count")
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lister les feuilles : %v", err)
 	}
 
+	var sheets []excelSheet
+	for i := 1; i <= int(count.Val); i++ {
+		item, err := oleutil.GetProperty(items, "Item", i)
+		if err != nil {
+			releaseSheets(sheets)
+			return nil, fmt.Errorf("impossible de lire la feuille %d : %v", i, err)
+		}
+		sheet := excelSheet{dispatch: item.ToIDispatch()}
+		sheets = append(sheets, sheet)
+
+		name, err := oleutil.GetProperty(sheet.dispatch, "Name")
+		if err != nil {
+			releaseSheets(sheets)
+			return nil, fmt.Errorf("impossible de lire la feuille %d : %v", i, err)
+		}
+		visible, err := oleutil.GetProperty(sheet.dispatch, "Visible")
+		if err != nil {
+			releaseSheets(sheets)
+			return nil, fmt.Errorf("impossible de lire la feuille %d : %v", i, err)
+		}
+		sheets[len(sheets)-1].sheetInfo = sheetInfo{
+			Name:   name.ToString(),
+			Hidden: visible.Value() != nil && toInt(visible.Value()) != xlSheetVisible,
+		}
+	}
+	return sheets, nil
+}
+
+func releaseSheets(sheets []excelSheet) {
+	for _, sheet := range sheets {
+		safeReleaseWithRetry(sheet.dispatch)
+	}
+}
+
+// toInt convertit une valeur numérique renvoyée par COM
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int32:
+		return int(n)
+	case int16:
+		return int(n)
+	case int64:
+		return int(n)
+	case bool:
+		// Certaines versions d'Excel renvoient un booléen (True = -1)
+		if n {
+			return xlSheetVisible
+		}
+		return xlSheetHidden
+	}
+	return xlSheetHidden
+}
+
+func setSheetVisible(sheet *ole.IDispatch, visibility int) error {
+	if _, err := oleutil.PutProperty(sheet, "Visible", visibility); err != nil {
+		return fmt.Errorf("impossible de modifier la visibilité d'une feuille : %v", err)
+	}
 	return nil
 }
 
+func (p *WindowsFileProcessor) closeWorkbook(workbook *ole.IDispatch) error {
+	if _, err := oleutil.CallMethod(workbook, "Close", false); err != nil {
+		return fmt.Errorf("impossible de fermer le classeur : %v", err)
+	}
+	return nil
+}
+
+// quitExcel ferme l'application ; l'erreur est ignorée si Excel a déjà été terminé
+func (p *WindowsFileProcessor) quitExcel(excel *ole.IDispatch) {
+	oleutil.CallMethod(excel, "Quit")
+}
+
 func safeReleaseWithRetry(dispatch *ole.IDispatch) {
 	if dispatch == nil {
 		return
@@ -0,0 +1,149 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Orientations de page
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "paysage"
+)
+
+// Orientations liste les orientations acceptées
+var Orientations = []string{OrientationPortrait, OrientationLandscape}
+
+// PaperSizes associe les formats de papier acceptés à leur code Excel (XlPaperSize)
+var PaperSizes = map[string]int{
+	"A2":     66,
+	"A3":     8,
+	"A4":     9,
+	"A5":     11,
+	"B4":     12,
+	"B5":     13,
+	"lettre": 1,
+	"legal":  5,
+}
+
+// Margins exprime les marges d'impression en centimètres, comme dans Excel
+type Margins struct {
+	Left   float64 `json:"left"`
+	Right  float64 `json:"right"`
+	Top    float64 `json:"top"`
+	Bottom float64 `json:"bottom"`
+}
+
+// PageSetup remplace la mise en page des feuilles avant l'export ; les champs
+// vides conservent celle du classeur
+type PageSetup struct {
+	Orientation string   `json:"orientation,omitempty"`   // portrait ou paysage
+	PaperSize   string   `json:"paper_size,omitempty"`    // format de papier : A4, A3, lettre...
+	Margins     *Margins `json:"margins,omitempty"`       // marges en centimètres
+	FitToWidth  int      `json:"fit_to_width,omitempty"`  // ajustement sur N pages en largeur
+	FitToHeight int      `json:"fit_to_height,omitempty"` // et N pages en hauteur, sans limite si 0
+	PrintArea   string   `json:"print_area,omitempty"`    // zone d'impression, ex. A1:H40
+	TitleRows   string   `json:"title_rows,omitempty"`    // lignes répétées en haut de page, ex. 1:2
+	Gridlines   *bool    `json:"gridlines,omitempty"`     // impression du quadrillage
+}
+
+var (
+	rangeRe     = regexp.MustCompile(`^\$?[A-Za-z]{1,3}\$?[0-9]+(:\$?[A-Za-z]{1,3}\$?[0-9]+)?$`)
+	titleRowsRe = regexp.MustCompile(`^\$?([0-9]+)(?::\$?([0-9]+))?$`)
+)
+
+// Empty indique qu'aucun paramètre de mise en page n'est remplacé
+func (p PageSetup) Empty() bool {
+	return p == PageSetup{}
+}
+
+// Merge renvoie la mise en page complétée par les champs renseignés de over
+func (p PageSetup) Merge(over PageSetup) PageSetup {
+	if over.Orientation != "" {
+		p.Orientation = over.Orientation
+	}
+	if over.PaperSize != "" {
+		p.PaperSize = over.PaperSize
+	}
+	if over.Margins != nil {
+		p.Margins = over.Margins
+	}
+	if over.FitToWidth > 0 {
+		p.FitToWidth = over.FitToWidth
+		p.FitToHeight = over.FitToHeight
+	}
+	if over.PrintArea != "" {
+		p.PrintArea = over.PrintArea
+	}
+	if over.TitleRows != "" {
+		p.TitleRows = over.TitleRows
+	}
+	if over.Gridlines != nil {
+		p.Gridlines = over.Gridlines
+	}
+	return p
+}
+
+// PaperCode renvoie le code Excel du format de papier, 0 s'il n'est pas remplacé
+func (p PageSetup) PaperCode() int {
+	for name, code := range PaperSizes {
+		if strings.EqualFold(name, p.PaperSize) {
+			return code
+		}
+	}
+	return 0
+}
+
+// TitleRowRange renvoie les numéros (base 1) de la première et de la dernière
+// ligne répétée en haut de chaque page
+func (p PageSetup) TitleRowRange() (first, last int, ok bool) {
+	m := titleRowsRe.FindStringSubmatch(strings.TrimSpace(p.TitleRows))
+	if m == nil {
+		return 0, 0, false
+	}
+	first, _ = strconv.Atoi(m[1])
+	last = first
+	if m[2] != "" {
+		last, _ = strconv.Atoi(m[2])
+	}
+	if first == 0 || last == 0 {
+		return 0, 0, false
+	}
+	return min(first, last), max(first, last), true
+}
+
+// Validate vérifie les valeurs de la mise en page
+func (p PageSetup) Validate() error {
+	if p.Orientation != "" && !slices.Contains(Orientations, p.Orientation) {
+		return fmt.Errorf("orientation inconnue : %s (valeurs : %s)", p.Orientation, strings.Join(Orientations, ", "))
+	}
+	if p.PaperSize != "" && p.PaperCode() == 0 {
+		names := make([]string, 0, len(PaperSizes))
+		for name := range PaperSizes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("format de papier inconnu : %s (valeurs : %s)", p.PaperSize, strings.Join(names, ", "))
+	}
+	if m := p.Margins; m != nil && (m.Left < 0 || m.Right < 0 || m.Top < 0 || m.Bottom < 0) {
+		return fmt.Errorf("marges négatives")
+	}
+	if p.FitToWidth < 0 || p.FitToHeight < 0 {
+		return fmt.Errorf("nombre de pages d'ajustement négatif")
+	}
+	if p.FitToHeight > 0 && p.FitToWidth == 0 {
+		return fmt.Errorf("fit_to_height exige fit_to_width")
+	}
+	if p.PrintArea != "" && !rangeRe.MatchString(strings.TrimSpace(p.PrintArea)) {
+		return fmt.Errorf("zone d'impression invalide : %s (ex. A1:H40)", p.PrintArea)
+	}
+	if p.TitleRows != "" {
+		if _, _, ok := p.TitleRowRange(); !ok {
+			return fmt.Errorf("lignes à répéter invalides : %s (ex. 1:2)", p.TitleRows)
+		}
+	}
+	return nil
+}
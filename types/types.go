@@ -1,8 +1,148 @@
 package types
 
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
 // ProcessResult représente le résultat du traitement d'un fichier
 type ProcessResult struct {
-	FileName string
-	PdfPath  string
-	Err      error
+	FileName    string
+	InputPath   string
+	PdfPath     string        // premier PDF produit
+	Outputs     []string      // tous les PDF produits : plusieurs en export par feuille
+	Export      ExportOptions // options d'export appliquées
+	Backend     string        // moteur de conversion ayant produit le PDF
+	Attempts    int           // nombre de moteurs essayés
+	StartedAt   time.Time
+	Duration    time.Duration
+	InputSize   int64
+	InputSHA256 string
+	OutputSize  int64  // taille cumulée des PDF
+	Pages       int    // nombre de pages cumulé des PDF
+	PDFA        string // conformité d'archivage vérifiée (PDF/A-2b), vide sinon
+	Skipped     bool   // inchangé depuis la dernière conversion : PDF existant conservé
+	Excluded    bool   // écarté avant la conversion (doublon, PDF existant conservé) : aucun PDF
+	DuplicateOf string // classeur au contenu identique converti à la place de celui-ci
+	Collision   string // collision de nom de PDF détectée avant la conversion et sa résolution
+	Cancelled   bool   // conversion interrompue ou non lancée suite à un arrêt demandé
+	Err         error
+}
+
+// PDFs renvoie les PDF produits par la conversion
+func (r ProcessResult) PDFs() []string {
+	if len(r.Outputs) == 0 && r.PdfPath != "" {
+		return []string{r.PdfPath}
+	}
+	return r.Outputs
+}
+
+// Politiques appliquées lorsque le PDF d'un classeur existe déjà sans en provenir,
+// ou que plusieurs classeurs d'un même lot produiraient le même PDF
+const (
+	OverwriteReplace = "overwrite"          // le PDF est remplacé
+	OverwriteSkip    = "skip"               // le classeur n'est pas converti
+	OverwriteRename  = "rename-with-suffix" // le PDF est nommé "<nom> (2).pdf", "<nom> (3).pdf"...
+	OverwriteFail    = "fail"               // la conversion du classeur échoue
+)
+
+// OverwritePolicies liste les politiques acceptées
+var OverwritePolicies = []string{OverwriteReplace, OverwriteSkip, OverwriteRename, OverwriteFail}
+
+// Profils de sortie des PDF
+const (
+	ProfileStandard = "standard" // PDF tel que produit par le moteur
+	ProfilePDFA2B   = "pdfa-2b"  // PDF/A-2b pour l'archivage : polices incorporées, profil ICC, XMP
+)
+
+// Profiles liste les profils de sortie acceptés
+var Profiles = []string{ProfileStandard, ProfilePDFA2B}
+
+// Sélection des feuilles exportées
+const (
+	SheetsAll     = "toutes"   // export du classeur tel qu'Excel l'imprime
+	SheetsVisible = "visibles" // feuilles visibles uniquement
+	SheetsNamed   = "nommees"  // feuilles dont le nom est listé, dans l'ordre du classeur
+)
+
+// SheetModes liste les modes de sélection des feuilles acceptés
+var SheetModes = []string{SheetsAll, SheetsVisible, SheetsNamed}
+
+// ExportOptions regroupe les paramètres d'export d'un classeur, transmis aux
+// moteurs de conversion
+type ExportOptions struct {
+	Sheets     string     `json:"sheets,omitempty"`      // mode de sélection, SheetsAll si vide
+	SheetNames []string   `json:"sheet_names,omitempty"` // feuilles exportées en mode SheetsNamed
+	PerSheet   bool       `json:"per_sheet,omitempty"`   // un PDF par feuille : <fichier>_<feuille>.pdf
+	Profile    string     `json:"profile,omitempty"`     // profil de sortie, ProfileStandard si vide
+	PageSetup  *PageSetup `json:"page_setup,omitempty"`  // mise en page appliquée aux feuilles, nil pour celle du classeur
+	Name       string     `json:"name,omitempty"`        // chemin du PDF relatif au dossier de sortie, sans extension ; nom du classeur si vide
+}
+
+// Default indique si les options correspondent à l'export complet du classeur
+func (o ExportOptions) Default() bool {
+	return (o.Sheets == "" || o.Sheets == SheetsAll) && !o.PerSheet
+}
+
+// OutputName renvoie le chemin du PDF d'un classeur relatif au dossier de sortie,
+// sans extension
+func (o ExportOptions) OutputName(inputFile string) string {
+	if o.Name != "" {
+		return filepath.FromSlash(o.Name)
+	}
+	return strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+}
+
+// Layout renvoie la mise en page à appliquer aux feuilles, vide pour conserver
+// celle du classeur
+func (o ExportOptions) Layout() PageSetup {
+	if o.PageSetup == nil {
+		return PageSetup{}
+	}
+	return *o.PageSetup
+}
+
+// PDFA indique si le profil d'archivage PDF/A-2b est demandé
+func (o ExportOptions) PDFA() bool {
+	return o.Profile == ProfilePDFA2B
+}
+
+// Validate vérifie le mode de sélection des feuilles, le profil de sortie et la
+// mise en page
+func (o ExportOptions) Validate() error {
+	if err := o.Layout().Validate(); err != nil {
+		return err
+	}
+	if o.Profile != "" && !slices.Contains(Profiles, o.Profile) {
+		return fmt.Errorf("profil PDF inconnu : %s (valeurs : %s)", o.Profile, strings.Join(Profiles, ", "))
+	}
+	if o.Sheets != "" && !slices.Contains(SheetModes, o.Sheets) {
+		return fmt.Errorf("sélection des feuilles inconnue : %s (valeurs : %s)", o.Sheets, strings.Join(SheetModes, ", "))
+	}
+	if o.Sheets == SheetsNamed && len(o.SheetNames) == 0 {
+		return fmt.Errorf("aucune feuille nommée pour la sélection %s", SheetsNamed)
+	}
+	return nil
+}
+
+// Key renvoie une représentation stable des options, pour détecter leur changement
+func (o ExportOptions) Key() string {
+	if o.Sheets == SheetsAll {
+		o.Sheets = ""
+	}
+	if o.Profile == ProfileStandard {
+		o.Profile = ""
+	}
+	if o.Sheets != SheetsNamed {
+		o.SheetNames = nil
+	}
+	if o.Layout().Empty() {
+		o.PageSetup = nil
+	}
+	data, _ := json.Marshal(o)
+	return string(data)
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fredon_to_pdf/config"
+	"fredon_to_pdf/discover"
+	"fredon_to_pdf/helper"
+	"fredon_to_pdf/queue"
+	"fredon_to_pdf/report"
+	"fredon_to_pdf/tools"
+	"fredon_to_pdf/types"
+	"fredon_to_pdf/watcher"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	watchCheckInterval = 500 * time.Millisecond
+	watchStableDelay   = 2 * time.Second // durée sans changement avant conversion
+)
+
+// runWatch surveille le dossier des fichiers Excel et convertit chaque classeur
+// déposé jusqu'à l'arrêt du programme (Ctrl+C, SIGTERM) ; les conversions en
+// cours sont alors menées à terme avant de quitter
+func runWatch(opts cliOptions) error {
+	displayHeader()
+
+	// Initialisation de la configuration
+	cfg, err := config.NewConfig(opts.Options)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ValidateExport(); err != nil {
+		return err
+	}
+	if err := initializeDirs(cfg); err != nil {
+		return err
+	}
+	if err := setupLogging(cfg, commandWatch, newRunID()); err != nil {
+		return err
+	}
+
+	backends, err := tools.Candidates(cfg.Converter)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	filter, err := discover.NewFilter(cfg.Include, cfg.Exclude)
+	if err != nil {
+		return err
+	}
+
+	events, err := watcher.Watch(ctx, cfg.ExcelDir, opts.Poll, cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	helper.GInfoLn("Surveillance de %s (Ctrl+C pour arrêter)", cfg.ExcelDir)
+	helper.GInfoLn("Moteurs de conversion : %s", backendNames(backends))
+	helper.GBlank()
+
+	state := loadManifest(cfg)
+	q, err := openQueue(cfg)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	// Le premier arrêt demandé laisse les conversions en attente se terminer ;
+	// un second les interrompt, elles seront reprises au prochain démarrage
+	poolCtx, cancelPool := context.WithCancel(context.Background())
+	defer cancelPool()
+	pool := newWorkerPool(poolCtx, q, cfg.Converter, backends, 0)
+
+	// Traitement des résultats au fil de l'eau
+	var (
+		mu         sync.Mutex
+		results    []types.ProcessResult
+		collisions = make(map[string]string) // collisions résolues, reportées dans le résumé
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for result := range pool.Results() {
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+			if result.Cancelled {
+				continue
+			}
+			if result.Err != nil {
+				helper.GErrorLn("Échec pour %s : %v", result.FileName, result.Err)
+				continue
+			}
+			helper.GInfoLn("%s (%s)", createdPDFs(result), result.Backend)
+			if err := state.Record(result); err != nil {
+				helper.GWarningLn("%v", err)
+			}
+			if err := state.Save(); err != nil {
+				helper.GWarningLn("%v", err)
+			}
+		}
+	}()
+
+	// Les classeurs déjà présents sont traités comme s'ils venaient d'être déposés
+	stability := watcher.NewStability(watchStableDelay)
+	files, err := getExcelFiles(cfg)
+	if err != nil {
+		helper.GWarningLn("%v", err)
+	}
+	for _, file := range files {
+		stability.Touch(file)
+	}
+
+	ticker := time.NewTicker(watchCheckInterval)
+	defer ticker.Stop()
+
+watchLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			break watchLoop
+		case path, ok := <-events:
+			if !ok {
+				break watchLoop
+			}
+			if rel, err := filepath.Rel(cfg.ExcelDir, path); err == nil && filter.Match(rel) {
+				stability.Touch(path)
+			}
+		case now := <-ticker.C:
+			// Doublons et collisions de noms de PDF, décidés avant la conversion
+			ready, plan, excluded := planOutputs(cfg, stability.Ready(now), state)
+			for _, result := range excluded {
+				switch {
+				case result.DuplicateOf != "":
+					helper.GInfoLn("%s ignoré : identique à %s", result.FileName, filepath.Base(result.DuplicateOf))
+				case result.Err != nil:
+					helper.GErrorLn("Échec pour %s : %v", result.FileName, result.Err)
+				default:
+					helper.GWarningLn("%s ignoré : %s", result.FileName, result.Collision)
+				}
+			}
+			mu.Lock()
+			results = append(results, excluded...)
+			maps.Copy(collisions, plan.collisions)
+			mu.Unlock()
+
+			for _, file := range ready {
+				exportOpts := plan.options[file]
+				if _, unchanged := state.Unchanged(file, exportOpts); unchanged && !opts.Force {
+					continue
+				}
+				if collision, ok := plan.collisions[file]; ok {
+					helper.GWarningLn("%s : %s", filepath.Base(file), collision)
+				}
+				helper.GInfoLn("Conversion de %s..", filepath.Base(file))
+				if _, err := pool.Submit(file, outputDirFor(cfg, file), "", queue.Options{Export: exportOpts}); err != nil {
+					helper.GErrorLn("%v", err)
+				}
+			}
+		}
+	}
+
+	helper.GBlank()
+	helper.GInfoLn("Arrêt demandé, fin des conversions en cours (Ctrl+C à nouveau pour les interrompre)..")
+	// Le second Ctrl+C doit être intercepté avant de libérer le premier : sinon
+	// un signal reçu entre les deux terminerait le programme sans rien libérer
+	force, stopForce := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopForce()
+	stop()
+	go func() {
+		select {
+		case <-force.Done():
+			cancelPool()
+		case <-done:
+		}
+	}()
+	pool.Close()
+	<-done
+
+	outputPlan{collisions: collisions}.annotate(results)
+	if len(results) > 0 {
+		if _, err := report.Write(cfg.OutputDir, results, Version); err != nil {
+			helper.GWarningLn("Impossible d'écrire le rapport de conversion : %v", err)
+		}
+	}
+	displaySummary(results)
+	return nil
+}
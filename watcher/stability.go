@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// Stability retient les fichiers signalés jusqu'à ce que leur taille et leur date
+// n'aient plus changé pendant un délai donné, afin de ne pas convertir un classeur
+// encore en cours d'écriture ou de copie
+type Stability struct {
+	delay   time.Duration
+	pending map[string]*pendingFile
+}
+
+type pendingFile struct {
+	state fileState
+	since time.Time
+}
+
+func NewStability(delay time.Duration) *Stability {
+	return &Stability{delay: delay, pending: make(map[string]*pendingFile)}
+}
+
+// Touch signale un fichier nouveau ou modifié ; son délai de stabilité repart de zéro
+func (s *Stability) Touch(path string) {
+	s.pending[path] = &pendingFile{since: time.Now()}
+}
+
+// Ready renvoie, triés, les fichiers stables depuis le délai demandé et les retire
+// de l'attente. Les fichiers supprimés entre-temps sont oubliés.
+func (s *Stability) Ready(now time.Time) []string {
+	var ready []string
+	for path, p := range s.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(s.pending, path)
+			continue
+		}
+
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if state != p.state {
+			p.state = state
+			p.since = now
+			continue
+		}
+		if now.Sub(p.since) < s.delay || !readable(path) {
+			continue
+		}
+
+		ready = append(ready, path)
+		delete(s.pending, path)
+	}
+	sort.Strings(ready)
+	return ready
+}
+
+// Len renvoie le nombre de fichiers en attente de stabilité
+func (s *Stability) Len() int {
+	return len(s.pending)
+}
+
+// readable vérifie que le fichier peut être ouvert : sous Windows, un fichier
+// encore verrouillé par l'application qui l'écrit est refusé
+func readable(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStability(t *testing.T) {
+	const delay = 2 * time.Second
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Client.xlsx")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written := time.Now().Add(-time.Hour).Truncate(time.Second)
+	touch := func(mtime time.Time) {
+		t.Helper()
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("début")
+	touch(written)
+	s := NewStability(delay)
+	s.Touch(path)
+	now := time.Now()
+
+	// Les appels successifs à Ready simulent le passage du temps
+	steps := []struct {
+		name   string
+		change func()
+		after  time.Duration
+		ready  bool
+	}{
+		{name: "premier relevé", after: 0},
+		{name: "délai non écoulé", after: delay / 2},
+		{name: "écriture en cours", change: func() { write("début de la copie"); touch(written) }, after: delay},
+		{name: "délai repris après l'écriture", after: delay + delay/2},
+		{name: "date modifiée, même taille", change: func() { touch(written.Add(time.Minute)) }, after: 2 * delay},
+		{name: "délai non écoulé depuis la date", after: 2*delay + delay/2},
+		{name: "fichier stable", after: 3 * delay, ready: true},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		ready := s.Ready(now.Add(step.after))
+		if got := slices.Equal(ready, []string{path}); got != step.ready {
+			t.Fatalf("%s : Ready() = %v, prêt attendu %v", step.name, ready, step.ready)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d, fichier émis encore en attente", s.Len())
+	}
+	if ready := s.Ready(now.Add(10 * delay)); len(ready) != 0 {
+		t.Errorf("fichier émis deux fois : %v", ready)
+	}
+}
+
+func TestStabilityRemovedFile(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "B.xlsx")
+	removed := filepath.Join(dir, "A.xlsx")
+	for _, path := range []string{kept, removed} {
+		if err := os.WriteFile(path, []byte("classeur"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewStability(time.Second)
+	s.Touch(removed)
+	s.Touch(kept)
+	now := time.Now()
+	s.Ready(now)
+	if err := os.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := s.Ready(now.Add(time.Second))
+	if !slices.Equal(ready, []string{kept}) {
+		t.Errorf("Ready() = %v, attendu %v", ready, []string{kept})
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d, fichier supprimé encore en attente", s.Len())
+	}
+}
+
+func TestStabilityTouchRestartsDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Client.xlsx")
+	if err := os.WriteFile(path, []byte("classeur"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStability(time.Second)
+	s.Touch(path)
+	now := time.Now()
+	s.Ready(now)
+
+	// Nouvelle notification juste avant l'échéance : le délai repart
+	s.Touch(path)
+	if ready := s.Ready(now.Add(time.Second)); len(ready) != 0 {
+		t.Errorf("Ready() = %v après une nouvelle notification", ready)
+	}
+	if ready := s.Ready(now.Add(2 * time.Second)); !slices.Equal(ready, []string{path}) {
+		t.Errorf("Ready() = %v, attendu %v", ready, []string{path})
+	}
+}
+
+func TestStabilitySortsReadyFiles(t *testing.T) {
+	dir := t.TempDir()
+	var want []string
+	for _, name := range []string{"C.xls", "A.xls", "B.xls"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, path)
+	}
+	slices.Sort(want)
+
+	s := NewStability(0)
+	for _, path := range want {
+		s.Touch(path)
+	}
+	now := time.Now()
+	s.Ready(now)
+	if ready := s.Ready(now); !slices.Equal(ready, want) {
+		t.Errorf("Ready() = %v, attendu %v", ready, want)
+	}
+}
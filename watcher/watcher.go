@@ -0,0 +1,186 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"fredon_to_pdf/helper"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// PollInterval est la période de scrutation lorsque les notifications du système
+// ne sont pas disponibles (partages réseau notamment)
+const PollInterval = 2 * time.Second
+
+// Watch surveille un dossier et ses sous-dossiers, hormis ceux listés dans skip,
+// et envoie le chemin de chaque fichier créé ou modifié. Le canal est fermé à
+// l'annulation du contexte.
+func Watch(ctx context.Context, dir string, poll bool, skip ...string) (<-chan string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("dossier à surveiller inaccessible : %v", err)
+	}
+	t := &tree{root: dir, skip: skip}
+	if !poll {
+		events, err := t.watchNotify(ctx)
+		if err == nil {
+			return events, nil
+		}
+		helper.GWarningLn("Notifications indisponibles (%v), surveillance par scrutation", err)
+	}
+	return t.watchPoll(ctx, PollInterval), nil
+}
+
+// tree décrit l'arborescence surveillée
+type tree struct {
+	root string
+	skip []string
+}
+
+// walk parcourt l'arborescence à partir de dir en appelant fn pour chaque dossier et fichier
+func (t *tree) walk(dir string, fn func(path string, d fs.DirEntry)) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path != t.root && slices.Contains(t.skip, path) {
+			return filepath.SkipDir
+		}
+		fn(path, d)
+		return nil
+	})
+}
+
+// watchNotify utilise les notifications du système (inotify, ReadDirectoryChangesW...) ;
+// chaque sous-dossier est surveillé individuellement, y compris ceux créés ensuite
+func (t *tree) watchNotify(ctx context.Context) (<-chan string, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(t.root); err != nil {
+		w.Close()
+		return nil, err
+	}
+	t.walk(t.root, func(path string, d fs.DirEntry) {
+		if d.IsDir() && path != t.root {
+			if err := w.Add(path); err != nil {
+				helper.GWarningLn("Impossible de surveiller %s : %v", path, err)
+			}
+		}
+	})
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		defer w.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+					continue
+				}
+				paths := []string{event.Name}
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// Nouveau dossier : surveillance et signalement des fichiers
+					// qui y ont été copiés avant qu'il ne soit surveillé
+					paths = t.addDir(w, event.Name)
+				}
+				for _, path := range paths {
+					select {
+					case events <- path:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				helper.GWarningLn("Erreur de surveillance du dossier : %v", err)
+			}
+		}
+	}()
+	return events, nil
+}
+
+// addDir surveille un nouveau dossier et ses sous-dossiers, et renvoie les fichiers
+// qu'ils contiennent déjà
+func (t *tree) addDir(w *fsnotify.Watcher, dir string) []string {
+	if slices.Contains(t.skip, dir) {
+		return nil
+	}
+	var files []string
+	t.walk(dir, func(path string, d fs.DirEntry) {
+		if !d.IsDir() {
+			files = append(files, path)
+			return
+		}
+		if err := w.Add(path); err != nil {
+			helper.GWarningLn("Impossible de surveiller %s : %v", path, err)
+		}
+	})
+	return files
+}
+
+// fileState identifie une version d'un fichier
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchPoll compare périodiquement le contenu de l'arborescence à l'état précédent
+func (t *tree) watchPoll(ctx context.Context, interval time.Duration) <-chan string {
+	events := make(chan string)
+	go func() {
+		defer close(events)
+
+		known := t.scan()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := t.scan()
+			for path, state := range current {
+				if previous, ok := known[path]; ok && previous == state {
+					continue
+				}
+				select {
+				case events <- path:
+				case <-ctx.Done():
+					return
+				}
+			}
+			known = current
+		}
+	}()
+	return events
+}
+
+// scan relève la taille et la date des fichiers de l'arborescence
+func (t *tree) scan() map[string]fileState {
+	states := make(map[string]fileState)
+	t.walk(t.root, func(path string, d fs.DirEntry) {
+		if !d.Type().IsRegular() {
+			return
+		}
+		if info, err := d.Info(); err == nil {
+			states[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+	})
+	return states
+}
@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile crée un fichier et ses dossiers parents
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// collect lit les événements jusqu'à avoir reçu tous les chemins attendus, puis
+// pendant encore settle, et renvoie les chemins reçus
+func collect(t *testing.T, events <-chan string, settle time.Duration, want ...string) map[string]bool {
+	t.Helper()
+	got := make(map[string]bool)
+	missing := func() []string {
+		var paths []string
+		for _, path := range want {
+			if !got[path] {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+
+	timeout := time.After(5 * time.Second)
+	for len(missing()) > 0 {
+		select {
+		case path, ok := <-events:
+			if !ok {
+				t.Fatalf("canal fermé, événements manquants : %v", missing())
+			}
+			got[path] = true
+		case <-timeout:
+			t.Fatalf("événements manquants : %v", missing())
+		}
+	}
+	quiet := time.After(settle)
+	for {
+		select {
+		case path := <-events:
+			got[path] = true
+		case <-quiet:
+			return got
+		}
+	}
+}
+
+// closed vérifie que le canal est fermé après l'annulation du contexte
+func closed(t *testing.T, events <-chan string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("canal non fermé après l'annulation")
+		}
+	}
+}
+
+func TestWatchPoll(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "Existant.xlsx")
+	modified := filepath.Join(root, "2024", "Modifié.xlsx")
+	skipped := filepath.Join(root, "pdf")
+	writeFile(t, existing, "classeur")
+	writeFile(t, modified, "classeur")
+	if err := os.Mkdir(skipped, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := &tree{root: root, skip: []string{skipped}}
+	events := tr.watchPoll(ctx, 20*time.Millisecond)
+	// Laisse le premier relevé de l'arborescence se faire avant les modifications
+	time.Sleep(100 * time.Millisecond)
+
+	created := filepath.Join(root, "2024", "Janvier", "Nouveau.xlsx")
+	writeFile(t, created, "classeur")
+	writeFile(t, modified, "classeur modifié")
+	writeFile(t, filepath.Join(skipped, "Ignoré.xlsx"), "classeur")
+
+	got := collect(t, events, 100*time.Millisecond, created, modified)
+	if got[existing] {
+		t.Error("fichier présent au démarrage signalé")
+	}
+	if len(got) != 2 {
+		t.Errorf("événements = %v, attendu %s et %s", got, created, modified)
+	}
+
+	// Fichier supprimé : aucun événement
+	if err := os.Remove(created); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, events, 100*time.Millisecond); len(got) > 0 {
+		t.Errorf("événements après suppression : %v", got)
+	}
+
+	cancel()
+	closed(t, events)
+}
+
+func TestWatchNotify(t *testing.T) {
+	root := t.TempDir()
+	skipped := filepath.Join(root, "pdf")
+	if err := os.Mkdir(skipped, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := Watch(ctx, root, false, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := filepath.Join(root, "Client.xlsx")
+	writeFile(t, created, "classeur")
+	// Dossier copié avec son contenu : les fichiers qu'il contient déjà sont
+	// signalés, puis ceux qui y sont ajoutés
+	staging := filepath.Join(t.TempDir(), "2024")
+	copied := filepath.Join(staging, "Janvier", "Facture.xlsx")
+	writeFile(t, copied, "classeur")
+	if err := os.Rename(staging, filepath.Join(root, "2024")); err != nil {
+		t.Fatal(err)
+	}
+	copied = filepath.Join(root, "2024", "Janvier", "Facture.xlsx")
+	got := collect(t, events, 0, created, copied)
+
+	added := filepath.Join(root, "2024", "Janvier", "Avoir.xlsx")
+	writeFile(t, added, "classeur")
+	writeFile(t, filepath.Join(skipped, "Ignoré.xlsx"), "classeur")
+	for path := range collect(t, events, 200*time.Millisecond, added) {
+		got[path] = true
+	}
+	for path := range got {
+		if filepath.Dir(path) == skipped {
+			t.Errorf("fichier du dossier ignoré signalé : %s", path)
+		}
+	}
+
+	cancel()
+	closed(t, events)
+}
+
+func TestWatchMissingDir(t *testing.T) {
+	if _, err := Watch(context.Background(), filepath.Join(t.TempDir(), "absent"), true); err == nil {
+		t.Error("dossier absent accepté")
+	}
+}
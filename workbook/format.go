@@ -0,0 +1,568 @@
+package workbook
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Séparateurs utilisés pour l'affichage des nombres (paramètres régionaux français)
+var (
+	DecimalSeparator   = ","
+	ThousandsSeparator = "\u00a0"
+)
+
+// Formats numériques intégrés d'Excel, tels qu'affichés avec les paramètres régionaux français
+var builtinFormats = map[int]string{
+	0:  "General",
+	1:  "0",
+	2:  "0.00",
+	3:  "#,##0",
+	4:  "#,##0.00",
+	5:  `#,##0 "€";-#,##0 "€"`,
+	6:  `#,##0 "€";[Red]-#,##0 "€"`,
+	7:  `#,##0.00 "€";-#,##0.00 "€"`,
+	8:  `#,##0.00 "€";[Red]-#,##0.00 "€"`,
+	9:  "0%",
+	10: "0.00%",
+	11: "0.00E+00",
+	12: "# ?/?",
+	13: "# ??/??",
+	14: "dd/mm/yyyy",
+	15: "d-mmm-yy",
+	16: "d-mmm",
+	17: "mmm-yy",
+	18: "h:mm AM/PM",
+	19: "h:mm:ss AM/PM",
+	20: "h:mm",
+	21: "h:mm:ss",
+	22: "dd/mm/yyyy h:mm",
+	37: "#,##0 ;(#,##0)",
+	38: "#,##0 ;[Red](#,##0)",
+	39: "#,##0.00;(#,##0.00)",
+	40: "#,##0.00;[Red](#,##0.00)",
+	41: `_-* #,##0 _€_-;-* #,##0 _€_-;_-* "-" _€_-;_-@_-`,
+	42: `_-* #,##0 "€"_-;-* #,##0 "€"_-;_-* "-" "€"_-;_-@_-`,
+	43: `_-* #,##0.00 _€_-;-* #,##0.00 _€_-;_-* "-"?? _€_-;_-@_-`,
+	44: `_-* #,##0.00 "€"_-;-* #,##0.00 "€"_-;_-* "-"?? "€"_-;_-@_-`,
+	45: "mm:ss",
+	46: "[h]:mm:ss",
+	47: "mm:ss.0",
+	48: "##0.0E+0",
+	49: "@",
+}
+
+// BuiltinFormat renvoie le code d'un format numérique intégré d'Excel
+func BuiltinFormat(id int) (string, bool) {
+	f, ok := builtinFormats[id]
+	return f, ok
+}
+
+var (
+	monthNames      = []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}
+	monthShortNames = []string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."}
+	dayNames        = []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"}
+	dayShortNames   = []string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."}
+)
+
+// Text renvoie la valeur d'une cellule telle qu'Excel l'affiche
+func (c *Cell) Text(date1904 bool) string {
+	if c == nil {
+		return ""
+	}
+	switch c.Type {
+	case CellString, CellError:
+		return c.Str
+	case CellBool:
+		if c.Bool {
+			return "VRAI"
+		}
+		return "FAUX"
+	case CellNumber:
+		return FormatNumber(c.Num, StyleOf(c).NumFmt, date1904)
+	}
+	return ""
+}
+
+// fmtToken est un élément d'un code de format : texte littéral ou symbole de format
+type fmtToken struct {
+	lit bool
+	s   string
+}
+
+// FormatNumber applique un code de format Excel à une valeur numérique
+func FormatNumber(v float64, format string, date1904 bool) string {
+	if format == "" || strings.EqualFold(format, "General") {
+		return formatGeneral(v)
+	}
+
+	sections := splitSections(format)
+	section := sections[0]
+	negative := v < 0
+	switch {
+	case len(sections) >= 3 && v == 0:
+		section = sections[2]
+		negative = false
+	case len(sections) >= 2 && v < 0:
+		// La section négative porte déjà son propre signe
+		section = sections[1]
+		v = -v
+		negative = false
+	}
+
+	tokens := tokenizeFormat(section)
+	if isDateFormat(tokens) {
+		return formatDate(v, tokens, date1904)
+	}
+	return formatNumeric(v, tokens, negative)
+}
+
+// splitSections découpe un code de format selon les ';' hors guillemets
+func splitSections(format string) []string {
+	var sections []string
+	var current strings.Builder
+	inQuote := false
+	escaped := false
+	for _, r := range format {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case r == ';' && !inQuote:
+			sections = append(sections, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	return append(sections, current.String())
+}
+
+// tokenizeFormat découpe une section de format en littéraux et symboles
+func tokenizeFormat(section string) []fmtToken {
+	var tokens []fmtToken
+	runes := []rune(section)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			tokens = append(tokens, fmtToken{lit: true, s: string(runes[i+1 : min(j, len(runes))])})
+			i = j
+		case r == '\\' && i+1 < len(runes):
+			tokens = append(tokens, fmtToken{lit: true, s: string(runes[i+1])})
+			i++
+		case r == '_' && i+1 < len(runes):
+			// Espace de la largeur du caractère suivant
+			tokens = append(tokens, fmtToken{lit: true, s: " "})
+			i++
+		case r == '*' && i+1 < len(runes):
+			// Caractère de remplissage : ignoré
+			i++
+		case r == '[':
+			j := i + 1
+			for j < len(runes) && runes[j] != ']' {
+				j++
+			}
+			content := string(runes[i+1 : min(j, len(runes))])
+			i = j
+			lower := strings.ToLower(content)
+			switch {
+			case strings.HasPrefix(content, "$"):
+				// Symbole monétaire et paramètres régionaux : [$€-40C]
+				symbol := strings.SplitN(content[1:], "-", 2)[0]
+				if symbol != "" {
+					tokens = append(tokens, fmtToken{lit: true, s: symbol})
+				}
+			case lower == "h" || lower == "hh" || lower == "m" || lower == "mm" || lower == "s" || lower == "ss":
+				tokens = append(tokens, fmtToken{s: "[" + lower + "]"})
+			}
+			// Couleurs et conditions ignorées
+		case strings.HasPrefix(strings.ToLower(string(runes[i:])), "general"):
+			tokens = append(tokens, fmtToken{s: "General"})
+			i += len("general") - 1
+		case strings.HasPrefix(strings.ToUpper(string(runes[i:])), "AM/PM"):
+			tokens = append(tokens, fmtToken{s: "AM/PM"})
+			i += len("AM/PM") - 1
+		case strings.HasPrefix(strings.ToUpper(string(runes[i:])), "A/P"):
+			tokens = append(tokens, fmtToken{s: "A/P"})
+			i += len("A/P") - 1
+		case strings.ContainsRune("yYmMdDhHsS", r):
+			// Regroupement des lettres identiques (yyyy, mm, dd...)
+			j := i
+			for j < len(runes) && unicode.ToLower(runes[j]) == unicode.ToLower(r) {
+				j++
+			}
+			tokens = append(tokens, fmtToken{s: strings.ToLower(string(runes[i:j]))})
+			i = j - 1
+		case (r == 'E' || r == 'e') && i+1 < len(runes) && (runes[i+1] == '+' || runes[i+1] == '-'):
+			tokens = append(tokens, fmtToken{s: "E" + string(runes[i+1])})
+			i++
+		case strings.ContainsRune("0#?.,%", r):
+			tokens = append(tokens, fmtToken{s: string(r)})
+		case r == '@':
+			tokens = append(tokens, fmtToken{s: "@"})
+		default:
+			tokens = append(tokens, fmtToken{lit: true, s: string(r)})
+		}
+	}
+	return tokens
+}
+
+func isDateFormat(tokens []fmtToken) bool {
+	for _, t := range tokens {
+		if t.lit {
+			continue
+		}
+		switch t.s[0] {
+		case 'y', 'm', 'd', 'h', 's', '[':
+			return true
+		}
+		if t.s == "AM/PM" || t.s == "A/P" {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlaceholder(s string) bool {
+	return s == "0" || s == "#" || s == "?"
+}
+
+// formatNumeric applique une section de format numérique (0, #, ?, ',', '.', %, E+)
+func formatNumeric(v float64, tokens []fmtToken, negative bool) string {
+	// Analyse de la partie numérique
+	var (
+		intZeros    int
+		minDec      int
+		maxDec      int
+		expDigits   int
+		percent     int
+		thousands   bool
+		scaleCommas int
+		afterPoint  bool
+		inExponent  bool
+		scientific  bool
+		hasDigits   bool
+		lastPlaceAt = -1
+	)
+	for i, t := range tokens {
+		if t.lit {
+			continue
+		}
+		switch {
+		case isPlaceholder(t.s):
+			hasDigits = true
+			lastPlaceAt = i
+			switch {
+			case inExponent:
+				expDigits++
+			case afterPoint:
+				maxDec++
+				if t.s != "#" {
+					minDec++
+				}
+			case t.s == "0":
+				intZeros++
+			}
+		case t.s == ".":
+			afterPoint = true
+		case t.s == "%":
+			percent++
+		case t.s == "E+" || t.s == "E-":
+			scientific = true
+			inExponent = true
+		}
+	}
+	for i, t := range tokens {
+		if t.lit || t.s != "," {
+			continue
+		}
+		if i > lastPlaceAt {
+			// Virgules finales : division par mille
+			scaleCommas++
+		} else if !afterPointBefore(tokens, i) {
+			thousands = true
+		}
+	}
+
+	v = math.Abs(v)
+	for i := 0; i < percent; i++ {
+		v *= 100
+	}
+	for i := 0; i < scaleCommas; i++ {
+		v /= 1000
+	}
+
+	var number string
+	if hasDigits {
+		if scientific {
+			number = formatScientific(v, intZeros, minDec, maxDec, expDigits)
+		} else {
+			number = formatFixed(v, intZeros, minDec, maxDec, thousands)
+		}
+		if negative && strings.ContainsAny(number, "123456789") {
+			number = "-" + number
+		}
+	}
+
+	var out strings.Builder
+	emitted := false
+	for _, t := range tokens {
+		switch {
+		case t.lit:
+			out.WriteString(t.s)
+		case t.s == "General":
+			g := formatGeneral(v)
+			if negative {
+				g = "-" + g
+			}
+			out.WriteString(g)
+		case isPlaceholder(t.s) || t.s == "." || t.s == "," || t.s == "E+" || t.s == "E-":
+			if !emitted && hasDigits {
+				out.WriteString(number)
+				emitted = true
+			}
+		case t.s == "%":
+			out.WriteString("%")
+		case t.s == "@":
+			// Section texte : sans objet pour un nombre
+		default:
+			out.WriteString(t.s)
+		}
+	}
+	return out.String()
+}
+
+func afterPointBefore(tokens []fmtToken, idx int) bool {
+	for _, t := range tokens[:idx] {
+		if !t.lit && t.s == "." {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFixed(v float64, intZeros, minDec, maxDec int, thousands bool) string {
+	s := strconv.FormatFloat(v, 'f', maxDec, 64)
+	intPart, decPart, _ := strings.Cut(s, ".")
+
+	// Suppression des zéros décimaux facultatifs
+	for len(decPart) > minDec && strings.HasSuffix(decPart, "0") {
+		decPart = decPart[:len(decPart)-1]
+	}
+
+	// Zéros obligatoires de la partie entière
+	intPart = strings.TrimLeft(intPart, "0")
+	for len(intPart) < intZeros {
+		intPart = "0" + intPart
+	}
+
+	if thousands && len(intPart) > 3 {
+		var grouped strings.Builder
+		for i, r := range intPart {
+			if i > 0 && (len(intPart)-i)%3 == 0 {
+				grouped.WriteString(ThousandsSeparator)
+			}
+			grouped.WriteRune(r)
+		}
+		intPart = grouped.String()
+	}
+
+	if maxDec > 0 {
+		return intPart + DecimalSeparator + decPart
+	}
+	return intPart
+}
+
+func formatScientific(v float64, intZeros, minDec, maxDec, expDigits int) string {
+	s := strconv.FormatFloat(v, 'E', maxDec, 64)
+	mantissa, exponent, _ := strings.Cut(s, "E")
+	intPart, decPart, _ := strings.Cut(mantissa, ".")
+	for len(decPart) > minDec && strings.HasSuffix(decPart, "0") {
+		decPart = decPart[:len(decPart)-1]
+	}
+	sign := exponent[:1]
+	digits := strings.TrimLeft(exponent[1:], "0")
+	for len(digits) < max(expDigits, 1) {
+		digits = "0" + digits
+	}
+	if intZeros == 0 && intPart == "0" {
+		intPart = ""
+	}
+	number := intPart
+	if decPart != "" || minDec > 0 {
+		number += DecimalSeparator + decPart
+	}
+	return number + "E" + sign + digits
+}
+
+// formatGeneral reproduit le format "Standard" d'Excel (11 caractères significatifs)
+func formatGeneral(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	abs := math.Abs(v)
+	if abs >= 1e11 || abs < 1e-9 {
+		s := strconv.FormatFloat(v, 'E', 5, 64)
+		mantissa, exponent, _ := strings.Cut(s, "E")
+		if strings.Contains(mantissa, ".") {
+			mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+		}
+		sign := exponent[:1]
+		digits := strings.TrimLeft(exponent[1:], "0")
+		for len(digits) < 2 {
+			digits = "0" + digits
+		}
+		return strings.Replace(mantissa, ".", DecimalSeparator, 1) + "E" + sign + digits
+	}
+
+	intDigits := len(strconv.FormatFloat(math.Trunc(abs), 'f', 0, 64))
+	decimals := max(0, 10-intDigits)
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return strings.Replace(s, ".", DecimalSeparator, 1)
+}
+
+// SerialToTime convertit un numéro de série Excel en date
+func SerialToTime(v float64, date1904 bool) time.Time {
+	var base time.Time
+	switch {
+	case date1904:
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	case v < 60:
+		// Excel considère à tort 1900 comme bissextile : décalage avant le 1er mars 1900
+		base = time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC)
+	default:
+		base = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(v)
+	seconds := math.Round((v - days) * 86400)
+	return base.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
+
+func formatDate(v float64, tokens []fmtToken, date1904 bool) string {
+	t := SerialToTime(v, date1904)
+	ampm := false
+	for _, tok := range tokens {
+		if !tok.lit && (tok.s == "AM/PM" || tok.s == "A/P") {
+			ampm = true
+		}
+	}
+
+	// Un "m" qui suit une heure ou précède des secondes désigne des minutes
+	isMinute := func(i int) bool {
+		for j := i - 1; j >= 0; j-- {
+			if tokens[j].lit {
+				continue
+			}
+			if s := tokens[j].s; s[0] == 'h' || s == "[h]" || s == "[hh]" {
+				return true
+			}
+			if s := tokens[j].s; s[0] == 'y' || s[0] == 'd' || s[0] == 'm' {
+				break
+			}
+		}
+		for j := i + 1; j < len(tokens); j++ {
+			if tokens[j].lit {
+				continue
+			}
+			if s := tokens[j].s; s[0] == 's' || s == "[s]" || s == "[ss]" {
+				return true
+			}
+			if s := tokens[j].s; s[0] == 'y' || s[0] == 'd' || s[0] == 'h' || s[0] == 'm' {
+				break
+			}
+		}
+		return false
+	}
+
+	pad := func(n, width int) string {
+		s := strconv.Itoa(n)
+		for len(s) < width {
+			s = "0" + s
+		}
+		return s
+	}
+
+	var out strings.Builder
+	for i, tok := range tokens {
+		if tok.lit {
+			out.WriteString(tok.s)
+			continue
+		}
+		switch s := tok.s; {
+		case s == "yy" || s == "y":
+			out.WriteString(pad(t.Year()%100, 2))
+		case s[0] == 'y':
+			out.WriteString(pad(t.Year(), 4))
+		case s[0] == 'm' && len(s) <= 2 && isMinute(i):
+			out.WriteString(pad(t.Minute(), len(s)))
+		case s == "m" || s == "mm":
+			out.WriteString(pad(int(t.Month()), len(s)))
+		case s == "mmm":
+			out.WriteString(monthShortNames[t.Month()-1])
+		case s == "mmmmm":
+			out.WriteString(strings.ToUpper(string([]rune(monthNames[t.Month()-1])[:1])))
+		case s[0] == 'm':
+			out.WriteString(monthNames[t.Month()-1])
+		case s == "d" || s == "dd":
+			out.WriteString(pad(t.Day(), len(s)))
+		case s == "ddd":
+			out.WriteString(dayShortNames[t.Weekday()])
+		case s[0] == 'd':
+			out.WriteString(dayNames[t.Weekday()])
+		case s[0] == 'h':
+			h := t.Hour()
+			if ampm {
+				h = h % 12
+				if h == 0 {
+					h = 12
+				}
+			}
+			out.WriteString(pad(h, len(s)))
+		case s[0] == 's':
+			out.WriteString(pad(t.Second(), len(s)))
+		case s == "[h]" || s == "[hh]":
+			out.WriteString(pad(int(math.Floor(v*24+1e-9)), len(s)-2))
+		case s == "[m]" || s == "[mm]":
+			out.WriteString(pad(int(math.Floor(v*1440+1e-9)), len(s)-2))
+		case s == "[s]" || s == "[ss]":
+			out.WriteString(pad(int(math.Round(v*86400)), len(s)-2))
+		case s == "AM/PM":
+			if t.Hour() < 12 {
+				out.WriteString("AM")
+			} else {
+				out.WriteString("PM")
+			}
+		case s == "A/P":
+			if t.Hour() < 12 {
+				out.WriteString("A")
+			} else {
+				out.WriteString("P")
+			}
+		case s == ".":
+			out.WriteString(DecimalSeparator)
+		case s == "0":
+			// Fractions de seconde (mm:ss.0)
+			frac := v*86400 - math.Floor(v*86400)
+			out.WriteString(strconv.Itoa(int(frac * 10)))
+		default:
+			out.WriteString(s)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,62 @@
+package workbook
+
+type Orientation int
+
+const (
+	Portrait Orientation = iota
+	Landscape
+)
+
+// Margins exprime les marges d'impression en points
+type Margins struct {
+	Left, Right, Top, Bottom float64
+}
+
+// PageSetup regroupe les paramètres d'impression d'une feuille
+type PageSetup struct {
+	Orientation Orientation
+	PaperSize   int // code de format de papier Excel (9 = A4)
+	Margins     Margins
+	Scale       int // pourcentage, 100 par défaut
+	FitToPage   bool
+	FitToWidth  int // 0 = pas de contrainte
+	FitToHeight int // 0 = pas de contrainte
+	PrintArea   *Range
+	TitleRows   *[2]int // lignes répétées en haut de chaque page (base 0, bornes incluses)
+	Gridlines   bool    // impression du quadrillage
+}
+
+// DefaultPageSetup renvoie la mise en page par défaut d'Excel (A4 portrait, marges normales)
+func DefaultPageSetup() PageSetup {
+	return PageSetup{
+		Orientation: Portrait,
+		PaperSize:   9,
+		Margins:     Margins{Left: 0.7 * 72, Right: 0.7 * 72, Top: 0.75 * 72, Bottom: 0.75 * 72},
+		Scale:       100,
+	}
+}
+
+// Formats de papier Excel en points (largeur, hauteur en portrait)
+var paperSizes = map[int][2]float64{
+	1:  {612, 792},         // Lettre
+	5:  {612, 1008},        // Légal
+	8:  {841.89, 1190.55},  // A3
+	9:  {595.28, 841.89},   // A4
+	11: {419.53, 595.28},   // A5
+	12: {728.5, 1031.81},   // B4
+	13: {515.91, 728.5},    // B5
+	66: {1190.55, 1683.78}, // A2
+}
+
+// PaperDimensions renvoie la largeur et la hauteur de la page en points,
+// orientation comprise (A4 si le format est inconnu)
+func (p PageSetup) PaperDimensions() (float64, float64) {
+	size, ok := paperSizes[p.PaperSize]
+	if !ok {
+		size = paperSizes[9]
+	}
+	if p.Orientation == Landscape {
+		return size[1], size[0]
+	}
+	return size[0], size[1]
+}
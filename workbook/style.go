@@ -0,0 +1,137 @@
+package workbook
+
+// Color représente une couleur RVB
+type Color struct {
+	R, G, B uint8
+}
+
+var (
+	Black = Color{0, 0, 0}
+	White = Color{255, 255, 255}
+)
+
+type HAlign int
+
+const (
+	AlignGeneral HAlign = iota
+	AlignLeft
+	AlignCenter
+	AlignRight
+	AlignFill
+	AlignJustify
+	AlignCenterContinuous
+)
+
+type VAlign int
+
+const (
+	AlignBottom VAlign = iota
+	AlignTop
+	AlignMiddle
+)
+
+type BorderStyle int
+
+const (
+	BorderNone BorderStyle = iota
+	BorderHair
+	BorderThin
+	BorderMedium
+	BorderThick
+	BorderDashed
+	BorderDotted
+	BorderDouble
+)
+
+// Edge décrit un côté de bordure
+type Edge struct {
+	Style BorderStyle
+	Color Color
+}
+
+// Border regroupe les quatre côtés de la bordure d'une cellule
+type Border struct {
+	Left, Right, Top, Bottom Edge
+}
+
+// Font décrit la police d'une cellule
+type Font struct {
+	Name      string
+	Size      float64
+	Bold      bool
+	Italic    bool
+	Underline bool
+	Color     Color
+}
+
+// Style regroupe la mise en forme d'une cellule
+type Style struct {
+	Font   Font
+	Fill   *Color
+	Border Border
+	NumFmt string
+	HAlign HAlign
+	VAlign VAlign
+	Wrap   bool
+}
+
+// DefaultFont est la police utilisée par Excel en l'absence de style
+var DefaultFont = Font{Name: "Calibri", Size: 11, Color: Black}
+
+// DefaultStyle est le style appliqué aux cellules sans mise en forme
+var DefaultStyle = &Style{Font: DefaultFont, NumFmt: "General"}
+
+// Visible indique si le style produit un rendu même sans valeur (fond ou bordure)
+func (s *Style) Visible() bool {
+	if s == nil {
+		return false
+	}
+	return s.Fill != nil || s.Border.Left.Style != BorderNone || s.Border.Right.Style != BorderNone ||
+		s.Border.Top.Style != BorderNone || s.Border.Bottom.Style != BorderNone
+}
+
+// StyleOf renvoie le style d'une cellule, ou le style par défaut
+func StyleOf(c *Cell) *Style {
+	if c == nil || c.Style == nil {
+		return DefaultStyle
+	}
+	return c.Style
+}
+
+// IndexedColors est la palette historique d'Excel (couleurs indexées 0 à 63)
+var IndexedColors = []Color{
+	{0, 0, 0}, {255, 255, 255}, {255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 255, 0}, {255, 0, 255}, {0, 255, 255},
+	{0, 0, 0}, {255, 255, 255}, {255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 255, 0}, {255, 0, 255}, {0, 255, 255},
+	{128, 0, 0}, {0, 128, 0}, {0, 0, 128}, {128, 128, 0}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192}, {128, 128, 128},
+	{153, 153, 255}, {153, 51, 102}, {255, 255, 204}, {204, 255, 255}, {102, 0, 102}, {255, 128, 128}, {0, 102, 204}, {204, 204, 255},
+	{0, 0, 128}, {255, 0, 255}, {255, 255, 0}, {0, 255, 255}, {128, 0, 128}, {128, 0, 0}, {0, 128, 128}, {0, 0, 255},
+	{0, 204, 255}, {204, 255, 255}, {204, 255, 204}, {255, 255, 153}, {153, 204, 255}, {255, 153, 204}, {204, 153, 255}, {255, 204, 153},
+	{51, 102, 255}, {51, 204, 204}, {153, 204, 0}, {255, 204, 0}, {255, 153, 0}, {255, 102, 0}, {102, 102, 153}, {150, 150, 150},
+	{0, 51, 102}, {51, 153, 102}, {0, 51, 0}, {51, 51, 0}, {153, 51, 0}, {153, 51, 102}, {51, 51, 153}, {51, 51, 51},
+}
+
+// IndexedColor renvoie la couleur de la palette, ou ok=false pour les couleurs
+// système (64 = texte automatique, 65 = fond automatique)
+func IndexedColor(i int) (Color, bool) {
+	if i >= 0 && i < len(IndexedColors) {
+		return IndexedColors[i], true
+	}
+	return Color{}, false
+}
+
+// Tint éclaircit (tint > 0) ou assombrit (tint < 0) une couleur
+func (c Color) Tint(tint float64) Color {
+	if tint == 0 {
+		return c
+	}
+	apply := func(v uint8) uint8 {
+		f := float64(v)
+		if tint < 0 {
+			f = f * (1 + tint)
+		} else {
+			f = f + (255-f)*tint
+		}
+		return uint8(f + 0.5)
+	}
+	return Color{apply(c.R), apply(c.G), apply(c.B)}
+}
@@ -0,0 +1,237 @@
+package workbook
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Workbook représente un classeur chargé en mémoire, indépendamment de son format d'origine
+type Workbook struct {
+	Sheets   []*Sheet
+	Date1904 bool
+}
+
+// Sheet représente une feuille de calcul et ses paramètres de mise en page
+type Sheet struct {
+	Name             string
+	Hidden           bool
+	Cells            map[Ref]*Cell
+	ColWidths        map[int]float64 // largeur en caractères, marges de cellule comprises
+	HiddenCols       map[int]bool
+	RowHeights       map[int]float64 // hauteur en points
+	HiddenRows       map[int]bool
+	DefaultColWidth  float64
+	DefaultRowHeight float64
+	Merges           []Range
+	PageSetup        PageSetup
+}
+
+// Ref identifie une cellule par sa ligne et sa colonne (base 0)
+type Ref struct {
+	Row, Col int
+}
+
+// Range représente une plage rectangulaire de cellules (bornes incluses)
+type Range struct {
+	First, Last Ref
+}
+
+type CellType int
+
+const (
+	CellEmpty CellType = iota
+	CellString
+	CellNumber
+	CellBool
+	CellError
+)
+
+// Cell contient la valeur brute d'une cellule et son style
+type Cell struct {
+	Type  CellType
+	Str   string
+	Num   float64
+	Bool  bool
+	Style *Style
+}
+
+const (
+	defaultColWidth  = 64.0 / 7 // 64 pixels, soit 8,43 caractères affichés par Excel
+	defaultRowHeight = 15
+)
+
+// NewSheet crée une feuille vide avec les dimensions par défaut d'Excel
+func NewSheet(name string) *Sheet {
+	return &Sheet{
+		Name:             name,
+		Cells:            make(map[Ref]*Cell),
+		ColWidths:        make(map[int]float64),
+		HiddenCols:       make(map[int]bool),
+		RowHeights:       make(map[int]float64),
+		HiddenRows:       make(map[int]bool),
+		DefaultColWidth:  defaultColWidth,
+		DefaultRowHeight: defaultRowHeight,
+		PageSetup:        DefaultPageSetup(),
+	}
+}
+
+// Cell renvoie la cellule aux coordonnées données, ou nil si elle n'existe pas
+func (s *Sheet) Cell(row, col int) *Cell {
+	return s.Cells[Ref{Row: row, Col: col}]
+}
+
+// SetCell enregistre une cellule aux coordonnées données
+func (s *Sheet) SetCell(row, col int, cell *Cell) {
+	s.Cells[Ref{Row: row, Col: col}] = cell
+}
+
+// ColWidth renvoie la largeur d'une colonne en caractères
+func (s *Sheet) ColWidth(col int) float64 {
+	if w, ok := s.ColWidths[col]; ok {
+		return w
+	}
+	return s.DefaultColWidth
+}
+
+// ColWidthPoints convertit la largeur d'une colonne en points, selon la police
+// par défaut d'Excel (7 pixels par caractère)
+func (s *Sheet) ColWidthPoints(col int) float64 {
+	if s.HiddenCols[col] {
+		return 0
+	}
+	w := s.ColWidth(col)
+	if w <= 0 {
+		return 0
+	}
+	return math.Trunc(w*7+0.5) * 0.75
+}
+
+// DefaultColWidthFromChars convertit une largeur par défaut exprimée en caractères
+// (hors marges) en largeur de colonne ; Excel l'arrondit au multiple de 8 pixels
+func DefaultColWidthFromChars(chars float64) float64 {
+	pixels := math.Ceil((chars*7+5)/8) * 8
+	return pixels / 7
+}
+
+// RowHeightPoints renvoie la hauteur d'une ligne en points
+func (s *Sheet) RowHeightPoints(row int) float64 {
+	if s.HiddenRows[row] {
+		return 0
+	}
+	if h, ok := s.RowHeights[row]; ok {
+		return h
+	}
+	return s.DefaultRowHeight
+}
+
+// MergeAt renvoie la plage fusionnée contenant la cellule, s'il y en a une
+func (s *Sheet) MergeAt(ref Ref) (Range, bool) {
+	for _, m := range s.Merges {
+		if m.Contains(ref) {
+			return m, true
+		}
+	}
+	return Range{}, false
+}
+
+// UsedRange renvoie la plage englobant toutes les cellules non vides ou stylées
+func (s *Sheet) UsedRange() (Range, bool) {
+	found := false
+	var r Range
+	extend := func(ref Ref) {
+		if !found {
+			r = Range{First: ref, Last: ref}
+			found = true
+			return
+		}
+		r.First.Row = min(r.First.Row, ref.Row)
+		r.First.Col = min(r.First.Col, ref.Col)
+		r.Last.Row = max(r.Last.Row, ref.Row)
+		r.Last.Col = max(r.Last.Col, ref.Col)
+	}
+
+	for ref, cell := range s.Cells {
+		if cell.Type != CellEmpty || cell.Style.Visible() {
+			extend(ref)
+		}
+	}
+	for _, m := range s.Merges {
+		extend(m.First)
+		extend(m.Last)
+	}
+	return r, found
+}
+
+// Contains indique si la plage contient la cellule
+func (r Range) Contains(ref Ref) bool {
+	return ref.Row >= r.First.Row && ref.Row <= r.Last.Row &&
+		ref.Col >= r.First.Col && ref.Col <= r.Last.Col
+}
+
+// String renvoie la plage au format A1:B2
+func (r Range) String() string {
+	if r.First == r.Last {
+		return r.First.String()
+	}
+	return r.First.String() + ":" + r.Last.String()
+}
+
+// String renvoie la référence au format A1
+func (r Ref) String() string {
+	return ColumnName(r.Col) + fmt.Sprint(r.Row+1)
+}
+
+// ColumnName convertit un index de colonne (base 0) en lettres (A, B, ..., AA)
+func ColumnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
+
+// ParseRef analyse une référence au format A1 (les $ sont ignorés)
+func ParseRef(s string) (Ref, error) {
+	s = strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(s)), "$", "")
+	i := 0
+	col := 0
+	for i < len(s) && s[i] >= 'A' && s[i] <= 'Z' {
+		col = col*26 + int(s[i]-'A'+1)
+		i++
+	}
+	row := 0
+	j := i
+	for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+		row = row*10 + int(s[j]-'0')
+		j++
+	}
+	if i == 0 || j == i || j != len(s) || row == 0 {
+		return Ref{}, fmt.Errorf("référence de cellule invalide : %q", s)
+	}
+	return Ref{Row: row - 1, Col: col - 1}, nil
+}
+
+// ParseRange analyse une plage au format A1:B2 (ou une cellule seule)
+func ParseRange(s string) (Range, error) {
+	// Suppression d'un éventuel préfixe de feuille ('Feuil1'!A1:B2)
+	if i := strings.LastIndex(s, "!"); i >= 0 {
+		s = s[i+1:]
+	}
+	parts := strings.SplitN(s, ":", 2)
+	first, err := ParseRef(parts[0])
+	if err != nil {
+		return Range{}, err
+	}
+	last := first
+	if len(parts) == 2 {
+		if last, err = ParseRef(parts[1]); err != nil {
+			return Range{}, err
+		}
+	}
+	return Range{
+		First: Ref{Row: min(first.Row, last.Row), Col: min(first.Col, last.Col)},
+		Last:  Ref{Row: max(first.Row, last.Row), Col: max(first.Col, last.Col)},
+	}, nil
+}
@@ -0,0 +1,207 @@
+package xls
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Format Compound File Binary (MS-CFB), conteneur des fichiers .xls
+
+const (
+	cfbSignature  = "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"
+	cfbHeaderSize = 512
+	cfbDirEntry   = 128
+
+	secFree       = 0xFFFFFFFF
+	secEndOfChain = 0xFFFFFFFE
+
+	objStream = 2
+	objRoot   = 5
+)
+
+// compoundFile donne accès aux flux d'un fichier CFB entièrement chargé en mémoire
+type compoundFile struct {
+	data        []byte
+	sectorSize  int
+	miniSize    int
+	miniCutoff  uint32
+	fat         []uint32
+	miniFat     []uint32
+	entries     []dirEntry
+	miniStream  []byte
+	maxSectorID uint32
+}
+
+type dirEntry struct {
+	name  string
+	typ   byte
+	start uint32
+	size  uint64
+}
+
+func openCompoundFile(data []byte) (*compoundFile, error) {
+	if len(data) < cfbHeaderSize || string(data[:8]) != cfbSignature {
+		return nil, fmt.Errorf("signature de fichier composé absente")
+	}
+
+	major := le.Uint16(data[0x1A:])
+	sectorShift := le.Uint16(data[0x1E:])
+	miniShift := le.Uint16(data[0x20:])
+	if (major != 3 && major != 4) || sectorShift < 7 || sectorShift > 16 || miniShift > sectorShift {
+		return nil, fmt.Errorf("en-tête de fichier composé invalide")
+	}
+
+	cf := &compoundFile{
+		data:       data,
+		sectorSize: 1 << sectorShift,
+		miniSize:   1 << miniShift,
+		miniCutoff: le.Uint32(data[0x38:]),
+	}
+	cf.maxSectorID = uint32((len(data)-cfbHeaderSize)/cf.sectorSize + 1)
+
+	// Table DIFAT : 109 entrées dans l'en-tête, puis chaînage éventuel
+	numFat := int(le.Uint32(data[0x2C:]))
+	var difat []uint32
+	for i := 0; i < 109 && len(difat) < numFat; i++ {
+		difat = append(difat, le.Uint32(data[0x4C+4*i:]))
+	}
+	next := le.Uint32(data[0x44:])
+	perSector := cf.sectorSize/4 - 1
+	for visited := 0; next != secEndOfChain && next != secFree && len(difat) < numFat; visited++ {
+		sector, err := cf.sector(next)
+		if err != nil || visited > int(cf.maxSectorID) {
+			return nil, fmt.Errorf("table DIFAT corrompue")
+		}
+		for i := 0; i < perSector && len(difat) < numFat; i++ {
+			difat = append(difat, le.Uint32(sector[4*i:]))
+		}
+		next = le.Uint32(sector[4*perSector:])
+	}
+
+	for _, id := range difat {
+		sector, err := cf.sector(id)
+		if err != nil {
+			return nil, fmt.Errorf("table FAT corrompue : %v", err)
+		}
+		for i := 0; i < cf.sectorSize; i += 4 {
+			cf.fat = append(cf.fat, le.Uint32(sector[i:]))
+		}
+	}
+
+	// Répertoire
+	dir, err := cf.chain(le.Uint32(data[0x30:]), 0)
+	if err != nil {
+		return nil, fmt.Errorf("répertoire illisible : %v", err)
+	}
+	for off := 0; off+cfbDirEntry <= len(dir); off += cfbDirEntry {
+		e := dir[off : off+cfbDirEntry]
+		nameLen := int(le.Uint16(e[0x40:]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+		units := make([]uint16, 0, 32)
+		for i := 0; i+1 < nameLen-1; i += 2 {
+			units = append(units, le.Uint16(e[i:]))
+		}
+		size := uint64(le.Uint32(e[0x78:]))
+		if major == 4 {
+			size = le.Uint64(e[0x78:])
+		}
+		cf.entries = append(cf.entries, dirEntry{
+			name:  string(utf16.Decode(units)),
+			typ:   e[0x42],
+			start: le.Uint32(e[0x74:]),
+			size:  size,
+		})
+	}
+	if len(cf.entries) == 0 || cf.entries[0].typ != objRoot {
+		return nil, fmt.Errorf("entrée racine absente")
+	}
+
+	// Mini-flux et table MiniFAT pour les petits flux
+	root := cf.entries[0]
+	if root.start != secEndOfChain && root.size > 0 {
+		if cf.miniStream, err = cf.chain(root.start, root.size); err != nil {
+			return nil, fmt.Errorf("mini-flux illisible : %v", err)
+		}
+	}
+	if start := le.Uint32(data[0x3C:]); start != secEndOfChain && start != secFree {
+		raw, err := cf.chain(start, 0)
+		if err != nil {
+			return nil, fmt.Errorf("table MiniFAT illisible : %v", err)
+		}
+		for i := 0; i+4 <= len(raw); i += 4 {
+			cf.miniFat = append(cf.miniFat, le.Uint32(raw[i:]))
+		}
+	}
+
+	return cf, nil
+}
+
+func (cf *compoundFile) sector(id uint32) ([]byte, error) {
+	off := cfbHeaderSize + int(id)*cf.sectorSize
+	if id >= cf.maxSectorID || off+cf.sectorSize > len(cf.data) {
+		return nil, fmt.Errorf("secteur %d hors du fichier", id)
+	}
+	return cf.data[off : off+cf.sectorSize], nil
+}
+
+// chain lit une chaîne de secteurs ; size = 0 lit la chaîne complète
+func (cf *compoundFile) chain(start uint32, size uint64) ([]byte, error) {
+	var buf bytes.Buffer
+	for id, n := start, 0; id != secEndOfChain; n++ {
+		if int(id) >= len(cf.fat) || n > len(cf.fat) {
+			return nil, fmt.Errorf("chaîne de secteurs corrompue")
+		}
+		sector, err := cf.sector(id)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(sector)
+		if size > 0 && uint64(buf.Len()) >= size {
+			break
+		}
+		id = cf.fat[id]
+	}
+	if size > 0 {
+		if uint64(buf.Len()) < size {
+			return nil, fmt.Errorf("flux tronqué")
+		}
+		return buf.Bytes()[:size], nil
+	}
+	return buf.Bytes(), nil
+}
+
+func (cf *compoundFile) miniChain(start uint32, size uint64) ([]byte, error) {
+	var buf bytes.Buffer
+	for id, n := start, 0; id != secEndOfChain && uint64(buf.Len()) < size; n++ {
+		if int(id) >= len(cf.miniFat) || n > len(cf.miniFat) {
+			return nil, fmt.Errorf("chaîne MiniFAT corrompue")
+		}
+		off := int(id) * cf.miniSize
+		if off+cf.miniSize > len(cf.miniStream) {
+			return nil, fmt.Errorf("mini-secteur %d hors du mini-flux", id)
+		}
+		buf.Write(cf.miniStream[off : off+cf.miniSize])
+		id = cf.miniFat[id]
+	}
+	if uint64(buf.Len()) < size {
+		return nil, fmt.Errorf("flux tronqué")
+	}
+	return buf.Bytes()[:size], nil
+}
+
+// stream renvoie le contenu du flux portant le nom donné
+func (cf *compoundFile) stream(name string) ([]byte, error) {
+	for _, e := range cf.entries[1:] {
+		if e.typ != objStream || e.name != name {
+			continue
+		}
+		if e.size < uint64(cf.miniCutoff) {
+			return cf.miniChain(e.start, e.size)
+		}
+		return cf.chain(e.start, e.size)
+	}
+	return nil, fmt.Errorf("flux %q absent", name)
+}
@@ -0,0 +1,284 @@
+package xls
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// Types d'enregistrements BIFF8 exploités
+const (
+	recFormula          = 0x0006
+	recEOF              = 0x000A
+	recName             = 0x0018
+	recDateMode         = 0x0022
+	recLeftMargin       = 0x0026
+	recRightMargin      = 0x0027
+	recTopMargin        = 0x0028
+	recBottomMargin     = 0x0029
+	recFilePass         = 0x002F
+	recFont             = 0x0031
+	recContinue         = 0x003C
+	recDefColWidth      = 0x0055
+	recColInfo          = 0x007D
+	recWsBool           = 0x0081
+	recBoundSheet       = 0x0085
+	recPalette          = 0x0092
+	recSetup            = 0x00A1
+	recMulRK            = 0x00BD
+	recMulBlank         = 0x00BE
+	recXF               = 0x00E0
+	recMergedCells      = 0x00E5
+	recSST              = 0x00FC
+	recLabelSST         = 0x00FD
+	recBlank            = 0x0201
+	recNumber           = 0x0203
+	recLabel            = 0x0204
+	recBoolErr          = 0x0205
+	recString           = 0x0207
+	recRow              = 0x0208
+	recDefaultRowHeight = 0x0225
+	recRK               = 0x027E
+	recFormat           = 0x041E
+	recBOF              = 0x0809
+)
+
+// record est un enregistrement BIFF et ses éventuels CONTINUE
+type record struct {
+	typ       uint16
+	data      []byte
+	continues [][]byte
+}
+
+var le = binary.LittleEndian
+
+// recordReader parcourt les enregistrements d'un flux Workbook
+type recordReader struct {
+	stream []byte
+	pos    int
+}
+
+// next lit l'enregistrement suivant en regroupant les CONTINUE qui le suivent
+func (r *recordReader) next() (*record, error) {
+	rec, err := r.raw()
+	if err != nil || rec == nil {
+		return rec, err
+	}
+	for r.pos+4 <= len(r.stream) && le.Uint16(r.stream[r.pos:]) == recContinue {
+		cont, err := r.raw()
+		if err != nil {
+			return nil, err
+		}
+		rec.continues = append(rec.continues, cont.data)
+	}
+	return rec, nil
+}
+
+func (r *recordReader) raw() (*record, error) {
+	if r.pos+4 > len(r.stream) {
+		return nil, nil
+	}
+	typ := le.Uint16(r.stream[r.pos:])
+	size := int(le.Uint16(r.stream[r.pos+2:]))
+	start := r.pos + 4
+	if start+size > len(r.stream) {
+		return nil, fmt.Errorf("enregistrement 0x%04X tronqué", typ)
+	}
+	r.pos = start + size
+	return &record{typ: typ, data: r.stream[start : start+size]}, nil
+}
+
+// all renvoie le contenu de l'enregistrement, CONTINUE compris
+func (rec *record) all() []byte {
+	if len(rec.continues) == 0 {
+		return rec.data
+	}
+	data := append([]byte(nil), rec.data...)
+	for _, c := range rec.continues {
+		data = append(data, c...)
+	}
+	return data
+}
+
+// segmentReader lit des données réparties sur un enregistrement et ses CONTINUE,
+// en gérant l'octet d'options répété au début de chaque CONTINUE pour les chaînes
+type segmentReader struct {
+	segments [][]byte
+	seg, pos int
+}
+
+func newSegmentReader(rec *record) *segmentReader {
+	return &segmentReader{segments: append([][]byte{rec.data}, rec.continues...)}
+}
+
+func (s *segmentReader) advance() bool {
+	for s.seg < len(s.segments) && s.pos >= len(s.segments[s.seg]) {
+		s.seg++
+		s.pos = 0
+	}
+	return s.seg < len(s.segments)
+}
+
+func (s *segmentReader) bytes(n int) ([]byte, error) {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		if !s.advance() {
+			return nil, fmt.Errorf("fin de données inattendue")
+		}
+		seg := s.segments[s.seg]
+		take := min(n-len(out), len(seg)-s.pos)
+		out = append(out, seg[s.pos:s.pos+take]...)
+		s.pos += take
+	}
+	return out, nil
+}
+
+func (s *segmentReader) u8() (byte, error) {
+	b, err := s.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (s *segmentReader) u16() (uint16, error) {
+	b, err := s.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return le.Uint16(b), nil
+}
+
+func (s *segmentReader) u32() (uint32, error) {
+	b, err := s.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return le.Uint32(b), nil
+}
+
+// chars lit cch caractères ; à chaque changement de segment, un nouvel octet
+// d'options indique si la suite est compressée (1 octet) ou non (2 octets)
+func (s *segmentReader) chars(cch int, highByte bool) (string, error) {
+	units := make([]uint16, 0, cch)
+	for len(units) < cch {
+		if !s.advance() {
+			return "", fmt.Errorf("chaîne tronquée")
+		}
+		seg := s.segments[s.seg]
+		for len(units) < cch && s.pos < len(seg) {
+			if highByte {
+				if s.pos+2 > len(seg) {
+					return "", fmt.Errorf("caractère coupé entre deux enregistrements")
+				}
+				units = append(units, le.Uint16(seg[s.pos:]))
+				s.pos += 2
+			} else {
+				units = append(units, uint16(seg[s.pos]))
+				s.pos++
+			}
+		}
+		if len(units) < cch {
+			s.seg++
+			s.pos = 0
+			if !s.advance() {
+				return "", fmt.Errorf("chaîne tronquée")
+			}
+			flags := s.segments[s.seg][s.pos]
+			s.pos++
+			highByte = flags&0x01 != 0
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// richString lit une XLUnicodeRichExtendedString (utilisée par la table SST)
+func (s *segmentReader) richString() (string, error) {
+	cch, err := s.u16()
+	if err != nil {
+		return "", err
+	}
+	flags, err := s.u8()
+	if err != nil {
+		return "", err
+	}
+	runs := 0
+	if flags&0x08 != 0 {
+		n, err := s.u16()
+		if err != nil {
+			return "", err
+		}
+		runs = int(n)
+	}
+	ext := 0
+	if flags&0x04 != 0 {
+		n, err := s.u32()
+		if err != nil {
+			return "", err
+		}
+		ext = int(n)
+	}
+	str, err := s.chars(int(cch), flags&0x01 != 0)
+	if err != nil {
+		return "", err
+	}
+	// Mises en forme et données phonétiques ignorées
+	if _, err := s.bytes(4*runs + ext); err != nil {
+		return "", err
+	}
+	return str, nil
+}
+
+// unicodeString lit une XLUnicodeString (longueur sur 16 bits) au début de data
+func unicodeString(data []byte) (string, int, error) {
+	if len(data) < 3 {
+		return "", 0, fmt.Errorf("chaîne tronquée")
+	}
+	return stringBody(data[2:], int(le.Uint16(data)), 2)
+}
+
+// shortUnicodeString lit une ShortXLUnicodeString (longueur sur 8 bits)
+func shortUnicodeString(data []byte) (string, int, error) {
+	if len(data) < 2 {
+		return "", 0, fmt.Errorf("chaîne tronquée")
+	}
+	return stringBody(data[1:], int(data[0]), 1)
+}
+
+func stringBody(data []byte, cch, headerLen int) (string, int, error) {
+	flags := data[0]
+	data = data[1:]
+	if flags&0x01 != 0 {
+		if len(data) < 2*cch {
+			return "", 0, fmt.Errorf("chaîne tronquée")
+		}
+		units := make([]uint16, cch)
+		for i := range units {
+			units[i] = le.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units)), headerLen + 1 + 2*cch, nil
+	}
+	if len(data) < cch {
+		return "", 0, fmt.Errorf("chaîne tronquée")
+	}
+	units := make([]uint16, cch)
+	for i := range units {
+		units[i] = uint16(data[i])
+	}
+	return string(utf16.Decode(units)), headerLen + 1 + cch, nil
+}
+
+// decodeRK décode un nombre compressé RK
+func decodeRK(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
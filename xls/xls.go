@@ -0,0 +1,634 @@
+package xls
+
+import (
+	"fmt"
+	"fredon_to_pdf/workbook"
+	"math"
+	"os"
+)
+
+const (
+	biff8Version  = 0x0600
+	bofWorksheet  = 0x0010
+	sheetTypeWork = 0x00
+	builtinPrint  = 0x06 // nom intégré Print_Area
+	ptgArea3d     = 0x3B
+	ptgMemFunc    = 0x29
+)
+
+// boundSheet décrit une feuille déclarée dans le flux global du classeur
+type boundSheet struct {
+	name   string
+	offset int
+	state  byte
+	typ    byte
+}
+
+// parser accumule les informations globales nécessaires au décodage des feuilles
+type parser struct {
+	stream   []byte
+	wb       *workbook.Workbook
+	sheets   []boundSheet
+	sst      []string
+	fonts    []workbook.Font
+	fontICV  []int
+	formats  map[int]string
+	xfs      []*workbook.Style
+	palette  []workbook.Color
+	rawXFs   []rawXF
+	printRng map[int]workbook.Range
+}
+
+// rawXF conserve les index bruts d'un XF jusqu'à la lecture complète de la palette
+type rawXF struct {
+	font, format          int
+	halign, valign        byte
+	wrap                  bool
+	borders               [4]byte // gauche, droite, haut, bas
+	borderColors          [4]int
+	fillPattern, fillFore int
+}
+
+// Open charge un classeur Excel 97-2003 (.xls, BIFF8) en mémoire
+func Open(filePath string) (*workbook.Workbook, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lire le fichier : %v", err)
+	}
+	return Read(data)
+}
+
+// Read charge un classeur BIFF8 à partir du contenu brut du fichier
+func Read(data []byte) (*workbook.Workbook, error) {
+	cf, err := openCompoundFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("fichier composé invalide : %v", err)
+	}
+
+	stream, err := cf.stream("Workbook")
+	if err != nil {
+		if _, errBook := cf.stream("Book"); errBook == nil {
+			return nil, fmt.Errorf("format Excel 5.0/95 (BIFF5) non supporté")
+		}
+		return nil, err
+	}
+
+	p := &parser{
+		stream:   stream,
+		wb:       &workbook.Workbook{},
+		formats:  make(map[int]string),
+		palette:  append([]workbook.Color(nil), workbook.IndexedColors...),
+		printRng: make(map[int]workbook.Range),
+	}
+	if err := p.parseGlobals(); err != nil {
+		return nil, err
+	}
+	p.buildStyles()
+
+	for i, bs := range p.sheets {
+		if bs.typ != sheetTypeWork {
+			continue
+		}
+		sheet, err := p.parseSheet(bs)
+		if err != nil {
+			return nil, fmt.Errorf("erreur de lecture de la feuille %q : %v", bs.name, err)
+		}
+		if rng, ok := p.printRng[i]; ok {
+			sheet.PageSetup.PrintArea = &rng
+		}
+		p.wb.Sheets = append(p.wb.Sheets, sheet)
+	}
+
+	return p.wb, nil
+}
+
+// parseGlobals lit le sous-flux global : feuilles, chaînes, polices, formats et styles
+func (p *parser) parseGlobals() error {
+	r := &recordReader{stream: p.stream}
+	first, err := r.next()
+	if err != nil || first == nil || first.typ != recBOF || len(first.data) < 4 {
+		return fmt.Errorf("enregistrement BOF initial absent")
+	}
+	if version := le.Uint16(first.data); version != biff8Version {
+		return fmt.Errorf("version BIFF 0x%04X non supportée", version)
+	}
+
+	for {
+		rec, err := r.next()
+		if err != nil {
+			return err
+		}
+		if rec == nil || rec.typ == recEOF {
+			return nil
+		}
+		data := rec.data
+
+		switch rec.typ {
+		case recFilePass:
+			return fmt.Errorf("classeur protégé par mot de passe")
+		case recDateMode:
+			p.wb.Date1904 = len(data) >= 2 && le.Uint16(data) == 1
+		case recBoundSheet:
+			if len(data) < 8 {
+				continue
+			}
+			name, _, err := shortUnicodeString(data[6:])
+			if err != nil {
+				return err
+			}
+			p.sheets = append(p.sheets, boundSheet{
+				name:   name,
+				offset: int(le.Uint32(data)),
+				state:  data[4] & 0x03,
+				typ:    data[5],
+			})
+		case recSST:
+			if err := p.parseSST(rec); err != nil {
+				return fmt.Errorf("table des chaînes illisible : %v", err)
+			}
+		case recFont:
+			p.parseFont(data)
+		case recFormat:
+			if len(data) < 5 {
+				continue
+			}
+			code, _, err := unicodeString(data[2:])
+			if err == nil {
+				p.formats[int(le.Uint16(data))] = code
+			}
+		case recXF:
+			p.parseXF(data)
+		case recPalette:
+			if len(data) < 2 {
+				continue
+			}
+			count := int(le.Uint16(data))
+			for i := 0; i < count && 2+4*i+3 <= len(data) && 8+i < len(p.palette); i++ {
+				c := data[2+4*i:]
+				p.palette[8+i] = workbook.Color{R: c[0], G: c[1], B: c[2]}
+			}
+		case recName:
+			p.parseName(rec.all())
+		}
+	}
+}
+
+func (p *parser) parseSST(rec *record) error {
+	s := newSegmentReader(rec)
+	if _, err := s.u32(); err != nil {
+		return err
+	}
+	unique, err := s.u32()
+	if err != nil {
+		return err
+	}
+	// Chaque chaîne occupe au moins 3 octets : un nombre annoncé supérieur
+	// signale une table corrompue, qui ne doit pas provoquer d'allocation démesurée
+	size := len(rec.data)
+	for _, c := range rec.continues {
+		size += len(c)
+	}
+	if uint64(unique)*3 > uint64(size) {
+		return fmt.Errorf("nombre de chaînes invalide : %d", unique)
+	}
+	p.sst = make([]string, 0, unique)
+	for i := uint32(0); i < unique; i++ {
+		str, err := s.richString()
+		if err != nil {
+			return err
+		}
+		p.sst = append(p.sst, str)
+	}
+	return nil
+}
+
+func (p *parser) parseFont(data []byte) {
+	if len(data) < 15 {
+		return
+	}
+	font := workbook.Font{
+		Size:      float64(le.Uint16(data)) / 20,
+		Italic:    le.Uint16(data[2:])&0x02 != 0,
+		Bold:      le.Uint16(data[6:]) >= 700,
+		Underline: data[10] != 0,
+	}
+	if name, _, err := shortUnicodeString(data[14:]); err == nil {
+		font.Name = name
+	}
+	p.fonts = append(p.fonts, font)
+	// La couleur est résolue avec les styles, une fois la palette lue
+	p.fontICV = append(p.fontICV, int(le.Uint16(data[4:])))
+}
+
+func (p *parser) parseXF(data []byte) {
+	if len(data) < 20 {
+		return
+	}
+	align := data[6]
+	b1 := le.Uint32(data[10:])
+	b2 := le.Uint32(data[14:])
+	fill := le.Uint16(data[18:])
+	p.rawXFs = append(p.rawXFs, rawXF{
+		font:   int(le.Uint16(data)),
+		format: int(le.Uint16(data[2:])),
+		halign: align & 0x07,
+		wrap:   align&0x08 != 0,
+		valign: (align >> 4) & 0x07,
+		borders: [4]byte{
+			byte(b1 & 0x0F), byte((b1 >> 4) & 0x0F), byte((b1 >> 8) & 0x0F), byte((b1 >> 12) & 0x0F),
+		},
+		borderColors: [4]int{
+			int((b1 >> 16) & 0x7F), int((b1 >> 23) & 0x7F), int(b2 & 0x7F), int((b2 >> 7) & 0x7F),
+		},
+		fillPattern: int((b2 >> 26) & 0x3F),
+		fillFore:    int(fill & 0x7F),
+	})
+}
+
+// parseName extrait les zones d'impression (nom intégré Print_Area)
+func (p *parser) parseName(data []byte) {
+	if len(data) < 15 {
+		return
+	}
+	flags := le.Uint16(data)
+	cch := int(data[3])
+	cce := int(le.Uint16(data[4:]))
+	itab := int(le.Uint16(data[8:]))
+	if flags&0x0020 == 0 || itab == 0 || cch != 1 {
+		return
+	}
+	// Nom intégré : un seul caractère après l'octet d'options
+	nameLen := 1
+	if data[14]&0x01 != 0 {
+		nameLen = 2
+	}
+	if 15+nameLen > len(data) || data[15] != builtinPrint {
+		return
+	}
+	formula := data[15+nameLen:]
+	if len(formula) > cce {
+		formula = formula[:cce]
+	}
+	// Zone unique, ou première zone d'une union (précédée d'un PtgMemFunc)
+	if len(formula) > 3 && isPtg(formula[0], ptgMemFunc) {
+		formula = formula[3:]
+	}
+	if len(formula) < 11 || !isPtg(formula[0], ptgArea3d) {
+		return
+	}
+	area := formula[3:]
+	p.printRng[itab-1] = workbook.Range{
+		First: workbook.Ref{Row: int(le.Uint16(area)), Col: int(le.Uint16(area[4:]) & 0x3FFF)},
+		Last:  workbook.Ref{Row: int(le.Uint16(area[2:])), Col: int(le.Uint16(area[6:]) & 0x3FFF)},
+	}
+}
+
+// isPtg compare un jeton de formule en ignorant sa classe (référence, valeur, tableau)
+func isPtg(b, ptg byte) bool {
+	return b&0x1F == ptg&0x1F && b&0x60 != 0
+}
+
+// colorIndex résout un index de palette ; les couleurs système renvoient def
+func (p *parser) colorIndex(idx int, def workbook.Color) workbook.Color {
+	if idx >= 0 && idx < len(p.palette) {
+		return p.palette[idx]
+	}
+	return def
+}
+
+// buildStyles convertit les XF bruts une fois la palette définitive connue
+func (p *parser) buildStyles() {
+	for _, x := range p.rawXFs {
+		style := &workbook.Style{Font: workbook.DefaultFont, NumFmt: "General"}
+
+		// L'index de police 4 n'existe pas en BIFF : les suivants sont décalés
+		fontIdx := x.font
+		if fontIdx > 4 {
+			fontIdx--
+		}
+		if fontIdx < len(p.fonts) {
+			style.Font = p.fonts[fontIdx]
+			style.Font.Color = p.colorIndex(p.fontICV[fontIdx], workbook.Black)
+		}
+
+		if code, ok := p.formats[x.format]; ok {
+			style.NumFmt = code
+		} else if code, ok := workbook.BuiltinFormat(x.format); ok {
+			style.NumFmt = code
+		}
+
+		switch x.halign {
+		case 1:
+			style.HAlign = workbook.AlignLeft
+		case 2:
+			style.HAlign = workbook.AlignCenter
+		case 3:
+			style.HAlign = workbook.AlignRight
+		case 4:
+			style.HAlign = workbook.AlignFill
+		case 5, 7:
+			style.HAlign = workbook.AlignJustify
+		case 6:
+			style.HAlign = workbook.AlignCenterContinuous
+		}
+		switch x.valign {
+		case 0:
+			style.VAlign = workbook.AlignTop
+		case 1, 3, 4:
+			style.VAlign = workbook.AlignMiddle
+		}
+		style.Wrap = x.wrap
+
+		edges := make([]workbook.Edge, 4)
+		for i := range edges {
+			edges[i] = workbook.Edge{Style: borderStyle(x.borders[i]), Color: p.colorIndex(x.borderColors[i], workbook.Black)}
+		}
+		style.Border = workbook.Border{Left: edges[0], Right: edges[1], Top: edges[2], Bottom: edges[3]}
+
+		if x.fillPattern != 0 {
+			if c, ok := workbook.IndexedColor(x.fillFore); ok {
+				c = p.colorIndex(x.fillFore, c)
+				style.Fill = &c
+			}
+		}
+
+		p.xfs = append(p.xfs, style)
+	}
+}
+
+func borderStyle(code byte) workbook.BorderStyle {
+	switch code {
+	case 1:
+		return workbook.BorderThin
+	case 2:
+		return workbook.BorderMedium
+	case 3, 8, 9, 10, 11, 12, 13:
+		return workbook.BorderDashed
+	case 4:
+		return workbook.BorderDotted
+	case 5:
+		return workbook.BorderThick
+	case 6:
+		return workbook.BorderDouble
+	case 7:
+		return workbook.BorderHair
+	}
+	return workbook.BorderNone
+}
+
+func (p *parser) style(ixfe int) *workbook.Style {
+	if ixfe >= 0 && ixfe < len(p.xfs) {
+		return p.xfs[ixfe]
+	}
+	return nil
+}
+
+// parseSheet lit le sous-flux d'une feuille de calcul
+func (p *parser) parseSheet(bs boundSheet) (*workbook.Sheet, error) {
+	if bs.offset < 0 || bs.offset >= len(p.stream) {
+		return nil, fmt.Errorf("position de feuille invalide")
+	}
+	r := &recordReader{stream: p.stream, pos: bs.offset}
+	bof, err := r.next()
+	if err != nil || bof == nil || bof.typ != recBOF || len(bof.data) < 4 || le.Uint16(bof.data[2:]) != bofWorksheet {
+		return nil, fmt.Errorf("enregistrement BOF de feuille absent")
+	}
+
+	sheet := workbook.NewSheet(bs.name)
+	sheet.Hidden = bs.state != 0
+	fitToPage := false
+	var setup []byte
+
+	// Dernière cellule FORMULA dont le résultat texte suit dans un enregistrement STRING
+	var pendingString *workbook.Cell
+
+	for {
+		rec, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil || rec.typ == recEOF {
+			break
+		}
+		data := rec.data
+
+		switch rec.typ {
+		case recLabelSST:
+			if len(data) < 10 {
+				continue
+			}
+			idx := int(le.Uint32(data[6:]))
+			if idx >= len(p.sst) {
+				return nil, fmt.Errorf("index de chaîne partagée invalide : %d", idx)
+			}
+			p.setCell(sheet, data, &workbook.Cell{Type: workbook.CellString, Str: p.sst[idx]})
+		case recLabel:
+			if len(data) < 9 {
+				continue
+			}
+			str, _, err := unicodeString(data[6:])
+			if err != nil {
+				return nil, err
+			}
+			p.setCell(sheet, data, &workbook.Cell{Type: workbook.CellString, Str: str})
+		case recNumber:
+			if len(data) < 14 {
+				continue
+			}
+			p.setCell(sheet, data, &workbook.Cell{Type: workbook.CellNumber, Num: math.Float64frombits(le.Uint64(data[6:]))})
+		case recRK:
+			if len(data) < 10 {
+				continue
+			}
+			p.setCell(sheet, data, &workbook.Cell{Type: workbook.CellNumber, Num: decodeRK(le.Uint32(data[6:]))})
+		case recMulRK:
+			if len(data) < 6 {
+				continue
+			}
+			row := int(le.Uint16(data))
+			col := int(le.Uint16(data[2:]))
+			for off := 4; off+6 <= len(data)-2; off += 6 {
+				sheet.SetCell(row, col, &workbook.Cell{
+					Type:  workbook.CellNumber,
+					Num:   decodeRK(le.Uint32(data[off+2:])),
+					Style: p.style(int(le.Uint16(data[off:]))),
+				})
+				col++
+			}
+		case recBlank:
+			if len(data) < 6 {
+				continue
+			}
+			p.setCell(sheet, data, &workbook.Cell{})
+		case recMulBlank:
+			if len(data) < 6 {
+				continue
+			}
+			row := int(le.Uint16(data))
+			col := int(le.Uint16(data[2:]))
+			for off := 4; off+2 <= len(data)-2; off += 2 {
+				sheet.SetCell(row, col, &workbook.Cell{Style: p.style(int(le.Uint16(data[off:])))})
+				col++
+			}
+		case recBoolErr:
+			if len(data) < 8 {
+				continue
+			}
+			cell := &workbook.Cell{Type: workbook.CellBool, Bool: data[6] != 0}
+			if data[7] != 0 {
+				cell = &workbook.Cell{Type: workbook.CellError, Str: errorText(data[6])}
+			}
+			p.setCell(sheet, data, cell)
+		case recFormula:
+			if len(data) < 14 {
+				continue
+			}
+			cell := formulaResult(data[6:14])
+			p.setCell(sheet, data, cell)
+			if cell.Type == workbook.CellString && data[6] == 0 {
+				pendingString = cell
+			}
+		case recString:
+			if pendingString != nil {
+				if str, _, err := unicodeString(rec.all()); err == nil {
+					pendingString.Str = str
+				}
+				pendingString = nil
+			}
+		case recRow:
+			if len(data) < 16 {
+				continue
+			}
+			row := int(le.Uint16(data))
+			height := le.Uint16(data[6:]) & 0x7FFF
+			sheet.RowHeights[row] = float64(height) / 20
+			if le.Uint16(data[12:])&0x0020 != 0 {
+				sheet.HiddenRows[row] = true
+			}
+		case recColInfo:
+			if len(data) < 10 {
+				continue
+			}
+			first := int(le.Uint16(data))
+			last := min(int(le.Uint16(data[2:])), 255)
+			width := float64(le.Uint16(data[4:])) / 256
+			hidden := le.Uint16(data[8:])&0x0001 != 0
+			for c := first; c <= last; c++ {
+				sheet.ColWidths[c] = width
+				if hidden {
+					sheet.HiddenCols[c] = true
+				}
+			}
+		case recDefColWidth:
+			if len(data) >= 2 {
+				sheet.DefaultColWidth = workbook.DefaultColWidthFromChars(float64(le.Uint16(data)))
+			}
+		case recDefaultRowHeight:
+			if len(data) >= 4 {
+				sheet.DefaultRowHeight = float64(le.Uint16(data[2:])) / 20
+			}
+		case recMergedCells:
+			all := rec.all()
+			if len(all) < 2 {
+				continue
+			}
+			count := int(le.Uint16(all))
+			for i := 0; i < count && 2+8*i+8 <= len(all); i++ {
+				m := all[2+8*i:]
+				sheet.Merges = append(sheet.Merges, workbook.Range{
+					First: workbook.Ref{Row: int(le.Uint16(m)), Col: int(le.Uint16(m[4:]))},
+					Last:  workbook.Ref{Row: int(le.Uint16(m[2:])), Col: int(le.Uint16(m[6:]))},
+				})
+			}
+		case recLeftMargin, recRightMargin, recTopMargin, recBottomMargin:
+			if len(data) < 8 {
+				continue
+			}
+			points := math.Float64frombits(le.Uint64(data)) * 72
+			switch rec.typ {
+			case recLeftMargin:
+				sheet.PageSetup.Margins.Left = points
+			case recRightMargin:
+				sheet.PageSetup.Margins.Right = points
+			case recTopMargin:
+				sheet.PageSetup.Margins.Top = points
+			case recBottomMargin:
+				sheet.PageSetup.Margins.Bottom = points
+			}
+		case recWsBool:
+			fitToPage = len(data) >= 2 && le.Uint16(data)&0x0100 != 0
+		case recSetup:
+			setup = data
+		}
+	}
+
+	applySetup(&sheet.PageSetup, setup, fitToPage)
+	return sheet, nil
+}
+
+func (p *parser) setCell(sheet *workbook.Sheet, data []byte, cell *workbook.Cell) {
+	cell.Style = p.style(int(le.Uint16(data[4:])))
+	sheet.SetCell(int(le.Uint16(data)), int(le.Uint16(data[2:])), cell)
+}
+
+// applySetup interprète l'enregistrement SETUP (format, échelle, orientation)
+func applySetup(ps *workbook.PageSetup, setup []byte, fitToPage bool) {
+	if len(setup) < 12 {
+		return
+	}
+	flags := le.Uint16(setup[10:])
+	// fNoPls : les paramètres d'imprimante ne sont pas renseignés
+	if flags&0x0004 == 0 {
+		if size := int(le.Uint16(setup)); size > 0 {
+			ps.PaperSize = size
+		}
+		if scale := int(le.Uint16(setup[2:])); scale > 0 {
+			ps.Scale = scale
+		}
+		if flags&0x0040 == 0 && flags&0x0002 == 0 {
+			ps.Orientation = workbook.Landscape
+		}
+	}
+	if fitToPage {
+		ps.FitToPage = true
+		ps.FitToWidth = int(le.Uint16(setup[6:]))
+		ps.FitToHeight = int(le.Uint16(setup[8:]))
+	}
+}
+
+// formulaResult décode la valeur en cache d'une formule
+func formulaResult(v []byte) *workbook.Cell {
+	if le.Uint16(v[6:]) != 0xFFFF {
+		return &workbook.Cell{Type: workbook.CellNumber, Num: math.Float64frombits(le.Uint64(v))}
+	}
+	switch v[0] {
+	case 0:
+		// Le texte est fourni par l'enregistrement STRING suivant
+		return &workbook.Cell{Type: workbook.CellString}
+	case 1:
+		return &workbook.Cell{Type: workbook.CellBool, Bool: v[2] != 0}
+	case 2:
+		return &workbook.Cell{Type: workbook.CellError, Str: errorText(v[2])}
+	}
+	return &workbook.Cell{Type: workbook.CellString}
+}
+
+func errorText(code byte) string {
+	switch code {
+	case 0x00:
+		return "#NUL!"
+	case 0x07:
+		return "#DIV/0!"
+	case 0x0F:
+		return "#VALEUR!"
+	case 0x17:
+		return "#REF!"
+	case 0x1D:
+		return "#NOM?"
+	case 0x24:
+		return "#NOMBRE!"
+	case 0x2A:
+		return "#N/A"
+	}
+	return "#ERREUR"
+}
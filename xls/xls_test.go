@@ -0,0 +1,657 @@
+package xls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fredon_to_pdf/workbook"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// sampleFile est le classeur fourni avec la demande de prise en charge des .xls
+var sampleFile = filepath.Join("..", "excel_files", "Andrieux V ( 2502 ) (1).xls")
+
+func TestReadSample(t *testing.T) {
+	wb, err := Open(sampleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, sheet := range wb.Sheets {
+		names = append(names, sheet.Name)
+	}
+	if strings.Join(names, ",") != "Feuil1,Feuil2,Feuil3" || wb.Date1904 {
+		t.Fatalf("feuilles = %v, calendrier 1904 = %v", names, wb.Date1904)
+	}
+	sheet := wb.Sheets[0]
+
+	strs := []struct {
+		ref  string
+		want string
+	}{
+		{"G4", "Saint-Germain les Belles, le"},
+		{"G9", "Mme ANDRIEUX Véronique"},
+		{"A14", "FACTURE N°"},
+		{"B17", "Fourniture et pose de volets roulants en alu blanc solaires , marque Profalux"},
+		{"I41", "TOTAL H.T."},
+		{"J17", ""}, // formule au résultat texte vide
+	}
+	for _, tt := range strs {
+		cell := sampleCell(t, sheet, tt.ref)
+		if cell.Type != workbook.CellString || cell.Str != tt.want {
+			t.Errorf("%s = %+v, attendu %q", tt.ref, cell, tt.want)
+		}
+	}
+
+	nums := []struct {
+		ref    string
+		want   float64
+		numFmt string
+	}{
+		{"C14", 2502, "General"}, // RK entier
+		{"I18", 735, "0.00"},     // RK entier
+		{"I44", 0.1, "0.0%"},     // RK décimal
+		{"J18", 1470, "0.00"},    // résultat de formule
+		{"J41", 3570, ""},        // résultat de formule, format monétaire
+		{"J47", 3927, ""},        // résultat de formule
+		{"D49", 25759.43139, ""}, // conversion en francs
+		{"I4", 44762, "dd/mm/yyyy"},
+	}
+	for _, tt := range nums {
+		cell := sampleCell(t, sheet, tt.ref)
+		if cell.Type != workbook.CellNumber || math.Abs(cell.Num-tt.want) > 1e-6 {
+			t.Errorf("%s = %+v, attendu %v", tt.ref, cell, tt.want)
+			continue
+		}
+		if tt.numFmt != "" && (cell.Style == nil || cell.Style.NumFmt != tt.numFmt) {
+			t.Errorf("%s : format %+v, attendu %q", tt.ref, cell.Style, tt.numFmt)
+		}
+	}
+	if date := workbook.SerialToTime(sampleCell(t, sheet, "I4").Num, wb.Date1904); !date.Equal(time.Date(2022, 7, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date de facture = %v", date)
+	}
+	if got := sampleCell(t, sheet, "J47").Text(wb.Date1904); !strings.Contains(got, "€") {
+		t.Errorf("NET A PAYER = %q, attendu un montant en euros", got)
+	}
+	if font := sampleCell(t, sheet, "G9").Style.Font; font.Name != "Arial" {
+		t.Errorf("police = %+v", font)
+	}
+
+	if len(sheet.Merges) != 50 {
+		t.Errorf("%d fusions, attendu 50", len(sheet.Merges))
+	}
+	for _, ref := range []string{"I4:J4", "G9:J9", "A46:E47", "H47:I49", "J41:J43"} {
+		if !hasMerge(sheet, ref) {
+			t.Errorf("fusion %s absente", ref)
+		}
+	}
+
+	if w := sheet.ColWidths[0]; math.Abs(w-8.85546875) > 1e-9 {
+		t.Errorf("largeur de la colonne A = %v", w)
+	}
+	if w := sheet.ColWidths[5]; math.Abs(w-1.42578125) > 1e-9 {
+		t.Errorf("largeur de la colonne F = %v", w)
+	}
+	ps := sheet.PageSetup
+	if ps.PaperSize != 9 || ps.Scale != 98 || ps.PrintArea == nil || ps.PrintArea.String() != "A1:J50" {
+		t.Errorf("mise en page = %+v", ps)
+	}
+	if math.Abs(ps.Margins.Left-28.3464567) > 1e-6 || math.Abs(ps.Margins.Bottom-14.1732283) > 1e-6 {
+		t.Errorf("marges = %+v", ps.Margins)
+	}
+
+	for _, empty := range wb.Sheets[1:] {
+		if len(empty.Cells) != 0 || empty.Hidden {
+			t.Errorf("%s : %d cellules, masquée = %v", empty.Name, len(empty.Cells), empty.Hidden)
+		}
+	}
+}
+
+func sampleCell(t *testing.T, sheet *workbook.Sheet, ref string) *workbook.Cell {
+	t.Helper()
+	r, err := workbook.ParseRef(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cell := sheet.Cell(r.Row, r.Col)
+	if cell == nil {
+		t.Fatalf("%s : cellule absente", ref)
+	}
+	return cell
+}
+
+func hasMerge(sheet *workbook.Sheet, ref string) bool {
+	for _, m := range sheet.Merges {
+		if m.String() == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// Construction de classeurs BIFF8 minimaux
+
+// biff encode un enregistrement BIFF
+func biff(typ uint16, parts ...[]byte) []byte {
+	data := bytes.Join(parts, nil)
+	out := binary.LittleEndian.AppendUint16(nil, typ)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
+
+func u16(v ...int) []byte {
+	var out []byte
+	for _, x := range v {
+		out = binary.LittleEndian.AppendUint16(out, uint16(x))
+	}
+	return out
+}
+
+func u32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func f64(v float64) []byte { return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)) }
+
+// latin1 encode une chaîne compressée (un octet par caractère)
+func latin1(s string) []byte {
+	var out []byte
+	for _, r := range s {
+		out = append(out, byte(r))
+	}
+	return out
+}
+
+// utf16le encode une chaîne non compressée (deux octets par caractère)
+func utf16le(s string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = binary.LittleEndian.AppendUint16(out, u)
+	}
+	return out
+}
+
+// bof renvoie l'enregistrement BOF du flux global ou d'une feuille
+func bof(typ int) []byte {
+	return biff(recBOF, u16(biff8Version, typ), make([]byte, 12))
+}
+
+// buildStream assemble le flux Workbook : les enregistrements globaux, une
+// feuille par élément de sheets, chacune précédée de son BOUNDSHEET
+func buildStream(globals [][]byte, sheets map[string][][]byte, order ...string) []byte {
+	boundSize := func(name string) int { return 4 + 8 + len(name) }
+	head := bof(0x0005)
+	for _, rec := range globals {
+		head = append(head, rec...)
+	}
+	offset := len(head) + len(biff(recEOF))
+	for _, name := range order {
+		offset += boundSize(name)
+	}
+
+	var bounds, bodies []byte
+	for _, name := range order {
+		bounds = append(bounds, biff(recBoundSheet, u32(uint32(offset+len(bodies))), []byte{0, sheetTypeWork, byte(len(name)), 0}, latin1(name))...)
+		bodies = append(bodies, bof(bofWorksheet)...)
+		for _, rec := range sheets[name] {
+			bodies = append(bodies, rec...)
+		}
+		bodies = append(bodies, biff(recEOF)...)
+	}
+	stream := append(head, bounds...)
+	stream = append(stream, biff(recEOF)...)
+	return append(stream, bodies...)
+}
+
+const testSector = 512
+
+// pad complète b jusqu'à un multiple de n octets
+func pad(b []byte, n int) []byte {
+	b = append([]byte(nil), b...)
+	if r := len(b) % n; r != 0 {
+		b = append(b, make([]byte, n-r)...)
+	}
+	return b
+}
+
+// chain ajoute à table une chaîne de count entrées consécutives à partir de start
+func chain(table []uint32, start, count int) []uint32 {
+	for i := 0; i < count; i++ {
+		next := uint32(start + i + 1)
+		if i == count-1 {
+			next = secEndOfChain
+		}
+		table = append(table, next)
+	}
+	return table
+}
+
+// buildCFB place le flux Workbook dans un fichier composé version 3 : secteurs
+// FAT, répertoire, puis données. Un flux de moins de 4096 octets est rangé dans
+// le mini-flux, précédé de sa MiniFAT, comme le fait Excel.
+func buildCFB(stream []byte) []byte {
+	mini := len(stream) < 4096
+	var body []byte // secteurs suivant le répertoire
+	var miniFat []uint32
+	if mini {
+		miniFat = chain(nil, 0, (len(stream)+63)/64)
+		var table []byte
+		for _, v := range miniFat {
+			table = append(table, u32(v)...)
+		}
+		body = append(pad(table, testSector), pad(stream, testSector)...)
+	} else {
+		body = pad(stream, testSector)
+	}
+	dataSectors := len(body) / testSector
+
+	// Nombre de secteurs FAT nécessaires pour décrire tous les secteurs
+	fatSectors := 1
+	for fatSectors*testSector/4 < fatSectors+1+dataSectors {
+		fatSectors++
+	}
+	dirSector := fatSectors
+	first := dirSector + 1 // premier secteur de données
+
+	var fat []uint32
+	for i := 0; i < fatSectors; i++ {
+		fat = append(fat, 0xFFFFFFFD)
+	}
+	fat = append(fat, secEndOfChain) // répertoire
+	var rootStart, rootSize, miniFatStart, streamStart uint32 = secEndOfChain, 0, secEndOfChain, uint32(first)
+	if mini {
+		fat = chain(fat, first, 1)
+		fat = chain(fat, first+1, dataSectors-1)
+		rootStart, rootSize, miniFatStart, streamStart = uint32(first+1), uint32(len(miniFat)*64), uint32(first), 0
+	} else {
+		fat = chain(fat, first, dataSectors)
+	}
+	var fatBytes []byte
+	for _, v := range fat {
+		fatBytes = append(fatBytes, u32(v)...)
+	}
+	for len(fatBytes) < fatSectors*testSector {
+		fatBytes = append(fatBytes, u32(secFree)...)
+	}
+
+	header := make([]byte, cfbHeaderSize)
+	copy(header, cfbSignature)
+	copy(header[0x18:], u16(0x3E, 3, 0xFFFE, 9, 6))
+	copy(header[0x2C:], u32(uint32(fatSectors)))
+	copy(header[0x30:], u32(uint32(dirSector)))
+	copy(header[0x38:], u32(4096)) // taille limite des mini-flux
+	copy(header[0x3C:], u32(miniFatStart))
+	if mini {
+		copy(header[0x40:], u32(1))
+	}
+	copy(header[0x44:], u32(secEndOfChain)) // pas de DIFAT chaînée
+	for i := 0; i < 109; i++ {
+		id := uint32(secFree)
+		if i < fatSectors {
+			id = uint32(i)
+		}
+		copy(header[0x4C+4*i:], u32(id))
+	}
+
+	dir := append(dirEntryBytes("Root Entry", objRoot, rootStart, rootSize), dirEntryBytes("Workbook", objStream, streamStart, uint32(len(stream)))...)
+
+	out := append(header, fatBytes...)
+	out = append(out, pad(dir, testSector)...)
+	return append(out, body...)
+}
+
+func dirEntryBytes(name string, typ byte, start, size uint32) []byte {
+	e := make([]byte, cfbDirEntry)
+	copy(e, utf16le(name))
+	copy(e[0x40:], u16((len(name)+1)*2))
+	e[0x42] = typ
+	copy(e[0x44:], u32(secFree)) // frères et enfant
+	copy(e[0x48:], u32(secFree))
+	copy(e[0x4C:], u32(secFree))
+	copy(e[0x74:], u32(start))
+	copy(e[0x78:], u32(size))
+	return e
+}
+
+// testGlobals renvoie une police, un format personnalisé et trois XF : le style
+// par défaut, un style monétaire aligné à droite, renvoyé à la ligne et bordé en
+// bas, et le format de date intégré 14
+func testGlobals() [][]byte {
+	font := biff(recFont, u16(220, 0, 0x7FFF, 700, 0), []byte{1, 0, 0, 0, 5, 0}, latin1("Arial"))
+	format := biff(recFormat, u16(164, 6), []byte{1}, utf16le("0.00 €"))
+	xf := func(fmtID int, align byte, border uint32) []byte {
+		data := u16(0, fmtID, 0)
+		data = append(data, align, 0, 0, 0)
+		data = append(data, u32(border)...)
+		data = append(data, u32(0)...)
+		return biff(recXF, data, u16(0))
+	}
+	return [][]byte{font, format, xf(0, 0, 0), xf(164, 0x2B, 0x1000), xf(14, 0, 0)}
+}
+
+// sst encode une table de chaînes compressées, sans CONTINUE
+func sst(strs ...string) []byte {
+	data := append(u32(uint32(len(strs))), u32(uint32(len(strs)))...)
+	for _, s := range strs {
+		data = append(data, u16(len(s))...)
+		data = append(data, 0)
+		data = append(data, latin1(s)...)
+	}
+	return biff(recSST, data)
+}
+
+func cellHeader(row, col, xf int) []byte { return u16(row, col, xf) }
+
+func rk(v uint32) []byte { return u32(v) }
+
+func TestReadSynthetic(t *testing.T) {
+	// Chaîne répartie sur SST et CONTINUE : la suite, non compressée, est
+	// précédée d'un nouvel octet d'options
+	long := "Désignation des travaux réalisés"
+	split := 12
+	sstData := append(u32(2), u32(2)...)
+	sstData = append(sstData, u16(len("Client"))...)
+	sstData = append(sstData, 0)
+	sstData = append(sstData, latin1("Client")...)
+	sstData = append(sstData, u16(len([]rune(long)))...)
+	sstData = append(sstData, 0)
+	sstData = append(sstData, latin1(string([]rune(long)[:split]))...)
+	cont := append([]byte{0x01}, utf16le(string([]rune(long)[split:]))...)
+
+	globals := append(testGlobals(), biff(recSST, sstData), biff(recContinue, cont))
+
+	formulaStr := append(cellHeader(6, 0, 0), 0, 0, 0, 0, 0, 0, 0xFF, 0xFF)
+	formulaStr = append(formulaStr, make([]byte, 8)...)
+	formulaBool := append(cellHeader(6, 1, 0), 1, 0, 1, 0, 0, 0, 0xFF, 0xFF)
+	formulaBool = append(formulaBool, make([]byte, 8)...)
+	formulaErr := append(cellHeader(6, 2, 0), 2, 0, 0x07, 0, 0, 0, 0xFF, 0xFF)
+	formulaErr = append(formulaErr, make([]byte, 8)...)
+	formulaNum := append(cellHeader(6, 3, 1), f64(1234.5)...)
+	formulaNum = append(formulaNum, make([]byte, 8)...)
+
+	sheet := [][]byte{
+		biff(recLabelSST, cellHeader(0, 0, 0), u32(0)),
+		biff(recLabelSST, cellHeader(0, 1, 0), u32(1)),
+		biff(recLabel, cellHeader(1, 0, 0), u16(4), []byte{1}, utf16le("Été€")),
+		biff(recRK, cellHeader(2, 0, 0), rk(42<<2|0x02)),                        // entier
+		biff(recRK, cellHeader(2, 1, 1), rk(1234<<2|0x03)),                      // entier centièmes
+		biff(recRK, cellHeader(2, 2, 0), rk(uint32(math.Float64bits(0.5)>>32))), // flottant tronqué
+		biff(recRK, cellHeader(2, 3, 2), rk(45000<<2|0x02)),                     // date, format 14
+		biff(recMulRK, u16(3, 1), u16(0), rk(1<<2|0x02), u16(1), rk(250<<2|0x03), u16(0), rk(3<<2|0x02), u16(3)),
+		biff(recNumber, cellHeader(4, 0, 1), f64(-12.75)),
+		biff(recBoolErr, cellHeader(5, 0, 0), []byte{1, 0}),
+		biff(recBoolErr, cellHeader(5, 1, 0), []byte{0x2A, 1}),
+		biff(recFormula, formulaStr),
+		biff(recString, u16(3), []byte{0}, latin1("TTC")),
+		biff(recFormula, formulaBool),
+		biff(recFormula, formulaErr),
+		biff(recFormula, formulaNum),
+		biff(recColInfo, u16(1, 2, 20*256, 0, 0x0001, 0)),
+		biff(recMergedCells, u16(2, 0, 0, 0, 1, 7, 8, 2, 4)),
+	}
+	stream := buildStream(globals, map[string][][]byte{"Facture": sheet, "Vide": nil}, "Facture", "Vide")
+
+	for _, size := range []struct {
+		name string
+		pad  int
+	}{{"mini-flux", 0}, {"flux ordinaire", 5000}} {
+		t.Run(size.name, func(t *testing.T) {
+			// Les octets ajoutés après le dernier EOF sont ignorés
+			data := buildCFB(append(append([]byte(nil), stream...), make([]byte, size.pad)...))
+			wb, err := Read(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(wb.Sheets) != 2 || wb.Sheets[0].Name != "Facture" || wb.Sheets[1].Name != "Vide" {
+				t.Fatalf("feuilles = %+v", wb.Sheets)
+			}
+			s := wb.Sheets[0]
+
+			strs := map[string]string{"A1": "Client", "B1": long, "A2": "Été€", "A7": "TTC"}
+			for ref, want := range strs {
+				if cell := sampleCell(t, s, ref); cell.Type != workbook.CellString || cell.Str != want {
+					t.Errorf("%s = %+v, attendu %q", ref, cell, want)
+				}
+			}
+			nums := map[string]float64{"A3": 42, "B3": 12.34, "C3": 0.5, "D3": 45000, "B4": 1, "C4": 2.5, "D4": 3, "A5": -12.75, "D7": 1234.5}
+			for ref, want := range nums {
+				if cell := sampleCell(t, s, ref); cell.Type != workbook.CellNumber || cell.Num != want {
+					t.Errorf("%s = %+v, attendu %v", ref, cell, want)
+				}
+			}
+			if cell := sampleCell(t, s, "A6"); cell.Type != workbook.CellBool || !cell.Bool {
+				t.Errorf("A6 = %+v, attendu VRAI", cell)
+			}
+			if cell := sampleCell(t, s, "B7"); cell.Type != workbook.CellBool || !cell.Bool {
+				t.Errorf("B7 = %+v, attendu VRAI", cell)
+			}
+			if cell := sampleCell(t, s, "B6"); cell.Type != workbook.CellError || cell.Str != "#N/A" {
+				t.Errorf("B6 = %+v, attendu #N/A", cell)
+			}
+			if cell := sampleCell(t, s, "C7"); cell.Type != workbook.CellError || cell.Str != "#DIV/0!" {
+				t.Errorf("C7 = %+v, attendu #DIV/0!", cell)
+			}
+
+			// Styles : police, format personnalisé, alignement, bordure, format intégré
+			money := sampleCell(t, s, "B3").Style
+			if money == nil || money.NumFmt != "0.00 €" || money.HAlign != workbook.AlignRight || !money.Wrap ||
+				money.Border.Bottom.Style != workbook.BorderThin || money.Font.Name != "Arial" || !money.Font.Bold || money.Font.Size != 11 {
+				t.Errorf("style monétaire = %+v", money)
+			}
+			if date := sampleCell(t, s, "D3").Style; date == nil || date.NumFmt != "dd/mm/yyyy" {
+				t.Errorf("style de date = %+v", date)
+			}
+
+			if !s.HiddenCols[1] || !s.HiddenCols[2] || s.ColWidths[2] != 20 || s.HiddenCols[3] {
+				t.Errorf("colonnes : largeurs %v, masquées %v", s.ColWidths, s.HiddenCols)
+			}
+			if len(s.Merges) != 2 || s.Merges[0].String() != "A1:B1" || s.Merges[1].String() != "C8:E9" {
+				t.Errorf("fusions = %v", s.Merges)
+			}
+		})
+	}
+}
+
+func TestDecodeRK(t *testing.T) {
+	tests := []struct {
+		rk   uint32
+		want float64
+	}{
+		{42<<2 | 0x02, 42},
+		{0xFFFFFFFC | 0x02, -1},
+		{1234<<2 | 0x03, 12.34},
+		{uint32(math.Float64bits(1.5) >> 32), 1.5},
+		{uint32(math.Float64bits(100.25)>>32) | 0x01, 1.0025},
+	}
+	for _, tt := range tests {
+		if got := decodeRK(tt.rk); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("decodeRK(0x%08X) = %v, attendu %v", tt.rk, got, tt.want)
+		}
+	}
+}
+
+func TestReadCorrupted(t *testing.T) {
+	sheet := [][]byte{biff(recLabelSST, cellHeader(0, 0, 0), u32(0))}
+	valid := buildStream(append(testGlobals(), sst("Client")), map[string][][]byte{"Feuil1": sheet}, "Feuil1")
+	if _, err := Read(buildCFB(valid)); err != nil {
+		t.Fatalf("classeur de référence illisible : %v", err)
+	}
+	large := buildCFB(append(append([]byte(nil), valid...), make([]byte, 5000)...))
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		wantErr string
+	}{
+		{"signature absente", func() []byte { return []byte("PK\x03\x04 pas un fichier composé") }, "signature"},
+		{
+			name: "taille de secteur invalide",
+			data: func() []byte {
+				data := buildCFB(valid)
+				copy(data[0x1E:], u16(20))
+				return data
+			},
+			wantErr: "en-tête",
+		},
+		{
+			name: "chaîne de secteurs hors de la FAT",
+			data: func() []byte {
+				data := append([]byte(nil), large...)
+				copy(data[cfbHeaderSize+4*2:], u32(0x00FFFFFF)) // successeur du premier secteur du flux
+				return data
+			},
+			wantErr: "chaîne de secteurs corrompue",
+		},
+		{
+			name: "fichier tronqué",
+			data: func() []byte {
+				return large[:len(large)-3*testSector]
+			},
+			wantErr: "hors du fichier",
+		},
+		{
+			name: "chaîne MiniFAT corrompue",
+			data: func() []byte {
+				data := buildCFB(valid)
+				copy(data[cfbHeaderSize+2*testSector:], u32(0x00FFFFFF)) // MiniFAT
+				return data
+			},
+			wantErr: "MiniFAT corrompue",
+		},
+		{
+			name: "flux Workbook absent",
+			data: func() []byte {
+				data := buildCFB(valid)
+				copy(data[cfbHeaderSize+testSector+cfbDirEntry:], utf16le("Autre..."))
+				return data
+			},
+			wantErr: "absent",
+		},
+		{
+			name: "enregistrement tronqué",
+			data: func() []byte {
+				// BOF, puis un FONT annonçant 200 octets quand le flux n'en contient que 20
+				short := append(bof(0x0005), u16(recFont, 200)...)
+				return buildCFB(append(short, make([]byte, 20)...))
+			},
+			wantErr: "tronqué",
+		},
+		{
+			name: "SST plus courte qu'annoncée",
+			data: func() []byte {
+				data := append(u32(3), u32(3)...)
+				data = append(data, u16(6)...)
+				data = append(data, 0)
+				data = append(data, latin1("Client")...)
+				return buildCFB(buildStream([][]byte{biff(recSST, data)}, nil))
+			},
+			wantErr: "table des chaînes",
+		},
+		{
+			name: "nombre de chaînes SST aberrant",
+			data: func() []byte {
+				return buildCFB(buildStream([][]byte{biff(recSST, u32(0xFFFFFFFF), u32(0xFFFFFFFF))}, nil))
+			},
+			wantErr: "table des chaînes",
+		},
+		{
+			name: "chaîne SST coupée avant son CONTINUE",
+			data: func() []byte {
+				data := append(u32(1), u32(1)...)
+				data = append(data, u16(10)...)
+				data = append(data, 0)
+				data = append(data, latin1("Clie")...)
+				return buildCFB(buildStream([][]byte{biff(recSST, data), biff(recContinue, []byte{0x01, 'n'})}, nil))
+			},
+			wantErr: "caractère coupé",
+		},
+		{
+			name: "index de chaîne partagée invalide",
+			data: func() []byte {
+				bad := [][]byte{biff(recLabelSST, cellHeader(0, 0, 0), u32(7))}
+				return buildCFB(buildStream(append(testGlobals(), sst("Client")), map[string][][]byte{"Feuil1": bad}, "Feuil1"))
+			},
+			wantErr: "index de chaîne partagée",
+		},
+		{
+			name: "classeur protégé",
+			data: func() []byte {
+				return buildCFB(buildStream([][]byte{biff(recFilePass, u16(0))}, nil))
+			},
+			wantErr: "mot de passe",
+		},
+		{
+			name: "version BIFF5",
+			data: func() []byte {
+				return buildCFB(append(biff(recBOF, u16(0x0500, 0x0005), make([]byte, 4)), biff(recEOF)...))
+			},
+			wantErr: "version BIFF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(tt.data())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("erreur = %v, attendu %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestReadTruncatedStream vérifie qu'un flux Workbook tronqué à n'importe quelle
+// position, ou altéré, donne une erreur ou un classeur partiel, jamais une panique
+func TestReadTruncatedStream(t *testing.T) {
+	data, err := os.ReadFile(sampleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := openCompoundFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := cf.stream("Workbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Troncature au milieu de l'en-tête puis des données de chaque enregistrement
+	r := &recordReader{stream: stream}
+	for {
+		start := r.pos
+		rec, err := r.raw()
+		if err != nil || rec == nil {
+			break
+		}
+		read(t, buildCFB(stream[:start+2]))
+		read(t, buildCFB(stream[:start+4+len(rec.data)/2]))
+	}
+
+	// Altération d'un octet sur deux cents, par pas successifs
+	for offset := 0; offset < 200; offset += 13 {
+		corrupted := append([]byte(nil), stream...)
+		for i := offset; i < len(corrupted); i += 200 {
+			corrupted[i] ^= 0xA5
+		}
+		read(t, buildCFB(corrupted))
+	}
+
+	// Altération de l'en-tête, de la FAT et du répertoire du fichier composé
+	for off := 0; off < cfbHeaderSize+2*cf.sectorSize; off += 4 {
+		corrupted := append([]byte(nil), data...)
+		copy(corrupted[off:], u32(0x7FFFFF01))
+		read(t, corrupted)
+	}
+}
+
+func read(t *testing.T, data []byte) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panique : %v", r)
+		}
+	}()
+	Read(data)
+}
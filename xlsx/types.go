@@ -0,0 +1,222 @@
+package xlsx
+
+import (
+	"fredon_to_pdf/workbook"
+	"strconv"
+	"strings"
+)
+
+// Structures de désérialisation des parties SpreadsheetML utilisées
+
+type xlsxRelationships struct {
+	Relationships []xlsxRelationship `xml:"Relationship"`
+}
+
+type xlsxRelationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets       []xlsxSheetRef    `xml:"sheets>sheet"`
+	DefinedNames []xlsxDefinedName `xml:"definedNames>definedName"`
+}
+
+type xlsxSheetRef struct {
+	Name  string `xml:"name,attr"`
+	State string `xml:"state,attr"`
+	RelID string `xml:"id,attr"`
+}
+
+type xlsxDefinedName struct {
+	Name         string `xml:"name,attr"`
+	LocalSheetID *int   `xml:"localSheetId,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type xlsxSST struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+// xlsxRichText représente un texte simple (<t>) ou enrichi (<r><t>)
+type xlsxRichText struct {
+	T    *string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt xlsxRichText) String() string {
+	if rt.T != nil {
+		return *rt.T
+	}
+	var b strings.Builder
+	for _, r := range rt.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxTheme struct {
+	Elements struct {
+		ColorScheme struct {
+			Dk1      xlsxThemeColor `xml:"dk1"`
+			Lt1      xlsxThemeColor `xml:"lt1"`
+			Dk2      xlsxThemeColor `xml:"dk2"`
+			Lt2      xlsxThemeColor `xml:"lt2"`
+			Accent1  xlsxThemeColor `xml:"accent1"`
+			Accent2  xlsxThemeColor `xml:"accent2"`
+			Accent3  xlsxThemeColor `xml:"accent3"`
+			Accent4  xlsxThemeColor `xml:"accent4"`
+			Accent5  xlsxThemeColor `xml:"accent5"`
+			Accent6  xlsxThemeColor `xml:"accent6"`
+			Hlink    xlsxThemeColor `xml:"hlink"`
+			FolHlink xlsxThemeColor `xml:"folHlink"`
+		} `xml:"clrScheme"`
+	} `xml:"themeElements"`
+}
+
+type xlsxThemeColor struct {
+	SRGB *struct {
+		Val string `xml:"val,attr"`
+	} `xml:"srgbClr"`
+	Sys *struct {
+		LastClr string `xml:"lastClr,attr"`
+	} `xml:"sysClr"`
+}
+
+func (c xlsxThemeColor) Color() workbook.Color {
+	hex := ""
+	switch {
+	case c.SRGB != nil:
+		hex = c.SRGB.Val
+	case c.Sys != nil:
+		hex = c.Sys.LastClr
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return workbook.Black
+	}
+	return workbook.Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}
+}
+
+type xlsxStyleSheet struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	Fonts   []xlsxFont   `xml:"fonts>font"`
+	Fills   []xlsxFill   `xml:"fills>fill"`
+	Borders []xlsxBorder `xml:"borders>border"`
+	CellXfs []xlsxXf     `xml:"cellXfs>xf"`
+}
+
+type xlsxVal struct {
+	Val string `xml:"val,attr"`
+}
+
+type xlsxFont struct {
+	Bold      *xlsxVal   `xml:"b"`
+	Italic    *xlsxVal   `xml:"i"`
+	Underline *xlsxVal   `xml:"u"`
+	Color     *xlsxColor `xml:"color"`
+	Size      struct {
+		Val float64 `xml:"val,attr"`
+	} `xml:"sz"`
+	Name xlsxVal `xml:"name"`
+}
+
+type xlsxColor struct {
+	Auto    string  `xml:"auto,attr"`
+	RGB     string  `xml:"rgb,attr"`
+	Theme   *int    `xml:"theme,attr"`
+	Indexed *int    `xml:"indexed,attr"`
+	Tint    float64 `xml:"tint,attr"`
+}
+
+type xlsxFill struct {
+	Pattern struct {
+		Type    string     `xml:"patternType,attr"`
+		FgColor *xlsxColor `xml:"fgColor"`
+		BgColor *xlsxColor `xml:"bgColor"`
+	} `xml:"patternFill"`
+}
+
+type xlsxBorder struct {
+	Left   xlsxBorderEdge `xml:"left"`
+	Right  xlsxBorderEdge `xml:"right"`
+	Top    xlsxBorderEdge `xml:"top"`
+	Bottom xlsxBorderEdge `xml:"bottom"`
+}
+
+type xlsxBorderEdge struct {
+	Style string     `xml:"style,attr"`
+	Color *xlsxColor `xml:"color"`
+}
+
+type xlsxXf struct {
+	NumFmtID  int `xml:"numFmtId,attr"`
+	FontID    int `xml:"fontId,attr"`
+	FillID    int `xml:"fillId,attr"`
+	BorderID  int `xml:"borderId,attr"`
+	Alignment *struct {
+		Horizontal string `xml:"horizontal,attr"`
+		Vertical   string `xml:"vertical,attr"`
+		WrapText   string `xml:"wrapText,attr"`
+	} `xml:"alignment"`
+}
+
+type xlsxWorksheet struct {
+	SheetPr *struct {
+		PageSetUpPr *struct {
+			FitToPage bool `xml:"fitToPage,attr"`
+		} `xml:"pageSetUpPr"`
+	} `xml:"sheetPr"`
+	FormatPr struct {
+		BaseColWidth     float64 `xml:"baseColWidth,attr"`
+		DefaultColWidth  float64 `xml:"defaultColWidth,attr"`
+		DefaultRowHeight float64 `xml:"defaultRowHeight,attr"`
+	} `xml:"sheetFormatPr"`
+	Cols []struct {
+		Min    int     `xml:"min,attr"`
+		Max    int     `xml:"max,attr"`
+		Width  float64 `xml:"width,attr"`
+		Hidden bool    `xml:"hidden,attr"`
+	} `xml:"cols>col"`
+	Rows       []xlsxRow `xml:"sheetData>row"`
+	MergeCells []struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"mergeCells>mergeCell"`
+	Margins *struct {
+		Left   float64 `xml:"left,attr"`
+		Right  float64 `xml:"right,attr"`
+		Top    float64 `xml:"top,attr"`
+		Bottom float64 `xml:"bottom,attr"`
+	} `xml:"pageMargins"`
+	PageSetup *struct {
+		Orientation string `xml:"orientation,attr"`
+		PaperSize   int    `xml:"paperSize,attr"`
+		Scale       int    `xml:"scale,attr"`
+		FitToWidth  *int   `xml:"fitToWidth,attr"`
+		FitToHeight *int   `xml:"fitToHeight,attr"`
+	} `xml:"pageSetup"`
+}
+
+type xlsxRow struct {
+	R      int        `xml:"r,attr"`
+	Ht     float64    `xml:"ht,attr"`
+	Hidden bool       `xml:"hidden,attr"`
+	Cells  []xlsxCell `xml:"c"`
+}
+
+type xlsxCell struct {
+	R      string        `xml:"r,attr"`
+	S      int           `xml:"s,attr"`
+	T      string        `xml:"t,attr"`
+	V      string        `xml:"v"`
+	Inline *xlsxRichText `xml:"is"`
+}
@@ -0,0 +1,489 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"fredon_to_pdf/workbook"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	relTypeOfficeDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+	relTypeSharedStrings  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings"
+	relTypeStyles         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"
+	relTypeTheme          = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/theme"
+	printAreaName         = "_xlnm.Print_Area"
+)
+
+// reader regroupe l'archive OOXML et les parties partagées entre les feuilles
+type reader struct {
+	zip     *zip.Reader
+	strings []string
+	styles  []*workbook.Style
+	theme   []workbook.Color
+}
+
+// Open charge un classeur .xlsx en mémoire
+func Open(filePath string) (*workbook.Workbook, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir l'archive xlsx : %v", err)
+	}
+	defer zr.Close()
+
+	return Read(&zr.Reader)
+}
+
+// Read charge un classeur à partir d'une archive OOXML déjà ouverte
+func Read(zr *zip.Reader) (*workbook.Workbook, error) {
+	r := &reader{zip: zr}
+
+	// Localisation de la partie principale via les relations du paquet
+	workbookPath := "xl/workbook.xml"
+	if rels, err := r.readRels("_rels/.rels", ""); err == nil {
+		for _, rel := range rels {
+			if rel.Type == relTypeOfficeDocument {
+				workbookPath = rel.Target
+			}
+		}
+	}
+
+	var wbXML xlsxWorkbook
+	if err := r.decode(workbookPath, &wbXML); err != nil {
+		return nil, err
+	}
+
+	relsPath := path.Join(path.Dir(workbookPath), "_rels", path.Base(workbookPath)+".rels")
+	rels, err := r.readRels(relsPath, path.Dir(workbookPath))
+	if err != nil {
+		return nil, err
+	}
+
+	// Les thèmes doivent être chargés avant les styles qui y font référence
+	for _, rel := range rels {
+		if rel.Type == relTypeTheme {
+			if err := r.loadTheme(rel.Target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, rel := range rels {
+		switch rel.Type {
+		case relTypeSharedStrings:
+			if err := r.loadSharedStrings(rel.Target); err != nil {
+				return nil, err
+			}
+		case relTypeStyles:
+			if err := r.loadStyles(rel.Target); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	wb := &workbook.Workbook{Date1904: wbXML.Properties.Date1904}
+	for i, s := range wbXML.Sheets {
+		target := ""
+		for _, rel := range rels {
+			if rel.ID == s.RelID {
+				target = rel.Target
+			}
+		}
+		if target == "" {
+			return nil, fmt.Errorf("feuille %q introuvable dans l'archive", s.Name)
+		}
+
+		sheet, err := r.readSheet(s.Name, target)
+		if err != nil {
+			return nil, fmt.Errorf("erreur de lecture de la feuille %q : %v", s.Name, err)
+		}
+		sheet.Hidden = s.State == "hidden" || s.State == "veryHidden"
+
+		// Zone d'impression définie au niveau du classeur
+		for _, dn := range wbXML.DefinedNames {
+			if dn.Name == printAreaName && dn.LocalSheetID != nil && *dn.LocalSheetID == i {
+				// Seule la première zone est retenue lorsque plusieurs sont définies
+				area := strings.Split(dn.Value, ",")[0]
+				if rng, err := workbook.ParseRange(area); err == nil {
+					sheet.PageSetup.PrintArea = &rng
+				}
+			}
+		}
+
+		wb.Sheets = append(wb.Sheets, sheet)
+	}
+
+	return wb, nil
+}
+
+func (r *reader) open(name string) (io.ReadCloser, error) {
+	name = strings.TrimPrefix(name, "/")
+	for _, f := range r.zip.File {
+		if strings.EqualFold(f.Name, name) {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("partie %s absente de l'archive", name)
+}
+
+func (r *reader) decode(name string, v any) error {
+	rc, err := r.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("impossible de lire %s : %v", name, err)
+	}
+	return nil
+}
+
+// readRels lit un fichier de relations et résout les cibles relativement à baseDir
+func (r *reader) readRels(name, baseDir string) ([]xlsxRelationship, error) {
+	var rels xlsxRelationships
+	if err := r.decode(name, &rels); err != nil {
+		return nil, err
+	}
+	for i, rel := range rels.Relationships {
+		if strings.HasPrefix(rel.Target, "/") {
+			rels.Relationships[i].Target = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			rels.Relationships[i].Target = path.Join(baseDir, rel.Target)
+		}
+	}
+	return rels.Relationships, nil
+}
+
+func (r *reader) loadSharedStrings(name string) error {
+	var sst xlsxSST
+	if err := r.decode(name, &sst); err != nil {
+		return err
+	}
+	r.strings = make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		r.strings[i] = si.String()
+	}
+	return nil
+}
+
+func (r *reader) loadTheme(name string) error {
+	var theme xlsxTheme
+	if err := r.decode(name, &theme); err != nil {
+		return err
+	}
+	s := theme.Elements.ColorScheme
+	// Ordre des index de thème utilisé par les styles : lt1, dk1, lt2, dk2, accents, liens
+	for _, c := range []xlsxThemeColor{s.Lt1, s.Dk1, s.Lt2, s.Dk2, s.Accent1, s.Accent2, s.Accent3, s.Accent4, s.Accent5, s.Accent6, s.Hlink, s.FolHlink} {
+		r.theme = append(r.theme, c.Color())
+	}
+	return nil
+}
+
+func (r *reader) loadStyles(name string) error {
+	var ss xlsxStyleSheet
+	if err := r.decode(name, &ss); err != nil {
+		return err
+	}
+
+	numFmts := make(map[int]string)
+	for _, nf := range ss.NumFmts {
+		numFmts[nf.ID] = nf.Code
+	}
+
+	for _, xf := range ss.CellXfs {
+		style := &workbook.Style{Font: workbook.DefaultFont, NumFmt: "General"}
+
+		if code, ok := numFmts[xf.NumFmtID]; ok {
+			style.NumFmt = code
+		} else if code, ok := workbook.BuiltinFormat(xf.NumFmtID); ok {
+			style.NumFmt = code
+		}
+
+		if xf.FontID < len(ss.Fonts) {
+			f := ss.Fonts[xf.FontID]
+			style.Font = workbook.Font{
+				Name:      f.Name.Val,
+				Size:      f.Size.Val,
+				Bold:      f.Bold != nil && f.Bold.Val != "0" && f.Bold.Val != "false",
+				Italic:    f.Italic != nil && f.Italic.Val != "0" && f.Italic.Val != "false",
+				Underline: f.Underline != nil && f.Underline.Val != "none",
+				Color:     workbook.Black,
+			}
+			if style.Font.Size == 0 {
+				style.Font.Size = workbook.DefaultFont.Size
+			}
+			if c, ok := r.color(f.Color); ok {
+				style.Font.Color = c
+			}
+		}
+
+		if xf.FillID < len(ss.Fills) {
+			pf := ss.Fills[xf.FillID].Pattern
+			if pf.Type != "" && pf.Type != "none" {
+				if c, ok := r.color(pf.FgColor); ok {
+					style.Fill = &c
+				} else if c, ok := r.color(pf.BgColor); ok {
+					style.Fill = &c
+				}
+			}
+		}
+
+		if xf.BorderID < len(ss.Borders) {
+			b := ss.Borders[xf.BorderID]
+			style.Border = workbook.Border{
+				Left:   r.edge(b.Left),
+				Right:  r.edge(b.Right),
+				Top:    r.edge(b.Top),
+				Bottom: r.edge(b.Bottom),
+			}
+		}
+
+		if a := xf.Alignment; a != nil {
+			style.HAlign = parseHAlign(a.Horizontal)
+			style.VAlign = parseVAlign(a.Vertical)
+			style.Wrap = a.WrapText == "1" || a.WrapText == "true"
+		}
+
+		r.styles = append(r.styles, style)
+	}
+	return nil
+}
+
+func (r *reader) edge(e xlsxBorderEdge) workbook.Edge {
+	edge := workbook.Edge{Style: parseBorderStyle(e.Style), Color: workbook.Black}
+	if c, ok := r.color(e.Color); ok {
+		edge.Color = c
+	}
+	return edge
+}
+
+// color résout une couleur OOXML (rgb, indexée ou de thème)
+func (r *reader) color(c *xlsxColor) (workbook.Color, bool) {
+	if c == nil || c.Auto == "1" || c.Auto == "true" {
+		return workbook.Color{}, false
+	}
+	var color workbook.Color
+	switch {
+	case c.RGB != "":
+		v, err := strconv.ParseUint(c.RGB, 16, 32)
+		if err != nil {
+			return workbook.Color{}, false
+		}
+		color = workbook.Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}
+	case c.Theme != nil:
+		if *c.Theme >= len(r.theme) {
+			return workbook.Color{}, false
+		}
+		color = r.theme[*c.Theme]
+	case c.Indexed != nil:
+		ic, ok := workbook.IndexedColor(*c.Indexed)
+		if !ok {
+			return workbook.Color{}, false
+		}
+		color = ic
+	default:
+		return workbook.Color{}, false
+	}
+	return color.Tint(c.Tint), true
+}
+
+func (r *reader) readSheet(name, target string) (*workbook.Sheet, error) {
+	var ws xlsxWorksheet
+	if err := r.decode(target, &ws); err != nil {
+		return nil, err
+	}
+
+	sheet := workbook.NewSheet(name)
+	if ws.FormatPr.DefaultColWidth > 0 {
+		sheet.DefaultColWidth = ws.FormatPr.DefaultColWidth
+	} else if ws.FormatPr.BaseColWidth > 0 {
+		sheet.DefaultColWidth = workbook.DefaultColWidthFromChars(ws.FormatPr.BaseColWidth)
+	}
+	if ws.FormatPr.DefaultRowHeight > 0 {
+		sheet.DefaultRowHeight = ws.FormatPr.DefaultRowHeight
+	}
+
+	for _, col := range ws.Cols {
+		for c := col.Min - 1; c < col.Max && c < 16384; c++ {
+			if col.Width > 0 {
+				sheet.ColWidths[c] = col.Width
+			}
+			if col.Hidden {
+				sheet.HiddenCols[c] = true
+			}
+		}
+	}
+
+	nextRow := 0
+	for _, row := range ws.Rows {
+		rowIdx := nextRow
+		if row.R > 0 {
+			rowIdx = row.R - 1
+		}
+		nextRow = rowIdx + 1
+
+		if row.Ht > 0 {
+			sheet.RowHeights[rowIdx] = row.Ht
+		}
+		if row.Hidden {
+			sheet.HiddenRows[rowIdx] = true
+		}
+
+		nextCol := 0
+		for _, c := range row.Cells {
+			ref := workbook.Ref{Row: rowIdx, Col: nextCol}
+			if c.R != "" {
+				parsed, err := workbook.ParseRef(c.R)
+				if err != nil {
+					return nil, err
+				}
+				ref = parsed
+			}
+			nextCol = ref.Col + 1
+
+			cell, err := r.cell(c)
+			if err != nil {
+				return nil, fmt.Errorf("cellule %s : %v", ref, err)
+			}
+			sheet.Cells[ref] = cell
+		}
+	}
+
+	for _, m := range ws.MergeCells {
+		rng, err := workbook.ParseRange(m.Ref)
+		if err != nil {
+			return nil, err
+		}
+		sheet.Merges = append(sheet.Merges, rng)
+	}
+
+	applyPageSetup(&sheet.PageSetup, &ws)
+	return sheet, nil
+}
+
+func (r *reader) cell(c xlsxCell) (*workbook.Cell, error) {
+	cell := &workbook.Cell{}
+	if c.S < len(r.styles) {
+		cell.Style = r.styles[c.S]
+	}
+
+	switch c.T {
+	case "s":
+		idx, err := strconv.Atoi(strings.TrimSpace(c.V))
+		if err != nil || idx < 0 || idx >= len(r.strings) {
+			return nil, fmt.Errorf("index de chaîne partagée invalide : %q", c.V)
+		}
+		cell.Type = workbook.CellString
+		cell.Str = r.strings[idx]
+	case "inlineStr":
+		cell.Type = workbook.CellString
+		if c.Inline != nil {
+			cell.Str = c.Inline.String()
+		}
+	case "str":
+		cell.Type = workbook.CellString
+		cell.Str = c.V
+	case "b":
+		cell.Type = workbook.CellBool
+		cell.Bool = strings.TrimSpace(c.V) == "1"
+	case "e":
+		cell.Type = workbook.CellError
+		cell.Str = c.V
+	case "d":
+		// Date ISO 8601 : conservée telle quelle
+		cell.Type = workbook.CellString
+		cell.Str = c.V
+	default:
+		if strings.TrimSpace(c.V) == "" {
+			return cell, nil
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(c.V), 64)
+		if err != nil {
+			return nil, fmt.Errorf("valeur numérique invalide : %q", c.V)
+		}
+		cell.Type = workbook.CellNumber
+		cell.Num = v
+	}
+	return cell, nil
+}
+
+func applyPageSetup(ps *workbook.PageSetup, ws *xlsxWorksheet) {
+	if m := ws.Margins; m != nil {
+		ps.Margins = workbook.Margins{Left: m.Left * 72, Right: m.Right * 72, Top: m.Top * 72, Bottom: m.Bottom * 72}
+	}
+	if p := ws.PageSetup; p != nil {
+		if p.Orientation == "landscape" {
+			ps.Orientation = workbook.Landscape
+		}
+		if p.PaperSize > 0 {
+			ps.PaperSize = p.PaperSize
+		}
+		if p.Scale > 0 {
+			ps.Scale = p.Scale
+		}
+		if ws.SheetPr != nil && ws.SheetPr.PageSetUpPr != nil && ws.SheetPr.PageSetUpPr.FitToPage {
+			ps.FitToPage = true
+			// Les attributs absents valent 1 selon la norme
+			ps.FitToWidth = 1
+			ps.FitToHeight = 1
+			if p.FitToWidth != nil {
+				ps.FitToWidth = *p.FitToWidth
+			}
+			if p.FitToHeight != nil {
+				ps.FitToHeight = *p.FitToHeight
+			}
+		}
+	}
+}
+
+func parseHAlign(s string) workbook.HAlign {
+	switch s {
+	case "left":
+		return workbook.AlignLeft
+	case "center":
+		return workbook.AlignCenter
+	case "right":
+		return workbook.AlignRight
+	case "fill":
+		return workbook.AlignFill
+	case "justify", "distributed":
+		return workbook.AlignJustify
+	case "centerContinuous":
+		return workbook.AlignCenterContinuous
+	}
+	return workbook.AlignGeneral
+}
+
+func parseVAlign(s string) workbook.VAlign {
+	switch s {
+	case "top":
+		return workbook.AlignTop
+	case "center", "justify", "distributed":
+		return workbook.AlignMiddle
+	}
+	return workbook.AlignBottom
+}
+
+func parseBorderStyle(s string) workbook.BorderStyle {
+	switch s {
+	case "hair":
+		return workbook.BorderHair
+	case "thin":
+		return workbook.BorderThin
+	case "medium":
+		return workbook.BorderMedium
+	case "thick":
+		return workbook.BorderThick
+	case "dashed", "mediumDashed", "dashDot", "mediumDashDot", "dashDotDot", "mediumDashDotDot", "slantDashDot":
+		return workbook.BorderDashed
+	case "dotted":
+		return workbook.BorderDotted
+	case "double":
+		return workbook.BorderDouble
+	}
+	return workbook.BorderNone
+}
@@ -0,0 +1,234 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"fredon_to_pdf/workbook"
+	"testing"
+)
+
+const (
+	testRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Feuil1" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+<si><t>Facture</t></si>
+<si><r><rPr><b/></rPr><t>Total </t></r><r><t>TTC</t></r></si>
+<si><t xml:space="preserve"> Espacé </t></si>
+</sst>`
+
+	testStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="#,##0.00\ &quot;€&quot;"/></numFmts>
+<fonts count="2">
+<font><sz val="11"/><name val="Calibri"/></font>
+<font><b/><i val="0"/><u/><sz val="14"/><color rgb="FFFF0000"/><name val="Arial"/></font>
+</fonts>
+<fills count="3">
+<fill><patternFill patternType="none"/></fill>
+<fill><patternFill patternType="gray125"/></fill>
+<fill><patternFill patternType="solid"><fgColor rgb="FF00FF00"/></patternFill></fill>
+</fills>
+<borders count="2">
+<border><left/><right/><top/><bottom/></border>
+<border><left style="thin"/><right style="medium"><color rgb="FF0000FF"/></right><top style="dashDot"/><bottom style="double"/></border>
+</borders>
+<cellXfs count="4">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0"/>
+<xf numFmtId="164" fontId="1" fillId="2" borderId="1"><alignment horizontal="center" vertical="top" wrapText="1"/></xf>
+<xf numFmtId="14" fontId="0" fillId="0" borderId="0"/>
+<xf numFmtId="0" fontId="9" fillId="9" borderId="9"/>
+</cellXfs>
+</styleSheet>`
+
+	testSheet = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" s="1"><v>1234.5</v></c></row>
+<row r="2"><c r="A2" t="s"><v>1</v></c><c t="s"><v>2</v></c><c s="2"><v>45000</v></c></row>
+<row r="4"><c r="B4" t="inlineStr"><is><t>En ligne</t></is></c><c r="C4" s="3"/></row>
+</sheetData>
+<mergeCells count="2"><mergeCell ref="A1:B1"/><mergeCell ref="A5:C7"/></mergeCells>
+</worksheet>`
+)
+
+// testArchive construit en mémoire une archive OOXML à partir des parties du
+// classeur de test, remplacées ou complétées par parts
+func testArchive(t *testing.T, parts map[string]string) *zip.Reader {
+	t.Helper()
+	all := map[string]string{
+		"_rels/.rels":                testRels,
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/styles.xml":              testStyles,
+		"xl/worksheets/sheet1.xml":   testSheet,
+	}
+	for name, data := range parts {
+		all[name] = data
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range all {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+// readTestSheet lit l'unique feuille du classeur de test
+func readTestSheet(t *testing.T) *workbook.Sheet {
+	t.Helper()
+	wb, err := Read(testArchive(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wb.Sheets) != 1 {
+		t.Fatalf("%d feuilles lues, attendu 1", len(wb.Sheets))
+	}
+	return wb.Sheets[0]
+}
+
+func TestSharedStrings(t *testing.T) {
+	sheet := readTestSheet(t)
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"A1", "Facture"},
+		{"A2", "Total TTC"}, // texte enrichi : les segments sont concaténés
+		{"B2", " Espacé "},  // référence implicite, à la suite de A2
+		{"B4", "En ligne"},  // chaîne en ligne
+	}
+	for _, tt := range tests {
+		ref, err := workbook.ParseRef(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cell := sheet.Cells[ref]
+		if cell == nil || cell.Type != workbook.CellString || cell.Str != tt.want {
+			t.Errorf("%s = %+v, attendu %q", tt.ref, cell, tt.want)
+		}
+	}
+}
+
+func TestSharedStringsInvalidIndex(t *testing.T) {
+	zr := testArchive(t, map[string]string{
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>3</v></c></row>
+</sheetData></worksheet>`,
+	})
+	if _, err := Read(zr); err == nil {
+		t.Fatal("index de chaîne partagée hors limites accepté")
+	}
+}
+
+func TestStyles(t *testing.T) {
+	sheet := readTestSheet(t)
+
+	// C1 : format personnalisé, police, remplissage, bordures et alignement
+	c1 := sheet.Cell(0, 2)
+	if c1 == nil || c1.Type != workbook.CellNumber || c1.Num != 1234.5 {
+		t.Fatalf("C1 = %+v", c1)
+	}
+	style := c1.Style
+	if style == nil {
+		t.Fatal("C1 sans style")
+	}
+	if style.NumFmt != `#,##0.00\ "€"` {
+		t.Errorf("format = %q", style.NumFmt)
+	}
+	wantFont := workbook.Font{Name: "Arial", Size: 14, Bold: true, Underline: true, Color: workbook.Color{R: 255}}
+	if style.Font != wantFont {
+		t.Errorf("police = %+v, attendu %+v", style.Font, wantFont)
+	}
+	if style.Fill == nil || *style.Fill != (workbook.Color{G: 255}) {
+		t.Errorf("remplissage = %v", style.Fill)
+	}
+	wantBorder := workbook.Border{
+		Left:   workbook.Edge{Style: workbook.BorderThin, Color: workbook.Black},
+		Right:  workbook.Edge{Style: workbook.BorderMedium, Color: workbook.Color{B: 255}},
+		Top:    workbook.Edge{Style: workbook.BorderDashed, Color: workbook.Black},
+		Bottom: workbook.Edge{Style: workbook.BorderDouble, Color: workbook.Black},
+	}
+	if style.Border != wantBorder {
+		t.Errorf("bordures = %+v, attendu %+v", style.Border, wantBorder)
+	}
+	if style.HAlign != workbook.AlignCenter || style.VAlign != workbook.AlignTop || !style.Wrap {
+		t.Errorf("alignement = %v %v %v", style.HAlign, style.VAlign, style.Wrap)
+	}
+
+	// C2 : format de date prédéfini
+	c2 := sheet.Cell(1, 2)
+	if code, _ := workbook.BuiltinFormat(14); c2 == nil || c2.Style.NumFmt != code {
+		t.Errorf("C2 = %+v, format attendu %q", c2, code)
+	}
+
+	// C4 : index de police, remplissage et bordure hors limites, cellule vide
+	c4 := sheet.Cell(3, 2)
+	if c4 == nil || c4.Type != workbook.CellEmpty || c4.Style == nil {
+		t.Fatalf("C4 = %+v", c4)
+	}
+	if c4.Style.Font != workbook.DefaultFont || c4.Style.Fill != nil || c4.Style.NumFmt != "General" {
+		t.Errorf("style de C4 = %+v", c4.Style)
+	}
+}
+
+func TestMergedCells(t *testing.T) {
+	sheet := readTestSheet(t)
+
+	want := []workbook.Range{
+		{First: workbook.Ref{Row: 0, Col: 0}, Last: workbook.Ref{Row: 0, Col: 1}},
+		{First: workbook.Ref{Row: 4, Col: 0}, Last: workbook.Ref{Row: 6, Col: 2}},
+	}
+	if len(sheet.Merges) != len(want) {
+		t.Fatalf("plages fusionnées = %v, attendu %v", sheet.Merges, want)
+	}
+	for i := range want {
+		if sheet.Merges[i] != want[i] {
+			t.Errorf("plage %d = %v, attendu %v", i, sheet.Merges[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		ref    workbook.Ref
+		merged bool
+	}{
+		{workbook.Ref{Row: 0, Col: 1}, true},
+		{workbook.Ref{Row: 0, Col: 2}, false},
+		{workbook.Ref{Row: 5, Col: 1}, true},
+		{workbook.Ref{Row: 7, Col: 0}, false},
+	}
+	for _, tt := range tests {
+		if _, ok := sheet.MergeAt(tt.ref); ok != tt.merged {
+			t.Errorf("MergeAt(%v) = %v, attendu %v", tt.ref, ok, tt.merged)
+		}
+	}
+}